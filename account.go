@@ -3,13 +3,20 @@ package account
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
+	"time"
 )
 
 // User represents a customer in the system.
 type User struct {
 	ID       string
 	Username string
+	// Status is a stage of the account lifecycle, e.g., StatusActive.
+	Status      string
+	Email       string
+	DisplayName string
+	// EmailVerified indicates whether the user proved the ownership of the email.
+	EmailVerified bool
 }
 
 // Group represents a group of customers.
@@ -24,30 +31,252 @@ type UserService interface {
 	FindUserByID(ctx context.Context, id string) (*User, error)
 	// CreateUser creates a new user.
 	CreateUser(ctx context.Context, user *User) error
+	// SuspendUser temporarily bans an active user.
+	SuspendUser(ctx context.Context, id string) error
+	// ReactivateUser lifts a suspension or a lock of a user.
+	ReactivateUser(ctx context.Context, id string) error
+	// LockUser locks a user account after abuse.
+	LockUser(ctx context.Context, id string) error
+	// DeleteUser deletes a user account for good.
+	DeleteUser(ctx context.Context, id string) error
+	// VerifyEmail confirms the email of a user by a token sent to that email.
+	VerifyEmail(ctx context.Context, token string) (*User, error)
+	// ChangeUsername renames a user keeping the former username in the history.
+	ChangeUsername(ctx context.Context, id, username string) error
+	// FindUserByUsername returns a user by current or former username.
+	// The returned user's username differs from the requested one if it was a former username.
+	FindUserByUsername(ctx context.Context, username string) (*User, error)
+	// ListUsers returns a page of users ordered by username.
+	ListUsers(ctx context.Context, filter UserFilter) ([]*User, error)
 }
 
-// Storage allows repositories to execute SQL transactions.
+// UserFilter narrows down a listing of users.
+type UserFilter struct {
+	// After is an exclusive lower bound of the usernames, i.e., the last username of the previous page.
+	After string
+	// Limit is max number of users to return.
+	Limit int
+}
+
+// GroupService represents a service for managing customer groups.
+type GroupService interface {
+	// FindGroupByID returns a group by ID.
+	FindGroupByID(ctx context.Context, id string) (*Group, error)
+	// CreateGroup creates a new group.
+	CreateGroup(ctx context.Context, group *Group) error
+	// RenameGroup changes the name of a group.
+	RenameGroup(ctx context.Context, id, name string) error
+	// ListGroups returns a page of groups ordered by name.
+	ListGroups(ctx context.Context, filter GroupFilter) ([]*Group, error)
+	// DeleteGroup deletes a group.
+	DeleteGroup(ctx context.Context, id string) error
+}
+
+// GroupFilter narrows down a listing of groups.
+type GroupFilter struct {
+	// After is an exclusive lower bound of the group names, i.e., the last name of the previous page.
+	After string
+	// Limit is max number of groups to return.
+	Limit int
+}
+
+// Storage allows repositories to execute transactions (units of work).
 // For example, a service might need to call CreateUser and CreateGroup within the same Postgres transaction.
+//
+// The transaction is carried by the context passed to the atomic function.
+// Repository methods called with that context are executed within the transaction,
+// and the changes are committed when the function returns no error, otherwise they are rolled back.
+// A nested Transact call with the transaction context acts as a savepoint:
+// its failure rolls back only the changes made within the nested call.
 type Storage interface {
-	Transact(ctx context.Context, atomic func(*sql.Tx) error) error
+	Transact(ctx context.Context, atomic func(ctx context.Context) error) error
 }
 
 // UserRepository represents a storage for keeping user records.
+// Every method is scoped by the tenant from the context (see TenantFromContext):
+// users of other tenants are not found and usernames are unique within a tenant.
+// Methods return EInvalidTenant error if the context has no tenant.
 type UserRepository interface {
 	Storage
-	// FindUserByID returns a user by ID.
-	FindUserByID(ctx context.Context, dbtx *sql.Tx, id string) (*User, error)
+	// FindUserByID returns a user by ID or ENotFound error.
+	FindUserByID(ctx context.Context, id string) (*User, error)
 	// UsernameInUse looks up a user by username.
-	UsernameInUse(ctx context.Context, username string) bool
+	UsernameInUse(ctx context.Context, username string) (bool, error)
+	// EmailInUse looks up a user by email ignoring its case.
+	EmailInUse(ctx context.Context, email string) (bool, error)
 	// CreateUser creates a new user.
+	// It returns EConflict error with the field set to "username" or "email" if either is already in use.
 	CreateUser(ctx context.Context, user *User) error
 	// UpdateUser updates a user.
-	UpdateUser(ctx context.Context, dbtx *sql.Tx, user *User) error
+	// It returns ENotFound error if the user does not exist or EConflict error similar to CreateUser.
+	UpdateUser(ctx context.Context, user *User) error
+	// FindUserByUsername returns a user by current username or ENotFound error.
+	FindUserByUsername(ctx context.Context, username string) (*User, error)
+	// CreateUsernameChange adds a username change to the history.
+	CreateUsernameChange(ctx context.Context, c *UsernameChange) error
+	// FindUsernameChange returns the latest change where the username was released or ENotFound error.
+	FindUsernameChange(ctx context.Context, username string) (*UsernameChange, error)
+	// ListUsers returns users ordered by username which come after filter.After, at most filter.Limit of them.
+	ListUsers(ctx context.Context, filter UserFilter) ([]*User, error)
+}
+
+// UserImportService represents a service for onboarding users in bulk.
+type UserImportService interface {
+	// ImportUsers creates users atomically: either all of them or none.
+	ImportUsers(ctx context.Context, users []*User) error
+}
+
+// UserImportRepository represents a storage of users which can create them in bulk.
+type UserImportRepository interface {
+	UserRepository
+	// ImportUsers creates users atomically: either all of them or none.
+	// It returns *ImportError with EConflict errors of the users whose username or email is already in use,
+	// including the users repeated within the import, and EUsernameReserved errors of the users
+	// whose username was released after reservedSince.
+	ImportUsers(ctx context.Context, users []*User, reservedSince time.Time) error
+}
+
+// ImportError lists the users rejected by a bulk import.
+type ImportError struct {
+	Rows []ImportRowError
+}
+
+func (e *ImportError) Error() string {
+	if len(e.Rows) == 0 {
+		return "no users rejected"
+	}
+	return fmt.Sprintf("%d users rejected, first at row %d: %v", len(e.Rows), e.Rows[0].Row, e.Rows[0].Err)
+}
+
+// ImportRowError is an error of a user rejected by a bulk import.
+type ImportRowError struct {
+	// Row is a position of the user in the import starting from 1.
+	Row int
+	Err error
+}
+
+// UsernameChange is a record of a user renaming, i.e., releasing a former username.
+type UsernameChange struct {
+	UserID string
+	// Username is the former username.
+	Username  string
+	ChangedAt time.Time
+}
+
+// EmailVerification is a single-use token to verify an email of a user.
+// Only a hash of the token is stored, the token itself is sent to the user.
+type EmailVerification struct {
+	TokenHash string
+	UserID    string
+	Email     string
+	ExpiresAt time.Time
+	// UsedAt is zero until the token is used.
+	UsedAt time.Time
+}
+
+// EmailVerificationRepository represents a storage for keeping email verification tokens.
+// Similar to UserRepository, every method is scoped by the tenant from the context.
+type EmailVerificationRepository interface {
+	Storage
+	// CreateEmailVerification creates a new verification token.
+	CreateEmailVerification(ctx context.Context, v *EmailVerification) error
+	// FindEmailVerification returns a verification by token hash.
+	FindEmailVerification(ctx context.Context, tokenHash string) (*EmailVerification, error)
+	// UpdateEmailVerification updates a verification, e.g., marks it used.
+	UpdateEmailVerification(ctx context.Context, v *EmailVerification) error
+}
+
+// Mail is an email message sent to a user.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// MailSender delivers emails to users.
+type MailSender interface {
+	SendMail(ctx context.Context, m *Mail) error
 }
 
 // GroupRepository represents a storage for keeping customer group records.
+// Similar to UserRepository, every method is scoped by the tenant from the context.
 type GroupRepository interface {
 	Storage
+	// FindGroupByID returns a group by ID or ENotFound error.
+	FindGroupByID(ctx context.Context, id string) (*Group, error)
 	// CreateGroup creates a new group.
-	CreateGroup(ctx context.Context, dbtx *sql.Tx, group *Group) error
+	// It returns EConflict error with the field set to "name" if the group name is already in use.
+	CreateGroup(ctx context.Context, group *Group) error
+	// UpdateGroup updates a group.
+	// It returns ENotFound error if the group does not exist or EConflict error similar to CreateGroup.
+	UpdateGroup(ctx context.Context, group *Group) error
+	// DeleteGroup deletes a group by ID or returns ENotFound error.
+	DeleteGroup(ctx context.Context, id string) error
+	// ListGroups returns groups ordered by name which come after filter.After, at most filter.Limit of them.
+	ListGroups(ctx context.Context, filter GroupFilter) ([]*Group, error)
+}
+
+// Audit outcomes describe how an account mutation attempt ended.
+const (
+	// The change was applied.
+	AuditSuccess = "success"
+	// The change was rejected with a domain error, e.g., invalid username.
+	AuditRejected = "rejected"
+	// The change failed due to an internal error.
+	AuditFailed = "failed"
+)
+
+// AuditEntry records who changed a user account, what and when.
+type AuditEntry struct {
+	ID        string
+	CreatedAt time.Time
+	// Actor is an ID of the authenticated principal who performed the action.
+	Actor string
+	// ClaimedActor is an ID of the principal API client claimed to act on behalf of.
+	// It's not verified, so it must not be trusted.
+	ClaimedActor string
+	// Action is a name of the UserService method, e.g., CreateUser.
+	Action string
+	// TargetID is an ID of the user account being changed.
+	TargetID string
+	// Changes is a field-level diff between the account before and after the action.
+	Changes   []Change
+	RequestID string
+	Outcome   string
+	// ErrorCode is a domain error code of a rejected or failed attempt.
+	ErrorCode string
+}
+
+// Change describes how a single field of an entity was changed.
+type Change struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// AuditFilter narrows down a search of audit entries.
+type AuditFilter struct {
+	// UserID is an ID of the user account the entries belong to.
+	UserID string
+	// Since is an inclusive lower bound of the entry creation time.
+	Since time.Time
+	// Until is an exclusive upper bound of the entry creation time.
+	Until time.Time
+	// Limit is max number of entries to return.
+	Limit int
+}
+
+// AuditService represents a service for querying the audit trail of user accounts.
+type AuditService interface {
+	// FindAuditEntries returns audit entries ordered by creation time.
+	FindAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// AuditRepository represents an append-only storage of audit entries.
+// Similar to UserRepository, every method is scoped by the tenant from the context.
+type AuditRepository interface {
+	// AppendAuditEntry adds an entry to the audit trail.
+	AppendAuditEntry(ctx context.Context, entry *AuditEntry) error
+	// FindAuditEntries returns audit entries ordered by creation time.
+	FindAuditEntries(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
 }
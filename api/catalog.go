@@ -0,0 +1,263 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// ErrorInfo describes a domain error code for API consumers.
+type ErrorInfo struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	// Message is an example of a human-readable message sent along with the code.
+	Message    string `json:"message"`
+	HTTPStatus int    `json:"http_status"`
+	// GRPCCode is the status code of a gRPC response with the error.
+	// The gRPC server sends domain errors in the error field of a response with OK status,
+	// except for the errors which are rejected before the service is called, e.g., Unauthenticated.
+	// It's empty if the error is returned only by HTTP API.
+	GRPCCode string `json:"grpc_code"`
+	// Retryable indicates whether the same request can succeed later.
+	Retryable bool `json:"retryable"`
+	// Operations are the API methods which may return the error, e.g., CreateUser.
+	// The list is advisory since it's maintained by hand,
+	// i.e., only the names of the operations are checked.
+	Operations []string `json:"operations"`
+}
+
+// Operations which the API exposes.
+var (
+	userOperations = []string{
+		"FindUserByID",
+		"CreateUser",
+		"SuspendUser",
+		"ReactivateUser",
+		"LockUser",
+		"DeleteUser",
+		"VerifyEmail",
+		"ChangeUsername",
+		"FindUserByUsername",
+		"ListUsers",
+	}
+	groupOperations = []string{
+		"FindGroupByID",
+		"CreateGroup",
+		"RenameGroup",
+		"ListGroups",
+		"DeleteGroup",
+	}
+	allOperations = append(append(userOperations, groupOperations...), "ImportUsers", "FindAuditEntries")
+	// writeOperations change users or groups within a db transaction.
+	writeOperations = []string{
+		"CreateUser",
+		"SuspendUser",
+		"ReactivateUser",
+		"LockUser",
+		"DeleteUser",
+		"VerifyEmail",
+		"ChangeUsername",
+		"ImportUsers",
+		"CreateGroup",
+		"RenameGroup",
+		"DeleteGroup",
+	}
+	statusOperations = []string{"SuspendUser", "ReactivateUser", "LockUser", "DeleteUser"}
+)
+
+// errorCatalog describes every domain error code.
+// A code without a description is reported as 400 Bad Request, see httpStatus.
+var errorCatalog = map[string]ErrorInfo{
+	account.EConflict: {
+		Description: "Action cannot be performed, e.g., the username, email or group name is already in use.",
+		Message:     "Username is already in use. Please choose a different username.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"CreateUser", "ChangeUsername", "ImportUsers", "CreateGroup", "RenameGroup"},
+	},
+	account.EInternal: {
+		Description: "Internal error. Its details are logged, but never shown to API consumers.",
+		Message:     "An internal error has occurred.",
+		HTTPStatus:  http.StatusInternalServerError,
+		GRPCCode:    codes.OK.String(),
+		Operations:  allOperations,
+	},
+	account.ENotFound: {
+		Description: "Entity does not exist, or it belongs to a tenant other than the authenticated one.",
+		Message:     "User not found.",
+		HTTPStatus:  http.StatusNotFound,
+		GRPCCode:    codes.OK.String(),
+		Operations:  allOperations,
+	},
+	account.ERateLimit: {
+		Description: "Too many API requests.",
+		Message:     "API rate limit exceeded.",
+		HTTPStatus:  http.StatusTooManyRequests,
+		GRPCCode:    codes.OK.String(),
+		Retryable:   true,
+		Operations:  allOperations,
+	},
+	account.EInvalidUserID: {
+		Description: "User ID is not a UUID.",
+		Message:     "Invalid user ID.",
+		HTTPStatus:  http.StatusNotFound,
+		GRPCCode:    codes.OK.String(),
+		Operations:  append([]string{"FindUserByID", "ChangeUsername", "FindAuditEntries"}, statusOperations...),
+	},
+	account.EInvalidUsername: {
+		Description: "Username has characters other than letters and digits, or it is blocked by the username policy.",
+		Message:     "Username is invalid.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"CreateUser", "ChangeUsername", "FindUserByUsername", "ImportUsers"},
+	},
+	account.EInvalidGroupID: {
+		Description: "Group ID is not a UUID.",
+		Message:     "Invalid group ID.",
+		HTTPStatus:  http.StatusNotFound,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"FindGroupByID", "RenameGroup", "DeleteGroup"},
+	},
+	account.EInvalidGroupName: {
+		Description: "Group name is empty, too long or has control characters or surrounding spaces.",
+		Message:     fmt.Sprintf("Group name must be 1 to %d characters without control characters and surrounding spaces.", maxGroupNameLength),
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"CreateGroup", "RenameGroup"},
+	},
+	account.EInvalidTimeRange: {
+		Description: "Time range is malformed or its start is after its end.",
+		Message:     "Time range start must be before its end.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"FindAuditEntries"},
+	},
+	account.EInvalidLimit: {
+		Description: "Limit of returned entities is not a number.",
+		Message:     "Limit must be an integer.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"FindAuditEntries"},
+	},
+	account.EInvalidStateTransition: {
+		Description: "User status does not allow the action, e.g., a deleted user can't be suspended.",
+		Message:     fmt.Sprintf("Cannot %s a user account that is %s.", account.EventSuspend, account.StatusDeleted),
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  statusOperations,
+	},
+	account.EInvalidEmail: {
+		Description: "Email is malformed.",
+		Message:     "Email is invalid.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"CreateUser", "ImportUsers"},
+	},
+	account.EInvalidDisplayName: {
+		Description: "Display name is too long or has control characters or surrounding spaces.",
+		Message:     fmt.Sprintf("Display name must be at most %d characters without control characters and surrounding spaces.", maxDisplayNameLength),
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"CreateUser", "ImportUsers"},
+	},
+	account.EInvalidVerificationToken: {
+		Description: "Verification token is unknown, expired, was already used or issued for a different email.",
+		Message:     "Verification token is invalid.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"VerifyEmail"},
+	},
+	account.EInvalidTenant: {
+		Description: "Tenant ID is missing or is not a UUID.",
+		Message:     "Invalid tenant ID.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  allOperations,
+	},
+	account.EUsernameReserved: {
+		Description: "Username was recently released by another user and can't be claimed yet.",
+		Message:     "Username was recently released and is reserved. Please choose a different username.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"CreateUser", "ChangeUsername", "ImportUsers"},
+	},
+	account.EConcurrentUpdate: {
+		Description: "Concurrent requests changed the same data.",
+		Message:     "The data was changed by a concurrent request. Please try again.",
+		HTTPStatus:  http.StatusConflict,
+		GRPCCode:    codes.OK.String(),
+		Retryable:   true,
+		Operations:  writeOperations,
+	},
+	account.EImportRejected: {
+		Description: "Bulk import was rejected because some of the users are invalid, the rejected rows are listed in the response.",
+		Message:     "2 users can't be imported.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"ImportUsers"},
+	},
+	account.EUnauthenticated: {
+		Description: "API key is missing or unknown.",
+		Message:     "API key is missing or invalid.",
+		HTTPStatus:  http.StatusUnauthorized,
+		GRPCCode:    codes.Unauthenticated.String(),
+		Operations:  allOperations,
+	},
+	account.EFeatureDisabled: {
+		Description: "Feature was disabled by the server configuration.",
+		Message:     "Bulk import of users is disabled.",
+		HTTPStatus:  http.StatusForbidden,
+		GRPCCode:    codes.OK.String(),
+		Operations:  []string{"ImportUsers", "FindAuditEntries", "VerifyEmail"},
+	},
+	account.ENotAcceptable: {
+		Description: "HTTP API can't respond with any of the media types listed in Accept header.",
+		Message:     "Response can be sent only as application/json or application/x-protobuf.",
+		HTTPStatus:  http.StatusNotAcceptable,
+		Operations:  allOperations,
+	},
+	account.EUnsupportedMediaType: {
+		Description: "HTTP API can't read the request body of the media type in Content-Type header.",
+		Message:     "Media type text/plain is not supported.",
+		HTTPStatus:  http.StatusUnsupportedMediaType,
+		Operations:  allOperations,
+	},
+	account.EMalformedRequest: {
+		Description: "Request can't be decoded, e.g., its body has unknown fields, invalid JSON or UTF-8.",
+		Message:     "Request body is not a valid CreateUserRequest message.",
+		HTTPStatus:  http.StatusBadRequest,
+		GRPCCode:    codes.InvalidArgument.String(),
+		Operations:  allOperations,
+	},
+	account.EPayloadTooLarge: {
+		Description: "Request body or gRPC message exceeds the size limit.",
+		Message:     "Request body must not exceed 1048576 bytes.",
+		HTTPStatus:  http.StatusRequestEntityTooLarge,
+		GRPCCode:    codes.ResourceExhausted.String(),
+		Operations:  []string{"CreateUser", "VerifyEmail", "ChangeUsername", "ImportUsers", "CreateGroup", "RenameGroup"},
+	},
+}
+
+// ErrorCatalog returns the descriptions of the domain error codes sorted by code.
+func ErrorCatalog() []ErrorInfo {
+	catalog := make([]ErrorInfo, 0, len(errorCatalog))
+	for code, info := range errorCatalog {
+		info.Code = code
+		info.Operations = append([]string(nil), info.Operations...)
+		catalog = append(catalog, info)
+	}
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Code < catalog[j].Code })
+	return catalog
+}
+
+// httpStatus returns HTTP status code that corresponds to the domain error code.
+func httpStatus(code string) int {
+	if info, ok := errorCatalog[code]; ok {
+		return info.HTTPStatus
+	}
+	return http.StatusBadRequest
+}
@@ -0,0 +1,187 @@
+package api_test
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+	"github.com/marselester/ddd-err/mock"
+	pb "github.com/marselester/ddd-err/rpc/account"
+)
+
+// declaredErrorCodes returns the error codes declared as E-prefixed constants in error.go.
+func declaredErrorCodes(t *testing.T) []string {
+	t.Helper()
+
+	f, err := parser.ParseFile(token.NewFileSet(), "../error.go", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var codes []string
+	for _, decl := range f.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.CONST {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			vs := spec.(*ast.ValueSpec)
+			for i, name := range vs.Names {
+				if !strings.HasPrefix(name.Name, "E") || i >= len(vs.Values) {
+					continue
+				}
+				lit, ok := vs.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				code, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					t.Fatal(err)
+				}
+				codes = append(codes, code)
+			}
+		}
+	}
+	return codes
+}
+
+// TestErrorCatalog fails when an error code is added without its description in the catalog.
+func TestErrorCatalog(t *testing.T) {
+	catalog := make(map[string]api.ErrorInfo)
+	for _, info := range api.ErrorCatalog() {
+		catalog[info.Code] = info
+	}
+
+	// operations are the methods of the services exposed by the API.
+	operations := make(map[string]bool)
+	for _, svc := range []interface{}{
+		(*account.UserService)(nil),
+		(*account.UserImportService)(nil),
+		(*account.AuditService)(nil),
+		(*account.GroupService)(nil),
+	} {
+		typ := reflect.TypeOf(svc).Elem()
+		for i := 0; i < typ.NumMethod(); i++ {
+			operations[typ.Method(i).Name] = true
+		}
+	}
+
+	grpcCodes := make(map[string]bool)
+	for c := codes.OK; c <= codes.Unauthenticated; c++ {
+		grpcCodes[c.String()] = true
+	}
+
+	declared := declaredErrorCodes(t)
+	if len(declared) == 0 {
+		t.Fatal("no error codes found in error.go")
+	}
+	for _, code := range declared {
+		info, ok := catalog[code]
+		if !ok {
+			t.Errorf("%s: not found in the error catalog", code)
+			continue
+		}
+		if info.Description == "" || info.Message == "" {
+			t.Errorf("%s: description and message are required", code)
+		}
+		if info.HTTPStatus < 400 {
+			t.Errorf("%s: HTTP status %d is not an error", code, info.HTTPStatus)
+		}
+		if info.GRPCCode != "" && !grpcCodes[info.GRPCCode] {
+			t.Errorf("%s: unknown gRPC code %q", code, info.GRPCCode)
+		}
+		if len(info.Operations) == 0 {
+			t.Errorf("%s: no operations return the error", code)
+		}
+		for _, op := range info.Operations {
+			if !operations[op] {
+				t.Errorf("%s: unknown operation %s", code, op)
+			}
+		}
+		delete(catalog, code)
+	}
+	for code := range catalog {
+		t.Errorf("%s: not declared in error.go", code)
+	}
+}
+
+// TestErrorCatalog_grpc fails when gRPC server sends an error with a status code other than the one in the catalog.
+func TestErrorCatalog_grpc(t *testing.T) {
+	catalog := make(map[string]api.ErrorInfo)
+	for _, info := range api.ErrorCatalog() {
+		catalog[info.Code] = info
+	}
+
+	var code string
+	svc := &mock.UserService{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return nil, account.Error{Code: code, Message: catalog[code].Message}
+		},
+	}
+	grpcListener := bufconn.Listen(1024 * 1024)
+	grpcserver := grpc.NewServer(grpc.MaxRecvMsgSize(1024))
+	api.RegisterGRPCServer(grpcserver, &pb.UserService_ServiceDesc, api.NewGRPCUserServer(svc, log.NewNopLogger(), 1000))
+	go func() {
+		if err := grpcserver.Serve(grpcListener); err != nil {
+			t.Errorf("grpc serve failed: %v", err)
+		}
+	}()
+	defer grpcserver.Stop()
+
+	conn, err := grpc.Dial("", grpc.WithInsecure(), grpc.WithContextDialer(
+		func(context.Context, string) (net.Conn, error) {
+			return grpcListener.Dial()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("grpc dial failed: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewUserServiceClient(conn)
+
+	// The domain errors returned by the service are sent in the error field.
+	for _, info := range catalog {
+		if info.GRPCCode != codes.OK.String() {
+			continue
+		}
+		code = info.Code
+		resp, err := client.FindUserByID(context.Background(), &pb.FindUserByIDRequest{Id: "123"})
+		if err != nil {
+			t.Errorf("%s: FindUserByID() got status %q, want OK", code, status.Code(err))
+			continue
+		}
+		if got := resp.GetError().GetCode(); got != code {
+			t.Errorf("%s: FindUserByID() got error code %q", code, got)
+		}
+	}
+
+	// The requests which can't be decoded are rejected with a status.
+	tt := map[string]interface{}{
+		account.EMalformedRequest: []byte{0x0a, 0x02, 0xff, 0xfe},
+		account.EPayloadTooLarge:  &pb.CreateUserRequest{Username: strings.Repeat("a", 2048)},
+	}
+	for code, req := range tt {
+		var opts []grpc.CallOption
+		if _, ok := req.([]byte); ok {
+			opts = append(opts, grpc.ForceCodec(rawCodec{}))
+		}
+		err = conn.Invoke(context.Background(), "/ddd_err.account.UserService/CreateUser", req, &pb.CreateUserResponse{}, opts...)
+		if got, want := status.Code(err).String(), catalog[code].GRPCCode; got != want {
+			t.Errorf("%s: CreateUser() got status %q, want %q", code, got, want)
+		}
+	}
+}
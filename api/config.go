@@ -1,6 +1,8 @@
 package api
 
 import (
+	"time"
+
 	"github.com/go-kit/log"
 
 	account "github.com/marselester/ddd-err"
@@ -9,9 +11,25 @@ import (
 // NewService configures new UserService that manages user accounts.
 // You must provide a repository where users are stored.
 func NewService(db account.UserRepository, options ...ConfigOption) account.UserService {
+	return newService(db, options...)
+}
+
+// NewUserImportService configures new UserImportService that creates users in bulk.
+// You must provide a repository where users are stored.
+// The options are shared with NewService, e.g., WithUsernameReservation.
+func NewUserImportService(db account.UserImportRepository, options ...ConfigOption) account.UserImportService {
+	s := newService(db, options...)
+	s.importer = db
+	return s
+}
+
+func newService(db account.UserRepository, options ...ConfigOption) *service {
 	s := service{
-		logger: log.NewNopLogger(),
-		db:     db,
+		logger:          log.NewNopLogger(),
+		db:              db,
+		verificationTTL: 24 * time.Hour,
+
+		usernameReservation: 30 * 24 * time.Hour,
 	}
 	for _, opt := range options {
 		opt(&s)
@@ -19,6 +37,18 @@ func NewService(db account.UserRepository, options ...ConfigOption) account.User
 	return &s
 }
 
+// NewAuditService configures new AuditService that queries the audit trail of user accounts.
+// You must provide a repository where audit entries are stored.
+func NewAuditService(db account.AuditRepository) account.AuditService {
+	return &auditService{db: db}
+}
+
+// NewGroupService configures new GroupService that manages customer groups.
+// You must provide a repository where groups are stored.
+func NewGroupService(db account.GroupRepository) account.GroupService {
+	return &groupService{db: db}
+}
+
 // ConfigOption configures the UserService.
 type ConfigOption func(*service)
 
@@ -28,3 +58,36 @@ func WithLogger(l log.Logger) ConfigOption {
 		r.logger = l
 	}
 }
+
+// WithEmailVerification enables email verification of users.
+// A single-use token is stored in db and sent to a user by sender
+// whenever the user signs up with an email.
+func WithEmailVerification(db account.EmailVerificationRepository, sender account.MailSender) ConfigOption {
+	return func(r *service) {
+		r.verifications = db
+		r.mailer = sender
+	}
+}
+
+// WithVerificationTTL sets how long an email verification token is valid, 24 hours by default.
+func WithVerificationTTL(ttl time.Duration) ConfigOption {
+	return func(r *service) {
+		r.verificationTTL = ttl
+	}
+}
+
+// WithUsernameReservation sets how long a released username can't be claimed by other users,
+// 30 days by default. Zero duration disables the reservation.
+func WithUsernameReservation(d time.Duration) ConfigOption {
+	return func(r *service) {
+		r.usernameReservation = d
+	}
+}
+
+// WithUsernamePolicy blocks usernames which users can't claim.
+// The policy can be changed while the service is running, see UsernamePolicy.SetBlocked.
+func WithUsernamePolicy(p *UsernamePolicy) ConfigOption {
+	return func(r *service) {
+		r.usernamePolicy = p
+	}
+}
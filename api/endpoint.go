@@ -2,6 +2,8 @@ package api
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/go-kit/kit/endpoint"
 
@@ -10,12 +12,16 @@ import (
 
 // CreateUserReq collects the request parameters for the CreateUser method.
 type CreateUserReq struct {
-	Username string
+	Username    string `json:"username"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
 }
 
 // CreateUserResp collects the response values for the CreateUser method.
+// ID is the ID assigned to the created user.
 type CreateUserResp struct {
-	Err error `json:"error,omitempty"`
+	ID  string `json:"id,omitempty"`
+	Err error  `json:"error,omitempty"`
 }
 
 // Failed implements endpoint.Failer.
@@ -28,22 +34,246 @@ type FindUserByIDReq struct {
 
 // FindUserByIDResp collects the response values for the FindUserByID method.
 type FindUserByIDResp struct {
-	ID       string `json:"id,omitempty"`
-	Username string `json:"username,omitempty"`
-	Err      error  `json:"error,omitempty"`
+	ID            string `json:"id,omitempty"`
+	Username      string `json:"username,omitempty"`
+	Status        string `json:"status,omitempty"`
+	Email         string `json:"email,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	Err           error  `json:"error,omitempty"`
 }
 
 // Failed implements endpoint.Failer.
 func (r FindUserByIDResp) Failed() error { return r.Err }
 
+// ChangeUserStatusReq collects the request parameters for the methods
+// that change user status: SuspendUser, ReactivateUser, LockUser and DeleteUser.
+type ChangeUserStatusReq struct {
+	ID string
+}
+
+// ChangeUserStatusResp collects the response values for the methods that change user status.
+type ChangeUserStatusResp struct {
+	Err error `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ChangeUserStatusResp) Failed() error { return r.Err }
+
+// VerifyEmailReq collects the request parameters for the VerifyEmail method.
+type VerifyEmailReq struct {
+	Token string `json:"token"`
+}
+
+// VerifyEmailResp collects the response values for the VerifyEmail method.
+type VerifyEmailResp struct {
+	UserID string `json:"user_id,omitempty"`
+	Err    error  `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r VerifyEmailResp) Failed() error { return r.Err }
+
+// ChangeUsernameReq collects the request parameters for the ChangeUsername method.
+type ChangeUsernameReq struct {
+	ID       string `json:"-"`
+	Username string `json:"username"`
+}
+
+// ChangeUsernameResp collects the response values for the ChangeUsername method.
+type ChangeUsernameResp struct {
+	Err error `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ChangeUsernameResp) Failed() error { return r.Err }
+
+// FindUserByUsernameReq collects the request parameters for the FindUserByUsername method.
+type FindUserByUsernameReq struct {
+	Username string
+}
+
+// FindUserByUsernameResp collects the response values for the FindUserByUsername method.
+// Moved indicates that the requested username is a former username of the user,
+// so HTTP clients are redirected to the user resource.
+type FindUserByUsernameResp struct {
+	FindUserByIDResp
+	Moved bool `json:"-"`
+}
+
+// ImportUsersReq collects the request parameters for the ImportUsers method.
+type ImportUsersReq struct {
+	Users []CreateUserReq
+}
+
+// ImportUsersResp collects the response values for the ImportUsers method.
+// UserIDs are IDs of the imported users in the order they were requested.
+// Rows lists the rejected users when the import failed with EImportRejected error.
+type ImportUsersResp struct {
+	UserIDs []string       `json:"user_ids"`
+	Rows    []ImportRowErr `json:"-"`
+	Err     error          `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ImportUsersResp) Failed() error { return r.Err }
+
+// ImportRowErr is an error of a user rejected by the import.
+type ImportRowErr struct {
+	// Row is a position of the user in the import starting from 1.
+	Row int
+	Err error
+}
+
+// FindAuditEntriesReq collects the request parameters for the FindAuditEntries method.
+type FindAuditEntriesReq struct {
+	UserID string
+	Since  time.Time
+	Until  time.Time
+	Limit  int
+}
+
+// FindAuditEntriesResp collects the response values for the FindAuditEntries method.
+type FindAuditEntriesResp struct {
+	Entries []AuditEntry `json:"entries"`
+	Err     error        `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r FindAuditEntriesResp) Failed() error { return r.Err }
+
+// AuditEntry is a record of the audit trail shown to API consumers.
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor"`
+	// ClaimedActor is the unverified actor sent by API client in X-Actor-ID header.
+	ClaimedActor string           `json:"claimed_actor,omitempty"`
+	Action       string           `json:"action"`
+	TargetID     string           `json:"target_id"`
+	Changes      []account.Change `json:"changes"`
+	RequestID    string           `json:"request_id"`
+	Outcome      string           `json:"outcome"`
+	ErrorCode    string           `json:"error_code,omitempty"`
+}
+
+// ListUsersReq collects the request parameters for the ListUsers method.
+type ListUsersReq struct {
+	After string
+	Limit int
+}
+
+// ListUsersResp collects the response values for the ListUsers method.
+// The users are omitted when the page is empty, similar to the JSON encoding of ListUsersResponse message.
+type ListUsersResp struct {
+	Users []User `json:"users,omitempty"`
+	Err   error  `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ListUsersResp) Failed() error { return r.Err }
+
+// User is a user account shown to API consumers in a listing.
+type User struct {
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	Status        string `json:"status"`
+	Email         string `json:"email,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+}
+
+// CreateGroupReq collects the request parameters for the CreateGroup method.
+type CreateGroupReq struct {
+	Name string `json:"name"`
+}
+
+// CreateGroupResp collects the response values for the CreateGroup method.
+// ID is the ID assigned to the created group.
+type CreateGroupResp struct {
+	ID  string `json:"id,omitempty"`
+	Err error  `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r CreateGroupResp) Failed() error { return r.Err }
+
+// FindGroupByIDReq collects the request parameters for the FindGroupByID method.
+type FindGroupByIDReq struct {
+	ID string
+}
+
+// FindGroupByIDResp collects the response values for the FindGroupByID method.
+type FindGroupByIDResp struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name,omitempty"`
+	Err  error  `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r FindGroupByIDResp) Failed() error { return r.Err }
+
+// RenameGroupReq collects the request parameters for the RenameGroup method.
+type RenameGroupReq struct {
+	ID   string `json:"-"`
+	Name string `json:"name"`
+}
+
+// RenameGroupResp collects the response values for the RenameGroup method.
+type RenameGroupResp struct {
+	Err error `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r RenameGroupResp) Failed() error { return r.Err }
+
+// ListGroupsReq collects the request parameters for the ListGroups method.
+type ListGroupsReq struct {
+	After string
+	Limit int
+}
+
+// ListGroupsResp collects the response values for the ListGroups method.
+// The groups are omitted when the page is empty, similar to the JSON encoding of ListGroupsResponse message.
+type ListGroupsResp struct {
+	Groups []Group `json:"groups,omitempty"`
+	Err    error   `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r ListGroupsResp) Failed() error { return r.Err }
+
+// Group is a customer group shown to API consumers in a listing.
+type Group struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// DeleteGroupReq collects the request parameters for the DeleteGroup method.
+type DeleteGroupReq struct {
+	ID string
+}
+
+// DeleteGroupResp collects the response values for the DeleteGroup method.
+type DeleteGroupResp struct {
+	Err error `json:"error,omitempty"`
+}
+
+// Failed implements endpoint.Failer.
+func (r DeleteGroupResp) Failed() error { return r.Err }
+
 func makeCreateUserEndpoint(s account.UserService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req := request.(CreateUserReq)
 		u := account.User{
-			Username: req.Username,
+			Username:    req.Username,
+			Email:       req.Email,
+			DisplayName: req.DisplayName,
+		}
+		if err := s.CreateUser(ctx, &u); err != nil {
+			return CreateUserResp{Err: err}, nil
 		}
-		err := s.CreateUser(ctx, &u)
-		return CreateUserResp{Err: err}, nil
+		return CreateUserResp{ID: u.ID}, nil
 	}
 }
 
@@ -54,6 +284,217 @@ func makeFindUserByIDEndpoint(s account.UserService) endpoint.Endpoint {
 		if err != nil {
 			return FindUserByIDResp{Err: err}, nil
 		}
-		return FindUserByIDResp{ID: u.ID, Username: u.Username}, nil
+		return FindUserByIDResp{
+			ID:            u.ID,
+			Username:      u.Username,
+			Status:        u.Status,
+			Email:         u.Email,
+			DisplayName:   u.DisplayName,
+			EmailVerified: u.EmailVerified,
+		}, nil
+	}
+}
+
+func makeVerifyEmailEndpoint(s account.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(VerifyEmailReq)
+		u, err := s.VerifyEmail(ctx, req.Token)
+		if err != nil {
+			return VerifyEmailResp{Err: err}, nil
+		}
+		return VerifyEmailResp{UserID: u.ID}, nil
+	}
+}
+
+func makeChangeUsernameEndpoint(s account.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ChangeUsernameReq)
+		err := s.ChangeUsername(ctx, req.ID, req.Username)
+		return ChangeUsernameResp{Err: err}, nil
+	}
+}
+
+func makeFindUserByUsernameEndpoint(s account.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(FindUserByUsernameReq)
+		u, err := s.FindUserByUsername(ctx, req.Username)
+		if err != nil {
+			return FindUserByUsernameResp{FindUserByIDResp: FindUserByIDResp{Err: err}}, nil
+		}
+		return FindUserByUsernameResp{
+			FindUserByIDResp: FindUserByIDResp{
+				ID:            u.ID,
+				Username:      u.Username,
+				Status:        u.Status,
+				Email:         u.Email,
+				DisplayName:   u.DisplayName,
+				EmailVerified: u.EmailVerified,
+			},
+			Moved: u.Username != req.Username,
+		}, nil
+	}
+}
+
+// makeChangeUserStatusEndpoint makes an endpoint for a service method that changes user status,
+// e.g., UserService.SuspendUser.
+func makeChangeUserStatusEndpoint(change func(ctx context.Context, id string) error) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ChangeUserStatusReq)
+		err := change(ctx, req.ID)
+		return ChangeUserStatusResp{Err: err}, nil
+	}
+}
+
+func makeImportUsersEndpoint(s account.UserImportService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ImportUsersReq)
+		users := make([]*account.User, len(req.Users))
+		for i, u := range req.Users {
+			users[i] = &account.User{
+				Username:    u.Username,
+				Email:       u.Email,
+				DisplayName: u.DisplayName,
+			}
+		}
+
+		if err := s.ImportUsers(ctx, users); err != nil {
+			resp := ImportUsersResp{Err: err}
+			var importErr *account.ImportError
+			if errors.As(err, &importErr) {
+				for _, r := range importErr.Rows {
+					resp.Rows = append(resp.Rows, ImportRowErr{Row: r.Row, Err: r.Err})
+				}
+			}
+			return resp, nil
+		}
+
+		resp := ImportUsersResp{
+			UserIDs: make([]string, len(users)),
+		}
+		for i, u := range users {
+			resp.UserIDs[i] = u.ID
+		}
+		return resp, nil
+	}
+}
+
+func makeFindAuditEntriesEndpoint(s account.AuditService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(FindAuditEntriesReq)
+		ee, err := s.FindAuditEntries(ctx, account.AuditFilter{
+			UserID: req.UserID,
+			Since:  req.Since,
+			Until:  req.Until,
+			Limit:  req.Limit,
+		})
+		if err != nil {
+			return FindAuditEntriesResp{Err: err}, nil
+		}
+
+		resp := FindAuditEntriesResp{
+			Entries: make([]AuditEntry, 0, len(ee)),
+		}
+		for _, e := range ee {
+			resp.Entries = append(resp.Entries, AuditEntry{
+				ID:           e.ID,
+				CreatedAt:    e.CreatedAt,
+				Actor:        e.Actor,
+				ClaimedActor: e.ClaimedActor,
+				Action:       e.Action,
+				TargetID:     e.TargetID,
+				Changes:      e.Changes,
+				RequestID:    e.RequestID,
+				Outcome:      e.Outcome,
+				ErrorCode:    e.ErrorCode,
+			})
+		}
+		return resp, nil
+	}
+}
+
+func makeListUsersEndpoint(s account.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ListUsersReq)
+		uu, err := s.ListUsers(ctx, account.UserFilter{
+			After: req.After,
+			Limit: req.Limit,
+		})
+		if err != nil {
+			return ListUsersResp{Err: err}, nil
+		}
+
+		resp := ListUsersResp{
+			Users: make([]User, 0, len(uu)),
+		}
+		for _, u := range uu {
+			resp.Users = append(resp.Users, User{
+				ID:            u.ID,
+				Username:      u.Username,
+				Status:        u.Status,
+				Email:         u.Email,
+				DisplayName:   u.DisplayName,
+				EmailVerified: u.EmailVerified,
+			})
+		}
+		return resp, nil
+	}
+}
+
+func makeCreateGroupEndpoint(s account.GroupService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(CreateGroupReq)
+		g := account.Group{Name: req.Name}
+		if err := s.CreateGroup(ctx, &g); err != nil {
+			return CreateGroupResp{Err: err}, nil
+		}
+		return CreateGroupResp{ID: g.ID}, nil
+	}
+}
+
+func makeFindGroupByIDEndpoint(s account.GroupService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(FindGroupByIDReq)
+		g, err := s.FindGroupByID(ctx, req.ID)
+		if err != nil {
+			return FindGroupByIDResp{Err: err}, nil
+		}
+		return FindGroupByIDResp{ID: g.ID, Name: g.Name}, nil
+	}
+}
+
+func makeRenameGroupEndpoint(s account.GroupService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(RenameGroupReq)
+		err := s.RenameGroup(ctx, req.ID, req.Name)
+		return RenameGroupResp{Err: err}, nil
+	}
+}
+
+func makeListGroupsEndpoint(s account.GroupService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(ListGroupsReq)
+		gg, err := s.ListGroups(ctx, account.GroupFilter{
+			After: req.After,
+			Limit: req.Limit,
+		})
+		if err != nil {
+			return ListGroupsResp{Err: err}, nil
+		}
+
+		resp := ListGroupsResp{
+			Groups: make([]Group, 0, len(gg)),
+		}
+		for _, g := range gg {
+			resp.Groups = append(resp.Groups, Group{ID: g.ID, Name: g.Name})
+		}
+		return resp, nil
+	}
+}
+
+func makeDeleteGroupEndpoint(s account.GroupService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req := request.(DeleteGroupReq)
+		err := s.DeleteGroup(ctx, req.ID)
+		return DeleteGroupResp{Err: err}, nil
 	}
 }
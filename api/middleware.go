@@ -2,9 +2,15 @@ package api
 
 import (
 	"context"
+	"fmt"
+	"strconv"
 	"time"
 
+	"github.com/go-kit/kit/endpoint"
+	"github.com/go-kit/kit/ratelimit"
 	"github.com/go-kit/log"
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
 
 	account "github.com/marselester/ddd-err"
 )
@@ -52,3 +58,391 @@ func (mw *loggingMiddleware) CreateUser(ctx context.Context, user *account.User)
 	err = mw.next.CreateUser(ctx, user)
 	return
 }
+
+func (mw *loggingMiddleware) SuspendUser(ctx context.Context, id string) (err error) {
+	defer mw.logStatusChange(time.Now(), "SuspendUser", id, &err)
+	err = mw.next.SuspendUser(ctx, id)
+	return
+}
+
+func (mw *loggingMiddleware) ReactivateUser(ctx context.Context, id string) (err error) {
+	defer mw.logStatusChange(time.Now(), "ReactivateUser", id, &err)
+	err = mw.next.ReactivateUser(ctx, id)
+	return
+}
+
+func (mw *loggingMiddleware) LockUser(ctx context.Context, id string) (err error) {
+	defer mw.logStatusChange(time.Now(), "LockUser", id, &err)
+	err = mw.next.LockUser(ctx, id)
+	return
+}
+
+func (mw *loggingMiddleware) DeleteUser(ctx context.Context, id string) (err error) {
+	defer mw.logStatusChange(time.Now(), "DeleteUser", id, &err)
+	err = mw.next.DeleteUser(ctx, id)
+	return
+}
+
+func (mw *loggingMiddleware) VerifyEmail(ctx context.Context, token string) (v *account.User, err error) {
+	// The token is not logged since it grants access to the user account.
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "VerifyEmail",
+			"output", v,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	v, err = mw.next.VerifyEmail(ctx, token)
+	return
+}
+
+func (mw *loggingMiddleware) ChangeUsername(ctx context.Context, id, username string) (err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ChangeUsername",
+			"user_id", id,
+			"username", username,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	err = mw.next.ChangeUsername(ctx, id, username)
+	return
+}
+
+func (mw *loggingMiddleware) FindUserByUsername(ctx context.Context, username string) (v *account.User, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "FindUserByUsername",
+			"username", username,
+			"output", v,
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	v, err = mw.next.FindUserByUsername(ctx, username)
+	return
+}
+
+func (mw *loggingMiddleware) ListUsers(ctx context.Context, f account.UserFilter) (v []*account.User, err error) {
+	defer func(begin time.Time) {
+		mw.logger.Log(
+			"method", "ListUsers",
+			"after", f.After,
+			"limit", f.Limit,
+			"users", len(v),
+			"err", err,
+			"took", time.Since(begin),
+		)
+	}(time.Now())
+
+	v, err = mw.next.ListUsers(ctx, f)
+	return
+}
+
+// logStatusChange logs an attempt to change a user status.
+// The error is passed by pointer because it's known only when the deferred call runs.
+func (mw *loggingMiddleware) logStatusChange(begin time.Time, method, id string, err *error) {
+	mw.logger.Log(
+		"method", method,
+		"user_id", id,
+		"err", *err,
+		"took", time.Since(begin),
+	)
+}
+
+// NewAuditMiddleware makes an audit middleware for UserService that
+// appends every account mutation attempt to the audit trail, including rejected ones.
+// Reads are not audited.
+//
+// The entry of a successful mutation is appended by the service (see NewService) within the db transaction
+// of the mutation, so the audit repository must share transactions with the service's user repository,
+// e.g., User and Audit storages of the same pg.Client.
+// The mutation fails if its entry can't be saved.
+// Failures to record a rejected mutation are logged instead.
+//
+// Only the service made by NewService reports its changes,
+// so NewAuditMiddleware panics if s is another UserService, e.g., wrapped by a middleware,
+// rather than recording just the rejected mutations.
+func NewAuditMiddleware(l log.Logger, db account.AuditRepository, s account.UserService) account.UserService {
+	if _, ok := s.(*service); !ok {
+		panic(fmt.Sprintf("api: audit middleware must wrap the service made by NewService, got %T", s))
+	}
+	return &auditMiddleware{
+		logger: l,
+		db:     db,
+		next:   s,
+	}
+}
+
+type auditMiddleware struct {
+	logger log.Logger
+	db     account.AuditRepository
+	next   account.UserService
+}
+
+func (mw *auditMiddleware) FindUserByID(ctx context.Context, id string) (*account.User, error) {
+	return mw.next.FindUserByID(ctx, id)
+}
+
+func (mw *auditMiddleware) CreateUser(ctx context.Context, user *account.User) error {
+	return mw.audit(ctx, "CreateUser", func(ctx context.Context) (string, *account.User, error) {
+		err := mw.next.CreateUser(ctx, user)
+		return user.ID, user, err
+	})
+}
+
+func (mw *auditMiddleware) SuspendUser(ctx context.Context, id string) error {
+	return mw.transition(ctx, "SuspendUser", id, mw.next.SuspendUser)
+}
+
+func (mw *auditMiddleware) ReactivateUser(ctx context.Context, id string) error {
+	return mw.transition(ctx, "ReactivateUser", id, mw.next.ReactivateUser)
+}
+
+func (mw *auditMiddleware) LockUser(ctx context.Context, id string) error {
+	return mw.transition(ctx, "LockUser", id, mw.next.LockUser)
+}
+
+func (mw *auditMiddleware) DeleteUser(ctx context.Context, id string) error {
+	return mw.transition(ctx, "DeleteUser", id, mw.next.DeleteUser)
+}
+
+func (mw *auditMiddleware) VerifyEmail(ctx context.Context, token string) (*account.User, error) {
+	var u *account.User
+	err := mw.audit(ctx, "VerifyEmail", func(ctx context.Context) (string, *account.User, error) {
+		var err error
+		u, err = mw.next.VerifyEmail(ctx, token)
+		// The user is unknown when the token is invalid.
+		return "", nil, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (mw *auditMiddleware) ChangeUsername(ctx context.Context, id, username string) error {
+	return mw.audit(ctx, "ChangeUsername", func(ctx context.Context) (string, *account.User, error) {
+		err := mw.next.ChangeUsername(ctx, id, username)
+		return id, &account.User{ID: id, Username: username}, err
+	})
+}
+
+func (mw *auditMiddleware) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	return mw.next.FindUserByUsername(ctx, username)
+}
+
+func (mw *auditMiddleware) ListUsers(ctx context.Context, f account.UserFilter) ([]*account.User, error) {
+	return mw.next.ListUsers(ctx, f)
+}
+
+// transition audits a user status change made by the next service.
+func (mw *auditMiddleware) transition(ctx context.Context, action, id string, next func(context.Context, string) error) error {
+	return mw.audit(ctx, action, func(ctx context.Context) (string, *account.User, error) {
+		return id, nil, next(ctx, id)
+	})
+}
+
+// audit records the outcome of the action performed by the next service.
+//
+// The service reports the user it changed to the audit hook from the context (see auditChange)
+// within the db transaction of the change, so the entry is committed along with the change.
+// The action fails if the entry can't be saved.
+//
+// A rejected or failed action is recorded once the transaction is rolled back.
+// The action func returns the target user ID and the attempted state of the user,
+// so the diff of the entry shows what was attempted.
+func (mw *auditMiddleware) audit(ctx context.Context, action string, do func(ctx context.Context) (targetID string, attempted *account.User, err error)) error {
+	ctx = context.WithValue(ctx, auditHookKey, auditHook(func(ctx context.Context, before, after *account.User) error {
+		e := newAuditEntry(ctx, action, after.ID, before, after, nil)
+		if err := mw.db.AppendAuditEntry(ctx, e); err != nil {
+			return fmt.Errorf("audit entry was not saved: %w", err)
+		}
+		return nil
+	}))
+
+	targetID, attempted, err := do(ctx)
+	if err == nil {
+		return nil
+	}
+
+	e := newAuditEntry(ctx, action, targetID, nil, attempted, err)
+	if appendErr := mw.db.AppendAuditEntry(ctx, e); appendErr != nil {
+		mw.logger.Log(
+			"msg", "audit entry was not saved",
+			"action", action,
+			"target_id", targetID,
+			"request_id", e.RequestID,
+			"err", appendErr,
+		)
+	}
+	return err
+}
+
+// NewUserImportAuditMiddleware makes an audit middleware for UserImportService that
+// appends an entry per imported user to the audit trail, so the user's trail starts with its import.
+// The entries are appended within the db transaction of the import (see NewAuditMiddleware),
+// and the import fails if they can't be saved.
+// A rejected or failed import is recorded as a single entry without a target user.
+// It panics if s wasn't made by NewUserImportService.
+func NewUserImportAuditMiddleware(l log.Logger, db account.AuditRepository, s account.UserImportService) account.UserImportService {
+	if _, ok := s.(*service); !ok {
+		panic(fmt.Sprintf("api: audit middleware must wrap the service made by NewUserImportService, got %T", s))
+	}
+	return &userImportAuditMiddleware{
+		// Only the audit method is used, so the user service is not set.
+		auditor: &auditMiddleware{
+			logger: l,
+			db:     db,
+		},
+		next: s,
+	}
+}
+
+type userImportAuditMiddleware struct {
+	auditor *auditMiddleware
+	next    account.UserImportService
+}
+
+func (mw *userImportAuditMiddleware) ImportUsers(ctx context.Context, users []*account.User) error {
+	return mw.auditor.audit(ctx, "ImportUsers", func(ctx context.Context) (string, *account.User, error) {
+		return "", nil, mw.next.ImportUsers(ctx, users)
+	})
+}
+
+// newAuditEntry returns an entry recording the outcome of the action performed on the target user.
+func newAuditEntry(ctx context.Context, action, targetID string, before, after *account.User, actionErr error) *account.AuditEntry {
+	e := account.AuditEntry{
+		ID:           uuid.NewString(),
+		CreatedAt:    time.Now().UTC(),
+		Actor:        account.ActorFromContext(ctx),
+		ClaimedActor: account.ClaimedActorFromContext(ctx),
+		Action:       action,
+		TargetID:     targetID,
+		Changes:      userChanges(before, after),
+		RequestID:    account.RequestIDFromContext(ctx),
+		Outcome:      account.AuditSuccess,
+	}
+	if actionErr != nil {
+		e.Outcome = account.AuditRejected
+		e.ErrorCode = account.ErrorCode(actionErr)
+		if e.ErrorCode == "" {
+			e.Outcome = account.AuditFailed
+			e.ErrorCode = account.EInternal
+		}
+	}
+	return &e
+}
+
+// auditHook records a change of a user made within a db transaction, see auditChange.
+type auditHook func(ctx context.Context, before, after *account.User) error
+
+// auditChange reports a change of a user to the audit hook from the context, if any.
+// It must be called within the db transaction which changed the user,
+// so the audit entry is committed or rolled back together with the change.
+// Every change made by the service must be reported, see NewAuditMiddleware.
+// The before is nil when the user was created.
+func auditChange(ctx context.Context, before, after *account.User) error {
+	h, ok := ctx.Value(auditHookKey).(auditHook)
+	if !ok {
+		return nil
+	}
+	return h(ctx, before, after)
+}
+
+// userChanges returns a field-level diff between two states of a user.
+// A nil user stands for an account that does not exist (yet or anymore).
+func userChanges(before, after *account.User) []account.Change {
+	var b, a account.User
+	if before != nil {
+		b = *before
+	}
+	if after != nil {
+		a = *after
+	}
+
+	var cc []account.Change
+	if b.Username != a.Username {
+		cc = append(cc, account.Change{Field: "username", Before: b.Username, After: a.Username})
+	}
+	if b.Status != a.Status {
+		cc = append(cc, account.Change{Field: "status", Before: b.Status, After: a.Status})
+	}
+	if b.Email != a.Email {
+		cc = append(cc, account.Change{Field: "email", Before: b.Email, After: a.Email})
+	}
+	if b.DisplayName != a.DisplayName {
+		cc = append(cc, account.Change{Field: "display_name", Before: b.DisplayName, After: a.DisplayName})
+	}
+	if b.EmailVerified != a.EmailVerified {
+		cc = append(cc, account.Change{
+			Field:  "email_verified",
+			Before: strconv.FormatBool(b.EmailVerified),
+			After:  strconv.FormatBool(a.EmailVerified),
+		})
+	}
+	return cc
+}
+
+type contextKey int
+
+const (
+	requestedTenantKey contextKey = iota
+	// mediaKey is a key of the media types negotiated with HTTP API client, see negotiate.
+	mediaKey
+	// auditHookKey is a key of the audit hook installed by the audit middleware, see auditChange.
+	auditHookKey
+)
+
+// contextWithRequestedTenant returns a copy of ctx that carries the tenant ID
+// that API client asked for, e.g., in X-Tenant-ID header.
+func contextWithRequestedTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, requestedTenantKey, tenantID)
+}
+
+// tenantMiddleware scopes a request to a tenant.
+// The tenant of the authenticated principal (stored in the context by an authentication layer
+// in front of the API) takes precedence over the tenant requested by API client.
+// A request to resources of a different tenant is rejected as if they did not exist.
+func tenantMiddleware(next endpoint.Endpoint) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		requested, _ := ctx.Value(requestedTenantKey).(string)
+		if requested == "" {
+			return next(ctx, request)
+		}
+
+		if authenticated := account.TenantFromContext(ctx); authenticated != "" {
+			if requested != authenticated {
+				return nil, account.Error{
+					Code:    account.ENotFound,
+					Message: "Tenant not found.",
+				}
+			}
+			return next(ctx, request)
+		}
+
+		if _, err := uuid.Parse(requested); err != nil {
+			return nil, account.Error{
+				Code:    account.EInvalidTenant,
+				Message: "Invalid tenant ID.",
+				Inner:   err,
+			}
+		}
+		return next(account.ContextWithTenant(ctx, requested), request)
+	}
+}
+
+// newLimiter returns a middleware that throttles requests that exceeded qps requests per second.
+// For example, when qps is 100, there might be max 100 requests per seconds to
+// all the API endpoints combined. When l is set, it is used instead, so its limit can be changed later.
+func newLimiter(qps int, l *rate.Limiter) endpoint.Middleware {
+	if l == nil {
+		l = rate.NewLimiter(rate.Limit(qps), qps)
+	}
+	return ratelimit.NewErroringLimiter(l)
+}
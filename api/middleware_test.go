@@ -0,0 +1,335 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+	"github.com/marselester/ddd-err/mock"
+)
+
+func TestAuditMiddleware_CreateUser(t *testing.T) {
+	tt := []struct {
+		name          string
+		inUse         bool
+		err           error
+		wantOutcome   string
+		wantErrorCode string
+	}{
+		{
+			name:        "success",
+			wantOutcome: account.AuditSuccess,
+		},
+		{
+			name:          "rejected",
+			inUse:         true,
+			wantOutcome:   account.AuditRejected,
+			wantErrorCode: account.EConflict,
+		},
+		{
+			name:          "failed",
+			err:           errors.New("db connection failed"),
+			wantOutcome:   account.AuditFailed,
+			wantErrorCode: account.EInternal,
+		},
+	}
+	for _, tc := range tt {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			var got *account.AuditEntry
+			audit := mock.AuditStorage{
+				AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+					got = e
+					return nil
+				},
+			}
+			db := mock.UserStorage{
+				UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+					return tc.inUse, nil
+				},
+				CreateUserFn: func(ctx context.Context, user *account.User) error {
+					return tc.err
+				},
+			}
+			s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&db))
+
+			ctx := account.ContextWithActor(context.Background(), "admin")
+			ctx = account.ContextWithClaimedActor(ctx, "bob")
+			ctx = account.ContextWithRequestID(ctx, "req-1")
+			user := account.User{Username: "bob"}
+			err := s.CreateUser(ctx, &user)
+			if account.ErrorCode(err) != tc.wantErrorCode && !errors.Is(err, tc.err) {
+				t.Fatalf("CreateUser() = %v want %v", err, tc.wantErrorCode)
+			}
+
+			if got == nil {
+				t.Fatal("audit entry was not appended")
+			}
+			want := account.AuditEntry{
+				ID:           got.ID,
+				CreatedAt:    got.CreatedAt,
+				Actor:        "admin",
+				ClaimedActor: "bob",
+				Action:       "CreateUser",
+				TargetID:     user.ID,
+				Changes: []account.Change{
+					{Field: "username", Before: "", After: "bob"},
+				},
+				RequestID: "req-1",
+				Outcome:   tc.wantOutcome,
+				ErrorCode: tc.wantErrorCode,
+			}
+			// The status is set once the user passes validation.
+			if user.Status != "" {
+				want.Changes = append(want.Changes, account.Change{Field: "status", Before: "", After: account.StatusActive})
+			}
+			if !reflect.DeepEqual(*got, want) {
+				t.Errorf("audit entry %+v want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestAuditMiddleware_CreateUser_auditFailed(t *testing.T) {
+	var inTx bool
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			if e.Outcome == account.AuditSuccess {
+				inTx = ctx.Value(txKey{}) != nil
+			}
+			return errors.New("db connection failed")
+		},
+	}
+	db := mock.UserStorage{
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
+		},
+		Storage: mock.Storage{
+			TransactFn: func(ctx context.Context, atomic func(ctx context.Context) error) error {
+				return atomic(context.WithValue(ctx, txKey{}, true))
+			},
+		},
+	}
+	s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&db))
+
+	if err := s.CreateUser(context.Background(), &account.User{Username: "bob"}); err == nil {
+		t.Fatal("CreateUser() expected error when audit entry can't be saved")
+	}
+	if !inTx {
+		t.Error("audit entry was appended outside of the transaction")
+	}
+}
+
+// txKey marks the context of a mock transaction.
+type txKey struct{}
+
+func TestAuditMiddleware_FindUserByID(t *testing.T) {
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			t.Errorf("FindUserByID must not be audited, got %+v", e)
+			return nil
+		},
+	}
+	s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&mock.UserStorage{}))
+	if _, err := s.FindUserByID(context.Background(), "87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef"); err != nil {
+		t.Errorf("FindUserByID() failed: %v", err)
+	}
+}
+
+func TestNewAuditMiddleware_unreported(t *testing.T) {
+	// The mock service doesn't report its changes, so nothing but rejections would be audited.
+	defer func() {
+		if recover() == nil {
+			t.Error("NewAuditMiddleware() expected panic")
+		}
+	}()
+	api.NewAuditMiddleware(log.NewNopLogger(), &mock.AuditStorage{}, &mock.UserService{})
+}
+
+func TestAuditMiddleware_SuspendUser(t *testing.T) {
+	var got []account.Change
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			got = e.Changes
+			return nil
+		},
+	}
+	db := mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return &account.User{ID: id, Username: "bob", Status: account.StatusActive}, nil
+		},
+	}
+	s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&db))
+
+	if err := s.SuspendUser(context.Background(), "87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef"); err != nil {
+		t.Fatalf("SuspendUser() failed: %v", err)
+	}
+	want := []account.Change{
+		{Field: "status", Before: account.StatusActive, After: account.StatusSuspended},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SuspendUser() audit changes %+v want %+v", got, want)
+	}
+}
+
+func TestAuditMiddleware_ReactivateUser_lockedState(t *testing.T) {
+	var got []account.Change
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			got = e.Changes
+			return nil
+		},
+	}
+	// The user was suspended by a concurrent request,
+	// so only the row locked within the transaction has the actual status.
+	db := mock.UserStorage{
+		Storage: mock.Storage{
+			TransactFn: func(ctx context.Context, atomic func(ctx context.Context) error) error {
+				return atomic(context.WithValue(ctx, txKey{}, true))
+			},
+		},
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			if ctx.Value(txKey{}) == nil {
+				return &account.User{ID: id, Username: "bob", Status: account.StatusActive}, nil
+			}
+			return &account.User{ID: id, Username: "bob", Status: account.StatusSuspended}, nil
+		},
+	}
+	s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&db))
+
+	if err := s.ReactivateUser(context.Background(), "87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef"); err != nil {
+		t.Fatalf("ReactivateUser() failed: %v", err)
+	}
+	want := []account.Change{
+		{Field: "status", Before: account.StatusSuspended, After: account.StatusActive},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReactivateUser() audit changes %+v want %+v", got, want)
+	}
+}
+
+func TestAuditMiddleware_ChangeUsername(t *testing.T) {
+	var got []account.Change
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			got = e.Changes
+			return nil
+		},
+	}
+	var saved account.User
+	db := mock.UserStorage{
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
+		},
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return &account.User{ID: id, Username: "bob", Status: account.StatusActive}, nil
+		},
+		UpdateUserFn: func(ctx context.Context, user *account.User) error {
+			saved = *user
+			return nil
+		},
+	}
+	s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&db))
+
+	if err := s.ChangeUsername(context.Background(), "87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef", "alice"); err != nil {
+		t.Fatalf("ChangeUsername() failed: %v", err)
+	}
+	want := []account.Change{
+		{Field: "username", Before: "bob", After: saved.Username},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChangeUsername() audit changes %+v want %+v", got, want)
+	}
+}
+
+func TestAuditMiddleware_ChangeUsername_same(t *testing.T) {
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			t.Errorf("renaming to the same username must not be audited, got %+v", e)
+			return nil
+		},
+	}
+	db := mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return &account.User{ID: id, Username: "bob", Status: account.StatusActive}, nil
+		},
+		UpdateUserFn: func(ctx context.Context, user *account.User) error {
+			t.Errorf("UpdateUser() must not be called, got %+v", user)
+			return nil
+		},
+	}
+	s := api.NewAuditMiddleware(log.NewNopLogger(), &audit, api.NewService(&db))
+
+	if err := s.ChangeUsername(context.Background(), "87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef", "bob"); err != nil {
+		t.Fatalf("ChangeUsername() failed: %v", err)
+	}
+}
+
+func TestUserImportAuditMiddleware_ImportUsers(t *testing.T) {
+	var got []*account.AuditEntry
+	audit := mock.AuditStorage{
+		AppendAuditEntryFn: func(ctx context.Context, e *account.AuditEntry) error {
+			if e.Outcome == account.AuditSuccess && ctx.Value(txKey{}) == nil {
+				t.Errorf("audit entry of %q was appended outside of the import transaction", e.TargetID)
+			}
+			got = append(got, e)
+			return nil
+		},
+	}
+	var conflict bool
+	db := mock.UserStorage{
+		ImportUsersFn: func(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+			if conflict {
+				return &account.ImportError{Rows: []account.ImportRowError{
+					{Row: 2, Err: account.Error{Code: account.EConflict, Field: "username"}},
+				}}
+			}
+			return nil
+		},
+		Storage: mock.Storage{
+			TransactFn: func(ctx context.Context, atomic func(ctx context.Context) error) error {
+				return atomic(context.WithValue(ctx, txKey{}, true))
+			},
+		},
+	}
+	s := api.NewUserImportAuditMiddleware(log.NewNopLogger(), &audit, api.NewUserImportService(&db))
+
+	ctx := account.ContextWithActor(context.Background(), "importer")
+	users := []*account.User{
+		{Username: "alice"},
+		{Username: "bob", Email: "bob@example.com"},
+	}
+	if err := s.ImportUsers(ctx, users); err != nil {
+		t.Fatalf("ImportUsers() failed: %v", err)
+	}
+	if len(got) != len(users) {
+		t.Fatalf("ImportUsers() appended %d audit entries want %d", len(got), len(users))
+	}
+	for i, e := range got {
+		if e.Action != "ImportUsers" || e.TargetID != users[i].ID || e.Actor != "importer" || e.Outcome != account.AuditSuccess {
+			t.Errorf("ImportUsers() audit entry %+v want success for %q", e, users[i].ID)
+		}
+	}
+	want := []account.Change{
+		{Field: "username", Before: "", After: "bob"},
+		{Field: "status", Before: "", After: account.StatusActive},
+		{Field: "email", Before: "", After: "bob@example.com"},
+	}
+	if !reflect.DeepEqual(got[1].Changes, want) {
+		t.Errorf("ImportUsers() audit changes %+v want %+v", got[1].Changes, want)
+	}
+
+	got, conflict = nil, true
+	if err := s.ImportUsers(ctx, []*account.User{{Username: "carol"}, {Username: "dave"}}); account.ErrorCode(err) != account.EImportRejected {
+		t.Fatalf("ImportUsers() = %v want %q", err, account.EImportRejected)
+	}
+	if len(got) != 1 || got[0].TargetID != "" || got[0].Outcome != account.AuditRejected || got[0].ErrorCode != account.EImportRejected {
+		t.Errorf("ImportUsers() rejected import audit entries %+v", got)
+	}
+}
@@ -0,0 +1,565 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+
+	account "github.com/marselester/ddd-err"
+	pb "github.com/marselester/ddd-err/rpc/account"
+)
+
+// openAPIOperation describes an HTTP route in OpenAPI document.
+type openAPIOperation struct {
+	method string
+	path   string
+	// id is a name of the service method, e.g., CreateUser, which is used to find its errors in the catalog.
+	id      string
+	summary string
+	params  []map[string]interface{}
+	// request is a type of the request body, if any, which is sent as media.
+	request reflect.Type
+	media   string
+	// response is a type of the successful response body sent with status.
+	response reflect.Type
+	status   int
+}
+
+// openAPIOperations returns the operations of the HTTP routes enabled by cfg, see NewHTTPHandler.
+func openAPIOperations(cfg handlerConfig) []openAPIOperation {
+	// The path variables are named after the request fields in account.proto.
+	id := ref("parameters", "ID")
+	ops := []openAPIOperation{
+		{
+			method: "post", path: "/v1/users", id: "CreateUser",
+			summary: "Create a user. A verification token is emailed if the user has an email.",
+			request: reflect.TypeOf(CreateUserReq{}), response: reflect.TypeOf(CreateUserResp{}),
+		},
+		{
+			method: "get", path: "/v1/users/{id}", id: "FindUserByID",
+			summary:  "Find a user by ID.",
+			params:   []map[string]interface{}{id},
+			response: reflect.TypeOf(FindUserByIDResp{}),
+		},
+		{
+			method: "post", path: "/v1/email-verifications", id: "VerifyEmail",
+			summary: "Confirm the email of a user by a token sent to that email.",
+			request: reflect.TypeOf(VerifyEmailReq{}), response: reflect.TypeOf(VerifyEmailResp{}),
+		},
+		{
+			method: "post", path: "/v1/users/{id}/suspend", id: "SuspendUser",
+			summary:  "Temporarily ban an active user.",
+			params:   []map[string]interface{}{id},
+			response: reflect.TypeOf(ChangeUserStatusResp{}),
+		},
+		{
+			method: "post", path: "/v1/users/{id}/reactivate", id: "ReactivateUser",
+			summary:  "Lift a suspension or a lock of a user.",
+			params:   []map[string]interface{}{id},
+			response: reflect.TypeOf(ChangeUserStatusResp{}),
+		},
+		{
+			method: "post", path: "/v1/users/{id}/lock", id: "LockUser",
+			summary:  "Lock a user account after abuse.",
+			params:   []map[string]interface{}{id},
+			response: reflect.TypeOf(ChangeUserStatusResp{}),
+		},
+		{
+			method: "delete", path: "/v1/users/{id}", id: "DeleteUser",
+			summary:  "Delete a user account for good.",
+			params:   []map[string]interface{}{id},
+			response: reflect.TypeOf(ChangeUserStatusResp{}),
+		},
+		{
+			method: "post", path: "/v1/users/{id}/username", id: "ChangeUsername",
+			summary: "Rename a user keeping the former username in the history.",
+			params:  []map[string]interface{}{id},
+			request: reflect.TypeOf(ChangeUsernameReq{}), response: reflect.TypeOf(ChangeUsernameResp{}),
+		},
+		{
+			method: "get", path: "/v1/usernames/{username}", id: "FindUserByUsername",
+			summary:  "Find a user by username. A former username is redirected to the user resource.",
+			params:   []map[string]interface{}{ref("parameters", "Username")},
+			response: reflect.TypeOf(FindUserByIDResp{}),
+		},
+		{
+			method: "get", path: "/v1/users", id: "ListUsers",
+			summary: "List users ordered by username. The next page starts after the username of the last user.",
+			params: []map[string]interface{}{
+				queryParam("after", "Username after which the page starts.", map[string]interface{}{"type": "string"}),
+				queryParam("limit", "Maximum number of returned users.", map[string]interface{}{"type": "integer"}),
+			},
+			response: reflect.TypeOf(ListUsersResp{}),
+		},
+	}
+	if cfg.audit != nil {
+		ops = append(ops, openAPIOperation{
+			method: "get", path: "/v1/users/{user_id}/audit", id: "FindAuditEntries",
+			summary: "Find the audit trail of a user account, the most recent entries first.",
+			params: []map[string]interface{}{
+				ref("parameters", "UserID"),
+				queryParam("since", "Start of the time range in RFC 3339 format.", map[string]interface{}{"type": "string", "format": "date-time"}),
+				queryParam("until", "End of the time range in RFC 3339 format.", map[string]interface{}{"type": "string", "format": "date-time"}),
+				queryParam("limit", "Maximum number of returned entries.", map[string]interface{}{"type": "integer"}),
+			},
+			response: reflect.TypeOf(FindAuditEntriesResp{}),
+		})
+	}
+	if cfg.importer != nil {
+		ops = append(ops, openAPIOperation{
+			method: "post", path: "/v1/user-imports", id: "ImportUsers",
+			summary: "Import users in bulk, either all of them are created or none. " +
+				"The request body has a JSON object per line.",
+			request: reflect.TypeOf(CreateUserReq{}), media: MediaTypeNDJSON,
+			response: reflect.TypeOf(ImportUsersResp{}),
+		})
+	}
+	if cfg.groups != nil {
+		ops = append(ops, []openAPIOperation{
+			{
+				method: "post", path: "/v1/groups", id: "CreateGroup",
+				summary: "Create a customer group.",
+				request: reflect.TypeOf(CreateGroupReq{}), response: reflect.TypeOf(CreateGroupResp{}),
+			},
+			{
+				method: "get", path: "/v1/groups/{id}", id: "FindGroupByID",
+				summary:  "Find a group by ID.",
+				params:   []map[string]interface{}{id},
+				response: reflect.TypeOf(FindGroupByIDResp{}),
+			},
+			{
+				method: "post", path: "/v1/groups/{id}/name", id: "RenameGroup",
+				summary: "Rename a group.",
+				params:  []map[string]interface{}{id},
+				request: reflect.TypeOf(RenameGroupReq{}), response: reflect.TypeOf(RenameGroupResp{}),
+			},
+			{
+				method: "get", path: "/v1/groups", id: "ListGroups",
+				summary: "List groups ordered by name. The next page starts after the name of the last group.",
+				params: []map[string]interface{}{
+					queryParam("after", "Group name after which the page starts.", map[string]interface{}{"type": "string"}),
+					queryParam("limit", "Maximum number of returned groups.", map[string]interface{}{"type": "integer"}),
+				},
+				response: reflect.TypeOf(ListGroupsResp{}),
+			},
+			{
+				method: "delete", path: "/v1/groups/{id}", id: "DeleteGroup",
+				summary:  "Delete a group.",
+				params:   []map[string]interface{}{id},
+				response: reflect.TypeOf(DeleteGroupResp{}),
+			},
+		}...)
+	}
+	return ops
+}
+
+// ref returns a reference to the component, e.g., #/components/schemas/Error.
+func ref(kind, name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/components/" + kind + "/" + name}
+}
+
+// queryParam returns an optional query parameter.
+func queryParam(name, description string, schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        name,
+		"in":          "query",
+		"description": description,
+		"schema":      schema,
+	}
+}
+
+// newOpenAPIDocument returns OpenAPI 3 document of the HTTP API routes enabled by cfg.
+// Every route is also documented under /v1/tenants/{tenant_id}/ prefix
+// with the operation ID suffixed by ForTenant, e.g., CreateUserForTenant.
+func newOpenAPIDocument(cfg handlerConfig) map[string]interface{} {
+	schemas := errorSchemas()
+	paths := make(map[string]map[string]interface{})
+	for _, op := range openAPIOperations(cfg) {
+		tenantPath := "/v1/tenants/{tenant_id}" + strings.TrimPrefix(op.path, "/v1")
+		for _, p := range []struct {
+			path   string
+			id     string
+			tenant map[string]interface{}
+		}{
+			{op.path, op.id, ref("parameters", "TenantIDHeader")},
+			{tenantPath, op.id + "ForTenant", ref("parameters", "TenantID")},
+		} {
+			if paths[p.path] == nil {
+				paths[p.path] = make(map[string]interface{})
+			}
+			paths[p.path][op.method] = op.document(p.id, p.tenant, schemas)
+		}
+	}
+
+	paths["/openapi.json"] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "GetOpenAPIDocument",
+			"summary":     "Get this OpenAPI document.",
+			"security":    []interface{}{map[string]interface{}{}},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "OpenAPI document.",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "object"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	uuidSchema := map[string]interface{}{"type": "string", "format": "uuid"}
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "Account API",
+			"version": "v1",
+		},
+		"paths":    paths,
+		"security": []interface{}{map[string]interface{}{}, map[string]interface{}{"apiKey": []string{}}},
+		"components": map[string]interface{}{
+			"schemas":   schemas,
+			"responses": errorResponses(),
+			"parameters": map[string]interface{}{
+				"TenantID": map[string]interface{}{
+					"name": "tenant_id", "in": "path", "required": true, "schema": uuidSchema,
+				},
+				"TenantIDHeader": map[string]interface{}{
+					"name": "X-Tenant-ID", "in": "header", "schema": uuidSchema,
+					"description": "Tenant ID, the tenant of the authenticated API key takes precedence.",
+				},
+				"ActorID": map[string]interface{}{
+					"name": "X-Actor-ID", "in": "header", "schema": map[string]interface{}{"type": "string"},
+					"description": "ID of the principal API client acts on behalf of, it is recorded in the audit trail as an unverified claimed actor.",
+				},
+				"ReadYourWrites": map[string]interface{}{
+					"name": "X-Read-Your-Writes", "in": "header", "schema": map[string]interface{}{"type": "string", "enum": []interface{}{"true"}},
+					"description": "Makes the reads observe the writes of the preceding requests instead of possibly lagging replicas.",
+				},
+				"RequestID": map[string]interface{}{
+					"name": "X-Request-ID", "in": "header", "schema": map[string]interface{}{"type": "string"},
+					"description": "ID of the request recorded in the audit trail, it is generated if missing.",
+				},
+				"ID": map[string]interface{}{
+					"name": "id", "in": "path", "required": true, "schema": uuidSchema,
+				},
+				"UserID": map[string]interface{}{
+					"name": "user_id", "in": "path", "required": true, "schema": uuidSchema,
+				},
+				"Username": map[string]interface{}{
+					"name": "username", "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"},
+				},
+			},
+			"securitySchemes": map[string]interface{}{
+				"apiKey": map[string]interface{}{
+					"type":        "http",
+					"scheme":      "bearer",
+					"description": "API key which scopes the requests to its tenant.",
+				},
+			},
+		},
+	}
+}
+
+// document returns OpenAPI operation object.
+// The schemas of the request and response types are added to schemas.
+func (op openAPIOperation) document(id string, tenant map[string]interface{}, schemas map[string]interface{}) map[string]interface{} {
+	params := []interface{}{tenant, ref("parameters", "ActorID"), ref("parameters", "ReadYourWrites"), ref("parameters", "RequestID")}
+	for _, p := range op.params {
+		params = append(params, p)
+	}
+
+	rpc := rpcMethod(op.id)
+	responses := map[string]interface{}{
+		strconv.Itoa(http.StatusOK): map[string]interface{}{
+			"description": "Successful response.",
+			"content": map[string]interface{}{
+				MediaTypeJSON:     map[string]interface{}{"schema": schemaRef(op.response, schemas)},
+				MediaTypeProtobuf: protobufMedia(rpc.Output()),
+			},
+		},
+	}
+	if op.id == "FindUserByUsername" {
+		responses[strconv.Itoa(http.StatusTemporaryRedirect)] = map[string]interface{}{
+			"description": "The username is a former username of the user, Location header has the user resource.",
+			"headers": map[string]interface{}{
+				"Location": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}},
+			},
+		}
+	}
+	for status, codes := range operationErrors(op.id) {
+		envelope := ref("schemas", "ErrorResponse")
+		if op.id == "ImportUsers" && status == http.StatusBadRequest {
+			envelope = ref("schemas", "ImportErrorResponse")
+		}
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				MediaTypeProtobuf: protobufMedia(rpc.Output()),
+				MediaTypeJSON: map[string]interface{}{
+					"schema": map[string]interface{}{
+						"allOf": []interface{}{
+							envelope,
+							map[string]interface{}{
+								"type": "object",
+								"properties": map[string]interface{}{
+									"error": map[string]interface{}{
+										"type": "object",
+										"properties": map[string]interface{}{
+											"code": map[string]interface{}{"type": "string", "enum": codes},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	doc := map[string]interface{}{
+		"operationId": id,
+		"summary":     op.summary,
+		"parameters":  params,
+		"responses":   responses,
+	}
+	if op.request != nil {
+		media := op.media
+		if media == "" {
+			media = MediaTypeJSON
+		}
+		doc["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content": map[string]interface{}{
+				media:             map[string]interface{}{"schema": schemaRef(op.request, schemas)},
+				MediaTypeProtobuf: protobufMedia(rpc.Input()),
+			},
+		}
+	}
+	return doc
+}
+
+// rpcMethod returns the RPC of account.proto named id, e.g., CreateUser.
+func rpcMethod(id string) protoreflect.MethodDescriptor {
+	services := pb.File_account_account_proto.Services()
+	for i := 0; i < services.Len(); i++ {
+		if md := services.Get(i).Methods().ByName(protoreflect.Name(id)); md != nil {
+			return md
+		}
+	}
+	panic("unknown RPC " + id)
+}
+
+// protobufMedia returns OpenAPI media type object of the protobuf message.
+func protobufMedia(msg protoreflect.MessageDescriptor) map[string]interface{} {
+	return map[string]interface{}{
+		"schema": map[string]interface{}{
+			"type":        "string",
+			"format":      "binary",
+			"description": fmt.Sprintf("Message %s defined in account.proto.", msg.FullName()),
+		},
+	}
+}
+
+// operationErrors returns the error codes which the operation may return grouped by HTTP status.
+func operationErrors(id string) map[int][]string {
+	errs := make(map[int][]string)
+	for _, info := range ErrorCatalog() {
+		for _, op := range info.Operations {
+			if op == id {
+				errs[info.HTTPStatus] = append(errs[info.HTTPStatus], info.Code)
+				break
+			}
+		}
+	}
+	return errs
+}
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// schemaRef returns a reference to the schema of the struct type t derived from its json tags.
+// The schema of t and the structs it refers to are added to schemas by their type names.
+// The fields of error type are omitted since the errors are sent in the error envelope.
+func schemaRef(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	if _, ok := schemas[t.Name()]; !ok {
+		// The placeholder stops the recursion on self-referencing types.
+		schemas[t.Name()] = nil
+		props := make(map[string]interface{})
+		var required []string
+		addFields(t, props, &required, schemas)
+		sort.Strings(required)
+
+		s := map[string]interface{}{
+			"type":       "object",
+			"properties": props,
+		}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		schemas[t.Name()] = s
+	}
+	return ref("schemas", t.Name())
+}
+
+// addFields adds the schemas of the json fields of the struct type t to props.
+// The fields of embedded structs are added as if they were declared in t.
+func addFields(t reflect.Type, props map[string]interface{}, required *[]string, schemas map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			addFields(f.Type, props, required, schemas)
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" || f.Type == errorType {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaOf(f.Type, schemas)
+		if opts != "omitempty" {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// schemaOf returns the schema of a JSON value of type t.
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	switch {
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case t.Kind() == reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case t.Kind() == reflect.Struct:
+		return schemaRef(t, schemas)
+	}
+	panic(fmt.Sprintf("openapi: unsupported type %s", t))
+}
+
+// errorSchemas returns ErrorCode, Error, ErrorResponse and ImportErrorResponse schemas.
+func errorSchemas() map[string]interface{} {
+	catalog := ErrorCatalog()
+	codes := make([]string, 0, len(catalog))
+	var descr strings.Builder
+	descr.WriteString("Machine-readable error code:\n")
+	for _, e := range catalog {
+		codes = append(codes, e.Code)
+		fmt.Fprintf(&descr, "- `%s` %s\n", e.Code, e.Description)
+	}
+
+	return map[string]interface{}{
+		"ErrorCode": map[string]interface{}{
+			"type":        "string",
+			"enum":        codes,
+			"description": descr.String(),
+		},
+		"Error": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"code", "message"},
+			"properties": map[string]interface{}{
+				"code": ref("schemas", "ErrorCode"),
+				"message": map[string]interface{}{
+					"type":        "string",
+					"description": "Human-readable message which can be shown to end users.",
+				},
+				"field": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the request field that failed validation, if any.",
+				},
+			},
+		},
+		"ErrorResponse": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]interface{}{
+				"error": ref("schemas", "Error"),
+			},
+		},
+		"ImportErrorResponse": map[string]interface{}{
+			"type":     "object",
+			"required": []string{"error"},
+			"properties": map[string]interface{}{
+				"error": ref("schemas", "Error"),
+				"rows": map[string]interface{}{
+					"type":        "array",
+					"description": "Errors of the rejected users when the import is rejected.",
+					"items": map[string]interface{}{
+						"type":     "object",
+						"required": []string{"row", "error"},
+						"properties": map[string]interface{}{
+							"row": map[string]interface{}{
+								"type":        "integer",
+								"description": "Line of the user in the request body starting from 1.",
+							},
+							"error": ref("schemas", "Error"),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// errorResponses returns the error responses named after HTTP statuses, e.g., NotFound,
+// with an example per error code.
+func errorResponses() map[string]interface{} {
+	type example struct {
+		Summary string      `json:"summary"`
+		Value   interface{} `json:"value"`
+	}
+	examples := make(map[int]map[string]example)
+	for _, e := range ErrorCatalog() {
+		if examples[e.HTTPStatus] == nil {
+			examples[e.HTTPStatus] = make(map[string]example)
+		}
+		examples[e.HTTPStatus][e.Code] = example{
+			Summary: e.Description,
+			Value: map[string]interface{}{
+				"error": account.Error{Code: e.Code, Message: e.Message},
+			},
+		}
+	}
+
+	responses := make(map[string]interface{}, len(examples))
+	for status, ee := range examples {
+		name := strings.ReplaceAll(http.StatusText(status), " ", "")
+		responses[name] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema":   ref("schemas", "ErrorResponse"),
+					"examples": ee,
+				},
+			},
+		}
+	}
+	return responses
+}
+
+// OpenAPIErrorComponents returns the components of OpenAPI document which describe the API errors:
+// ErrorCode, Error and ErrorResponse schemas, and the error responses named after HTTP statuses.
+func OpenAPIErrorComponents() map[string]interface{} {
+	return map[string]interface{}{
+		"schemas":   errorSchemas(),
+		"responses": errorResponses(),
+	}
+}
@@ -0,0 +1,165 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+
+	"github.com/marselester/ddd-err/api"
+	"github.com/marselester/ddd-err/mock"
+)
+
+// openAPIDocument requests the OpenAPI document from the handler.
+func openAPIDocument(t *testing.T, h http.Handler) map[string]interface{} {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json status %d want %d", rec.Code, http.StatusOK)
+	}
+	var doc map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+// routes returns the routes of the router formatted as "GET /v1/users/{id}".
+func routes(t *testing.T, h http.Handler) []string {
+	t.Helper()
+
+	var rr []string
+	err := h.(*mux.Router).Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		path, err := route.GetPathTemplate()
+		if err != nil {
+			return err
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return err
+		}
+		for _, m := range methods {
+			rr = append(rr, m+" "+path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(rr)
+	return rr
+}
+
+// documentedRoutes returns the operations of OpenAPI document formatted as "GET /v1/users/{id}".
+func documentedRoutes(doc map[string]interface{}) []string {
+	var rr []string
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		for method := range item.(map[string]interface{}) {
+			rr = append(rr, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(rr)
+	return rr
+}
+
+// difference returns the elements of a which aren't in b.
+func difference(a, b []string) []string {
+	seen := make(map[string]bool, len(b))
+	for _, s := range b {
+		seen[s] = true
+	}
+	var diff []string
+	for _, s := range a {
+		if !seen[s] {
+			diff = append(diff, s)
+		}
+	}
+	return diff
+}
+
+// refs returns all the references found in v, e.g., #/components/schemas/Error.
+func refs(v interface{}) []string {
+	var rr []string
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, vv := range v {
+			if s, ok := vv.(string); ok && k == "$ref" {
+				rr = append(rr, s)
+				continue
+			}
+			rr = append(rr, refs(vv)...)
+		}
+	case []interface{}:
+		for _, vv := range v {
+			rr = append(rr, refs(vv)...)
+		}
+	}
+	return rr
+}
+
+func TestNewHTTPHandler_openAPI(t *testing.T) {
+	tt := map[string][]api.HandlerOption{
+		"required routes": nil,
+		"all routes": {
+			api.WithAuditService(&mock.AuditService{}),
+			api.WithUserImportService(&mock.UserImportService{}),
+			api.WithGroupService(&mock.GroupService{}),
+		},
+	}
+	for name, opts := range tt {
+		t.Run(name, func(t *testing.T) {
+			h := api.NewHTTPHandler(api.NewService(nil), log.NewNopLogger(), 1, opts...)
+			doc := openAPIDocument(t, h)
+
+			documented, served := documentedRoutes(doc), routes(t, h)
+			for _, r := range difference(served, documented) {
+				t.Errorf("route %s is not documented", r)
+			}
+			for _, r := range difference(documented, served) {
+				t.Errorf("documented route %s is not served", r)
+			}
+
+			components := doc["components"].(map[string]interface{})
+			for _, r := range refs(doc) {
+				parts := strings.Split(strings.TrimPrefix(r, "#/components/"), "/")
+				kind, _ := components[parts[0]].(map[string]interface{})
+				if len(parts) != 2 || kind[parts[1]] == nil {
+					t.Errorf("unresolved reference %s", r)
+				}
+			}
+		})
+	}
+}
+
+func TestNewHTTPHandler_openAPIErrors(t *testing.T) {
+	h := api.NewHTTPHandler(api.NewService(nil), log.NewNopLogger(), 1)
+	doc := openAPIDocument(t, h)
+
+	op := doc["paths"].(map[string]interface{})["/v1/users"].(map[string]interface{})["post"].(map[string]interface{})
+	if op["operationId"] != "CreateUser" {
+		t.Fatalf("POST /v1/users operationId = %v want CreateUser", op["operationId"])
+	}
+	resp, ok := op["responses"].(map[string]interface{})["400"]
+	if !ok {
+		t.Fatal("POST /v1/users has no 400 response")
+	}
+	b, err := json.Marshal(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{`"conflict"`, `"invalid_username"`, `"username_reserved"`} {
+		if !strings.Contains(string(b), code) {
+			t.Errorf("POST /v1/users 400 response has no %s code: %s", code, b)
+		}
+	}
+	if strings.Contains(string(b), `"invalid_verification_token"`) {
+		t.Errorf("POST /v1/users 400 response has invalid_verification_token code: %s", b)
+	}
+}
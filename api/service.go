@@ -3,8 +3,19 @@ package api
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net/mail"
 	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/go-kit/log"
 	"github.com/google/uuid"
@@ -15,6 +26,16 @@ import (
 type service struct {
 	logger log.Logger
 	db     account.UserRepository
+
+	verifications   account.EmailVerificationRepository
+	mailer          account.MailSender
+	verificationTTL time.Duration
+	// usernameReservation is how long a released username can't be claimed by other users.
+	usernameReservation time.Duration
+	// importer creates users in bulk, it's set only for UserImportService.
+	importer account.UserImportRepository
+	// usernamePolicy restricts which usernames can be claimed, if set.
+	usernamePolicy *UsernamePolicy
 }
 
 // FindUserByID returns a user by its ID.
@@ -28,7 +49,7 @@ func (s *service) FindUserByID(ctx context.Context, id string) (*account.User, e
 		}
 	}
 
-	u, err := s.db.FindUserByID(ctx, nil, userID.String())
+	u, err := s.db.FindUserByID(ctx, userID.String())
 	if err != nil {
 		return nil, fmt.Errorf("user (id %s) not found: %w", userID, err)
 	}
@@ -37,23 +58,745 @@ func (s *service) FindUserByID(ctx context.Context, id string) (*account.User, e
 
 var validUsername = regexp.MustCompile(`^[A-z0-9]+$`)
 
-// CreateUser creates a new user in the system.
-// It returns EInvalidUsername if the username is blank or
-// EConflict if the username is already in use.
+const (
+	// maxEmailLength is max length of an email address according to RFC 5321.
+	maxEmailLength = 254
+	// maxDisplayNameLength is max number of characters in a display name.
+	maxDisplayNameLength = 64
+)
+
+// CreateUser creates a new user in the system and assigns it a random ID.
+// The email and display name are optional.
+// When the email is set, the user is asked to verify it if email verification is enabled.
+// It returns EInvalidUsername, EInvalidEmail, EInvalidDisplayName if the user fails validation,
+// EConflict if the username or email is already in use or
+// EUsernameReserved if the username was recently released by another user.
 func (s *service) CreateUser(ctx context.Context, u *account.User) error {
-	if !validUsername.MatchString(u.Username) {
+	if err := validateUsername(u.Username); err != nil {
+		return err
+	}
+	if err := s.checkUsernamePolicy(u.Username); err != nil {
+		return err
+	}
+	if err := validateEmail(u.Email); err != nil {
+		return err
+	}
+	if err := validateDisplayName(u.DisplayName); err != nil {
+		return err
+	}
+
+	if err := s.checkUsernameAvailable(ctx, "", u.Username); err != nil {
+		return err
+	}
+	if err := s.checkEmailAvailable(ctx, u.Email); err != nil {
+		return err
+	}
+
+	u.ID = uuid.NewString()
+	u.Status = account.StatusActive
+	u.EmailVerified = false
+	err := s.db.Transact(ctx, func(ctx context.Context) error {
+		if err := s.db.CreateUser(ctx, u); err != nil {
+			return err
+		}
+		return auditChange(ctx, nil, u)
+	})
+	if err != nil {
+		return err
+	}
+
+	if u.Email != "" && s.verifications != nil {
+		// The user is already created, so the verification can be requested again later.
+		if err := s.requestEmailVerification(ctx, u); err != nil {
+			s.logger.Log("msg", "email verification was not requested", "user_id", u.ID, "err", err)
+		}
+	}
+	return nil
+}
+
+// validateUsername returns EInvalidUsername if the username has characters other than letters and digits.
+func validateUsername(username string) error {
+	if !validUsername.MatchString(username) {
 		return account.Error{
 			Code:    account.EInvalidUsername,
 			Message: "Username is invalid.",
+			Field:   "username",
 		}
 	}
+	return nil
+}
+
+// UsernamePolicy is a list of usernames which users can't claim, e.g., "admin" or "support".
+// The usernames are compared case-insensitively.
+// It's safe for concurrent use, so the list can be replaced while the service is running.
+type UsernamePolicy struct {
+	blocked atomic.Pointer[map[string]bool]
+}
+
+// NewUsernamePolicy returns a policy that blocks the usernames.
+func NewUsernamePolicy(blocked ...string) *UsernamePolicy {
+	var p UsernamePolicy
+	p.SetBlocked(blocked...)
+	return &p
+}
+
+// SetBlocked replaces the blocked usernames.
+func (p *UsernamePolicy) SetBlocked(usernames ...string) {
+	m := make(map[string]bool, len(usernames))
+	for _, u := range usernames {
+		m[strings.ToLower(u)] = true
+	}
+	p.blocked.Store(&m)
+}
+
+// Blocked reports whether the username can't be claimed.
+func (p *UsernamePolicy) Blocked(username string) bool {
+	m := p.blocked.Load()
+	return m != nil && (*m)[strings.ToLower(username)]
+}
 
-	if s.db.UsernameInUse(ctx, u.Username) {
+// checkUsernamePolicy returns EInvalidUsername if the username is blocked by the policy.
+func (s *service) checkUsernamePolicy(username string) error {
+	if s.usernamePolicy != nil && s.usernamePolicy.Blocked(username) {
+		return account.Error{
+			Code:    account.EInvalidUsername,
+			Message: "Username is not allowed.",
+			Field:   "username",
+		}
+	}
+	return nil
+}
+
+// checkUsernameAvailable returns EConflict if the username is in use or
+// EUsernameReserved if another user released it within the reservation period.
+// The user who released the username can claim it back at any time, so userID is
+// the ID of the user claiming the username (empty for a new user).
+func (s *service) checkUsernameAvailable(ctx context.Context, userID, username string) error {
+	inUse, err := s.db.UsernameInUse(ctx, username)
+	if err != nil {
+		return fmt.Errorf("username availability check failed: %w", err)
+	}
+	if inUse {
 		return account.Error{
 			Code:    account.EConflict,
 			Message: "Username is already in use. Please choose a different username.",
+			Field:   "username",
+		}
+	}
+	return s.checkUsernameReserved(ctx, userID, username)
+}
+
+// checkEmailAvailable returns EConflict if the email is claimed by another user.
+// An empty email is always available.
+func (s *service) checkEmailAvailable(ctx context.Context, email string) error {
+	if email == "" {
+		return nil
+	}
+	inUse, err := s.db.EmailInUse(ctx, email)
+	if err != nil {
+		return fmt.Errorf("email availability check failed: %w", err)
+	}
+	if inUse {
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Email is already in use. Please choose a different email.",
+			Field:   "email",
+		}
+	}
+	return nil
+}
+
+// checkUsernameReserved returns EUsernameReserved if a user other than userID
+// released the username within the reservation period.
+func (s *service) checkUsernameReserved(ctx context.Context, userID, username string) error {
+	c, err := s.db.FindUsernameChange(ctx, username)
+	switch {
+	case account.ErrorCode(err) == account.ENotFound:
+		return nil
+	case err != nil:
+		return err
+	case c.UserID != userID && time.Since(c.ChangedAt) < s.usernameReservation:
+		return account.Error{
+			Code:    account.EUsernameReserved,
+			Message: "Username was recently released and is reserved. Please choose a different username.",
+			Field:   "username",
+		}
+	}
+	return nil
+}
+
+// validateEmail returns EInvalidEmail if the email is set, but it's not a bare address,
+// e.g., "Bob <bob@example.com>" is not allowed.
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxEmailLength {
+		return account.Error{
+			Code:    account.EInvalidEmail,
+			Message: "Email is invalid.",
+			Field:   "email",
+		}
+	}
+	return nil
+}
+
+// validateDisplayName returns EInvalidDisplayName if the name is too long,
+// has surrounding spaces or control characters.
+func validateDisplayName(name string) error {
+	invalid := !utf8.ValidString(name) ||
+		utf8.RuneCountInString(name) > maxDisplayNameLength ||
+		strings.TrimSpace(name) != name ||
+		strings.IndexFunc(name, unicode.IsControl) != -1
+	if invalid {
+		return account.Error{
+			Code:    account.EInvalidDisplayName,
+			Message: fmt.Sprintf("Display name must be at most %d characters without control characters and surrounding spaces.", maxDisplayNameLength),
+			Field:   "display_name",
+		}
+	}
+	return nil
+}
+
+// requestEmailVerification stores a hash of a random single-use token and
+// sends the token to the user's email.
+func (s *service) requestEmailVerification(ctx context.Context, u *account.User) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Errorf("verification token generation failed: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+
+	v := account.EmailVerification{
+		TokenHash: hashToken(token),
+		UserID:    u.ID,
+		Email:     u.Email,
+		ExpiresAt: time.Now().Add(s.verificationTTL),
+	}
+	if err := s.verifications.CreateEmailVerification(ctx, &v); err != nil {
+		return err
+	}
+
+	return s.mailer.SendMail(ctx, &account.Mail{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nplease verify your email using the token below before %s.\n\n%s\n",
+			u.Username, v.ExpiresAt.UTC().Format(time.RFC1123), token,
+		),
+	})
+}
+
+// hashToken returns hex encoded SHA-256 hash of the token.
+// Tokens have enough entropy, so they don't need to be salted.
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// VerifyEmail confirms the email of a user by a token sent to that email.
+// The token can be used only once and only until it expires.
+// It returns EInvalidVerificationToken if the token is unknown, expired, already used or
+// the user has changed the email since the token was issued,
+// or EFeatureDisabled if email verification is not enabled (see WithEmailVerification).
+func (s *service) VerifyEmail(ctx context.Context, token string) (*account.User, error) {
+	if s.verifications == nil {
+		return nil, account.Error{
+			Code:    account.EFeatureDisabled,
+			Message: "Email verification is disabled.",
+		}
+	}
+
+	var u *account.User
+	err := s.db.Transact(ctx, func(ctx context.Context) error {
+		v, err := s.verifications.FindEmailVerification(ctx, hashToken(token))
+		if account.ErrorCode(err) == account.ENotFound {
+			return account.Error{
+				Code:    account.EInvalidVerificationToken,
+				Message: "Verification token is invalid.",
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch {
+		case !v.UsedAt.IsZero():
+			return account.Error{
+				Code:    account.EInvalidVerificationToken,
+				Message: "Verification token was already used.",
+			}
+		case now.After(v.ExpiresAt):
+			return account.Error{
+				Code:    account.EInvalidVerificationToken,
+				Message: "Verification token has expired.",
+			}
+		}
+
+		if u, err = s.db.FindUserByID(ctx, v.UserID); err != nil {
+			return fmt.Errorf("user (id %s) not found: %w", v.UserID, err)
+		}
+		before := *u
+		if !strings.EqualFold(u.Email, v.Email) {
+			return account.Error{
+				Code:    account.EInvalidVerificationToken,
+				Message: "Verification token was issued for a different email.",
+			}
+		}
+
+		v.UsedAt = now
+		if err = s.verifications.UpdateEmailVerification(ctx, v); err != nil {
+			return err
+		}
+		u.EmailVerified = true
+		if err = s.db.UpdateUser(ctx, u); err != nil {
+			return err
+		}
+		return auditChange(ctx, &before, u)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ChangeUsername renames a user within a db transaction.
+// The former username is kept in the history, so the user can be found by it,
+// and it's reserved for the user for the reservation period.
+// Renaming a user to its current username changes nothing, so it isn't audited.
+// It returns EInvalidUserID if the ID is invalid UUID, EInvalidUsername if the username fails validation,
+// ENotFound if the user does not exist, EConflict if the username is already in use or
+// EUsernameReserved if the username was recently released by another user.
+func (s *service) ChangeUsername(ctx context.Context, id, username string) error {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return account.Error{
+			Code:    account.EInvalidUserID,
+			Message: "Invalid user ID.",
+		}
+	}
+	if err = validateUsername(username); err != nil {
+		return err
+	}
+	if err = s.checkUsernamePolicy(username); err != nil {
+		return err
+	}
+
+	return s.db.Transact(ctx, func(ctx context.Context) error {
+		u, err := s.db.FindUserByID(ctx, userID.String())
+		if err != nil {
+			return fmt.Errorf("user (id %s) not found: %w", userID, err)
+		}
+		if u.Username == username {
+			return nil
+		}
+		if err = s.checkUsernameAvailable(ctx, u.ID, username); err != nil {
+			return err
+		}
+
+		c := account.UsernameChange{
+			UserID:    u.ID,
+			Username:  u.Username,
+			ChangedAt: time.Now(),
+		}
+		if err = s.db.CreateUsernameChange(ctx, &c); err != nil {
+			return err
+		}
+		before := *u
+		u.Username = username
+		if err = s.db.UpdateUser(ctx, u); err != nil {
+			return err
+		}
+		return auditChange(ctx, &before, u)
+	})
+}
+
+// FindUserByUsername returns a user by current username or
+// the user who most recently released the username if nobody claimed it since then.
+// It returns EInvalidUsername if the username fails validation or ENotFound if the user does not exist.
+func (s *service) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	if err := validateUsername(username); err != nil {
+		return nil, err
+	}
+
+	u, err := s.db.FindUserByUsername(ctx, username)
+	if account.ErrorCode(err) != account.ENotFound {
+		if err != nil {
+			return nil, fmt.Errorf("user (username %s) not found: %w", username, err)
+		}
+		return u, nil
+	}
+
+	c, err := s.db.FindUsernameChange(ctx, username)
+	if account.ErrorCode(err) == account.ENotFound {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "User not found.",
+			Inner:   err,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("username change (username %s) not found: %w", username, err)
+	}
+	if u, err = s.db.FindUserByID(ctx, c.UserID); err != nil {
+		return nil, fmt.Errorf("user (id %s) not found: %w", c.UserID, err)
+	}
+	return u, nil
+}
+
+const (
+	// defaultListLimit is a number of users or groups listed when the limit is not set.
+	defaultListLimit = 100
+	// maxListLimit is max number of users or groups listed at once.
+	maxListLimit = 1000
+)
+
+// listLimit caps the number of listed entities, see defaultListLimit and maxListLimit.
+func listLimit(limit int) int {
+	if limit <= 0 {
+		return defaultListLimit
+	}
+	if limit > maxListLimit {
+		return maxListLimit
+	}
+	return limit
+}
+
+// ListUsers returns users ordered by username which come after f.After.
+// The next page starts after the username of the last user in the page.
+// By default the number of users is capped by defaultListLimit.
+func (s *service) ListUsers(ctx context.Context, f account.UserFilter) ([]*account.User, error) {
+	f.Limit = listLimit(f.Limit)
+	users, err := s.db.ListUsers(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("users (after %q) not found: %w", f.After, err)
+	}
+	return users, nil
+}
+
+// SuspendUser temporarily bans an active user.
+// It returns EInvalidUserID if the ID is invalid UUID, ENotFound if the user does not exist or
+// EInvalidStateTransition if the user is not active.
+func (s *service) SuspendUser(ctx context.Context, id string) error {
+	return s.transition(ctx, id, account.EventSuspend)
+}
+
+// ReactivateUser lifts a suspension or a lock of a user.
+// It returns EInvalidUserID if the ID is invalid UUID, ENotFound if the user does not exist or
+// EInvalidStateTransition if the user is neither suspended nor locked.
+func (s *service) ReactivateUser(ctx context.Context, id string) error {
+	return s.transition(ctx, id, account.EventReactivate)
+}
+
+// LockUser locks a user account after abuse.
+// It returns EInvalidUserID if the ID is invalid UUID, ENotFound if the user does not exist or
+// EInvalidStateTransition if the user is already locked or deleted.
+func (s *service) LockUser(ctx context.Context, id string) error {
+	return s.transition(ctx, id, account.EventLock)
+}
+
+// DeleteUser deletes a user account for good.
+// The account is kept in the storage with StatusDeleted status.
+// It returns EInvalidUserID if the ID is invalid UUID, ENotFound if the user does not exist or
+// EInvalidStateTransition if the user is already deleted.
+func (s *service) DeleteUser(ctx context.Context, id string) error {
+	return s.transition(ctx, id, account.EventDelete)
+}
+
+// transition changes the user status according to the event within a db transaction.
+func (s *service) transition(ctx context.Context, id, event string) error {
+	userID, err := uuid.Parse(id)
+	if err != nil {
+		return account.Error{
+			Code:    account.EInvalidUserID,
+			Message: "Invalid user ID.",
 		}
 	}
 
-	return s.db.CreateUser(ctx, u)
+	return s.db.Transact(ctx, func(ctx context.Context) error {
+		u, err := s.db.FindUserByID(ctx, userID.String())
+		if err != nil {
+			return fmt.Errorf("user (id %s) not found: %w", userID, err)
+		}
+		before := *u
+		if err = u.Transition(event); err != nil {
+			return err
+		}
+		if err = s.db.UpdateUser(ctx, u); err != nil {
+			return err
+		}
+		return auditChange(ctx, &before, u)
+	})
+}
+
+// maxImportUsers is max number of users imported at once.
+const maxImportUsers = 50000
+
+// ImportUsers creates users in bulk and assigns them random IDs.
+// Either all the users are imported or none of them.
+// Unlike CreateUser, the imported users are not asked to verify their emails.
+//
+// It returns EImportRejected error wrapping *account.ImportError if any user fails validation (see CreateUser),
+// repeats a username or email of a preceding user in the import, conflicts with existing users, or
+// claims a username which was recently released.
+// The validation errors are reported before the storage checks the conflicts and reserved usernames.
+func (s *service) ImportUsers(ctx context.Context, users []*account.User) error {
+	if len(users) > maxImportUsers {
+		return account.Error{
+			Code:    account.EImportRejected,
+			Message: fmt.Sprintf("At most %d users can be imported at once.", maxImportUsers),
+		}
+	}
+
+	var (
+		importErr account.ImportError
+		usernames = make(map[string]bool, len(users))
+		emails    = make(map[string]bool, len(users))
+	)
+	for i, u := range users {
+		if err := s.validateImportedUser(u, usernames, emails); err != nil {
+			importErr.Rows = append(importErr.Rows, account.ImportRowError{Row: i + 1, Err: err})
+		}
+	}
+	if len(importErr.Rows) > 0 {
+		return importRejected(&importErr)
+	}
+
+	for _, u := range users {
+		u.ID = uuid.NewString()
+		u.Status = account.StatusActive
+		u.EmailVerified = false
+	}
+	err := s.importer.Transact(ctx, func(ctx context.Context) error {
+		if err := s.importer.ImportUsers(ctx, users, time.Now().Add(-s.usernameReservation)); err != nil {
+			return err
+		}
+		for _, u := range users {
+			if err := auditChange(ctx, nil, u); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	var e *account.ImportError
+	if errors.As(err, &e) {
+		return importRejected(e)
+	}
+	return err
+}
+
+// validateImportedUser validates the user similar to CreateUser.
+// The usernames and emails of the preceding users are tracked to find repetitions within the import.
+func (s *service) validateImportedUser(u *account.User, usernames, emails map[string]bool) error {
+	if err := validateUsername(u.Username); err != nil {
+		return err
+	}
+	if err := s.checkUsernamePolicy(u.Username); err != nil {
+		return err
+	}
+	if err := validateEmail(u.Email); err != nil {
+		return err
+	}
+	if err := validateDisplayName(u.DisplayName); err != nil {
+		return err
+	}
+
+	if usernames[u.Username] {
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Username is repeated in the import.",
+			Field:   "username",
+		}
+	}
+	usernames[u.Username] = true
+	if u.Email != "" {
+		email := strings.ToLower(u.Email)
+		if emails[email] {
+			return account.Error{
+				Code:    account.EConflict,
+				Message: "Email is repeated in the import.",
+				Field:   "email",
+			}
+		}
+		emails[email] = true
+	}
+	return nil
+}
+
+// importRejected wraps the import error into EImportRejected domain error.
+func importRejected(err *account.ImportError) error {
+	return account.Error{
+		Code:    account.EImportRejected,
+		Message: fmt.Sprintf("%d users can't be imported.", len(err.Rows)),
+		Inner:   err,
+	}
+}
+
+const (
+	// defaultAuditLimit is a number of audit entries returned when the limit is not set.
+	defaultAuditLimit = 100
+	// maxAuditLimit is max number of audit entries returned at once.
+	maxAuditLimit = 1000
+)
+
+type auditService struct {
+	db account.AuditRepository
+}
+
+// FindAuditEntries returns the audit trail of a user within a time range.
+// By default the range ends now and the number of entries is capped by defaultAuditLimit.
+// It returns EInvalidUserID if the user ID is invalid UUID or
+// EInvalidTimeRange if the range is empty.
+func (s *auditService) FindAuditEntries(ctx context.Context, f account.AuditFilter) ([]*account.AuditEntry, error) {
+	userID, err := uuid.Parse(f.UserID)
+	if err != nil {
+		return nil, account.Error{
+			Code:    account.EInvalidUserID,
+			Message: "Invalid user ID.",
+		}
+	}
+	f.UserID = userID.String()
+
+	if f.Until.IsZero() {
+		f.Until = time.Now()
+	}
+	if !f.Since.Before(f.Until) {
+		return nil, account.Error{
+			Code:    account.EInvalidTimeRange,
+			Message: "Time range start must be before its end.",
+		}
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultAuditLimit
+	}
+	if f.Limit > maxAuditLimit {
+		f.Limit = maxAuditLimit
+	}
+
+	entries, err := s.db.FindAuditEntries(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("audit entries (user id %s) not found: %w", userID, err)
+	}
+	return entries, nil
+}
+
+// maxGroupNameLength is max number of characters in a group name.
+const maxGroupNameLength = 64
+
+type groupService struct {
+	db account.GroupRepository
+}
+
+// FindGroupByID returns a group by its ID.
+// It returns EInvalidGroupID if the ID is invalid UUID or ENotFound if the group does not exist.
+func (s *groupService) FindGroupByID(ctx context.Context, id string) (*account.Group, error) {
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := s.db.FindGroupByID(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("group (id %s) not found: %w", groupID, err)
+	}
+	return g, nil
+}
+
+// CreateGroup creates a new group and assigns it a random ID.
+// It returns EInvalidGroupName if the name fails validation or
+// EConflict if the name is already in use.
+func (s *groupService) CreateGroup(ctx context.Context, g *account.Group) error {
+	if err := validateGroupName(g.Name); err != nil {
+		return err
+	}
+
+	g.ID = uuid.NewString()
+	if err := s.db.CreateGroup(ctx, g); err != nil {
+		return fmt.Errorf("group (name %s) not created: %w", g.Name, err)
+	}
+	return nil
+}
+
+// RenameGroup changes the name of a group.
+// It returns EInvalidGroupID if the ID is invalid UUID, EInvalidGroupName if the name fails validation,
+// ENotFound if the group does not exist or EConflict if the name is already in use.
+func (s *groupService) RenameGroup(ctx context.Context, id, name string) error {
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return err
+	}
+	if err = validateGroupName(name); err != nil {
+		return err
+	}
+
+	return s.db.Transact(ctx, func(ctx context.Context) error {
+		g, err := s.db.FindGroupByID(ctx, groupID)
+		if err != nil {
+			return fmt.Errorf("group (id %s) not found: %w", groupID, err)
+		}
+		if g.Name == name {
+			return nil
+		}
+		g.Name = name
+		return s.db.UpdateGroup(ctx, g)
+	})
+}
+
+// ListGroups returns groups ordered by name which come after f.After.
+// The next page starts after the name of the last group in the page.
+// By default the number of groups is capped by defaultListLimit.
+func (s *groupService) ListGroups(ctx context.Context, f account.GroupFilter) ([]*account.Group, error) {
+	f.Limit = listLimit(f.Limit)
+	groups, err := s.db.ListGroups(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("groups (after %q) not found: %w", f.After, err)
+	}
+	return groups, nil
+}
+
+// DeleteGroup deletes a group.
+// It returns EInvalidGroupID if the ID is invalid UUID or ENotFound if the group does not exist.
+func (s *groupService) DeleteGroup(ctx context.Context, id string) error {
+	groupID, err := parseGroupID(id)
+	if err != nil {
+		return err
+	}
+
+	if err = s.db.DeleteGroup(ctx, groupID); err != nil {
+		return fmt.Errorf("group (id %s) not deleted: %w", groupID, err)
+	}
+	return nil
+}
+
+// parseGroupID returns the canonical form of the group ID or EInvalidGroupID if it's invalid UUID.
+func parseGroupID(id string) (string, error) {
+	groupID, err := uuid.Parse(id)
+	if err != nil {
+		return "", account.Error{
+			Code:    account.EInvalidGroupID,
+			Message: "Invalid group ID.",
+		}
+	}
+	return groupID.String(), nil
+}
+
+// validateGroupName returns EInvalidGroupName if the name is empty, too long,
+// has surrounding spaces or control characters.
+func validateGroupName(name string) error {
+	invalid := name == "" ||
+		!utf8.ValidString(name) ||
+		utf8.RuneCountInString(name) > maxGroupNameLength ||
+		strings.TrimSpace(name) != name ||
+		strings.IndexFunc(name, unicode.IsControl) != -1
+	if invalid {
+		return account.Error{
+			Code:    account.EInvalidGroupName,
+			Message: fmt.Sprintf("Group name must be 1 to %d characters without control characters and surrounding spaces.", maxGroupNameLength),
+			Field:   "name",
+		}
+	}
+	return nil
 }
@@ -0,0 +1,459 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+	"github.com/marselester/ddd-err/mock"
+)
+
+func TestService_CreateUser_profile_validation(t *testing.T) {
+	tt := []struct {
+		user account.User
+		want account.Error
+	}{
+		{
+			account.User{Username: "bob", Email: "bob"},
+			account.Error{Code: "invalid_email", Message: "Email is invalid.", Field: "email"},
+		},
+		{
+			account.User{Username: "bob", Email: "Bob <bob@example.com>"},
+			account.Error{Code: "invalid_email", Message: "Email is invalid.", Field: "email"},
+		},
+		{
+			account.User{Username: "bob", Email: strings.Repeat("b", 250) + "@example.com"},
+			account.Error{Code: "invalid_email", Message: "Email is invalid.", Field: "email"},
+		},
+		{
+			account.User{Username: "bob", DisplayName: " Bob"},
+			account.Error{Code: "invalid_display_name", Message: "Display name must be at most 64 characters without control characters and surrounding spaces.", Field: "display_name"},
+		},
+		{
+			account.User{Username: "bob", DisplayName: "Bob\n"},
+			account.Error{Code: "invalid_display_name", Message: "Display name must be at most 64 characters without control characters and surrounding spaces.", Field: "display_name"},
+		},
+		{
+			account.User{Username: "bob", Email: "BOB@example.com"},
+			account.Error{Code: "conflict", Message: "Email is already in use. Please choose a different email.", Field: "email"},
+		},
+	}
+
+	s := api.NewService(&mock.UserStorage{
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
+		},
+		EmailInUseFn: func(ctx context.Context, email string) (bool, error) {
+			return strings.EqualFold(email, "bob@example.com"), nil
+		},
+	})
+	for _, tc := range tt {
+		err := s.CreateUser(context.Background(), &tc.user)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("CreateUser(%+v) = %q want %q", tc.user, err, tc.want)
+		}
+	}
+}
+
+func TestService_CreateUser_storageFailure(t *testing.T) {
+	errOutage := errors.New("db connection failed")
+	s := api.NewService(&mock.UserStorage{
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, errOutage
+		},
+	})
+	err := s.CreateUser(context.Background(), &account.User{Username: "bob"})
+	if !errors.Is(err, errOutage) || account.ErrorCode(err) == account.EConflict {
+		t.Errorf("CreateUser() = %v, want storage error not reported as %s", err, account.EConflict)
+	}
+}
+
+func TestService_VerifyEmail(t *testing.T) {
+	users := map[string]*account.User{}
+	db := &mock.UserStorage{
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
+		},
+		CreateUserFn: func(ctx context.Context, user *account.User) error {
+			u := *user
+			users[u.ID] = &u
+			return nil
+		},
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			u := *users[id]
+			return &u, nil
+		},
+		UpdateUserFn: func(ctx context.Context, user *account.User) error {
+			u := *user
+			users[u.ID] = &u
+			return nil
+		},
+	}
+	verifications := map[string]*account.EmailVerification{}
+	verificationDB := &mock.EmailVerificationStorage{
+		CreateEmailVerificationFn: func(ctx context.Context, v *account.EmailVerification) error {
+			verifications[v.TokenHash] = v
+			return nil
+		},
+		FindEmailVerificationFn: func(ctx context.Context, tokenHash string) (*account.EmailVerification, error) {
+			v, ok := verifications[tokenHash]
+			if !ok {
+				return nil, account.Error{Code: account.ENotFound, Message: "Email verification not found."}
+			}
+			return v, nil
+		},
+	}
+	var token string
+	mailer := &mock.MailSender{
+		SendMailFn: func(ctx context.Context, m *account.Mail) error {
+			lines := strings.Split(strings.TrimSpace(m.Body), "\n")
+			token = lines[len(lines)-1]
+			return nil
+		},
+	}
+	s := api.NewService(db, api.WithEmailVerification(verificationDB, mailer))
+
+	bob := account.User{Username: "bob", Email: "bob@example.com"}
+	if err := s.CreateUser(context.Background(), &bob); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	if token == "" {
+		t.Fatal("CreateUser() did not send verification token")
+	}
+	if _, ok := verifications[token]; ok {
+		t.Fatal("CreateUser() stored verification token in plain text")
+	}
+
+	u, err := s.VerifyEmail(context.Background(), token)
+	if err != nil {
+		t.Fatalf("VerifyEmail() failed: %v", err)
+	}
+	if u.ID != bob.ID || !users[bob.ID].EmailVerified {
+		t.Errorf("VerifyEmail() user %+v is not verified", users[bob.ID])
+	}
+
+	tt := []struct {
+		name  string
+		token string
+		setup func()
+		want  account.Error
+	}{
+		{
+			name:  "used token",
+			token: token,
+			want:  account.Error{Code: "invalid_verification_token", Message: "Verification token was already used."},
+		},
+		{
+			name:  "unknown token",
+			token: "123",
+			want:  account.Error{Code: "invalid_verification_token", Message: "Verification token is invalid."},
+		},
+		{
+			name:  "expired token",
+			token: token,
+			setup: func() {
+				for _, v := range verifications {
+					v.UsedAt = time.Time{}
+					v.ExpiresAt = time.Now().Add(-time.Minute)
+				}
+			},
+			want: account.Error{Code: "invalid_verification_token", Message: "Verification token has expired."},
+		},
+		{
+			name:  "changed email",
+			token: token,
+			setup: func() {
+				for _, v := range verifications {
+					v.ExpiresAt = time.Now().Add(time.Minute)
+				}
+				users[bob.ID].Email = "robert@example.com"
+			},
+			want: account.Error{Code: "invalid_verification_token", Message: "Verification token was issued for a different email."},
+		},
+	}
+	for _, tc := range tt {
+		if tc.setup != nil {
+			tc.setup()
+		}
+		_, err = s.VerifyEmail(context.Background(), tc.token)
+		if !errors.Is(err, tc.want) {
+			t.Errorf("%s: VerifyEmail() = %q want %q", tc.name, err, tc.want)
+		}
+	}
+}
+
+func TestService_ChangeUsername(t *testing.T) {
+	const (
+		aliceID = "a1b2c3d4-0000-4000-8000-000000000001"
+		bobID   = "a1b2c3d4-0000-4000-8000-000000000002"
+	)
+	users := map[string]*account.User{
+		aliceID: {ID: aliceID, Username: "alice", Status: account.StatusActive},
+		bobID:   {ID: bobID, Username: "bob", Status: account.StatusActive},
+	}
+	var history []account.UsernameChange
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			u := *users[id]
+			return &u, nil
+		},
+		FindUserByUsernameFn: func(ctx context.Context, username string) (*account.User, error) {
+			for _, u := range users {
+				if u.Username == username {
+					v := *u
+					return &v, nil
+				}
+			}
+			return nil, account.Error{Code: account.ENotFound, Message: "User not found."}
+		},
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			for _, u := range users {
+				if u.Username == username {
+					return true, nil
+				}
+			}
+			return false, nil
+		},
+		UpdateUserFn: func(ctx context.Context, user *account.User) error {
+			u := *user
+			users[u.ID] = &u
+			return nil
+		},
+		CreateUsernameChangeFn: func(ctx context.Context, c *account.UsernameChange) error {
+			history = append(history, *c)
+			return nil
+		},
+		FindUsernameChangeFn: func(ctx context.Context, username string) (*account.UsernameChange, error) {
+			for i := len(history) - 1; i >= 0; i-- {
+				if history[i].Username == username {
+					c := history[i]
+					return &c, nil
+				}
+			}
+			return nil, account.Error{Code: account.ENotFound, Message: "Username change not found."}
+		},
+	}
+	s := api.NewService(db, api.WithUsernameReservation(time.Hour))
+	ctx := context.Background()
+
+	if err := s.ChangeUsername(ctx, aliceID, "bob"); account.ErrorCode(err) != account.EConflict {
+		t.Errorf("ChangeUsername() to a taken username got %v, want conflict", err)
+	}
+	if err := s.ChangeUsername(ctx, aliceID, "alice2"); err != nil {
+		t.Fatalf("ChangeUsername() failed: %v", err)
+	}
+
+	u, err := s.FindUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatalf("FindUserByUsername() by former username failed: %v", err)
+	}
+	if u.ID != aliceID || u.Username != "alice2" {
+		t.Errorf("FindUserByUsername() got %+v, want alice2", u)
+	}
+
+	err = s.CreateUser(ctx, &account.User{Username: "alice"})
+	want := account.Error{
+		Code:    account.EUsernameReserved,
+		Message: "Username was recently released and is reserved. Please choose a different username.",
+		Field:   "username",
+	}
+	if !errors.Is(err, want) {
+		t.Errorf("CreateUser() with a reserved username got %v, want %v", err, want)
+	}
+	if err = s.ChangeUsername(ctx, bobID, "alice"); !errors.Is(err, want) {
+		t.Errorf("ChangeUsername() to a reserved username got %v, want %v", err, want)
+	}
+	// The former owner can claim the username back.
+	if err = s.ChangeUsername(ctx, aliceID, "alice"); err != nil {
+		t.Errorf("ChangeUsername() back to the former username failed: %v", err)
+	}
+}
+
+func TestService_ImportUsers(t *testing.T) {
+	var (
+		imported []*account.User
+		reserved time.Time
+	)
+	s := api.NewUserImportService(&mock.UserStorage{
+		ImportUsersFn: func(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+			imported, reserved = users, reservedSince
+			return nil
+		},
+	}, api.WithUsernameReservation(24*time.Hour))
+
+	users := []*account.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: "bob", DisplayName: "Bob"},
+	}
+	if err := s.ImportUsers(context.Background(), users); err != nil {
+		t.Fatal(err)
+	}
+	if len(imported) != len(users) {
+		t.Fatalf("ImportUsers imported %d users want %d", len(imported), len(users))
+	}
+	for _, u := range imported {
+		if u.ID == "" {
+			t.Errorf("ImportUsers(%q) has no ID", u.Username)
+		}
+		if u.Status != account.StatusActive {
+			t.Errorf("ImportUsers(%q) status = %q want %q", u.Username, u.Status, account.StatusActive)
+		}
+	}
+	if d := time.Since(reserved); d < 24*time.Hour || d > 25*time.Hour {
+		t.Errorf("ImportUsers() reserved usernames released %v ago want 24h", d)
+	}
+}
+
+func TestService_ImportUsers_rejected(t *testing.T) {
+	s := api.NewUserImportService(&mock.UserStorage{
+		ImportUsersFn: func(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+			t.Fatal("ImportUsers reached the storage")
+			return nil
+		},
+	})
+
+	users := []*account.User{
+		{Username: "alice", Email: "alice@example.com"},
+		{Username: ">_<"},
+		{Username: "alice"},
+		{Username: "bob", Email: "ALICE@example.com"},
+		{Username: "carol", Email: "carol"},
+	}
+	err := s.ImportUsers(context.Background(), users)
+	if account.ErrorCode(err) != account.EImportRejected {
+		t.Fatalf("ImportUsers() = %q want %q", err, account.EImportRejected)
+	}
+
+	var importErr *account.ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("ImportUsers() = %q want ImportError", err)
+	}
+	want := []struct {
+		row   int
+		code  string
+		field string
+	}{
+		{2, account.EInvalidUsername, "username"},
+		{3, account.EConflict, "username"},
+		{4, account.EConflict, "email"},
+		{5, account.EInvalidEmail, "email"},
+	}
+	if len(importErr.Rows) != len(want) {
+		t.Fatalf("ImportUsers() rejected %d rows want %d: %v", len(importErr.Rows), len(want), importErr)
+	}
+	for i, w := range want {
+		r := importErr.Rows[i]
+		var e account.Error
+		errors.As(r.Err, &e)
+		if r.Row != w.row || e.Code != w.code || e.Field != w.field {
+			t.Errorf("ImportUsers() row %d = %d %q %q want %d %q %q", i, r.Row, e.Code, e.Field, w.row, w.code, w.field)
+		}
+	}
+}
+
+func TestService_ImportUsers_conflict(t *testing.T) {
+	s := api.NewUserImportService(&mock.UserStorage{
+		ImportUsersFn: func(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+			return &account.ImportError{Rows: []account.ImportRowError{
+				{Row: 2, Err: account.Error{Code: account.EConflict, Field: "username"}},
+			}}
+		},
+	})
+
+	users := []*account.User{
+		{Username: "alice"},
+		{Username: "bob"},
+	}
+	err := s.ImportUsers(context.Background(), users)
+	var e account.Error
+	if !errors.As(err, &e) || e.Code != account.EImportRejected || e.Message != "1 users can't be imported." {
+		t.Fatalf("ImportUsers() = %q want %q", err, account.EImportRejected)
+	}
+	var importErr *account.ImportError
+	if !errors.As(err, &importErr) || len(importErr.Rows) != 1 || importErr.Rows[0].Row != 2 {
+		t.Fatalf("ImportUsers() = %v want row 2 conflict", importErr)
+	}
+}
+
+func TestService_UsernamePolicy(t *testing.T) {
+	policy := api.NewUsernamePolicy("admin")
+	s := api.NewService(&mock.UserStorage{
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
+		},
+	}, api.WithUsernamePolicy(policy))
+
+	want := account.Error{Code: "invalid_username", Message: "Username is not allowed.", Field: "username"}
+	err := s.CreateUser(context.Background(), &account.User{Username: "Admin"})
+	if !errors.Is(err, want) {
+		t.Errorf("CreateUser(Admin) = %q want %q", err, want)
+	}
+
+	policy.SetBlocked("root")
+	if err = s.CreateUser(context.Background(), &account.User{Username: "admin"}); err != nil {
+		t.Errorf("CreateUser(admin) = %q want nil", err)
+	}
+	err = s.ChangeUsername(context.Background(), "7a0c1f39-8c1e-4b6b-9a57-2d6f1bb3a5d1", "root")
+	if !errors.Is(err, want) {
+		t.Errorf("ChangeUsername(root) = %q want %q", err, want)
+	}
+}
+
+func TestGroupService_validation(t *testing.T) {
+	const groupID = "a1b2c3d4-0000-4000-8000-000000000001"
+	invalidName := account.Error{
+		Code:    account.EInvalidGroupName,
+		Message: "Group name must be 1 to 64 characters without control characters and surrounding spaces.",
+		Field:   "name",
+	}
+	invalidID := account.Error{Code: account.EInvalidGroupID, Message: "Invalid group ID."}
+
+	s := api.NewGroupService(&mock.GroupStorage{})
+	ctx := context.Background()
+	for _, name := range []string{"", " sales", "sales\n", strings.Repeat("s", 65)} {
+		if err := s.CreateGroup(ctx, &account.Group{Name: name}); !errors.Is(err, invalidName) {
+			t.Errorf("CreateGroup(%q) = %v, want %v", name, err, invalidName)
+		}
+		if err := s.RenameGroup(ctx, groupID, name); !errors.Is(err, invalidName) {
+			t.Errorf("RenameGroup(%q) = %v, want %v", name, err, invalidName)
+		}
+	}
+	if _, err := s.FindGroupByID(ctx, "sales"); !errors.Is(err, invalidID) {
+		t.Errorf("FindGroupByID() = %v, want %v", err, invalidID)
+	}
+	if err := s.RenameGroup(ctx, "sales", "marketing"); !errors.Is(err, invalidID) {
+		t.Errorf("RenameGroup() = %v, want %v", err, invalidID)
+	}
+	if err := s.DeleteGroup(ctx, "sales"); !errors.Is(err, invalidID) {
+		t.Errorf("DeleteGroup() = %v, want %v", err, invalidID)
+	}
+}
+
+func TestService_ListUsers_limit(t *testing.T) {
+	tt := map[int]int{
+		0:    100,
+		-1:   100,
+		10:   10,
+		5000: 1000,
+	}
+
+	var got int
+	s := api.NewService(&mock.UserStorage{
+		ListUsersFn: func(ctx context.Context, f account.UserFilter) ([]*account.User, error) {
+			got = f.Limit
+			return nil, nil
+		},
+	})
+	for limit, want := range tt {
+		if _, err := s.ListUsers(context.Background(), account.UserFilter{Limit: limit}); err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("ListUsers(limit %d) requested %d users, want %d", limit, got, want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-kit/log"
+	"github.com/gorilla/mux"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	account "github.com/marselester/ddd-err"
+	pb "github.com/marselester/ddd-err/rpc/account"
+)
+
+var (
+	// gatewayMarshaler encodes responses the same way as encodeResponse does,
+	// i.e., with snake_case field names and without empty fields.
+	gatewayMarshaler = protojson.MarshalOptions{UseProtoNames: true}
+	// gatewayUnmarshaler rejects unknown fields of requests, so API client learns about a misspelled field
+	// instead of the field being silently ignored.
+	gatewayUnmarshaler = protojson.UnmarshalOptions{}
+)
+
+// gatewayRoute is an HTTP route of RPC defined by google.api.http option in account.proto.
+type gatewayRoute struct {
+	// method is an HTTP method, e.g., POST.
+	method string
+	// path is a path template with field names as variables, e.g., /v1/users/{id}.
+	path string
+	// body is a field of the request message which is sent as HTTP request body.
+	// It's "*" when the whole message is sent, or empty when the fields come from path and query.
+	body string
+	rpc  protoreflect.MethodDescriptor
+	// handler is a gRPC handler of the unary RPC, it's nil for streaming RPCs.
+	handler func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error)
+}
+
+// gatewayRoutes returns the routes of the gRPC service's RPCs annotated with google.api.http option
+// including their additional bindings.
+// Only the path variables which name the request fields are supported, e.g., {id}.
+func gatewayRoutes(desc *grpc.ServiceDesc) []gatewayRoute {
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(desc.ServiceName))
+	if err != nil {
+		panic(err)
+	}
+	methods := d.(protoreflect.ServiceDescriptor).Methods()
+
+	var routes []gatewayRoute
+	for i := 0; i < methods.Len(); i++ {
+		md := methods.Get(i)
+		rule, ok := proto.GetExtension(md.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+
+		rt := gatewayRoute{rpc: md}
+		for _, m := range desc.Methods {
+			if m.MethodName == string(md.Name()) {
+				rt.handler = m.Handler
+			}
+		}
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+			rt.body = r.Body
+			switch p := r.Pattern.(type) {
+			case *annotations.HttpRule_Get:
+				rt.method, rt.path = http.MethodGet, p.Get
+			case *annotations.HttpRule_Post:
+				rt.method, rt.path = http.MethodPost, p.Post
+			case *annotations.HttpRule_Put:
+				rt.method, rt.path = http.MethodPut, p.Put
+			case *annotations.HttpRule_Patch:
+				rt.method, rt.path = http.MethodPatch, p.Patch
+			case *annotations.HttpRule_Delete:
+				rt.method, rt.path = http.MethodDelete, p.Delete
+			case *annotations.HttpRule_Custom:
+				rt.method, rt.path = p.Custom.Kind, p.Custom.Path
+			default:
+				panic(fmt.Sprintf("%s: HTTP rule has no pattern", md.FullName()))
+			}
+			if strings.ContainsAny(rt.path, "=*:") {
+				panic(fmt.Sprintf("%s: path template %s is not supported", md.FullName(), rt.path))
+			}
+			if rt.body != "" && rt.body != "*" {
+				if fd := md.Input().Fields().ByName(protoreflect.Name(rt.body)); fd == nil || fd.Message() == nil {
+					panic(fmt.Sprintf("%s: body field %q is not a message", md.FullName(), rt.body))
+				}
+			}
+			routes = append(routes, rt)
+		}
+	}
+	return routes
+}
+
+// newGatewayHandler returns HTTP handler which translates HTTP request into the request message of the route's RPC,
+// calls the RPC of the gRPC server srv, and translates its response message back into JSON or protobuf (see negotiate).
+// This way the route is served by the same endpoint as gRPC request, and
+// the error in the response message is sent with the same HTTP status as encodeResponse would send.
+//
+// The request message is decoded from the body, the path variables, and the query parameters
+// unless the whole message is sent in the body.
+// The tenant, claimed actor and request ID are passed to the server as metadata, see populateGRPCRequestContext.
+func newGatewayHandler(rt gatewayRoute, srv interface{}, logger log.Logger) http.Handler {
+	if rt.handler == nil {
+		panic(fmt.Sprintf("%s: streaming RPC can't be served by the gateway", rt.rpc.FullName()))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := metadata.NewIncomingContext(r.Context(), gatewayMetadata(r))
+		dec := func(req interface{}) error {
+			return decodeGatewayReq(rt, r, req.(proto.Message))
+		}
+		resp, err := rt.handler(srv, ctx, dec, nil)
+		if err != nil {
+			logger.Log("err", err)
+			encodeError(ctx, err, w)
+			return
+		}
+		if err = encodeGatewayResp(ctx, w, resp.(proto.Message)); err != nil {
+			logger.Log("err", err)
+		}
+	})
+}
+
+// gatewayMetadata returns the gRPC metadata which is expected by populateGRPCRequestContext.
+// The tenant from /v1/tenants/{tenant_id}/ path takes precedence over X-Tenant-ID header.
+func gatewayMetadata(r *http.Request) metadata.MD {
+	md := metadata.MD{}
+	tenantID := mux.Vars(r)["tenant_id"]
+	if tenantID == "" {
+		tenantID = r.Header.Get("X-Tenant-ID")
+	}
+	if tenantID != "" {
+		md.Set("x-tenant-id", tenantID)
+	}
+	if v := r.Header.Get("X-Actor-ID"); v != "" {
+		md.Set("x-actor-id", v)
+	}
+	if v := r.Header.Get("X-Read-Your-Writes"); v != "" {
+		md.Set("x-read-your-writes", v)
+	}
+	if v := r.Header.Get("X-Request-ID"); v != "" {
+		md.Set("x-request-id", v)
+	}
+	return md
+}
+
+// decodeGatewayReq fills the request message m from HTTP request.
+// The path variables take precedence over the body, e.g., the id in /v1/users/{id}/username.
+// An empty body leaves the message empty.
+//
+// The body which can't be decoded (unknown fields, invalid JSON or UTF-8) is rejected with EMalformedRequest error,
+// and the body which exceeds maxRequestSize with EPayloadTooLarge.
+// Other errors are converted into HTTP response by encodeError as internal.
+func decodeGatewayReq(rt gatewayRoute, r *http.Request, m proto.Message) error {
+	if rt.body != "" {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			return requestBodyError(err)
+		}
+		if err = unmarshalGatewayBody(rt, r, b, m); err != nil {
+			return account.Error{
+				Code:    account.EMalformedRequest,
+				Message: fmt.Sprintf("Request body is not a valid %s message.", rt.rpc.Input().Name()),
+				Inner:   err,
+			}
+		}
+	}
+
+	if rt.body != "*" {
+		for name, values := range r.URL.Query() {
+			if err := setGatewayField(m, name, values); err != nil {
+				return err
+			}
+		}
+	}
+	for name, value := range mux.Vars(r) {
+		if err := setGatewayField(m, name, []string{value}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalGatewayBody decodes HTTP request body b into the request message m, or into its field named by the route.
+// The body is protobuf or JSON depending on the negotiated media type, see negotiate.
+func unmarshalGatewayBody(rt gatewayRoute, r *http.Request, b []byte, m proto.Message) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	unmarshal := gatewayUnmarshaler.Unmarshal
+	if mediaFromContext(r.Context()).request == MediaTypeProtobuf {
+		unmarshal = proto.Unmarshal
+	} else if !utf8.Valid(b) {
+		return errors.New("invalid UTF-8")
+	}
+	if rt.body == "*" {
+		return unmarshal(b, m)
+	}
+	fd := m.ProtoReflect().Descriptor().Fields().ByName(protoreflect.Name(rt.body))
+	return unmarshal(b, m.ProtoReflect().Mutable(fd).Message().Interface())
+}
+
+// setGatewayField sets the field of the message m to the values taken from a path or query, e.g., limit=10.
+// The values are converted the same way as JSON strings, e.g., a timestamp is expected in RFC 3339 format.
+// The unknown fields are ignored, e.g., tenant_id, and
+// the value which can't be converted is rejected with EMalformedRequest error.
+func setGatewayField(m proto.Message, name string, values []string) error {
+	fields := m.ProtoReflect().Descriptor().Fields()
+	fd := fields.ByName(protoreflect.Name(name))
+	if fd == nil {
+		fd = fields.ByJSONName(name)
+	}
+	if fd == nil || fd.IsMap() || len(values) == 0 {
+		return nil
+	}
+	malformed := func(err error) error {
+		return account.Error{
+			Code:    account.EMalformedRequest,
+			Message: fmt.Sprintf("Parameter %s is not valid.", name),
+			Field:   string(fd.Name()),
+			Inner:   err,
+		}
+	}
+
+	vv := make([]interface{}, len(values))
+	for i, v := range values {
+		if !utf8.ValidString(v) {
+			return malformed(errors.New("invalid UTF-8"))
+		}
+		vv[i] = v
+		if fd.Kind() == protoreflect.BoolKind {
+			if b, err := strconv.ParseBool(v); err == nil {
+				vv[i] = b
+			}
+		}
+	}
+	var value interface{} = vv[len(vv)-1]
+	if fd.IsList() {
+		value = vv
+	}
+	b, err := json.Marshal(map[string]interface{}{fd.JSONName(): value})
+	if err != nil {
+		return err
+	}
+
+	field := m.ProtoReflect().New().Interface()
+	if err = gatewayUnmarshaler.Unmarshal(b, field); err != nil {
+		return malformed(err)
+	}
+	m.ProtoReflect().Clear(fd)
+	proto.Merge(m, field)
+	return nil
+}
+
+// encodeGatewayResp converts the response message into HTTP response in the negotiated media type.
+// The error of the response message (see encodeGRPCerror) is sent by encodeError,
+// so the client gets the same status and body as from other HTTP routes.
+func encodeGatewayResp(ctx context.Context, w http.ResponseWriter, m proto.Message) error {
+	msg := m.ProtoReflect()
+	if fd := msg.Descriptor().Fields().ByName("error"); fd != nil && msg.Has(fd) {
+		if e, ok := msg.Get(fd).Message().Interface().(*pb.Error); ok {
+			encodeError(ctx, account.Error{
+				Code:    e.Code,
+				Message: e.Message,
+				Field:   e.Field,
+			}, w)
+			return nil
+		}
+	}
+
+	if mediaFromContext(ctx).response == MediaTypeProtobuf {
+		return writeProtobuf(w, http.StatusOK, m)
+	}
+
+	b, err := gatewayMarshaler.Marshal(m)
+	if err != nil {
+		return err
+	}
+	// The protojson output is unstable by design, e.g., it might have random spaces.
+	var body bytes.Buffer
+	if err = json.Compact(&body, b); err != nil {
+		return err
+	}
+	body.WriteByte('\n')
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	_, err = body.WriteTo(w)
+	return err
+}
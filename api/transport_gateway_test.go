@@ -0,0 +1,139 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+	"github.com/marselester/ddd-err/mock"
+	pb "github.com/marselester/ddd-err/rpc/account"
+)
+
+func TestGateway_ChangeUsername(t *testing.T) {
+	const (
+		userID   = "a1b2c3d4-0000-4000-8000-000000000001"
+		tenantID = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+	)
+	var (
+		gotID, gotUsername, gotActor, gotRequestID, gotTenant string
+		gotReadYourWrites                                     bool
+	)
+	s := &mock.UserService{
+		ChangeUsernameFn: func(ctx context.Context, id, username string) error {
+			gotID, gotUsername = id, username
+			gotActor = account.ClaimedActorFromContext(ctx)
+			gotRequestID = account.RequestIDFromContext(ctx)
+			gotTenant = account.TenantFromContext(ctx)
+			gotReadYourWrites = account.ReadYourWritesFromContext(ctx)
+			return nil
+		},
+	}
+	h := api.NewHTTPHandler(s, log.NewNopLogger(), 100)
+
+	// The user ID in the body is ignored.
+	params := `{"id":"123","username":"bob"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/tenants/"+tenantID+"/users/"+userID+"/username", strings.NewReader(params))
+	req.Header.Set("X-Actor-ID", "admin")
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("X-Read-Your-Writes", "true")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChangeUsername status code: %d, want %d: %s", w.Code, http.StatusOK, w.Body)
+	}
+	if want := "{}\n"; w.Body.String() != want {
+		t.Errorf("ChangeUsername body %q, want %q", w.Body, want)
+	}
+	if gotID != userID || gotUsername != "bob" {
+		t.Errorf("ChangeUsername(%q, %q), want (%q, %q)", gotID, gotUsername, userID, "bob")
+	}
+	if gotActor != "admin" || gotRequestID != "req-1" || gotTenant != tenantID {
+		t.Errorf("ChangeUsername actor %q, request ID %q, tenant %q, want %q, %q, %q", gotActor, gotRequestID, gotTenant, "admin", "req-1", tenantID)
+	}
+	if !gotReadYourWrites {
+		t.Errorf("ChangeUsername didn't read your writes")
+	}
+}
+
+func TestGateway_malformed_path(t *testing.T) {
+	h := api.NewHTTPHandler(&mock.UserService{}, log.NewNopLogger(), 100)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/%FF", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("FindUserByID status code: %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	want := `{"error":{"code":"malformed_request","message":"Parameter id is not valid.","field":"id"}}` + "\n"
+	if w.Body.String() != want {
+		t.Errorf("FindUserByID body %s, want %s", w.Body, want)
+	}
+}
+
+// TestGateway_errors checks that HTTP client gets the same error as gRPC client
+// with the status from the error catalog.
+func TestGateway_errors(t *testing.T) {
+	statuses := make(map[string]int)
+	for _, info := range api.ErrorCatalog() {
+		statuses[info.Code] = info.HTTPStatus
+	}
+
+	const userID = "a1b2c3d4-0000-4000-8000-000000000001"
+	tt := map[string]error{
+		"not found": account.Error{Code: account.ENotFound, Message: "User not found."},
+		"validation": account.Error{
+			Code:    account.EInvalidUsername,
+			Message: "Username is invalid.",
+			Field:   "username",
+		},
+		"concurrent update": account.Error{Code: account.EConcurrentUpdate, Message: "User was changed by another request."},
+		"internal":          errors.New("connection refused"),
+	}
+	for name, serviceErr := range tt {
+		t.Run(name, func(t *testing.T) {
+			s := &mock.UserService{
+				FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+					return nil, serviceErr
+				},
+			}
+
+			grpcResp, err := api.NewGRPCUserServer(s, log.NewNopLogger(), 100).FindUserByID(
+				context.Background(),
+				&pb.FindUserByIDRequest{Id: userID},
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			api.NewHTTPHandler(s, log.NewNopLogger(), 100).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/users/"+userID, nil))
+
+			var httpResp struct {
+				Err account.Error `json:"error"`
+			}
+			if err = json.NewDecoder(w.Body).Decode(&httpResp); err != nil {
+				t.Fatal(err)
+			}
+			want := account.Error{
+				Code:    grpcResp.Error.Code,
+				Message: grpcResp.Error.Message,
+				Field:   grpcResp.Error.Field,
+			}
+			if httpResp.Err != want {
+				t.Errorf("FindUserByID HTTP error %+v, want gRPC error %+v", httpResp.Err, want)
+			}
+			if w.Code != statuses[want.Code] {
+				t.Errorf("FindUserByID status code: %d, want %d", w.Code, statuses[want.Code])
+			}
+		})
+	}
+}
@@ -3,34 +3,145 @@ package api
 import (
 	"context"
 	"errors"
+	"fmt"
+	"io"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/ratelimit"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 	"github.com/go-kit/log"
+	"github.com/google/uuid"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
 
 	account "github.com/marselester/ddd-err"
 	pb "github.com/marselester/ddd-err/rpc/account"
 )
 
+// GRPCServerOption configures a gRPC server.
+type GRPCServerOption func(*grpcServerConfig)
+
+type grpcServerConfig struct {
+	limiter *rate.Limiter
+}
+
+func newGRPCServerConfig(options []GRPCServerOption) grpcServerConfig {
+	var cfg grpcServerConfig
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// WithGRPCRateLimiter throttles the requests with l instead of a limiter created from qps.
+// The limit can be changed while the server is serving requests, see rate.Limiter.SetLimit.
+func WithGRPCRateLimiter(l *rate.Limiter) GRPCServerOption {
+	return func(c *grpcServerConfig) {
+		c.limiter = l
+	}
+}
+
+// RegisterGRPCServer registers the gRPC server srv of the service desc, e.g., pb.UserService_ServiceDesc,
+// the same way as the generated pb.RegisterUserServiceServer does, except that
+// the request messages which can't be decoded (e.g., a string with invalid UTF-8) are rejected
+// with InvalidArgument status instead of Internal one sent by grpc.Server.
+// The request messages larger than grpc.MaxRecvMsgSize are rejected with ResourceExhausted status.
+func RegisterGRPCServer(s grpc.ServiceRegistrar, desc *grpc.ServiceDesc, srv interface{}) {
+	d := *desc
+	d.Methods = make([]grpc.MethodDesc, len(desc.Methods))
+	for i, m := range desc.Methods {
+		handler := m.Handler
+		m.Handler = func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			return handler(srv, ctx, func(req interface{}) error {
+				if err := dec(req); err != nil {
+					return malformedGRPCRequest(req)
+				}
+				return nil
+			}, interceptor)
+		}
+		d.Methods[i] = m
+	}
+	d.Streams = make([]grpc.StreamDesc, len(desc.Streams))
+	for i, sd := range desc.Streams {
+		handler := sd.Handler
+		sd.Handler = func(srv interface{}, stream grpc.ServerStream) error {
+			return handler(srv, &strictServerStream{ServerStream: stream})
+		}
+		d.Streams[i] = sd
+	}
+	s.RegisterService(&d, srv)
+}
+
+// strictServerStream is a server stream which rejects malformed request messages
+// with InvalidArgument status, see RegisterGRPCServer.
+type strictServerStream struct {
+	grpc.ServerStream
+}
+
+// RecvMsg receives a request message m from the client.
+// The message is received as raw bytes and decoded afterwards,
+// because grpc.Server sends Internal status to the client as soon as it fails to decode m.
+// Transport errors and io.EOF are returned as is.
+func (s *strictServerStream) RecvMsg(m interface{}) error {
+	var raw rawMessage
+	if err := s.ServerStream.RecvMsg(&raw); err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(raw.b, m.(proto.Message)); err != nil {
+		return malformedGRPCRequest(m)
+	}
+	return nil
+}
+
+// rawMessage is a message which keeps its wire bytes b undecoded.
+// The proto codec of gRPC passes the bytes to Unmarshal method of such legacy messages
+// instead of parsing them, so receiving rawMessage fails only on transport errors.
+type rawMessage struct {
+	b []byte
+}
+
+func (m *rawMessage) Reset()         { m.b = nil }
+func (m *rawMessage) String() string { return fmt.Sprintf("%x", m.b) }
+func (*rawMessage) ProtoMessage()    {}
+
+// Unmarshal copies b because gRPC reuses its buffer after decoding.
+func (m *rawMessage) Unmarshal(b []byte) error {
+	m.b = append(m.b[:0], b...)
+	return nil
+}
+
+// malformedGRPCRequest returns InvalidArgument status which corresponds to EMalformedRequest error
+// of the request message req.
+func malformedGRPCRequest(req interface{}) error {
+	name := "request"
+	if m, ok := req.(proto.Message); ok {
+		name = string(m.ProtoReflect().Descriptor().Name())
+	}
+	return status.Errorf(codes.InvalidArgument, "Request is not a valid %s message.", name)
+}
+
 // NewGRPCUserServer makes user service available as a gRPC UserServer.
-func NewGRPCUserServer(s account.UserService, logger log.Logger, qps int) pb.UserServiceServer {
+// The claimed actor, tenant and request ID are taken from x-actor-id, x-tenant-id and x-request-id metadata,
+// and x-read-your-writes: true makes the reads observe the preceding writes (see account.ContextWithReadYourWrites).
+// The tenant of the authenticated principal (see account.ContextWithTenant) takes precedence.
+func NewGRPCUserServer(s account.UserService, logger log.Logger, qps int, serverOptions ...GRPCServerOption) pb.UserServiceServer {
 	options := []grpctransport.ServerOption{
 		grpctransport.ServerErrorLogger(logger),
+		grpctransport.ServerBefore(populateGRPCRequestContext),
 	}
-	// limiter throttles requests that exceeded qps requests per second.
-	// For example, when qps is 100, there might be max 100 requests per seconds to
-	// all the API endpoints combined.
-	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(
-		rate.Limit(qps), qps,
-	))
+	limiter := newLimiter(qps, newGRPCServerConfig(serverOptions).limiter)
 
 	srv := userServer{}
 	var ep endpoint.Endpoint
 	{
 		ep = makeFindUserByIDEndpoint(s)
 		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
 		srv.findUserByIDHandler = grpctransport.NewServer(
 			ep,
 			decodeGRPCFindUserByIDReq,
@@ -41,6 +152,7 @@ func NewGRPCUserServer(s account.UserService, logger log.Logger, qps int) pb.Use
 	{
 		ep = makeCreateUserEndpoint(s)
 		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
 		srv.createUserHandler = grpctransport.NewServer(
 			ep,
 			decodeGRPCCreateUserReq,
@@ -48,17 +160,384 @@ func NewGRPCUserServer(s account.UserService, logger log.Logger, qps int) pb.Use
 			options...,
 		)
 	}
+	{
+		ep = makeVerifyEmailEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.verifyEmailHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCVerifyEmailReq,
+			encodeGRPCVerifyEmailResp,
+			options...,
+		)
+	}
+	{
+		ep = makeChangeUserStatusEndpoint(s.SuspendUser)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.suspendUserHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCChangeUserStatusReq,
+			encodeGRPCSuspendUserResp,
+			options...,
+		)
+	}
+	{
+		ep = makeChangeUserStatusEndpoint(s.ReactivateUser)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.reactivateUserHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCChangeUserStatusReq,
+			encodeGRPCReactivateUserResp,
+			options...,
+		)
+	}
+	{
+		ep = makeChangeUserStatusEndpoint(s.LockUser)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.lockUserHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCChangeUserStatusReq,
+			encodeGRPCLockUserResp,
+			options...,
+		)
+	}
+	{
+		ep = makeChangeUserStatusEndpoint(s.DeleteUser)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.deleteUserHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCChangeUserStatusReq,
+			encodeGRPCDeleteUserResp,
+			options...,
+		)
+	}
+	{
+		ep = makeChangeUsernameEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.changeUsernameHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCChangeUsernameReq,
+			encodeGRPCChangeUsernameResp,
+			options...,
+		)
+	}
+	{
+		ep = makeFindUserByUsernameEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.findUserByUsernameHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCFindUserByUsernameReq,
+			encodeGRPCFindUserByUsernameResp,
+			options...,
+		)
+	}
+	{
+		ep = makeListUsersEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.listUsersHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCListUsersReq,
+			encodeGRPCListUsersResp,
+			options...,
+		)
+	}
 	return &srv
 }
 
 // userServer is gRPC server that implements protobuf UserServer interface.
 // It's like HTTP multiplexer.
 type userServer struct {
-	findUserByIDHandler grpctransport.Handler
-	createUserHandler   grpctransport.Handler
+	findUserByIDHandler   grpctransport.Handler
+	createUserHandler     grpctransport.Handler
+	suspendUserHandler    grpctransport.Handler
+	reactivateUserHandler grpctransport.Handler
+	lockUserHandler       grpctransport.Handler
+	deleteUserHandler     grpctransport.Handler
+	verifyEmailHandler    grpctransport.Handler
+
+	changeUsernameHandler     grpctransport.Handler
+	findUserByUsernameHandler grpctransport.Handler
+	listUsersHandler          grpctransport.Handler
 	pb.UnimplementedUserServiceServer
 }
 
+// NewGRPCGroupServer makes group service available as a gRPC GroupServer.
+// The tenant and request ID are taken from metadata similar to NewGRPCUserServer.
+func NewGRPCGroupServer(s account.GroupService, logger log.Logger, qps int, serverOptions ...GRPCServerOption) pb.GroupServiceServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorLogger(logger),
+		grpctransport.ServerBefore(populateGRPCRequestContext),
+	}
+	limiter := newLimiter(qps, newGRPCServerConfig(serverOptions).limiter)
+
+	srv := groupServer{}
+	var ep endpoint.Endpoint
+	{
+		ep = makeFindGroupByIDEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.findGroupByIDHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCFindGroupByIDReq,
+			encodeGRPCFindGroupByIDResp,
+			options...,
+		)
+	}
+	{
+		ep = makeCreateGroupEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.createGroupHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCCreateGroupReq,
+			encodeGRPCCreateGroupResp,
+			options...,
+		)
+	}
+	{
+		ep = makeRenameGroupEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.renameGroupHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCRenameGroupReq,
+			encodeGRPCRenameGroupResp,
+			options...,
+		)
+	}
+	{
+		ep = makeListGroupsEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.listGroupsHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCListGroupsReq,
+			encodeGRPCListGroupsResp,
+			options...,
+		)
+	}
+	{
+		ep = makeDeleteGroupEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.deleteGroupHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCDeleteGroupReq,
+			encodeGRPCDeleteGroupResp,
+			options...,
+		)
+	}
+	return &srv
+}
+
+// groupServer is gRPC server that implements protobuf GroupServer interface.
+type groupServer struct {
+	findGroupByIDHandler grpctransport.Handler
+	createGroupHandler   grpctransport.Handler
+	renameGroupHandler   grpctransport.Handler
+	listGroupsHandler    grpctransport.Handler
+	deleteGroupHandler   grpctransport.Handler
+	pb.UnimplementedGroupServiceServer
+}
+
+// FindGroupByID looks up a group by ID.
+func (srv *groupServer) FindGroupByID(ctx context.Context, req *pb.FindGroupByIDRequest) (*pb.FindGroupByIDResponse, error) {
+	_, resp, err := srv.findGroupByIDHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.FindGroupByIDResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.FindGroupByIDResponse), nil
+}
+
+// CreateGroup creates a group.
+func (srv *groupServer) CreateGroup(ctx context.Context, req *pb.CreateGroupRequest) (*pb.CreateGroupResponse, error) {
+	_, resp, err := srv.createGroupHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.CreateGroupResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.CreateGroupResponse), nil
+}
+
+// RenameGroup changes the name of a group.
+func (srv *groupServer) RenameGroup(ctx context.Context, req *pb.RenameGroupRequest) (*pb.RenameGroupResponse, error) {
+	_, resp, err := srv.renameGroupHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.RenameGroupResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.RenameGroupResponse), nil
+}
+
+// ListGroups lists groups ordered by name.
+func (srv *groupServer) ListGroups(ctx context.Context, req *pb.ListGroupsRequest) (*pb.ListGroupsResponse, error) {
+	_, resp, err := srv.listGroupsHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.ListGroupsResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.ListGroupsResponse), nil
+}
+
+// DeleteGroup deletes a group.
+func (srv *groupServer) DeleteGroup(ctx context.Context, req *pb.DeleteGroupRequest) (*pb.DeleteGroupResponse, error) {
+	_, resp, err := srv.deleteGroupHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.DeleteGroupResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.DeleteGroupResponse), nil
+}
+
+// NewGRPCAuditServer makes audit service available as a gRPC AuditServer.
+func NewGRPCAuditServer(s account.AuditService, logger log.Logger, qps int, serverOptions ...GRPCServerOption) pb.AuditServiceServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorLogger(logger),
+		grpctransport.ServerBefore(populateGRPCRequestContext),
+	}
+	limiter := newLimiter(qps, newGRPCServerConfig(serverOptions).limiter)
+
+	srv := auditServer{}
+	var ep endpoint.Endpoint
+	{
+		ep = makeFindAuditEntriesEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.findAuditEntriesHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCFindAuditEntriesReq,
+			encodeGRPCFindAuditEntriesResp,
+			options...,
+		)
+	}
+	return &srv
+}
+
+// auditServer is gRPC server that implements protobuf AuditServer interface.
+type auditServer struct {
+	findAuditEntriesHandler grpctransport.Handler
+	pb.UnimplementedAuditServiceServer
+}
+
+// FindAuditEntries looks up the audit trail of a user.
+func (srv *auditServer) FindAuditEntries(ctx context.Context, req *pb.FindAuditEntriesRequest) (*pb.FindAuditEntriesResponse, error) {
+	_, resp, err := srv.findAuditEntriesHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.FindAuditEntriesResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.FindAuditEntriesResponse), nil
+}
+
+// NewGRPCUserImportServer makes user import service available as a gRPC UserImportServer.
+// The claimed actor, tenant and request ID are taken from metadata similar to NewGRPCUserServer.
+func NewGRPCUserImportServer(s account.UserImportService, logger log.Logger, qps int, serverOptions ...GRPCServerOption) pb.UserImportServiceServer {
+	options := []grpctransport.ServerOption{
+		grpctransport.ServerErrorLogger(logger),
+		grpctransport.ServerBefore(populateGRPCRequestContext),
+	}
+	limiter := newLimiter(qps, newGRPCServerConfig(serverOptions).limiter)
+
+	srv := userImportServer{}
+	var ep endpoint.Endpoint
+	{
+		ep = makeImportUsersEndpoint(s)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		srv.importUsersHandler = grpctransport.NewServer(
+			ep,
+			decodeGRPCImportUsersReq,
+			encodeGRPCImportUsersResp,
+			options...,
+		)
+	}
+	return &srv
+}
+
+// userImportServer is gRPC server that implements protobuf UserImportServer interface.
+type userImportServer struct {
+	importUsersHandler grpctransport.Handler
+	pb.UnimplementedUserImportServiceServer
+}
+
+// ImportUsers receives batches of users until the client closes the stream and imports them at once.
+// The receiving stops as soon as there are more users than can be imported at once.
+func (srv *userImportServer) ImportUsers(stream pb.UserImportService_ImportUsersServer) error {
+	var req pb.ImportUsersRequest
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		req.Users = append(req.Users, batch.Users...)
+		if len(req.Users) > maxImportUsers {
+			return stream.SendAndClose(&pb.ImportUsersResponse{
+				Error: encodeGRPCerror(account.Error{
+					Code:    account.EImportRejected,
+					Message: fmt.Sprintf("At most %d users can be imported at once.", maxImportUsers),
+				}),
+			})
+		}
+	}
+
+	_, resp, err := srv.importUsersHandler.ServeGRPC(stream.Context(), &req)
+	if err != nil {
+		return stream.SendAndClose(&pb.ImportUsersResponse{
+			Error: encodeGRPCerror(err),
+		})
+	}
+	return stream.SendAndClose(resp.(*pb.ImportUsersResponse))
+}
+
+// VerifyEmail confirms the email of a user.
+func (srv *userServer) VerifyEmail(ctx context.Context, req *pb.VerifyEmailRequest) (*pb.VerifyEmailResponse, error) {
+	_, resp, err := srv.verifyEmailHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.VerifyEmailResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.VerifyEmailResponse), nil
+}
+
+// populateGRPCRequestContext stores the claimed actor, requested tenant, request ID and
+// read-your-writes flag in the request context.
+// The request ID is generated if the client did not provide it.
+func populateGRPCRequestContext(ctx context.Context, md metadata.MD) context.Context {
+	if v := md.Get("x-tenant-id"); len(v) > 0 {
+		ctx = contextWithRequestedTenant(ctx, v[0])
+	}
+	if v := md.Get("x-actor-id"); len(v) > 0 {
+		ctx = account.ContextWithClaimedActor(ctx, v[0])
+	}
+	if v := md.Get("x-read-your-writes"); len(v) > 0 && v[0] == "true" {
+		ctx = account.ContextWithReadYourWrites(ctx)
+	}
+	requestID := uuid.NewString()
+	if v := md.Get("x-request-id"); len(v) > 0 {
+		requestID = v[0]
+	}
+	return account.ContextWithRequestID(ctx, requestID)
+}
+
 // FindUserByID looks up a user by ID.
 func (srv *userServer) FindUserByID(ctx context.Context, req *pb.FindUserByIDRequest) (*pb.FindUserByIDResponse, error) {
 	_, resp, err := srv.findUserByIDHandler.ServeGRPC(ctx, req)
@@ -81,6 +560,83 @@ func (srv *userServer) CreateUser(ctx context.Context, req *pb.CreateUserRequest
 	return resp.(*pb.CreateUserResponse), nil
 }
 
+// SuspendUser temporarily bans a user.
+func (srv *userServer) SuspendUser(ctx context.Context, req *pb.SuspendUserRequest) (*pb.SuspendUserResponse, error) {
+	_, resp, err := srv.suspendUserHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.SuspendUserResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.SuspendUserResponse), nil
+}
+
+// ReactivateUser lifts a suspension or a lock of a user.
+func (srv *userServer) ReactivateUser(ctx context.Context, req *pb.ReactivateUserRequest) (*pb.ReactivateUserResponse, error) {
+	_, resp, err := srv.reactivateUserHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.ReactivateUserResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.ReactivateUserResponse), nil
+}
+
+// LockUser locks a user account.
+func (srv *userServer) LockUser(ctx context.Context, req *pb.LockUserRequest) (*pb.LockUserResponse, error) {
+	_, resp, err := srv.lockUserHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.LockUserResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.LockUserResponse), nil
+}
+
+// DeleteUser deletes a user account.
+func (srv *userServer) DeleteUser(ctx context.Context, req *pb.DeleteUserRequest) (*pb.DeleteUserResponse, error) {
+	_, resp, err := srv.deleteUserHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.DeleteUserResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.DeleteUserResponse), nil
+}
+
+// ChangeUsername renames a user.
+func (srv *userServer) ChangeUsername(ctx context.Context, req *pb.ChangeUsernameRequest) (*pb.ChangeUsernameResponse, error) {
+	_, resp, err := srv.changeUsernameHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.ChangeUsernameResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.ChangeUsernameResponse), nil
+}
+
+// FindUserByUsername looks up a user by current or former username.
+func (srv *userServer) FindUserByUsername(ctx context.Context, req *pb.FindUserByUsernameRequest) (*pb.FindUserByUsernameResponse, error) {
+	_, resp, err := srv.findUserByUsernameHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.FindUserByUsernameResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.FindUserByUsernameResponse), nil
+}
+
+// ListUsers lists users ordered by username.
+func (srv *userServer) ListUsers(ctx context.Context, req *pb.ListUsersRequest) (*pb.ListUsersResponse, error) {
+	_, resp, err := srv.listUsersHandler.ServeGRPC(ctx, req)
+	if err != nil {
+		return &pb.ListUsersResponse{
+			Error: encodeGRPCerror(err),
+		}, nil
+	}
+	return resp.(*pb.ListUsersResponse), nil
+}
+
 // decodeGRPCFindUserByIDReq is a transport/grpc.DecodeRequestFunc that converts a
 // gRPC FindUserByIDReq request to a user-domain FindUserByIDReq request.
 func decodeGRPCFindUserByIDReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
@@ -93,9 +649,13 @@ func decodeGRPCFindUserByIDReq(_ context.Context, grpcReq interface{}) (interfac
 func encodeGRPCFindUserByIDResp(_ context.Context, response interface{}) (interface{}, error) {
 	resp := response.(FindUserByIDResp)
 	return &pb.FindUserByIDResponse{
-		Id:       resp.ID,
-		Username: resp.Username,
-		Error:    encodeGRPCerror(resp.Err),
+		Id:            resp.ID,
+		Username:      resp.Username,
+		Status:        resp.Status,
+		Email:         resp.Email,
+		DisplayName:   resp.DisplayName,
+		EmailVerified: resp.EmailVerified,
+		Error:         encodeGRPCerror(resp.Err),
 	}, nil
 }
 
@@ -103,7 +663,11 @@ func encodeGRPCFindUserByIDResp(_ context.Context, response interface{}) (interf
 // gRPC CreateUserReq request to a user-domain CreateUserReq request.
 func decodeGRPCCreateUserReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
 	req := grpcReq.(*pb.CreateUserRequest)
-	return CreateUserReq{Username: req.Username}, nil
+	return CreateUserReq{
+		Username:    req.Username,
+		Email:       req.Email,
+		DisplayName: req.DisplayName,
+	}, nil
 }
 
 // encodeGRPCCreateUserResp is a transport/grpc.EncodeResponseFunc that converts a
@@ -112,6 +676,328 @@ func encodeGRPCCreateUserResp(_ context.Context, response interface{}) (interfac
 	resp := response.(CreateUserResp)
 	return &pb.CreateUserResponse{
 		Error: encodeGRPCerror(resp.Err),
+		Id:    resp.ID,
+	}, nil
+}
+
+// decodeGRPCVerifyEmailReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC VerifyEmailReq request to a user-domain VerifyEmailReq request.
+func decodeGRPCVerifyEmailReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.VerifyEmailRequest)
+	return VerifyEmailReq{Token: req.Token}, nil
+}
+
+// encodeGRPCVerifyEmailResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain VerifyEmailResp response to a gRPC VerifyEmailResp response.
+func encodeGRPCVerifyEmailResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(VerifyEmailResp)
+	return &pb.VerifyEmailResponse{
+		UserId: resp.UserID,
+		Error:  encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// decodeGRPCChangeUsernameReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC ChangeUsernameReq request to a user-domain ChangeUsernameReq request.
+func decodeGRPCChangeUsernameReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ChangeUsernameRequest)
+	return ChangeUsernameReq{
+		ID:       req.Id,
+		Username: req.Username,
+	}, nil
+}
+
+// encodeGRPCChangeUsernameResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ChangeUsernameResp response to a gRPC ChangeUsernameResp response.
+func encodeGRPCChangeUsernameResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ChangeUsernameResp)
+	return &pb.ChangeUsernameResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// decodeGRPCFindUserByUsernameReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC FindUserByUsernameReq request to a user-domain FindUserByUsernameReq request.
+func decodeGRPCFindUserByUsernameReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.FindUserByUsernameRequest)
+	return FindUserByUsernameReq{Username: req.Username}, nil
+}
+
+// encodeGRPCFindUserByUsernameResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain FindUserByUsernameResp response to a gRPC FindUserByUsernameResp response.
+func encodeGRPCFindUserByUsernameResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(FindUserByUsernameResp)
+	return &pb.FindUserByUsernameResponse{
+		Id:            resp.ID,
+		Username:      resp.Username,
+		Status:        resp.Status,
+		Email:         resp.Email,
+		DisplayName:   resp.DisplayName,
+		EmailVerified: resp.EmailVerified,
+		Moved:         resp.Moved,
+		Error:         encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// decodeGRPCListUsersReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC ListUsersReq request to a user-domain ListUsersReq request.
+func decodeGRPCListUsersReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListUsersRequest)
+	return ListUsersReq{
+		After: req.After,
+		Limit: int(req.Limit),
+	}, nil
+}
+
+// encodeGRPCListUsersResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ListUsersResp response to a gRPC ListUsersResp response.
+func encodeGRPCListUsersResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ListUsersResp)
+	grpcResp := pb.ListUsersResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}
+	for _, u := range resp.Users {
+		grpcResp.Users = append(grpcResp.Users, &pb.User{
+			Id:            u.ID,
+			Username:      u.Username,
+			Status:        u.Status,
+			Email:         u.Email,
+			DisplayName:   u.DisplayName,
+			EmailVerified: u.EmailVerified,
+		})
+	}
+	return &grpcResp, nil
+}
+
+// decodeGRPCChangeUserStatusReq is a transport/grpc.DecodeRequestFunc that converts
+// gRPC requests of SuspendUser, ReactivateUser, LockUser and DeleteUser
+// to a user-domain ChangeUserStatusReq request.
+func decodeGRPCChangeUserStatusReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	switch req := grpcReq.(type) {
+	case *pb.SuspendUserRequest:
+		return ChangeUserStatusReq{ID: req.Id}, nil
+	case *pb.ReactivateUserRequest:
+		return ChangeUserStatusReq{ID: req.Id}, nil
+	case *pb.LockUserRequest:
+		return ChangeUserStatusReq{ID: req.Id}, nil
+	case *pb.DeleteUserRequest:
+		return ChangeUserStatusReq{ID: req.Id}, nil
+	}
+	return nil, fmt.Errorf("unexpected user status request %T", grpcReq)
+}
+
+// encodeGRPCSuspendUserResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ChangeUserStatusResp response to a gRPC SuspendUserResp response.
+func encodeGRPCSuspendUserResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ChangeUserStatusResp)
+	return &pb.SuspendUserResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// encodeGRPCReactivateUserResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ChangeUserStatusResp response to a gRPC ReactivateUserResp response.
+func encodeGRPCReactivateUserResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ChangeUserStatusResp)
+	return &pb.ReactivateUserResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// encodeGRPCLockUserResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ChangeUserStatusResp response to a gRPC LockUserResp response.
+func encodeGRPCLockUserResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ChangeUserStatusResp)
+	return &pb.LockUserResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// encodeGRPCDeleteUserResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ChangeUserStatusResp response to a gRPC DeleteUserResp response.
+func encodeGRPCDeleteUserResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ChangeUserStatusResp)
+	return &pb.DeleteUserResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// decodeGRPCImportUsersReq is a transport/grpc.DecodeRequestFunc that converts
+// users received from gRPC ImportUsers stream to a user-domain ImportUsersReq request.
+func decodeGRPCImportUsersReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ImportUsersRequest)
+	r := ImportUsersReq{
+		Users: make([]CreateUserReq, len(req.Users)),
+	}
+	for i, u := range req.Users {
+		r.Users[i] = CreateUserReq{
+			Username:    u.Username,
+			Email:       u.Email,
+			DisplayName: u.DisplayName,
+		}
+	}
+	return r, nil
+}
+
+// encodeGRPCImportUsersResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ImportUsersResp response to a gRPC ImportUsersResp response.
+func encodeGRPCImportUsersResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ImportUsersResp)
+	grpcResp := pb.ImportUsersResponse{
+		UserIds: resp.UserIDs,
+		Error:   encodeGRPCerror(resp.Err),
+	}
+	for _, r := range resp.Rows {
+		grpcResp.Rows = append(grpcResp.Rows, &pb.ImportUsersResponse_RowError{
+			Row:   int32(r.Row),
+			Error: encodeGRPCerror(r.Err),
+		})
+	}
+	return &grpcResp, nil
+}
+
+// decodeGRPCFindAuditEntriesReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC FindAuditEntriesReq request to a user-domain FindAuditEntriesReq request.
+func decodeGRPCFindAuditEntriesReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.FindAuditEntriesRequest)
+	r := FindAuditEntriesReq{
+		UserID: req.UserId,
+		Limit:  int(req.Limit),
+	}
+	// Unset timestamps are left zero, otherwise they would become Unix epoch.
+	if req.Since != nil {
+		r.Since = req.Since.AsTime()
+	}
+	if req.Until != nil {
+		r.Until = req.Until.AsTime()
+	}
+	return r, nil
+}
+
+// encodeGRPCFindAuditEntriesResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain FindAuditEntriesResp response to a gRPC FindAuditEntriesResp response.
+func encodeGRPCFindAuditEntriesResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(FindAuditEntriesResp)
+	grpcResp := pb.FindAuditEntriesResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}
+	for _, e := range resp.Entries {
+		grpcEntry := pb.AuditEntry{
+			Id:           e.ID,
+			CreatedAt:    timestamppb.New(e.CreatedAt),
+			Actor:        e.Actor,
+			ClaimedActor: e.ClaimedActor,
+			Action:       e.Action,
+			TargetId:     e.TargetID,
+			RequestId:    e.RequestID,
+			Outcome:      e.Outcome,
+			ErrorCode:    e.ErrorCode,
+		}
+		for _, c := range e.Changes {
+			grpcEntry.Changes = append(grpcEntry.Changes, &pb.AuditEntry_Change{
+				Field:  c.Field,
+				Before: c.Before,
+				After:  c.After,
+			})
+		}
+		grpcResp.Entries = append(grpcResp.Entries, &grpcEntry)
+	}
+	return &grpcResp, nil
+}
+
+// decodeGRPCFindGroupByIDReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC FindGroupByIDReq request to a user-domain FindGroupByIDReq request.
+func decodeGRPCFindGroupByIDReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.FindGroupByIDRequest)
+	return FindGroupByIDReq{ID: req.Id}, nil
+}
+
+// encodeGRPCFindGroupByIDResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain FindGroupByIDResp response to a gRPC FindGroupByIDResp response.
+func encodeGRPCFindGroupByIDResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(FindGroupByIDResp)
+	return &pb.FindGroupByIDResponse{
+		Id:    resp.ID,
+		Name:  resp.Name,
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// decodeGRPCCreateGroupReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC CreateGroupReq request to a user-domain CreateGroupReq request.
+func decodeGRPCCreateGroupReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.CreateGroupRequest)
+	return CreateGroupReq{Name: req.Name}, nil
+}
+
+// encodeGRPCCreateGroupResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain CreateGroupResp response to a gRPC CreateGroupResp response.
+func encodeGRPCCreateGroupResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(CreateGroupResp)
+	return &pb.CreateGroupResponse{
+		Error: encodeGRPCerror(resp.Err),
+		Id:    resp.ID,
+	}, nil
+}
+
+// decodeGRPCRenameGroupReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC RenameGroupReq request to a user-domain RenameGroupReq request.
+func decodeGRPCRenameGroupReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.RenameGroupRequest)
+	return RenameGroupReq{
+		ID:   req.Id,
+		Name: req.Name,
+	}, nil
+}
+
+// encodeGRPCRenameGroupResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain RenameGroupResp response to a gRPC RenameGroupResp response.
+func encodeGRPCRenameGroupResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(RenameGroupResp)
+	return &pb.RenameGroupResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}, nil
+}
+
+// decodeGRPCListGroupsReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC ListGroupsReq request to a user-domain ListGroupsReq request.
+func decodeGRPCListGroupsReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.ListGroupsRequest)
+	return ListGroupsReq{
+		After: req.After,
+		Limit: int(req.Limit),
+	}, nil
+}
+
+// encodeGRPCListGroupsResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain ListGroupsResp response to a gRPC ListGroupsResp response.
+func encodeGRPCListGroupsResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(ListGroupsResp)
+	grpcResp := pb.ListGroupsResponse{
+		Error: encodeGRPCerror(resp.Err),
+	}
+	for _, g := range resp.Groups {
+		grpcResp.Groups = append(grpcResp.Groups, &pb.Group{
+			Id:   g.ID,
+			Name: g.Name,
+		})
+	}
+	return &grpcResp, nil
+}
+
+// decodeGRPCDeleteGroupReq is a transport/grpc.DecodeRequestFunc that converts a
+// gRPC DeleteGroupReq request to a user-domain DeleteGroupReq request.
+func decodeGRPCDeleteGroupReq(_ context.Context, grpcReq interface{}) (interface{}, error) {
+	req := grpcReq.(*pb.DeleteGroupRequest)
+	return DeleteGroupReq{ID: req.Id}, nil
+}
+
+// encodeGRPCDeleteGroupResp is a transport/grpc.EncodeResponseFunc that converts a
+// user-domain DeleteGroupResp response to a gRPC DeleteGroupResp response.
+func encodeGRPCDeleteGroupResp(_ context.Context, response interface{}) (interface{}, error) {
+	resp := response.(DeleteGroupResp)
+	return &pb.DeleteGroupResponse{
+		Error: encodeGRPCerror(resp.Err),
 	}, nil
 }
 
@@ -140,5 +1026,6 @@ func encodeGRPCerror(err error) *pb.Error {
 	return &pb.Error{
 		Code:    accErr.Code,
 		Message: accErr.Message,
+		Field:   accErr.Field,
 	}
 }
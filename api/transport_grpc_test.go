@@ -7,9 +7,12 @@ import (
 	"fmt"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/grpc/test/bufconn"
 
 	account "github.com/marselester/ddd-err"
@@ -101,7 +104,7 @@ func TestGRPCUserService_FindUserByID_invalid_user_id(t *testing.T) {
 
 func TestGRPCUserService_FindUserByID_notfound(t *testing.T) {
 	svc := api.NewService(&mock.UserStorage{
-		FindUserByIDFn: func(ctx context.Context, dbtx *sql.Tx, id string) (*account.User, error) {
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
 			return nil, account.Error{
 				Code:    account.ENotFound,
 				Message: "User not found.",
@@ -153,19 +156,19 @@ func TestGRPCUserService_CreateUser_validation(t *testing.T) {
 	}{
 		{
 			account.User{},
-			account.Error{Code: "invalid_username", Message: "Username is invalid."},
+			account.Error{Code: "invalid_username", Message: "Username is invalid.", Field: "username"},
 		},
 		{
 			account.User{Username: " "},
-			account.Error{Code: "invalid_username", Message: "Username is invalid."},
+			account.Error{Code: "invalid_username", Message: "Username is invalid.", Field: "username"},
 		},
 		{
 			account.User{Username: ">_<"},
-			account.Error{Code: "invalid_username", Message: "Username is invalid."},
+			account.Error{Code: "invalid_username", Message: "Username is invalid.", Field: "username"},
 		},
 		{
 			account.User{Username: "bob123"},
-			account.Error{Code: "conflict", Message: "Username is already in use. Please choose a different username."},
+			account.Error{Code: "conflict", Message: "Username is already in use. Please choose a different username.", Field: "username"},
 		},
 	}
 
@@ -203,8 +206,8 @@ func TestGRPCUserService_CreateUser_validation(t *testing.T) {
 
 func TestGRPCUserService_CreateUser_dberror(t *testing.T) {
 	svc := api.NewService(&mock.UserStorage{
-		UsernameInUseFn: func(ctx context.Context, username string) bool {
-			return false
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
 		},
 		CreateUserFn: func(ctx context.Context, user *account.User) error {
 			return fmt.Errorf("UserStorage.CreateUser: %w", errors.New("db connection failed"))
@@ -245,3 +248,189 @@ func TestGRPCUserService_CreateUser_dberror(t *testing.T) {
 		t.Errorf("CreateUser(%+v) = %q want %q", user, err, want)
 	}
 }
+
+func TestGRPCUserService_ChangeUserStatus(t *testing.T) {
+	svc := api.NewService(&mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return &account.User{ID: id, Username: "bob", Status: account.StatusDeleted}, nil
+		},
+	})
+	usrSrv := api.NewGRPCUserServer(svc, log.NewNopLogger(), 100)
+
+	grpcListener := bufconn.Listen(1024)
+	grpcserver := grpc.NewServer()
+	pb.RegisterUserServiceServer(grpcserver, usrSrv)
+	go func() {
+		if err := grpcserver.Serve(grpcListener); err != nil {
+			t.Errorf("grpc serve failed: %v", err)
+		}
+	}()
+	defer grpcserver.Stop()
+
+	conn, err := grpc.Dial("", grpc.WithInsecure(), grpc.WithContextDialer(
+		func(context.Context, string) (net.Conn, error) {
+			return grpcListener.Dial()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("grpc dial failed: %v", err)
+	}
+	defer conn.Close()
+	svc = apiclient.NewGRPCUserClient(conn)
+
+	userID := "87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef"
+	user, err := svc.FindUserByID(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("FindUserByID(%q) failed: %v", userID, err)
+	}
+	if user.Status != account.StatusDeleted {
+		t.Errorf("FindUserByID(%q) got %q status, want deleted", userID, user.Status)
+	}
+
+	err = svc.ReactivateUser(context.Background(), userID)
+	want := account.Error{
+		Code:    "invalid_state_transition",
+		Message: "Cannot reactivate a user account that is deleted.",
+	}
+	if !errors.Is(err, want) {
+		t.Errorf("ReactivateUser(%q) = %q want %q", userID, err, want)
+	}
+}
+
+func TestGRPCUserImportService_ImportUsers(t *testing.T) {
+	var imported int
+	svc := api.NewUserImportService(&mock.UserStorage{
+		ImportUsersFn: func(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+			imported = len(users)
+			return nil
+		},
+	})
+	importSrv := api.NewGRPCUserImportServer(svc, log.NewNopLogger(), 100)
+
+	grpcListener := bufconn.Listen(1024 * 1024)
+	grpcserver := grpc.NewServer()
+	pb.RegisterUserImportServiceServer(grpcserver, importSrv)
+	go func() {
+		if err := grpcserver.Serve(grpcListener); err != nil {
+			t.Errorf("grpc serve failed: %v", err)
+		}
+	}()
+	defer grpcserver.Stop()
+
+	conn, err := grpc.Dial("", grpc.WithInsecure(), grpc.WithContextDialer(
+		func(context.Context, string) (net.Conn, error) {
+			return grpcListener.Dial()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("grpc dial failed: %v", err)
+	}
+	defer conn.Close()
+	svc = apiclient.NewGRPCUserImportClient(conn)
+
+	// The users are sent in a few batches.
+	users := make([]*account.User, 2500)
+	for i := range users {
+		users[i] = &account.User{Username: fmt.Sprintf("user%d", i)}
+	}
+	if err = svc.ImportUsers(context.Background(), users); err != nil {
+		t.Fatal(err)
+	}
+	if imported != len(users) {
+		t.Fatalf("ImportUsers imported %d users want %d", imported, len(users))
+	}
+	for _, u := range users {
+		if u.ID == "" {
+			t.Fatalf("ImportUsers(%q) has no ID", u.Username)
+		}
+	}
+
+	users = []*account.User{
+		{Username: "alice"},
+		{Username: "alice"},
+	}
+	err = svc.ImportUsers(context.Background(), users)
+	if account.ErrorCode(err) != account.EImportRejected {
+		t.Fatalf("ImportUsers() = %q want %q", err, account.EImportRejected)
+	}
+	var importErr *account.ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("ImportUsers() = %q want ImportError", err)
+	}
+	want := account.Error{Code: account.EConflict, Message: "Username is repeated in the import.", Field: "username"}
+	if len(importErr.Rows) != 1 || importErr.Rows[0].Row != 2 || !errors.Is(importErr.Rows[0].Err, want) {
+		t.Fatalf("ImportUsers() rows = %v want row 2 %q", importErr, want)
+	}
+}
+
+// rawCodec sends the bytes as is, so the test can send a message which protobuf client wouldn't marshal.
+type rawCodec struct{}
+
+func (rawCodec) Marshal(v interface{}) ([]byte, error)      { return v.([]byte), nil }
+func (rawCodec) Unmarshal(data []byte, v interface{}) error { return nil }
+func (rawCodec) Name() string                               { return "proto" }
+
+func TestRegisterGRPCServer_malformed(t *testing.T) {
+	grpcListener := bufconn.Listen(1024)
+	grpcserver := grpc.NewServer()
+	api.RegisterGRPCServer(grpcserver, &pb.UserService_ServiceDesc, api.NewGRPCUserServer(&mock.UserService{}, log.NewNopLogger(), 100))
+	api.RegisterGRPCServer(grpcserver, &pb.UserImportService_ServiceDesc, api.NewGRPCUserImportServer(&mock.UserImportService{}, log.NewNopLogger(), 100))
+	go func() {
+		if err := grpcserver.Serve(grpcListener); err != nil {
+			t.Errorf("grpc serve failed: %v", err)
+		}
+	}()
+	defer grpcserver.Stop()
+
+	conn, err := grpc.Dial("", grpc.WithInsecure(), grpc.WithContextDialer(
+		func(context.Context, string) (net.Conn, error) {
+			return grpcListener.Dial()
+		}),
+	)
+	if err != nil {
+		t.Fatalf("grpc dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	// The username field has invalid UTF-8.
+	msg := []byte{0x0a, 0x02, 0xff, 0xfe}
+	err = conn.Invoke(context.Background(), "/ddd_err.account.UserService/CreateUser", msg, nil, grpc.ForceCodec(rawCodec{}))
+	want := status.Error(codes.InvalidArgument, "Request is not a valid CreateUserRequest message.")
+	if status.Code(err) != codes.InvalidArgument || err.Error() != want.Error() {
+		t.Errorf("CreateUser() = %q want %q", err, want)
+	}
+
+	importUsers := func(msg []byte) error {
+		stream, err := conn.NewStream(
+			context.Background(),
+			&pb.UserImportService_ServiceDesc.Streams[0],
+			"/ddd_err.account.UserImportService/ImportUsers",
+			grpc.ForceCodec(rawCodec{}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = stream.SendMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+		if err = stream.CloseSend(); err != nil {
+			t.Fatal(err)
+		}
+		return stream.RecvMsg(nil)
+	}
+	want = status.Error(codes.InvalidArgument, "Request is not a valid ImportUsersRequest message.")
+	tt := map[string][]byte{
+		// The users field has a user with invalid UTF-8 username.
+		"invalid UTF-8": {0x0a, 0x04, 0x0a, 0x02, 0xff, 0xfe},
+		// The users field is shorter than its length.
+		"truncated": {0x0a, 0x04, 0x0a},
+		// The field number 0 is reserved.
+		"invalid field number": {0x00, 0x01},
+	}
+	for name, msg := range tt {
+		err = importUsers(msg)
+		if status.Code(err) != codes.InvalidArgument || err.Error() != want.Error() {
+			t.Errorf("ImportUsers() %s = %q want %q", name, err, want)
+		}
+	}
+}
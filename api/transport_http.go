@@ -1,77 +1,379 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/go-kit/kit/endpoint"
 	"github.com/go-kit/kit/ratelimit"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/go-kit/log"
+	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"golang.org/x/time/rate"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
 
 	account "github.com/marselester/ddd-err"
+	pb "github.com/marselester/ddd-err/rpc/account"
 )
 
+const (
+	// maxRequestSize is max size of HTTP request body in bytes.
+	maxRequestSize = 1 << 20
+	// maxImportRequestSize is max size of HTTP request body of ImportUsers in bytes,
+	// it fits maxImportUsers users.
+	maxImportRequestSize = 32 << 20
+)
+
+// HandlerOption configures optional API endpoints of the HTTP handler.
+type HandlerOption func(*handlerConfig)
+
+type handlerConfig struct {
+	audit    account.AuditService
+	importer account.UserImportService
+	groups   account.GroupService
+	limiter  *rate.Limiter
+}
+
+// WithAuditService exposes the audit trail of user accounts at /v1/users/{user_id}/audit.
+func WithAuditService(s account.AuditService) HandlerOption {
+	return func(c *handlerConfig) {
+		c.audit = s
+	}
+}
+
+// WithUserImportService exposes bulk import of users at /v1/user-imports.
+// The users are sent as newline delimited JSON objects (NDJSON),
+// e.g., {"username":"alice","email":"alice@example.com"}.
+func WithUserImportService(s account.UserImportService) HandlerOption {
+	return func(c *handlerConfig) {
+		c.importer = s
+	}
+}
+
+// WithGroupService exposes management of customer groups at /v1/groups.
+func WithGroupService(s account.GroupService) HandlerOption {
+	return func(c *handlerConfig) {
+		c.groups = s
+	}
+}
+
+// WithRateLimiter throttles the requests with l instead of a limiter created from qps.
+// The limit can be changed while the handler is serving requests, see rate.Limiter.SetLimit.
+func WithRateLimiter(l *rate.Limiter) HandlerOption {
+	return func(c *handlerConfig) {
+		c.limiter = l
+	}
+}
+
 // NewHTTPHandler attaches service API endpoints to HTTP routes in REST-style fashion.
-func NewHTTPHandler(s account.UserService, logger log.Logger, qps int) http.Handler {
+// The routes are defined by google.api.http options of RPCs in account.proto,
+// so a new annotated RPC gets its route without changes here, see newGatewayHandler.
+// The request ID is taken from X-Request-ID header, and X-Actor-ID header is recorded in the audit trail
+// as a claimed actor, since only the authenticated principal (see account.ContextWithActor) is trusted.
+// X-Read-Your-Writes: true header makes the reads observe the preceding writes (see account.ContextWithReadYourWrites).
+//
+// Every route is also available under /v1/tenants/{tenant_id}/ prefix, e.g.,
+// /v1/tenants/{tenant_id}/users/{id}, otherwise the tenant is taken from X-Tenant-ID header.
+// The tenant of the authenticated principal (see account.ContextWithTenant) takes precedence.
+//
+// The requests and responses are JSON or protobuf messages of account.proto (see MediaTypeProtobuf)
+// according to Content-Type and Accept headers.
+// The errors are sent in the error field of the RPC's response message when API client accepts protobuf.
+//
+// OpenAPI 3 document of the routes is served at /openapi.json.
+func NewHTTPHandler(s account.UserService, logger log.Logger, qps int, handlerOptions ...HandlerOption) http.Handler {
+	var cfg handlerConfig
+	for _, opt := range handlerOptions {
+		opt(&cfg)
+	}
+
 	r := mux.NewRouter()
+	// handle registers h on both tenant-agnostic path such as /v1/users and
+	// tenant-scoped /v1/tenants/{tenant_id}/users.
+	handle := func(method, path string, h http.Handler) {
+		r.Methods(method).Path(path).Handler(h)
+		r.Methods(method).Path("/v1/tenants/{tenant_id}" + strings.TrimPrefix(path, "/v1")).Handler(h)
+	}
+
+	// handleRPCs registers the routes of RPCs defined by google.api.http options in account.proto.
+	// The routes are served by the gateway which calls the gRPC server srv
+	// unless the RPC has a dedicated handler, e.g., to redirect API client.
+	handleRPCs := func(desc *grpc.ServiceDesc, srv interface{}, handlers map[protoreflect.Name]http.Handler) {
+		for _, rt := range gatewayRoutes(desc) {
+			h, ok := handlers[rt.rpc.Name()]
+			if !ok {
+				h = limitRequestBody(newGatewayHandler(rt, srv, logger), maxRequestSize)
+			}
+			handle(rt.method, rt.path, negotiate(rt.rpc, h))
+		}
+	}
 
 	options := []httptransport.ServerOption{
 		httptransport.ServerErrorLogger(logger),
 		httptransport.ServerErrorEncoder(encodeError),
+		httptransport.ServerBefore(populateRequestContext),
+	}
+	// The gateway and the dedicated handlers share the limiter.
+	if cfg.limiter == nil {
+		cfg.limiter = rate.NewLimiter(rate.Limit(qps), qps)
 	}
-	// limiter throttles requests that exceeded qps requests per second.
-	// For example, when qps is 100, there might be max 100 requests per seconds to
-	// all the API endpoints combined.
-	limiter := ratelimit.NewErroringLimiter(rate.NewLimiter(
-		rate.Limit(qps), qps,
-	))
+	limiter := newLimiter(qps, cfg.limiter)
 
 	var ep endpoint.Endpoint
 	{
-		ep = makeCreateUserEndpoint(s)
+		ep = makeFindUserByUsernameEndpoint(s)
 		ep = limiter(ep)
-		r.Methods("Post").Path("/v1/users").Handler(httptransport.NewServer(
-			ep,
-			decodeCreateUserReq,
-			encodeResponse,
-			options...,
-		))
+		ep = tenantMiddleware(ep)
+		handleRPCs(
+			&pb.UserService_ServiceDesc,
+			NewGRPCUserServer(s, logger, qps, WithGRPCRateLimiter(cfg.limiter)),
+			map[protoreflect.Name]http.Handler{
+				"FindUserByUsername": httptransport.NewServer(
+					ep,
+					decodeFindUserByUsernameReq,
+					encodeFindUserByUsernameResp,
+					append(options, httptransport.ServerBefore(httptransport.PopulateRequestContext))...,
+				),
+			},
+		)
 	}
-	{
-		ep = makeFindUserByIDEndpoint(s)
+	if cfg.audit != nil {
+		ep = makeFindAuditEntriesEndpoint(cfg.audit)
+		ep = limiter(ep)
+		ep = tenantMiddleware(ep)
+		handleRPCs(
+			&pb.AuditService_ServiceDesc,
+			NewGRPCAuditServer(cfg.audit, logger, qps, WithGRPCRateLimiter(cfg.limiter)),
+			map[protoreflect.Name]http.Handler{
+				"FindAuditEntries": httptransport.NewServer(
+					ep,
+					decodeFindAuditEntriesReq,
+					encodeNegotiatedResp(encodeResponse, encodeGRPCFindAuditEntriesResp),
+					options...,
+				),
+			},
+		)
+	}
+	if cfg.importer != nil {
+		ep = makeImportUsersEndpoint(cfg.importer)
 		ep = limiter(ep)
-		r.Methods("Get").Path("/v1/users/{user_id}").Handler(httptransport.NewServer(
-			ep,
-			decodeFindUserByIDReq,
-			encodeResponse,
-			options...,
-		))
+		ep = tenantMiddleware(ep)
+		handleRPCs(
+			&pb.UserImportService_ServiceDesc,
+			NewGRPCUserImportServer(cfg.importer, logger, qps, WithGRPCRateLimiter(cfg.limiter)),
+			map[protoreflect.Name]http.Handler{
+				"ImportUsers": limitRequestBody(
+					httptransport.NewServer(
+						ep,
+						decodeImportUsersReq,
+						encodeNegotiatedResp(encodeImportUsersResp, encodeGRPCImportUsersResp),
+						options...,
+					),
+					maxImportRequestSize,
+				),
+			},
+		)
+	}
+	if cfg.groups != nil {
+		handleRPCs(
+			&pb.GroupService_ServiceDesc,
+			NewGRPCGroupServer(cfg.groups, logger, qps, WithGRPCRateLimiter(cfg.limiter)),
+			nil,
+		)
 	}
+
+	doc, err := json.Marshal(newOpenAPIDocument(cfg))
+	if err != nil {
+		panic(err)
+	}
+	r.Methods("Get").Path("/openapi.json").HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(doc)
+	})
 	return r
 }
 
-// decodeCreateUserReq converts HTTP request into service-domain request object CreateUserReq.
-// Its error (e.g., json) is converted into HTTP response by encodeError.
-func decodeCreateUserReq(_ context.Context, r *http.Request) (interface{}, error) {
-	var req CreateUserReq
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+// limitRequestBody makes reading HTTP request body fail when it exceeds n bytes,
+// see requestBodyError.
+func limitRequestBody(h http.Handler, n int64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, n)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// requestBodyError converts an error of reading HTTP request body into EPayloadTooLarge error
+// if the body exceeds the limit, see limitRequestBody.
+// Other errors such as a closed connection are returned as is.
+func requestBodyError(err error) error {
+	var maxErr *http.MaxBytesError
+	if !errors.As(err, &maxErr) {
+		return err
+	}
+	return account.Error{
+		Code:    account.EPayloadTooLarge,
+		Message: fmt.Sprintf("Request body must not exceed %d bytes.", maxErr.Limit),
+		Inner:   err,
+	}
+}
+
+// populateRequestContext stores the claimed actor, requested tenant, request ID and
+// read-your-writes flag in the request context.
+// The request ID is generated if the client did not provide it.
+func populateRequestContext(ctx context.Context, r *http.Request) context.Context {
+	tenantID := mux.Vars(r)["tenant_id"]
+	if tenantID == "" {
+		tenantID = r.Header.Get("X-Tenant-ID")
 	}
+	ctx = contextWithRequestedTenant(ctx, tenantID)
+
+	if actor := r.Header.Get("X-Actor-ID"); actor != "" {
+		ctx = account.ContextWithClaimedActor(ctx, actor)
+	}
+	if r.Header.Get("X-Read-Your-Writes") == "true" {
+		ctx = account.ContextWithReadYourWrites(ctx)
+	}
+	requestID := r.Header.Get("X-Request-ID")
+	if requestID == "" {
+		requestID = uuid.NewString()
+	}
+	return account.ContextWithRequestID(ctx, requestID)
+}
+
+// decodeFindUserByUsernameReq converts HTTP request into service-domain request object FindUserByUsernameReq.
+func decodeFindUserByUsernameReq(_ context.Context, r *http.Request) (interface{}, error) {
+	req := FindUserByUsernameReq{Username: mux.Vars(r)["username"]}
 	return req, nil
 }
 
-// decodeFindUserByIDReq converts HTTP request into service-domain request object FindUserByIDReq.
-func decodeFindUserByIDReq(_ context.Context, r *http.Request) (interface{}, error) {
-	vars := mux.Vars(r)
-	req := FindUserByIDReq{ID: vars["user_id"]}
+// decodeFindAuditEntriesReq converts HTTP request into service-domain request object FindAuditEntriesReq.
+// The time range is set by since and until query parameters in RFC 3339 format.
+func decodeFindAuditEntriesReq(_ context.Context, r *http.Request) (interface{}, error) {
+	req := FindAuditEntriesReq{UserID: mux.Vars(r)["user_id"]}
+	q := r.URL.Query()
+
+	var err error
+	if v := q.Get("since"); v != "" {
+		if req.Since, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, account.Error{
+				Code:    account.EInvalidTimeRange,
+				Message: "Time range start must be in RFC 3339 format.",
+				Inner:   err,
+			}
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if req.Until, err = time.Parse(time.RFC3339, v); err != nil {
+			return nil, account.Error{
+				Code:    account.EInvalidTimeRange,
+				Message: "Time range end must be in RFC 3339 format.",
+				Inner:   err,
+			}
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if req.Limit, err = strconv.Atoi(v); err != nil {
+			return nil, account.Error{
+				Code:    account.EInvalidLimit,
+				Message: "Limit must be an integer.",
+				Inner:   err,
+			}
+		}
+	}
 	return req, nil
 }
 
+// decodeImportUsersReq converts a stream of NDJSON objects into service-domain request object ImportUsersReq.
+// The objects are decoded as they arrive, e.g., in a chunked request body, and
+// the decoding stops as soon as there are more users than can be imported at once.
+// A row which can't be decoded (unknown fields, invalid JSON or UTF-8) is rejected with EMalformedRequest error.
+// The users can be also sent in protobuf ImportUsersRequest message.
+func decodeImportUsersReq(ctx context.Context, r *http.Request) (interface{}, error) {
+	if mediaFromContext(ctx).request == MediaTypeProtobuf {
+		return decodeProtobufImportUsersReq(ctx, r)
+	}
+
+	var req ImportUsersReq
+	dec := json.NewDecoder(r.Body)
+	for row := 1; ; row++ {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			return req, nil
+		}
+		var u CreateUserReq
+		if err == nil {
+			err = decodeStrictJSON(raw, &u)
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, requestBodyError(err)
+		}
+		if err != nil {
+			return nil, account.Error{
+				Code:    account.EMalformedRequest,
+				Message: fmt.Sprintf("Row %d is not a valid JSON object.", row),
+				Inner:   err,
+			}
+		}
+		if row > maxImportUsers {
+			return nil, account.Error{
+				Code:    account.EImportRejected,
+				Message: fmt.Sprintf("At most %d users can be imported at once.", maxImportUsers),
+			}
+		}
+		req.Users = append(req.Users, u)
+	}
+}
+
+// decodeProtobufImportUsersReq converts protobuf ImportUsersRequest message into
+// service-domain request object ImportUsersReq.
+func decodeProtobufImportUsersReq(ctx context.Context, r *http.Request) (interface{}, error) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, requestBodyError(err)
+	}
+	var req pb.ImportUsersRequest
+	if err = proto.Unmarshal(b, &req); err != nil {
+		return nil, account.Error{
+			Code:    account.EMalformedRequest,
+			Message: "Request body is not a valid ImportUsersRequest message.",
+			Inner:   err,
+		}
+	}
+	if len(req.Users) > maxImportUsers {
+		return nil, account.Error{
+			Code:    account.EImportRejected,
+			Message: fmt.Sprintf("At most %d users can be imported at once.", maxImportUsers),
+		}
+	}
+	return decodeGRPCImportUsersReq(ctx, &req)
+}
+
+// decodeStrictJSON decodes JSON object b into v rejecting unknown fields and invalid UTF-8,
+// which encoding/json would silently replace with U+FFFD.
+func decodeStrictJSON(b []byte, v interface{}) error {
+	if !utf8.Valid(b) {
+		return errors.New("invalid UTF-8")
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
 // encodeResponse converts any service-domain response object, such as CreateUserResp,
 // into HTTP response. Its error (e.g., json) is converted into HTTP response by encodeError.
 // A service returns Error (business-logic error) that is shown to API client as is.
@@ -81,35 +383,86 @@ func encodeResponse(_ context.Context, w http.ResponseWriter, response interface
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
 	if resp, ok := response.(endpoint.Failer); ok && resp.Failed() != nil {
-		err := resp.Failed()
-		var accErr account.Error
-		if !errors.As(err, &accErr) {
-			accErr = account.Error{
-				Code:    account.EInternal,
-				Message: "An internal error has occurred.",
-			}
-		}
+		accErr := publicError(resp.Failed())
 		response = struct {
 			Err account.Error `json:"error"`
 		}{accErr}
 
-		switch accErr.Code {
-		case account.ENotFound, account.EInvalidUserID:
-			w.WriteHeader(http.StatusNotFound)
-		case account.EInternal:
-			w.WriteHeader(http.StatusInternalServerError)
-		default:
-			w.WriteHeader(http.StatusBadRequest)
+		w.WriteHeader(httpStatus(accErr.Code))
+	}
+
+	return json.NewEncoder(w).Encode(response)
+}
+
+// encodeImportUsersResp converts ImportUsersResp into HTTP response.
+// When the import is rejected, the response lists errors of the rejected users, e.g.,
+// {"error":{"code":"import_rejected",...},"rows":[{"row":2,"error":{"code":"invalid_username",...}}]}.
+func encodeImportUsersResp(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(ImportUsersResp)
+	if resp.Err == nil {
+		return encodeResponse(ctx, w, response)
+	}
+
+	type rowError struct {
+		Row int           `json:"row"`
+		Err account.Error `json:"error"`
+	}
+	errResp := struct {
+		Err  account.Error `json:"error"`
+		Rows []rowError    `json:"rows,omitempty"`
+	}{Err: publicError(resp.Err)}
+	for _, r := range resp.Rows {
+		errResp.Rows = append(errResp.Rows, rowError{Row: r.Row, Err: publicError(r.Err)})
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(httpStatus(errResp.Err.Code))
+	return json.NewEncoder(w).Encode(errResp)
+}
+
+// publicError returns the domain error to show to API clients.
+// Other errors are hidden behind EInternal error.
+func publicError(err error) account.Error {
+	var accErr account.Error
+	if !errors.As(err, &accErr) {
+		accErr = account.Error{
+			Code:    account.EInternal,
+			Message: "An internal error has occurred.",
 		}
 	}
+	return accErr
+}
+
+// encodeFindUserByUsernameResp redirects API client to the user resource
+// when the requested username is a former username of the user, e.g.,
+// /v1/usernames/alice is redirected to /v1/users/{id}.
+// The redirect is temporary because the username can be claimed by another user later.
+func encodeFindUserByUsernameResp(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+	resp := response.(FindUserByUsernameResp)
+	if !resp.Moved {
+		return encodeNegotiatedResp(encodeResponse, encodeGRPCFindUserByUsernameResp)(ctx, w, response)
+	}
 
+	// The request path is /v1/usernames/{username} or /v1/tenants/{tenant_id}/usernames/{username}.
+	reqPath, _ := ctx.Value(httptransport.ContextKeyRequestPath).(string)
+	w.Header().Set("Location", path.Join(path.Dir(path.Dir(reqPath)), "users", resp.ID))
+	if mediaFromContext(ctx).response == MediaTypeProtobuf {
+		m, err := encodeGRPCFindUserByUsernameResp(ctx, response)
+		if err != nil {
+			return err
+		}
+		return writeProtobuf(w, http.StatusTemporaryRedirect, m.(proto.Message))
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusTemporaryRedirect)
 	return json.NewEncoder(w).Encode(response)
 }
 
 // encodeError converts errors returned by endpoint.Endpoint, its middleware (e.g., ratelimit),
-// request decoder/response encoder (JSON serialization errors, e.g., EOF) into HTTP response.
-// Business logic errors are not sent here.
-func encodeError(_ context.Context, err error, w http.ResponseWriter) {
+// request decoder/response encoder (JSON serialization errors) into HTTP response.
+// Business logic errors are not sent here, though decoders return domain errors
+// when request parameters are invalid or the body is malformed, e.g., EMalformedRequest.
+func encodeError(ctx context.Context, err error, w http.ResponseWriter) {
 	errResp := struct {
 		Err account.Error `json:"error"`
 	}{account.Error{
@@ -117,15 +470,21 @@ func encodeError(_ context.Context, err error, w http.ResponseWriter) {
 		Message: "An internal error has occurred.",
 	}}
 
-	if errors.Is(err, ratelimit.ErrLimited) {
-		w.WriteHeader(http.StatusTooManyRequests)
+	var accErr account.Error
+	switch {
+	case errors.Is(err, ratelimit.ErrLimited):
 		errResp.Err = account.Error{
 			Code:    account.ERateLimit,
 			Message: "API rate limit exceeded.",
 		}
-	} else {
-		w.WriteHeader(http.StatusInternalServerError)
+	case errors.As(err, &accErr):
+		errResp.Err = accErr
+	}
+	if encodeProtobufError(ctx, errResp.Err, w) {
+		return
 	}
 
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(httpStatus(errResp.Err.Code))
 	json.NewEncoder(w).Encode(&errResp)
 }
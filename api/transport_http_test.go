@@ -75,7 +75,7 @@ func TestUserService_FindUserByID_invalid_user_id(t *testing.T) {
 
 func TestUserService_FindUserByID_notfound(t *testing.T) {
 	db := &mock.UserStorage{
-		FindUserByIDFn: func(ctx context.Context, dbtx *sql.Tx, id string) (*account.User, error) {
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
 			return nil, account.Error{
 				Code:    account.ENotFound,
 				Message: "User not found.",
@@ -116,27 +116,27 @@ func TestUserService_CreateUser_validation(t *testing.T) {
 		{
 			params:     `{}`,
 			statusCode: http.StatusBadRequest,
-			want:       `{"error":{"code":"invalid_username","message":"Username is invalid."}}` + "\n",
+			want:       `{"error":{"code":"invalid_username","message":"Username is invalid.","field":"username"}}` + "\n",
 		},
 		{
 			params:     `{"username": ""}`,
 			statusCode: http.StatusBadRequest,
-			want:       `{"error":{"code":"invalid_username","message":"Username is invalid."}}` + "\n",
+			want:       `{"error":{"code":"invalid_username","message":"Username is invalid.","field":"username"}}` + "\n",
 		},
 		{
 			params:     `{"username": " "}`,
 			statusCode: http.StatusBadRequest,
-			want:       `{"error":{"code":"invalid_username","message":"Username is invalid."}}` + "\n",
+			want:       `{"error":{"code":"invalid_username","message":"Username is invalid.","field":"username"}}` + "\n",
 		},
 		{
 			params:     `{"username": ">_<"}`,
 			statusCode: http.StatusBadRequest,
-			want:       `{"error":{"code":"invalid_username","message":"Username is invalid."}}` + "\n",
+			want:       `{"error":{"code":"invalid_username","message":"Username is invalid.","field":"username"}}` + "\n",
 		},
 		{
 			params:     `{"username": "bob123"}`,
 			statusCode: http.StatusBadRequest,
-			want:       `{"error":{"code":"conflict","message":"Username is already in use. Please choose a different username."}}` + "\n",
+			want:       `{"error":{"code":"conflict","message":"Username is already in use. Please choose a different username.","field":"username"}}` + "\n",
 		},
 	}
 
@@ -165,10 +165,70 @@ func TestUserService_CreateUser_validation(t *testing.T) {
 	}
 }
 
+func TestUserService_CreateUser_malformed(t *testing.T) {
+	tt := map[string]struct {
+		params     string
+		statusCode int
+		want       string
+	}{
+		"invalid json": {
+			params:     `{"username":`,
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"malformed_request","message":"Request body is not a valid CreateUserRequest message."}}` + "\n",
+		},
+		"unknown field": {
+			params:     `{"username":"bob","nickname":"bobby"}`,
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"malformed_request","message":"Request body is not a valid CreateUserRequest message."}}` + "\n",
+		},
+		"invalid utf-8": {
+			params:     "{\"username\":\"bob\xff\"}",
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"malformed_request","message":"Request body is not a valid CreateUserRequest message."}}` + "\n",
+		},
+		"too large": {
+			params:     `{"username":"` + strings.Repeat("b", 1<<20) + `"}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+			want:       `{"error":{"code":"payload_too_large","message":"Request body must not exceed 1048576 bytes."}}` + "\n",
+		},
+	}
+
+	s := &mock.UserService{
+		CreateUserFn: func(ctx context.Context, u *account.User) error {
+			t.Errorf("CreateUser(%q) must not be called", u.Username)
+			return nil
+		},
+	}
+	h := api.NewHTTPHandler(s, log.NewNopLogger(), 100)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			resp, err := http.Post(srv.URL+"/v1/users", api.MediaTypeJSON, strings.NewReader(tc.params))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tc.statusCode {
+				t.Fatalf("CreateUser status code: %d, want %d", resp.StatusCode, tc.statusCode)
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tc.want {
+				t.Fatalf("CreateUser body %s, want %s", body, tc.want)
+			}
+		})
+	}
+}
+
 func TestUserService_CreateUser_dberror(t *testing.T) {
 	s := api.NewService(&mock.UserStorage{
-		UsernameInUseFn: func(ctx context.Context, username string) bool {
-			return false
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return false, nil
 		},
 		CreateUserFn: func(ctx context.Context, user *account.User) error {
 			return fmt.Errorf("UserStorage.CreateUser: %w", errors.New("db connection failed"))
@@ -197,3 +257,331 @@ func TestUserService_CreateUser_dberror(t *testing.T) {
 		t.Fatalf("CreateUser body %s, want %s", body, want)
 	}
 }
+
+func TestUserService_FindAuditEntries(t *testing.T) {
+	audit := &mock.AuditStorage{
+		FindAuditEntriesFn: func(ctx context.Context, f account.AuditFilter) ([]*account.AuditEntry, error) {
+			return []*account.AuditEntry{
+				{
+					ID:        "1",
+					CreatedAt: f.Since,
+					Actor:     "admin",
+					Action:    "CreateUser",
+					TargetID:  f.UserID,
+					Changes:   []account.Change{{Field: "username", After: "bob"}},
+					RequestID: "req-1",
+					Outcome:   account.AuditSuccess,
+				},
+			}, nil
+		},
+	}
+	h := api.NewHTTPHandler(
+		api.NewService(nil), log.NewNopLogger(), 100,
+		api.WithAuditService(api.NewAuditService(audit)),
+	)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tt := []struct {
+		query      string
+		statusCode int
+		want       string
+	}{
+		{
+			query:      "?since=2023-01-02T03:04:05Z&until=2023-01-03T00:00:00Z",
+			statusCode: http.StatusOK,
+			want:       `{"entries":[{"id":"1","created_at":"2023-01-02T03:04:05Z","actor":"admin","action":"CreateUser","target_id":"87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef","changes":[{"field":"username","before":"","after":"bob"}],"request_id":"req-1","outcome":"success"}]}` + "\n",
+		},
+		{
+			query:      "?since=yesterday",
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"invalid_time_range","message":"Time range start must be in RFC 3339 format."}}` + "\n",
+		},
+		{
+			query:      "?since=2023-01-03T00:00:00Z&until=2023-01-02T00:00:00Z",
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"invalid_time_range","message":"Time range start must be before its end."}}` + "\n",
+		},
+		{
+			query:      "?limit=ten",
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"invalid_limit","message":"Limit must be an integer."}}` + "\n",
+		},
+	}
+	for _, tc := range tt {
+		resp, err := http.Get(srv.URL + "/v1/users/87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef/audit" + tc.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.statusCode {
+			t.Fatalf("FindAuditEntries(%s) status code: %d, want %d", tc.query, resp.StatusCode, tc.statusCode)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tc.want {
+			t.Fatalf("FindAuditEntries(%s) body %s, want %s", tc.query, body, tc.want)
+		}
+	}
+}
+
+func TestUserService_ChangeUserStatus(t *testing.T) {
+	var updated *account.User
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return &account.User{ID: id, Username: "bob", Status: account.StatusSuspended}, nil
+		},
+		UpdateUserFn: func(ctx context.Context, user *account.User) error {
+			updated = user
+			return nil
+		},
+	}
+	s := api.NewService(db)
+	h := api.NewHTTPHandler(s, log.NewNopLogger(), 100)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tt := []struct {
+		method     string
+		path       string
+		statusCode int
+		want       string
+		wantStatus string
+	}{
+		{
+			method:     http.MethodPost,
+			path:       "/v1/users/87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef/suspend",
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"invalid_state_transition","message":"Cannot suspend a user account that is suspended."}}` + "\n",
+		},
+		{
+			method:     http.MethodPost,
+			path:       "/v1/users/87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef/reactivate",
+			statusCode: http.StatusOK,
+			want:       "{}\n",
+			wantStatus: account.StatusActive,
+		},
+		{
+			method:     http.MethodDelete,
+			path:       "/v1/users/87553f14-4c0f-4bd8-8be1-1b6ff5bd8eef",
+			statusCode: http.StatusOK,
+			want:       "{}\n",
+			wantStatus: account.StatusDeleted,
+		},
+		{
+			method:     http.MethodPost,
+			path:       "/v1/users/123/lock",
+			statusCode: http.StatusNotFound,
+			want:       `{"error":{"code":"invalid_user_id","message":"Invalid user ID."}}` + "\n",
+		},
+	}
+	for _, tc := range tt {
+		updated = nil
+		req, err := http.NewRequest(tc.method, srv.URL+tc.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.statusCode {
+			t.Fatalf("%s %s status code: %d, want %d", tc.method, tc.path, resp.StatusCode, tc.statusCode)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != tc.want {
+			t.Fatalf("%s %s body %s, want %s", tc.method, tc.path, body, tc.want)
+		}
+
+		if tc.wantStatus == "" {
+			if updated != nil {
+				t.Errorf("%s %s updated user %+v", tc.method, tc.path, updated)
+			}
+			continue
+		}
+		if updated == nil || updated.Status != tc.wantStatus {
+			t.Errorf("%s %s updated user %+v, want %s status", tc.method, tc.path, updated, tc.wantStatus)
+		}
+	}
+}
+
+func TestUserService_tenant(t *testing.T) {
+	const (
+		userID        = "a1b2c3d4-0000-4000-8000-000000000001"
+		tenantID      = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+		otherTenantID = "5d1c0a57-4d7e-4b4b-9a43-7c2f3f0e6c02"
+	)
+	var gotTenant string
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			gotTenant = account.TenantFromContext(ctx)
+			return &account.User{ID: id, Username: "bob", Status: account.StatusActive}, nil
+		},
+	}
+	s := api.NewService(db)
+	h := api.NewHTTPHandler(s, log.NewNopLogger(), 100)
+
+	tt := map[string]struct {
+		path          string
+		header        string
+		authenticated string
+		wantStatus    int
+		wantTenant    string
+		wantBody      string
+	}{
+		"path": {
+			path:       "/v1/tenants/" + tenantID + "/users/" + userID,
+			wantStatus: http.StatusOK,
+			wantTenant: tenantID,
+		},
+		"header": {
+			path:       "/v1/users/" + userID,
+			header:     tenantID,
+			wantStatus: http.StatusOK,
+			wantTenant: tenantID,
+		},
+		"authenticated": {
+			path:          "/v1/users/" + userID,
+			authenticated: tenantID,
+			wantStatus:    http.StatusOK,
+			wantTenant:    tenantID,
+		},
+		"invalid": {
+			path:       "/v1/users/" + userID,
+			header:     "123",
+			wantStatus: http.StatusBadRequest,
+			wantBody:   `{"error":{"code":"invalid_tenant","message":"Invalid tenant ID."}}` + "\n",
+		},
+		"other tenant": {
+			path:          "/v1/tenants/" + otherTenantID + "/users/" + userID,
+			authenticated: tenantID,
+			wantStatus:    http.StatusNotFound,
+			wantBody:      `{"error":{"code":"not_found","message":"Tenant not found."}}` + "\n",
+		},
+	}
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			gotTenant = ""
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("X-Tenant-ID", tc.header)
+			}
+			if tc.authenticated != "" {
+				req = req.WithContext(account.ContextWithTenant(req.Context(), tc.authenticated))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("FindUserByID status code: %d, want %d", w.Code, tc.wantStatus)
+			}
+			if gotTenant != tc.wantTenant {
+				t.Errorf("FindUserByID tenant %q, want %q", gotTenant, tc.wantTenant)
+			}
+			if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+				t.Errorf("FindUserByID body %s, want %s", w.Body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserService_FindUserByUsername_redirect(t *testing.T) {
+	s := &mock.UserService{
+		FindUserByUsernameFn: func(ctx context.Context, username string) (*account.User, error) {
+			return &account.User{ID: "123", Username: "alice2"}, nil
+		},
+	}
+	h := api.NewHTTPHandler(s, log.NewNopLogger(), 100)
+
+	tt := map[string]string{
+		"/v1/usernames/alice": "/v1/users/123",
+		"/v1/tenants/0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01/usernames/alice": "/v1/tenants/0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01/users/123",
+		"/v1/usernames/alice2": "",
+	}
+	for path, wantLocation := range tt {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		wantStatus := http.StatusTemporaryRedirect
+		if wantLocation == "" {
+			wantStatus = http.StatusOK
+		}
+		if w.Code != wantStatus {
+			t.Errorf("GET %s status code: %d, want %d", path, w.Code, wantStatus)
+		}
+		if got := w.Header().Get("Location"); got != wantLocation {
+			t.Errorf("GET %s location %q, want %q", path, got, wantLocation)
+		}
+	}
+}
+
+func TestUserService_ImportUsers(t *testing.T) {
+	tt := []struct {
+		params     string
+		statusCode int
+		want       string
+	}{
+		{
+			params:     `{"username":"alice"}` + "\n" + `{"username":"bob","email":"bob@example.com"}` + "\n",
+			statusCode: http.StatusOK,
+			want:       `{"user_ids":["`,
+		},
+		{
+			params:     `{"username":"alice"}` + "\n" + `{"username":">_<"}` + "\n" + `{"username":"alice"}`,
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"import_rejected","message":"2 users can't be imported."},"rows":[{"row":2,"error":{"code":"invalid_username","message":"Username is invalid.","field":"username"}},{"row":3,"error":{"code":"conflict","message":"Username is repeated in the import.","field":"username"}}]}` + "\n",
+		},
+		{
+			params:     `{"username":"alice"}` + "\n" + `{"username":`,
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"malformed_request","message":"Row 2 is not a valid JSON object."}}` + "\n",
+		},
+		{
+			params:     `{"username":"alice","nickname":"al"}`,
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"malformed_request","message":"Row 1 is not a valid JSON object."}}` + "\n",
+		},
+		{
+			params:     `{"username":"alice"}` + "\n" + `{"username":"b\xffb"}`,
+			statusCode: http.StatusBadRequest,
+			want:       `{"error":{"code":"malformed_request","message":"Row 2 is not a valid JSON object."}}` + "\n",
+		},
+		{
+			params:     `{"username":"alice","email":"` + strings.Repeat("a", 32<<20) + `"}`,
+			statusCode: http.StatusRequestEntityTooLarge,
+			want:       `{"error":{"code":"payload_too_large","message":"Request body must not exceed 33554432 bytes."}}` + "\n",
+		},
+	}
+
+	s := api.NewUserImportService(&mock.UserStorage{})
+	h := api.NewHTTPHandler(api.NewService(nil), log.NewNopLogger(), 100, api.WithUserImportService(s))
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	for _, tc := range tt {
+		resp, err := http.Post(srv.URL+"/v1/user-imports", "application/x-ndjson", strings.NewReader(tc.params))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tc.statusCode {
+			t.Fatalf("ImportUsers status code: %d, want %d", resp.StatusCode, tc.statusCode)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(string(body), tc.want) {
+			t.Fatalf("ImportUsers body %s, want %s", body, tc.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-kit/kit/endpoint"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
+	httptransport "github.com/go-kit/kit/transport/http"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	account "github.com/marselester/ddd-err"
+	pb "github.com/marselester/ddd-err/rpc/account"
+)
+
+// Media types of HTTP API requests and responses, see NewHTTPHandler.
+const (
+	MediaTypeJSON = "application/json"
+	// MediaTypeProtobuf is a binary encoding of the messages defined in account.proto,
+	// e.g., CreateUserRequest and CreateUserResponse of CreateUser RPC.
+	MediaTypeProtobuf = "application/x-protobuf"
+	// MediaTypeNDJSON is newline delimited JSON, one message per line.
+	// It is accepted only by client-streaming RPCs such as ImportUsers, see WithUserImportService.
+	MediaTypeNDJSON = "application/x-ndjson"
+)
+
+// media holds the media types negotiated with API client.
+type media struct {
+	// request is a media type of the request body.
+	request string
+	// response is a media type of the response body.
+	response string
+	// output is the response message of RPC which carries the error sent as protobuf.
+	output protoreflect.MessageType
+}
+
+// mediaFromContext returns the negotiated media types, JSON is used if there was no negotiation.
+func mediaFromContext(ctx context.Context) media {
+	m, ok := ctx.Value(mediaKey).(media)
+	if !ok {
+		return media{request: MediaTypeJSON, response: MediaTypeJSON}
+	}
+	return m
+}
+
+// negotiate chooses the media types of the request and response of the route of rpc
+// according to Content-Type and Accept headers, and stores them in the request context.
+// The request is rejected with ENotAcceptable or EUnsupportedMediaType error
+// when neither JSON nor protobuf can be used, or NDJSON is sent to the RPC which doesn't stream requests.
+// A request without Content-Type is read as JSON.
+func negotiate(rpc protoreflect.MethodDescriptor, h http.Handler) http.Handler {
+	output, err := protoregistry.GlobalTypes.FindMessageByName(rpc.Output().FullName())
+	if err != nil {
+		panic(err)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+
+		m := media{request: MediaTypeJSON, response: MediaTypeJSON, output: output}
+		ctx := r.Context()
+		var ok bool
+		if m.response, ok = acceptedMediaType(r.Header.Get("Accept")); !ok {
+			m.response = MediaTypeJSON
+			encodeError(context.WithValue(ctx, mediaKey, m), account.Error{
+				Code:    account.ENotAcceptable,
+				Message: "Response can be sent only as application/json or application/x-protobuf.",
+			}, w)
+			return
+		}
+
+		if v := r.Header.Get("Content-Type"); v != "" {
+			mt, _, err := mime.ParseMediaType(v)
+			switch {
+			case err != nil:
+				mt = v
+				fallthrough
+			case mt == MediaTypeNDJSON && !rpc.IsStreamingClient(),
+				mt != MediaTypeJSON && mt != MediaTypeNDJSON && mt != MediaTypeProtobuf:
+				encodeError(context.WithValue(ctx, mediaKey, m), account.Error{
+					Code:    account.EUnsupportedMediaType,
+					Message: fmt.Sprintf("Media type %s is not supported.", mt),
+					Inner:   err,
+				}, w)
+				return
+			}
+			m.request = mt
+		}
+
+		h.ServeHTTP(w, r.WithContext(context.WithValue(ctx, mediaKey, m)))
+	})
+}
+
+// acceptedMediaType returns the supported media type which API client prefers according to Accept header,
+// e.g., "application/x-protobuf, application/json;q=0.5" prefers protobuf.
+// JSON is chosen when the header is empty or the client accepts any media type.
+func acceptedMediaType(accept string) (string, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return MediaTypeJSON, true
+	}
+
+	var best string
+	var bestQ float64
+	for _, s := range strings.Split(accept, ",") {
+		mt, params, err := mime.ParseMediaType(s)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+
+		switch mt {
+		case MediaTypeJSON, "application/*", "*/*":
+			mt = MediaTypeJSON
+		case MediaTypeProtobuf:
+		default:
+			continue
+		}
+		if q > bestQ {
+			best, bestQ = mt, q
+		}
+	}
+	return best, best != ""
+}
+
+// encodeNegotiatedResp returns a response encoder which sends the response as JSON using encodeJSON,
+// or as protobuf message converted by encodeMsg (an encoder of gRPC transport) if API client prefers it.
+// The error of the response is sent with the same HTTP status in both cases.
+func encodeNegotiatedResp(encodeJSON httptransport.EncodeResponseFunc, encodeMsg grpctransport.EncodeResponseFunc) httptransport.EncodeResponseFunc {
+	return func(ctx context.Context, w http.ResponseWriter, response interface{}) error {
+		if mediaFromContext(ctx).response != MediaTypeProtobuf {
+			return encodeJSON(ctx, w, response)
+		}
+
+		m, err := encodeMsg(ctx, response)
+		if err != nil {
+			return err
+		}
+		status := http.StatusOK
+		if resp, ok := response.(endpoint.Failer); ok && resp.Failed() != nil {
+			status = httpStatus(publicError(resp.Failed()).Code)
+		}
+		return writeProtobuf(w, status, m.(proto.Message))
+	}
+}
+
+// encodeProtobufError sends the domain error in the error field of the response message of RPC
+// if API client prefers protobuf. It reports whether the error was sent.
+func encodeProtobufError(ctx context.Context, accErr account.Error, w http.ResponseWriter) bool {
+	m := mediaFromContext(ctx)
+	if m.response != MediaTypeProtobuf || m.output == nil {
+		return false
+	}
+	msg := m.output.New()
+	fd := msg.Descriptor().Fields().ByName("error")
+	if fd == nil {
+		return false
+	}
+
+	msg.Set(fd, protoreflect.ValueOfMessage((&pb.Error{
+		Code:    accErr.Code,
+		Message: accErr.Message,
+		Field:   accErr.Field,
+	}).ProtoReflect()))
+	writeProtobuf(w, httpStatus(accErr.Code), msg.Interface())
+	return true
+}
+
+// writeProtobuf sends the message m as protobuf with the status code.
+func writeProtobuf(w http.ResponseWriter, status int, m proto.Message) error {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", MediaTypeProtobuf)
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
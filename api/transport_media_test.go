@@ -0,0 +1,124 @@
+package api_test
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/marselester/ddd-err/api"
+	pb "github.com/marselester/ddd-err/rpc/account"
+)
+
+func TestNewHTTPHandler_negotiation(t *testing.T) {
+	tt := map[string]struct {
+		accept      string
+		contentType string
+		wantStatus  int
+		wantMedia   string
+		wantBody    string
+	}{
+		"default": {
+			wantStatus: http.StatusBadRequest,
+			wantMedia:  api.MediaTypeJSON,
+		},
+		"any": {
+			accept:      "text/html, */*;q=0.8",
+			contentType: "application/json; charset=utf-8",
+			wantStatus:  http.StatusBadRequest,
+			wantMedia:   api.MediaTypeJSON,
+		},
+		"protobuf preferred": {
+			accept:     "application/json;q=0.5, application/x-protobuf",
+			wantStatus: http.StatusBadRequest,
+			wantMedia:  api.MediaTypeProtobuf,
+		},
+		"json preferred": {
+			accept:     "application/x-protobuf;q=0.1, application/*",
+			wantStatus: http.StatusBadRequest,
+			wantMedia:  api.MediaTypeJSON,
+		},
+		"not acceptable": {
+			accept:     "text/html, application/json;q=0",
+			wantStatus: http.StatusNotAcceptable,
+			wantMedia:  api.MediaTypeJSON,
+			wantBody:   `{"error":{"code":"not_acceptable","message":"Response can be sent only as application/json or application/x-protobuf."}}` + "\n",
+		},
+		"unsupported": {
+			contentType: "application/x-www-form-urlencoded",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantMedia:   api.MediaTypeJSON,
+			wantBody:    `{"error":{"code":"unsupported_media_type","message":"Media type application/x-www-form-urlencoded is not supported."}}` + "\n",
+		},
+		"unsupported ndjson": {
+			contentType: "application/x-ndjson",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantMedia:   api.MediaTypeJSON,
+			wantBody:    `{"error":{"code":"unsupported_media_type","message":"Media type application/x-ndjson is not supported."}}` + "\n",
+		},
+		"unsupported protobuf": {
+			accept:      api.MediaTypeProtobuf,
+			contentType: "text/plain",
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantMedia:   api.MediaTypeProtobuf,
+		},
+	}
+	h := api.NewHTTPHandler(api.NewService(nil), log.NewNopLogger(), 100)
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(`{"username":">_<"}`))
+			if tc.accept != "" {
+				req.Header.Set("Accept", tc.accept)
+			}
+			if tc.contentType != "" {
+				req.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("CreateUser status code: %d, want %d", w.Code, tc.wantStatus)
+			}
+			if got := w.Header().Get("Content-Type"); !strings.HasPrefix(got, tc.wantMedia) {
+				t.Errorf("CreateUser content type %q, want %q", got, tc.wantMedia)
+			}
+			if tc.wantBody != "" && w.Body.String() != tc.wantBody {
+				t.Errorf("CreateUser body %s, want %s", w.Body, tc.wantBody)
+			}
+		})
+	}
+}
+
+func TestNewHTTPHandler_protobuf(t *testing.T) {
+	h := api.NewHTTPHandler(api.NewService(nil), log.NewNopLogger(), 100)
+
+	b, err := proto.Marshal(&pb.CreateUserRequest{Username: ">_<"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", bytes.NewReader(b))
+	req.Header.Set("Content-Type", api.MediaTypeProtobuf)
+	req.Header.Set("Accept", api.MediaTypeProtobuf)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("CreateUser status code: %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	var resp pb.CreateUserResponse
+	if err = proto.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.Error{
+		Code:    "invalid_username",
+		Message: "Username is invalid.",
+		Field:   "username",
+	}
+	if !proto.Equal(resp.Error, want) {
+		t.Errorf("CreateUser error %v, want %v", resp.Error, want)
+	}
+}
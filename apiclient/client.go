@@ -12,8 +12,17 @@ import (
 
 // client represents an API client for UserService backed by remote server.
 type client struct {
-	findUserByIDEndpoint endpoint.Endpoint
-	createUserEndpoint   endpoint.Endpoint
+	findUserByIDEndpoint   endpoint.Endpoint
+	createUserEndpoint     endpoint.Endpoint
+	suspendUserEndpoint    endpoint.Endpoint
+	reactivateUserEndpoint endpoint.Endpoint
+	lockUserEndpoint       endpoint.Endpoint
+	deleteUserEndpoint     endpoint.Endpoint
+	verifyEmailEndpoint    endpoint.Endpoint
+
+	changeUsernameEndpoint     endpoint.Endpoint
+	findUserByUsernameEndpoint endpoint.Endpoint
+	listUsersEndpoint          endpoint.Endpoint
 }
 
 // FindUserByID requests user info by ID from API server.
@@ -29,19 +38,231 @@ func (c *client) FindUserByID(ctx context.Context, id string) (*account.User, er
 		return nil, resp.Err
 	}
 	u := account.User{
-		ID:       resp.ID,
-		Username: resp.Username,
+		ID:            resp.ID,
+		Username:      resp.Username,
+		Status:        resp.Status,
+		Email:         resp.Email,
+		DisplayName:   resp.DisplayName,
+		EmailVerified: resp.EmailVerified,
 	}
 	return &u, nil
 }
 
 // CreateUser creates user at API server.
+// The ID assigned to the user is set on success.
 func (c *client) CreateUser(ctx context.Context, user *account.User) error {
-	req := api.CreateUserReq{Username: user.Username}
+	req := api.CreateUserReq{
+		Username:    user.Username,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+	}
 	response, err := c.createUserEndpoint(ctx, req)
 	if err != nil {
 		return err
 	}
 	resp := response.(api.CreateUserResp)
+	if resp.Err != nil {
+		return resp.Err
+	}
+	user.ID = resp.ID
+	return nil
+}
+
+// VerifyEmail verifies the email of a user at API server.
+// Only the user ID is known after verification.
+func (c *client) VerifyEmail(ctx context.Context, token string) (*account.User, error) {
+	req := api.VerifyEmailReq{Token: token}
+	response, err := c.verifyEmailEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp := response.(api.VerifyEmailResp)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &account.User{ID: resp.UserID, EmailVerified: true}, nil
+}
+
+// ChangeUsername renames a user at API server.
+func (c *client) ChangeUsername(ctx context.Context, id, username string) error {
+	req := api.ChangeUsernameReq{
+		ID:       id,
+		Username: username,
+	}
+	response, err := c.changeUsernameEndpoint(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp := response.(api.ChangeUsernameResp)
+	return resp.Err
+}
+
+// FindUserByUsername requests user info by current or former username from API server.
+func (c *client) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	req := api.FindUserByUsernameReq{Username: username}
+	response, err := c.findUserByUsernameEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := response.(api.FindUserByUsernameResp)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	u := account.User{
+		ID:            resp.ID,
+		Username:      resp.Username,
+		Status:        resp.Status,
+		Email:         resp.Email,
+		DisplayName:   resp.DisplayName,
+		EmailVerified: resp.EmailVerified,
+	}
+	return &u, nil
+}
+
+// ListUsers requests a page of users ordered by username from API server.
+func (c *client) ListUsers(ctx context.Context, f account.UserFilter) ([]*account.User, error) {
+	req := api.ListUsersReq{
+		After: f.After,
+		Limit: f.Limit,
+	}
+	response, err := c.listUsersEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := response.(api.ListUsersResp)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	users := make([]*account.User, 0, len(resp.Users))
+	for _, u := range resp.Users {
+		users = append(users, &account.User{
+			ID:            u.ID,
+			Username:      u.Username,
+			Status:        u.Status,
+			Email:         u.Email,
+			DisplayName:   u.DisplayName,
+			EmailVerified: u.EmailVerified,
+		})
+	}
+	return users, nil
+}
+
+// SuspendUser suspends a user at API server.
+func (c *client) SuspendUser(ctx context.Context, id string) error {
+	return c.changeUserStatus(ctx, c.suspendUserEndpoint, id)
+}
+
+// ReactivateUser reactivates a user at API server.
+func (c *client) ReactivateUser(ctx context.Context, id string) error {
+	return c.changeUserStatus(ctx, c.reactivateUserEndpoint, id)
+}
+
+// LockUser locks a user at API server.
+func (c *client) LockUser(ctx context.Context, id string) error {
+	return c.changeUserStatus(ctx, c.lockUserEndpoint, id)
+}
+
+// DeleteUser deletes a user at API server.
+func (c *client) DeleteUser(ctx context.Context, id string) error {
+	return c.changeUserStatus(ctx, c.deleteUserEndpoint, id)
+}
+
+// changeUserStatus calls the endpoint that changes user status.
+func (c *client) changeUserStatus(ctx context.Context, ep endpoint.Endpoint, id string) error {
+	req := api.ChangeUserStatusReq{ID: id}
+	response, err := ep(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp := response.(api.ChangeUserStatusResp)
+	return resp.Err
+}
+
+// groupClient represents an API client for GroupService backed by remote server.
+type groupClient struct {
+	findGroupByIDEndpoint endpoint.Endpoint
+	createGroupEndpoint   endpoint.Endpoint
+	renameGroupEndpoint   endpoint.Endpoint
+	listGroupsEndpoint    endpoint.Endpoint
+	deleteGroupEndpoint   endpoint.Endpoint
+}
+
+// FindGroupByID requests group info by ID from API server.
+func (c *groupClient) FindGroupByID(ctx context.Context, id string) (*account.Group, error) {
+	req := api.FindGroupByIDReq{ID: id}
+	response, err := c.findGroupByIDEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := response.(api.FindGroupByIDResp)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	return &account.Group{ID: resp.ID, Name: resp.Name}, nil
+}
+
+// CreateGroup creates group at API server.
+// The ID assigned to the group is set on success.
+func (c *groupClient) CreateGroup(ctx context.Context, group *account.Group) error {
+	req := api.CreateGroupReq{Name: group.Name}
+	response, err := c.createGroupEndpoint(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp := response.(api.CreateGroupResp)
+	if resp.Err != nil {
+		return resp.Err
+	}
+	group.ID = resp.ID
+	return nil
+}
+
+// RenameGroup renames a group at API server.
+func (c *groupClient) RenameGroup(ctx context.Context, id, name string) error {
+	req := api.RenameGroupReq{
+		ID:   id,
+		Name: name,
+	}
+	response, err := c.renameGroupEndpoint(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp := response.(api.RenameGroupResp)
+	return resp.Err
+}
+
+// ListGroups requests a page of groups ordered by name from API server.
+func (c *groupClient) ListGroups(ctx context.Context, f account.GroupFilter) ([]*account.Group, error) {
+	req := api.ListGroupsReq{
+		After: f.After,
+		Limit: f.Limit,
+	}
+	response, err := c.listGroupsEndpoint(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := response.(api.ListGroupsResp)
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+	groups := make([]*account.Group, 0, len(resp.Groups))
+	for _, g := range resp.Groups {
+		groups = append(groups, &account.Group{ID: g.ID, Name: g.Name})
+	}
+	return groups, nil
+}
+
+// DeleteGroup deletes a group at API server.
+func (c *groupClient) DeleteGroup(ctx context.Context, id string) error {
+	req := api.DeleteGroupReq{ID: id}
+	response, err := c.deleteGroupEndpoint(ctx, req)
+	if err != nil {
+		return err
+	}
+	resp := response.(api.DeleteGroupResp)
 	return resp.Err
 }
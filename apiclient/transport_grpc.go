@@ -2,12 +2,16 @@ package apiclient
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
 
 	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
 	grpctransport "github.com/go-kit/kit/transport/grpc"
 	"github.com/sony/gobreaker"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 
 	account "github.com/marselester/ddd-err"
 	"github.com/marselester/ddd-err/api"
@@ -16,17 +20,21 @@ import (
 
 // NewGRPCUserClient returns a gRPC client for a user service.
 // The caller is responsible for constructing the conn, and eventually closing the underlying transport.
+// The tenant, actor and request ID found in a request context are sent in
+// x-tenant-id, x-actor-id and x-request-id metadata,
+// and x-read-your-writes metadata is sent if the context requires to read your writes.
 func NewGRPCUserClient(conn *grpc.ClientConn) account.UserService {
 	c := client{}
 	var ep endpoint.Endpoint
 	{
 		ep = grpctransport.NewClient(
 			conn,
-			"ddd_err.account.User",
+			"ddd_err.account.UserService",
 			"FindUserByID",
 			encodeGRPCFindUserByIDReq,
 			decodeGRPCFindUserByIDResp,
 			pb.FindUserByIDResponse{},
+			grpctransport.ClientBefore(setRequestMetadata),
 		).Endpoint()
 		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
 			Name: "FindUserByID",
@@ -36,20 +44,178 @@ func NewGRPCUserClient(conn *grpc.ClientConn) account.UserService {
 	{
 		ep = grpctransport.NewClient(
 			conn,
-			"ddd_err.account.User",
+			"ddd_err.account.UserService",
 			"CreateUser",
 			encodeGRPCCreateUserReq,
 			decodeGRPCCreateUserResp,
 			pb.CreateUserResponse{},
+			grpctransport.ClientBefore(setRequestMetadata),
 		).Endpoint()
 		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
 			Name: "CreateUser",
 		}))(ep)
 		c.createUserEndpoint = ep
 	}
+	{
+		ep = grpctransport.NewClient(
+			conn,
+			"ddd_err.account.UserService",
+			"VerifyEmail",
+			encodeGRPCVerifyEmailReq,
+			decodeGRPCVerifyEmailResp,
+			pb.VerifyEmailResponse{},
+			grpctransport.ClientBefore(setRequestMetadata),
+		).Endpoint()
+		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name: "VerifyEmail",
+		}))(ep)
+		c.verifyEmailEndpoint = ep
+	}
+	{
+		ep = grpctransport.NewClient(
+			conn,
+			"ddd_err.account.UserService",
+			"ChangeUsername",
+			encodeGRPCChangeUsernameReq,
+			decodeGRPCChangeUsernameResp,
+			pb.ChangeUsernameResponse{},
+			grpctransport.ClientBefore(setRequestMetadata),
+		).Endpoint()
+		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name: "ChangeUsername",
+		}))(ep)
+		c.changeUsernameEndpoint = ep
+	}
+	{
+		ep = grpctransport.NewClient(
+			conn,
+			"ddd_err.account.UserService",
+			"FindUserByUsername",
+			encodeGRPCFindUserByUsernameReq,
+			decodeGRPCFindUserByUsernameResp,
+			pb.FindUserByUsernameResponse{},
+			grpctransport.ClientBefore(setRequestMetadata),
+		).Endpoint()
+		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name: "FindUserByUsername",
+		}))(ep)
+		c.findUserByUsernameEndpoint = ep
+	}
+	{
+		ep = grpctransport.NewClient(
+			conn,
+			"ddd_err.account.UserService",
+			"ListUsers",
+			encodeGRPCListUsersReq,
+			decodeGRPCListUsersResp,
+			pb.ListUsersResponse{},
+			grpctransport.ClientBefore(setRequestMetadata),
+		).Endpoint()
+		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name: "ListUsers",
+		}))(ep)
+		c.listUsersEndpoint = ep
+	}
+	statusEndpoints := []struct {
+		method   string
+		encode   grpctransport.EncodeRequestFunc
+		response interface{}
+		ep       *endpoint.Endpoint
+	}{
+		{"SuspendUser", encodeGRPCSuspendUserReq, pb.SuspendUserResponse{}, &c.suspendUserEndpoint},
+		{"ReactivateUser", encodeGRPCReactivateUserReq, pb.ReactivateUserResponse{}, &c.reactivateUserEndpoint},
+		{"LockUser", encodeGRPCLockUserReq, pb.LockUserResponse{}, &c.lockUserEndpoint},
+		{"DeleteUser", encodeGRPCDeleteUserReq, pb.DeleteUserResponse{}, &c.deleteUserEndpoint},
+	}
+	for _, se := range statusEndpoints {
+		ep = grpctransport.NewClient(
+			conn,
+			"ddd_err.account.UserService",
+			se.method,
+			se.encode,
+			decodeGRPCChangeUserStatusResp,
+			se.response,
+			grpctransport.ClientBefore(setRequestMetadata),
+		).Endpoint()
+		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name: se.method,
+		}))(ep)
+		*se.ep = ep
+	}
 	return &c
 }
 
+// NewGRPCGroupClient returns a gRPC client for a group service.
+// The caller is responsible for constructing the conn, and eventually closing the underlying transport.
+// The tenant and request ID are sent similar to NewGRPCUserClient.
+func NewGRPCGroupClient(conn *grpc.ClientConn) account.GroupService {
+	c := groupClient{}
+	endpoints := []struct {
+		method   string
+		encode   grpctransport.EncodeRequestFunc
+		decode   grpctransport.DecodeResponseFunc
+		response interface{}
+		ep       *endpoint.Endpoint
+	}{
+		{"FindGroupByID", encodeGRPCFindGroupByIDReq, decodeGRPCFindGroupByIDResp, pb.FindGroupByIDResponse{}, &c.findGroupByIDEndpoint},
+		{"CreateGroup", encodeGRPCCreateGroupReq, decodeGRPCCreateGroupResp, pb.CreateGroupResponse{}, &c.createGroupEndpoint},
+		{"RenameGroup", encodeGRPCRenameGroupReq, decodeGRPCRenameGroupResp, pb.RenameGroupResponse{}, &c.renameGroupEndpoint},
+		{"ListGroups", encodeGRPCListGroupsReq, decodeGRPCListGroupsResp, pb.ListGroupsResponse{}, &c.listGroupsEndpoint},
+		{"DeleteGroup", encodeGRPCDeleteGroupReq, decodeGRPCDeleteGroupResp, pb.DeleteGroupResponse{}, &c.deleteGroupEndpoint},
+	}
+	for _, e := range endpoints {
+		ep := grpctransport.NewClient(
+			conn,
+			"ddd_err.account.GroupService",
+			e.method,
+			e.encode,
+			e.decode,
+			e.response,
+			grpctransport.ClientBefore(setRequestMetadata),
+		).Endpoint()
+		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
+			Name: e.method,
+		}))(ep)
+		*e.ep = ep
+	}
+	return &c
+}
+
+// WithGRPCAPIKey returns a dial option which sends the API key as a bearer token in authorization metadata.
+func WithGRPCAPIKey(key string) grpc.DialOption {
+	return grpc.WithPerRPCCredentials(apiKeyCredentials(key))
+}
+
+// apiKeyCredentials implements credentials.PerRPCCredentials to authenticate by API key.
+type apiKeyCredentials string
+
+// GetRequestMetadata returns the authorization metadata attached to every request.
+func (k apiKeyCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(k)}, nil
+}
+
+// RequireTransportSecurity allows to send the key over an insecure connection, e.g., within a private network.
+func (k apiKeyCredentials) RequireTransportSecurity() bool {
+	return false
+}
+
+// setRequestMetadata passes the tenant, actor, request ID and read-your-writes flag from the request context to API server.
+func setRequestMetadata(ctx context.Context, md *metadata.MD) context.Context {
+	if v := account.TenantFromContext(ctx); v != "" {
+		md.Set("x-tenant-id", v)
+	}
+	if v := account.ActorFromContext(ctx); v != "" {
+		md.Set("x-actor-id", v)
+	}
+	if account.ReadYourWritesFromContext(ctx) {
+		md.Set("x-read-your-writes", "true")
+	}
+	if v := account.RequestIDFromContext(ctx); v != "" {
+		md.Set("x-request-id", v)
+	}
+	return ctx
+}
+
 // encodeGRPCFindUserByIDReq is a transport/grpc.EncodeRequestFunc that converts
 // a user-domain FindUserByIDReq to a gRPC FindUserByIDReq.
 func encodeGRPCFindUserByIDReq(_ context.Context, request interface{}) (interface{}, error) {
@@ -65,8 +231,12 @@ func decodeGRPCFindUserByIDResp(_ context.Context, grpcResp interface{}) (interf
 	resp := grpcResp.(*pb.FindUserByIDResponse)
 	if resp.Error == nil {
 		return api.FindUserByIDResp{
-			ID:       resp.Id,
-			Username: resp.Username,
+			ID:            resp.Id,
+			Username:      resp.Username,
+			Status:        resp.Status,
+			Email:         resp.Email,
+			DisplayName:   resp.DisplayName,
+			EmailVerified: resp.EmailVerified,
 		}, nil
 	}
 
@@ -74,6 +244,7 @@ func decodeGRPCFindUserByIDResp(_ context.Context, grpcResp interface{}) (interf
 	e := account.Error{
 		Code:    resp.Error.Code,
 		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
 	}
 	apiResp := api.FindUserByIDResp{Err: e}
 	// Only certain errors returned by endpoint count against the circuit breaker's error count.
@@ -89,7 +260,9 @@ func decodeGRPCFindUserByIDResp(_ context.Context, grpcResp interface{}) (interf
 func encodeGRPCCreateUserReq(_ context.Context, request interface{}) (interface{}, error) {
 	req := request.(api.CreateUserReq)
 	return &pb.CreateUserRequest{
-		Username: req.Username,
+		Username:    req.Username,
+		Email:       req.Email,
+		DisplayName: req.DisplayName,
 	}, nil
 }
 
@@ -98,13 +271,14 @@ func encodeGRPCCreateUserReq(_ context.Context, request interface{}) (interface{
 func decodeGRPCCreateUserResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
 	resp := grpcResp.(*pb.CreateUserResponse)
 	if resp.Error == nil {
-		return api.CreateUserResp{}, nil
+		return api.CreateUserResp{ID: resp.Id}, nil
 	}
 
 	// Decode gRPC error into domain error.
 	e := account.Error{
 		Code:    resp.Error.Code,
 		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
 	}
 	apiResp := api.CreateUserResp{Err: e}
 	// Only certain errors returned by endpoint count against the circuit breaker's error count.
@@ -114,3 +288,473 @@ func decodeGRPCCreateUserResp(_ context.Context, grpcResp interface{}) (interfac
 	}
 	return apiResp, nil
 }
+
+// encodeGRPCVerifyEmailReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain VerifyEmailReq to a gRPC VerifyEmailReq.
+func encodeGRPCVerifyEmailReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.VerifyEmailReq)
+	return &pb.VerifyEmailRequest{Token: req.Token}, nil
+}
+
+// decodeGRPCVerifyEmailResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC VerifyEmailResp to a user-domain VerifyEmailResp.
+func decodeGRPCVerifyEmailResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.VerifyEmailResponse)
+	if resp.Error == nil {
+		return api.VerifyEmailResp{UserID: resp.UserId}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.VerifyEmailResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCChangeUsernameReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ChangeUsernameReq to a gRPC ChangeUsernameReq.
+func encodeGRPCChangeUsernameReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ChangeUsernameReq)
+	return &pb.ChangeUsernameRequest{
+		Id:       req.ID,
+		Username: req.Username,
+	}, nil
+}
+
+// decodeGRPCChangeUsernameResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC ChangeUsernameResp to a user-domain ChangeUsernameResp.
+func decodeGRPCChangeUsernameResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.ChangeUsernameResponse)
+	if resp.Error == nil {
+		return api.ChangeUsernameResp{}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.ChangeUsernameResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCFindUserByUsernameReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain FindUserByUsernameReq to a gRPC FindUserByUsernameReq.
+func encodeGRPCFindUserByUsernameReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.FindUserByUsernameReq)
+	return &pb.FindUserByUsernameRequest{Username: req.Username}, nil
+}
+
+// decodeGRPCFindUserByUsernameResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC FindUserByUsernameResp to a user-domain FindUserByUsernameResp.
+func decodeGRPCFindUserByUsernameResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.FindUserByUsernameResponse)
+	if resp.Error == nil {
+		return api.FindUserByUsernameResp{
+			FindUserByIDResp: api.FindUserByIDResp{
+				ID:            resp.Id,
+				Username:      resp.Username,
+				Status:        resp.Status,
+				Email:         resp.Email,
+				DisplayName:   resp.DisplayName,
+				EmailVerified: resp.EmailVerified,
+			},
+			Moved: resp.Moved,
+		}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.FindUserByUsernameResp{FindUserByIDResp: api.FindUserByIDResp{Err: e}}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCSuspendUserReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ChangeUserStatusReq to a gRPC SuspendUserReq.
+func encodeGRPCSuspendUserReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ChangeUserStatusReq)
+	return &pb.SuspendUserRequest{Id: req.ID}, nil
+}
+
+// encodeGRPCReactivateUserReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ChangeUserStatusReq to a gRPC ReactivateUserReq.
+func encodeGRPCReactivateUserReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ChangeUserStatusReq)
+	return &pb.ReactivateUserRequest{Id: req.ID}, nil
+}
+
+// encodeGRPCLockUserReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ChangeUserStatusReq to a gRPC LockUserReq.
+func encodeGRPCLockUserReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ChangeUserStatusReq)
+	return &pb.LockUserRequest{Id: req.ID}, nil
+}
+
+// encodeGRPCDeleteUserReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ChangeUserStatusReq to a gRPC DeleteUserReq.
+func encodeGRPCDeleteUserReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ChangeUserStatusReq)
+	return &pb.DeleteUserRequest{Id: req.ID}, nil
+}
+
+// decodeGRPCChangeUserStatusResp is a transport/grpc.DecodeResponseFunc that converts
+// gRPC responses of SuspendUser, ReactivateUser, LockUser and DeleteUser
+// to a user-domain ChangeUserStatusResp.
+func decodeGRPCChangeUserStatusResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	var grpcErr *pb.Error
+	switch resp := grpcResp.(type) {
+	case *pb.SuspendUserResponse:
+		grpcErr = resp.Error
+	case *pb.ReactivateUserResponse:
+		grpcErr = resp.Error
+	case *pb.LockUserResponse:
+		grpcErr = resp.Error
+	case *pb.DeleteUserResponse:
+		grpcErr = resp.Error
+	default:
+		return nil, fmt.Errorf("unexpected user status response %T", grpcResp)
+	}
+	if grpcErr == nil {
+		return api.ChangeUserStatusResp{}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    grpcErr.Code,
+		Message: grpcErr.Message,
+		Field:   grpcErr.Field,
+	}
+	apiResp := api.ChangeUserStatusResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCListUsersReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ListUsersReq to a gRPC ListUsersReq.
+func encodeGRPCListUsersReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ListUsersReq)
+	return &pb.ListUsersRequest{
+		After: req.After,
+		Limit: int32(req.Limit),
+	}, nil
+}
+
+// decodeGRPCListUsersResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC ListUsersResp to a user-domain ListUsersResp.
+func decodeGRPCListUsersResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.ListUsersResponse)
+	if resp.Error == nil {
+		apiResp := api.ListUsersResp{
+			Users: make([]api.User, 0, len(resp.Users)),
+		}
+		for _, u := range resp.Users {
+			apiResp.Users = append(apiResp.Users, api.User{
+				ID:            u.Id,
+				Username:      u.Username,
+				Status:        u.Status,
+				Email:         u.Email,
+				DisplayName:   u.DisplayName,
+				EmailVerified: u.EmailVerified,
+			})
+		}
+		return apiResp, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.ListUsersResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCFindGroupByIDReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain FindGroupByIDReq to a gRPC FindGroupByIDReq.
+func encodeGRPCFindGroupByIDReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.FindGroupByIDReq)
+	return &pb.FindGroupByIDRequest{Id: req.ID}, nil
+}
+
+// decodeGRPCFindGroupByIDResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC FindGroupByIDResp to a user-domain FindGroupByIDResp.
+func decodeGRPCFindGroupByIDResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.FindGroupByIDResponse)
+	if resp.Error == nil {
+		return api.FindGroupByIDResp{ID: resp.Id, Name: resp.Name}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.FindGroupByIDResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCCreateGroupReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain CreateGroupReq to a gRPC CreateGroupReq.
+func encodeGRPCCreateGroupReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.CreateGroupReq)
+	return &pb.CreateGroupRequest{Name: req.Name}, nil
+}
+
+// decodeGRPCCreateGroupResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC CreateGroupResp to a user-domain CreateGroupResp.
+func decodeGRPCCreateGroupResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.CreateGroupResponse)
+	if resp.Error == nil {
+		return api.CreateGroupResp{ID: resp.Id}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.CreateGroupResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCRenameGroupReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain RenameGroupReq to a gRPC RenameGroupReq.
+func encodeGRPCRenameGroupReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.RenameGroupReq)
+	return &pb.RenameGroupRequest{
+		Id:   req.ID,
+		Name: req.Name,
+	}, nil
+}
+
+// decodeGRPCRenameGroupResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC RenameGroupResp to a user-domain RenameGroupResp.
+func decodeGRPCRenameGroupResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.RenameGroupResponse)
+	if resp.Error == nil {
+		return api.RenameGroupResp{}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.RenameGroupResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCListGroupsReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain ListGroupsReq to a gRPC ListGroupsReq.
+func encodeGRPCListGroupsReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.ListGroupsReq)
+	return &pb.ListGroupsRequest{
+		After: req.After,
+		Limit: int32(req.Limit),
+	}, nil
+}
+
+// decodeGRPCListGroupsResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC ListGroupsResp to a user-domain ListGroupsResp.
+func decodeGRPCListGroupsResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.ListGroupsResponse)
+	if resp.Error == nil {
+		apiResp := api.ListGroupsResp{
+			Groups: make([]api.Group, 0, len(resp.Groups)),
+		}
+		for _, g := range resp.Groups {
+			apiResp.Groups = append(apiResp.Groups, api.Group{ID: g.Id, Name: g.Name})
+		}
+		return apiResp, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.ListGroupsResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// encodeGRPCDeleteGroupReq is a transport/grpc.EncodeRequestFunc that converts
+// a user-domain DeleteGroupReq to a gRPC DeleteGroupReq.
+func encodeGRPCDeleteGroupReq(_ context.Context, request interface{}) (interface{}, error) {
+	req := request.(api.DeleteGroupReq)
+	return &pb.DeleteGroupRequest{Id: req.ID}, nil
+}
+
+// decodeGRPCDeleteGroupResp is a transport/grpc.DecodeResponseFunc that converts a
+// gRPC DeleteGroupResp to a user-domain DeleteGroupResp.
+func decodeGRPCDeleteGroupResp(_ context.Context, grpcResp interface{}) (interface{}, error) {
+	resp := grpcResp.(*pb.DeleteGroupResponse)
+	if resp.Error == nil {
+		return api.DeleteGroupResp{}, nil
+	}
+
+	// Decode gRPC error into domain error.
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	apiResp := api.DeleteGroupResp{Err: e}
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(e) {
+	case account.ERateLimit, account.EInternal:
+		return apiResp, e
+	}
+	return apiResp, nil
+}
+
+// importBatchSize is a number of users sent in a single message of ImportUsers stream.
+const importBatchSize = 1000
+
+// NewGRPCUserImportClient returns a gRPC client for a user import service.
+// The users are streamed to the server in batches, so an import isn't limited by gRPC max message size.
+// The caller is responsible for constructing the conn, and eventually closing the underlying transport.
+// The tenant, actor and request ID are sent similar to NewGRPCUserClient.
+func NewGRPCUserImportClient(conn *grpc.ClientConn) account.UserImportService {
+	return &importClient{
+		client: pb.NewUserImportServiceClient(conn),
+	}
+}
+
+// importClient represents an API client for UserImportService backed by remote gRPC server.
+// The streaming RPC is called directly since go-kit supports only unary RPCs.
+type importClient struct {
+	client pb.UserImportServiceClient
+}
+
+// ImportUsers imports users at API server and sets their IDs.
+// The rejected users are reported by account.Error wrapping *account.ImportError.
+func (c *importClient) ImportUsers(ctx context.Context, users []*account.User) error {
+	md := metadata.MD{}
+	ctx = metadata.NewOutgoingContext(setRequestMetadata(ctx, &md), md)
+
+	stream, err := c.client.ImportUsers(ctx)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(users); i += importBatchSize {
+		batch := pb.ImportUsersRequest{}
+		for _, u := range users[i:min(i+importBatchSize, len(users))] {
+			batch.Users = append(batch.Users, &pb.CreateUserRequest{
+				Username:    u.Username,
+				Email:       u.Email,
+				DisplayName: u.DisplayName,
+			})
+		}
+		// The server closes the stream early when it rejects the import,
+		// the reason is received by CloseAndRecv.
+		if err = stream.Send(&batch); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+	}
+
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return decodeGRPCImportError(resp)
+	}
+	if len(resp.UserIds) != len(users) {
+		return account.Error{
+			Code:    account.EInternal,
+			Message: "An internal error has occurred.",
+			Inner:   fmt.Errorf("unexpected number of imported users %d, want %d", len(resp.UserIds), len(users)),
+		}
+	}
+	for i, id := range resp.UserIds {
+		users[i].ID = id
+	}
+	return nil
+}
+
+// decodeGRPCImportError decodes gRPC error of ImportUsers into domain error.
+// The errors of the rejected users are wrapped as *account.ImportError.
+func decodeGRPCImportError(resp *pb.ImportUsersResponse) error {
+	e := account.Error{
+		Code:    resp.Error.Code,
+		Message: resp.Error.Message,
+		Field:   resp.Error.Field,
+	}
+	if len(resp.Rows) == 0 {
+		return e
+	}
+
+	importErr := account.ImportError{}
+	for _, r := range resp.Rows {
+		importErr.Rows = append(importErr.Rows, account.ImportRowError{
+			Row: int(r.Row),
+			Err: account.Error{
+				Code:    r.Error.GetCode(),
+				Message: r.Error.GetMessage(),
+				Field:   r.Error.GetField(),
+			},
+		})
+	}
+	e.Inner = &importErr
+	return e
+}
@@ -1,61 +1,434 @@
 package apiclient
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"io"
+	"mime"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
 
 	"github.com/go-kit/kit/circuitbreaker"
 	"github.com/go-kit/kit/endpoint"
+	grpctransport "github.com/go-kit/kit/transport/grpc"
 	httptransport "github.com/go-kit/kit/transport/http"
 	"github.com/sony/gobreaker"
+	"google.golang.org/protobuf/proto"
 
 	account "github.com/marselester/ddd-err"
 	"github.com/marselester/ddd-err/api"
+	pb "github.com/marselester/ddd-err/rpc/account"
 )
 
+// HTTPClientOption sets an optional parameter for the HTTP client.
+type HTTPClientOption func(*httpClientConfig)
+
+// httpClientConfig holds the optional parameters of the HTTP client.
+type httpClientConfig struct {
+	apiKey   string
+	protobuf bool
+}
+
+// WithAPIKey sets the API key sent as a bearer token in Authorization header.
+func WithAPIKey(key string) HTTPClientOption {
+	return func(c *httpClientConfig) {
+		c.apiKey = key
+	}
+}
+
+// WithProtobuf makes the client send and accept protobuf messages defined in account.proto
+// instead of JSON, see api.MediaTypeProtobuf.
+func WithProtobuf() HTTPClientOption {
+	return func(c *httpClientConfig) {
+		c.protobuf = true
+	}
+}
+
 // NewHTTPClient returns UserService backed by an HTTP server living at the remote server.
-func NewHTTPClient(baseURL string) (account.UserService, error) {
-	u, err := url.Parse(baseURL)
+// The tenant, actor and request ID found in a request context are sent in
+// X-Tenant-ID, X-Actor-ID and X-Request-ID headers,
+// and X-Read-Your-Writes header is sent if the context requires to read your writes.
+func NewHTTPClient(baseURL string, options ...HTTPClientOption) (account.UserService, error) {
+	c := client{}
+	err := makeHTTPEndpoints(baseURL, options, []httpEndpoint{
+		{
+			name: "CreateUser", method: "POST", body: true,
+			path: func(interface{}) string {
+				return "/v1/users"
+			},
+			encodeMsg:  encodeGRPCCreateUserReq,
+			decodeJSON: decodeHTTPCreateUserResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.CreateUserResponse{}
+			},
+			decodeMsg: decodeGRPCCreateUserResp,
+			ep:        &c.createUserEndpoint,
+		},
+		{
+			name: "FindUserByID", method: "GET",
+			path: func(request interface{}) string {
+				return "/v1/users/" + request.(api.FindUserByIDReq).ID
+			},
+			decodeJSON: decodeHTTPFindUserByIDResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.FindUserByIDResponse{}
+			},
+			decodeMsg: decodeGRPCFindUserByIDResp,
+			ep:        &c.findUserByIDEndpoint,
+		},
+		{
+			name: "VerifyEmail", method: "POST", body: true,
+			path: func(interface{}) string {
+				return "/v1/email-verifications"
+			},
+			encodeMsg:  encodeGRPCVerifyEmailReq,
+			decodeJSON: decodeHTTPVerifyEmailResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.VerifyEmailResponse{}
+			},
+			decodeMsg: decodeGRPCVerifyEmailResp,
+			ep:        &c.verifyEmailEndpoint,
+		},
+		{
+			name: "ChangeUsername", method: "POST", body: true,
+			path: func(request interface{}) string {
+				return "/v1/users/" + request.(api.ChangeUsernameReq).ID + "/username"
+			},
+			encodeMsg:  encodeGRPCChangeUsernameReq,
+			decodeJSON: decodeHTTPChangeUsernameResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.ChangeUsernameResponse{}
+			},
+			decodeMsg: decodeGRPCChangeUsernameResp,
+			ep:        &c.changeUsernameEndpoint,
+		},
+		{
+			// A former username is redirected to the user resource which is followed by http.Client.
+			name: "FindUserByUsername", method: "GET",
+			path: func(request interface{}) string {
+				return "/v1/usernames/" + request.(api.FindUserByUsernameReq).Username
+			},
+			decodeJSON: decodeHTTPFindUserByUsernameResp,
+			newMsg: func(r *http.Response) proto.Message {
+				if !strings.Contains(r.Request.URL.Path, "/usernames/") {
+					return &pb.FindUserByIDResponse{}
+				}
+				return &pb.FindUserByUsernameResponse{}
+			},
+			decodeMsg: decodeProtobufFindUserByUsernameResp,
+			ep:        &c.findUserByUsernameEndpoint,
+		},
+		{
+			name: "SuspendUser", method: "POST",
+			path: func(request interface{}) string {
+				return "/v1/users/" + request.(api.ChangeUserStatusReq).ID + "/suspend"
+			},
+			decodeJSON: decodeHTTPChangeUserStatusResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.SuspendUserResponse{}
+			},
+			decodeMsg: decodeGRPCChangeUserStatusResp,
+			ep:        &c.suspendUserEndpoint,
+		},
+		{
+			name: "ReactivateUser", method: "POST",
+			path: func(request interface{}) string {
+				return "/v1/users/" + request.(api.ChangeUserStatusReq).ID + "/reactivate"
+			},
+			decodeJSON: decodeHTTPChangeUserStatusResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.ReactivateUserResponse{}
+			},
+			decodeMsg: decodeGRPCChangeUserStatusResp,
+			ep:        &c.reactivateUserEndpoint,
+		},
+		{
+			name: "LockUser", method: "POST",
+			path: func(request interface{}) string {
+				return "/v1/users/" + request.(api.ChangeUserStatusReq).ID + "/lock"
+			},
+			decodeJSON: decodeHTTPChangeUserStatusResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.LockUserResponse{}
+			},
+			decodeMsg: decodeGRPCChangeUserStatusResp,
+			ep:        &c.lockUserEndpoint,
+		},
+		{
+			name: "DeleteUser", method: "DELETE",
+			path: func(request interface{}) string {
+				return "/v1/users/" + request.(api.ChangeUserStatusReq).ID
+			},
+			decodeJSON: decodeHTTPChangeUserStatusResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.DeleteUserResponse{}
+			},
+			decodeMsg: decodeGRPCChangeUserStatusResp,
+			ep:        &c.deleteUserEndpoint,
+		},
+		{
+			name: "ListUsers", method: "GET",
+			path: func(interface{}) string {
+				return "/v1/users"
+			},
+			query: func(request interface{}) url.Values {
+				req := request.(api.ListUsersReq)
+				return listQuery(req.After, req.Limit)
+			},
+			decodeJSON: decodeHTTPListUsersResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.ListUsersResponse{}
+			},
+			decodeMsg: decodeGRPCListUsersResp,
+			ep:        &c.listUsersEndpoint,
+		},
+	})
 	if err != nil {
 		return nil, err
 	}
+	return &c, nil
+}
 
-	c := client{}
-	var ep endpoint.Endpoint
-	{
-		ep = httptransport.NewClient(
-			"POST",
-			u,
-			func(ctx context.Context, r *http.Request, request interface{}) error {
-				r.URL.Path = "/v1/users"
-				return httptransport.EncodeJSONRequest(ctx, r, request)
+// NewHTTPGroupClient returns GroupService backed by an HTTP server living at the remote server.
+// The tenant and request ID are sent similar to NewHTTPClient.
+func NewHTTPGroupClient(baseURL string, options ...HTTPClientOption) (account.GroupService, error) {
+	c := groupClient{}
+	err := makeHTTPEndpoints(baseURL, options, []httpEndpoint{
+		{
+			name: "CreateGroup", method: "POST", body: true,
+			path: func(interface{}) string {
+				return "/v1/groups"
 			},
-			decodeHTTPCreateUserResp,
-		).Endpoint()
-		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name: "CreateUser",
-		}))(ep)
-		c.createUserEndpoint = ep
+			encodeMsg:  encodeGRPCCreateGroupReq,
+			decodeJSON: decodeHTTPCreateGroupResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.CreateGroupResponse{}
+			},
+			decodeMsg: decodeGRPCCreateGroupResp,
+			ep:        &c.createGroupEndpoint,
+		},
+		{
+			name: "FindGroupByID", method: "GET",
+			path: func(request interface{}) string {
+				return "/v1/groups/" + request.(api.FindGroupByIDReq).ID
+			},
+			decodeJSON: decodeHTTPFindGroupByIDResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.FindGroupByIDResponse{}
+			},
+			decodeMsg: decodeGRPCFindGroupByIDResp,
+			ep:        &c.findGroupByIDEndpoint,
+		},
+		{
+			name: "RenameGroup", method: "POST", body: true,
+			path: func(request interface{}) string {
+				return "/v1/groups/" + request.(api.RenameGroupReq).ID + "/name"
+			},
+			encodeMsg:  encodeGRPCRenameGroupReq,
+			decodeJSON: decodeHTTPRenameGroupResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.RenameGroupResponse{}
+			},
+			decodeMsg: decodeGRPCRenameGroupResp,
+			ep:        &c.renameGroupEndpoint,
+		},
+		{
+			name: "ListGroups", method: "GET",
+			path: func(interface{}) string {
+				return "/v1/groups"
+			},
+			query: func(request interface{}) url.Values {
+				req := request.(api.ListGroupsReq)
+				return listQuery(req.After, req.Limit)
+			},
+			decodeJSON: decodeHTTPListGroupsResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.ListGroupsResponse{}
+			},
+			decodeMsg: decodeGRPCListGroupsResp,
+			ep:        &c.listGroupsEndpoint,
+		},
+		{
+			name: "DeleteGroup", method: "DELETE",
+			path: func(request interface{}) string {
+				return "/v1/groups/" + request.(api.DeleteGroupReq).ID
+			},
+			decodeJSON: decodeHTTPDeleteGroupResp,
+			newMsg: func(*http.Response) proto.Message {
+				return &pb.DeleteGroupResponse{}
+			},
+			decodeMsg: decodeGRPCDeleteGroupResp,
+			ep:        &c.deleteGroupEndpoint,
+		},
+	})
+	if err != nil {
+		return nil, err
 	}
-	{
-		ep = httptransport.NewClient(
-			"GET",
+	return &c, nil
+}
+
+// httpEndpoint describes an HTTP route of a service method.
+// The responses are decoded according to their media type,
+// e.g., a proxy might respond with JSON error to a protobuf request.
+// The protobuf messages are converted with the functions of gRPC client.
+type httpEndpoint struct {
+	name   string
+	method string
+	path   func(request interface{}) string
+	// query returns the query string parameters of the request, if any.
+	query func(request interface{}) url.Values
+	// body indicates whether the request is sent in the body.
+	body       bool
+	encodeMsg  grpctransport.EncodeRequestFunc
+	decodeJSON httptransport.DecodeResponseFunc
+	// newMsg returns the response message to decode protobuf response into.
+	newMsg    func(r *http.Response) proto.Message
+	decodeMsg grpctransport.DecodeResponseFunc
+	ep        *endpoint.Endpoint
+}
+
+// makeHTTPEndpoints makes the endpoints of the routes at the server living at baseURL.
+// Every endpoint is wrapped in its own circuit breaker.
+func makeHTTPEndpoints(baseURL string, options []HTTPClientOption, endpoints []httpEndpoint) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	cfg := httpClientConfig{}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	before := []httptransport.RequestFunc{setRequestHeaders}
+	if cfg.apiKey != "" {
+		before = append(before, setAuthorization(cfg.apiKey))
+	}
+
+	for _, e := range endpoints {
+		e := e
+		ep := httptransport.NewClient(
+			e.method,
 			u,
 			func(ctx context.Context, r *http.Request, request interface{}) error {
-				req := request.(api.FindUserByIDReq)
-				r.URL.Path = "/v1/users/" + req.ID
-				return httptransport.EncodeJSONRequest(ctx, r, request)
+				r.URL.Path = e.path(request)
+				if e.query != nil {
+					r.URL.RawQuery = e.query(request).Encode()
+				}
+				if cfg.protobuf {
+					r.Header.Set("Accept", api.MediaTypeProtobuf)
+				}
+				switch {
+				case !e.body:
+					return nil
+				case cfg.protobuf:
+					return encodeProtobufRequest(ctx, r, request, e.encodeMsg)
+				default:
+					return httptransport.EncodeJSONRequest(ctx, r, request)
+				}
 			},
-			decodeHTTPFindUserByIDResp,
+			func(ctx context.Context, r *http.Response) (interface{}, error) {
+				if !isProtobuf(r) {
+					return e.decodeJSON(ctx, r)
+				}
+				return decodeProtobufResponse(ctx, r, e.newMsg(r), e.decodeMsg)
+			},
+			httptransport.ClientBefore(before...),
 		).Endpoint()
 		ep = circuitbreaker.Gobreaker(gobreaker.NewCircuitBreaker(gobreaker.Settings{
-			Name: "FindUserByID",
+			Name: e.name,
 		}))(ep)
-		c.findUserByIDEndpoint = ep
+		*e.ep = ep
+	}
+	return nil
+}
+
+// listQuery returns the query string parameters of a listing, the zero values are omitted.
+func listQuery(after string, limit int) url.Values {
+	q := url.Values{}
+	if after != "" {
+		q.Set("after", after)
+	}
+	if limit != 0 {
+		q.Set("limit", strconv.Itoa(limit))
+	}
+	return q
+}
+
+// encodeProtobufRequest sends the request converted by encodeMsg as protobuf message.
+func encodeProtobufRequest(ctx context.Context, r *http.Request, request interface{}, encodeMsg grpctransport.EncodeRequestFunc) error {
+	m, err := encodeMsg(ctx, request)
+	if err != nil {
+		return err
+	}
+	b, err := proto.Marshal(m.(proto.Message))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", api.MediaTypeProtobuf)
+	r.ContentLength = int64(len(b))
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	return nil
+}
+
+// isProtobuf reports whether the response body is a protobuf message.
+func isProtobuf(r *http.Response) bool {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt == api.MediaTypeProtobuf
+}
+
+// decodeProtobufResponse decodes the response body into the message m,
+// and converts it into a user-domain response with decodeMsg.
+func decodeProtobufResponse(ctx context.Context, r *http.Response, m proto.Message, decodeMsg grpctransport.DecodeResponseFunc) (interface{}, error) {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err = proto.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return decodeMsg(ctx, m)
+}
+
+// decodeProtobufFindUserByUsernameResp converts FindUserByUsernameResp message,
+// or FindUserByIDResp message of the user resource which a former username was redirected to,
+// into a user-domain FindUserByUsernameResp.
+func decodeProtobufFindUserByUsernameResp(ctx context.Context, msg interface{}) (interface{}, error) {
+	m, ok := msg.(*pb.FindUserByIDResponse)
+	if !ok {
+		return decodeGRPCFindUserByUsernameResp(ctx, msg)
+	}
+	resp, err := decodeGRPCFindUserByIDResp(ctx, m)
+	if resp == nil {
+		return nil, err
+	}
+	return api.FindUserByUsernameResp{FindUserByIDResp: resp.(api.FindUserByIDResp)}, err
+}
+
+// setRequestHeaders passes the tenant, actor, request ID and read-your-writes flag from the request context to API server.
+func setRequestHeaders(ctx context.Context, r *http.Request) context.Context {
+	if v := account.TenantFromContext(ctx); v != "" {
+		r.Header.Set("X-Tenant-ID", v)
+	}
+	if v := account.ActorFromContext(ctx); v != "" {
+		r.Header.Set("X-Actor-ID", v)
+	}
+	if account.ReadYourWritesFromContext(ctx) {
+		r.Header.Set("X-Read-Your-Writes", "true")
+	}
+	if v := account.RequestIDFromContext(ctx); v != "" {
+		r.Header.Set("X-Request-ID", v)
+	}
+	return ctx
+}
+
+// setAuthorization returns a request func which sends the API key as a bearer token.
+func setAuthorization(apiKey string) httptransport.RequestFunc {
+	return func(ctx context.Context, r *http.Request) context.Context {
+		r.Header.Set("Authorization", "Bearer "+apiKey)
+		return ctx
 	}
-	return &c, nil
 }
 
 func decodeHTTPCreateUserResp(_ context.Context, r *http.Response) (interface{}, error) {
@@ -65,8 +438,14 @@ func decodeHTTPCreateUserResp(_ context.Context, r *http.Response) (interface{},
 	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
 		return resp, err
 	}
-	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	// A successful response has no error.
 	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
 	switch account.ErrorCode(*e) {
 	case account.ERateLimit, account.EInternal:
 		return resp, resp.Err
@@ -81,8 +460,234 @@ func decodeHTTPFindUserByIDResp(_ context.Context, r *http.Response) (interface{
 	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
 		return resp, err
 	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
 	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPChangeUserStatusResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.ChangeUserStatusResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
 	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPVerifyEmailResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.VerifyEmailResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPChangeUsernameResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.ChangeUsernameResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPFindUserByUsernameResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.FindUserByUsernameResp{
+		FindUserByIDResp: api.FindUserByIDResp{Err: &account.Error{}},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPListUsersResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.ListUsersResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPCreateGroupResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.CreateGroupResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPFindGroupByIDResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.FindGroupByIDResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPRenameGroupResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.RenameGroupResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPListGroupsResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.ListGroupsResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
+	switch account.ErrorCode(*e) {
+	case account.ERateLimit, account.EInternal:
+		return resp, resp.Err
+	}
+	return resp, nil
+}
+
+func decodeHTTPDeleteGroupResp(_ context.Context, r *http.Response) (interface{}, error) {
+	resp := api.DeleteGroupResp{
+		Err: &account.Error{},
+	}
+	if err := json.NewDecoder(r.Body).Decode(&resp); err != nil {
+		return resp, err
+	}
+	// A successful response has no error.
+	e := resp.Err.(*account.Error)
+	if e.Code == "" {
+		resp.Err = nil
+		return resp, nil
+	}
+	resp.Err = *e
+	// Only certain errors returned by endpoint count against the circuit breaker's error count.
 	switch account.ErrorCode(*e) {
 	case account.ERateLimit, account.EInternal:
 		return resp, resp.Err
@@ -6,8 +6,12 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-kit/log"
+
 	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
 	"github.com/marselester/ddd-err/apiclient"
+	"github.com/marselester/ddd-err/inmem"
 )
 
 func TestUserService_CreateUser_errors(t *testing.T) {
@@ -197,3 +201,142 @@ func TestUserService_circuitbreaker(t *testing.T) {
 		})
 	}
 }
+
+func TestUserService_tenant(t *testing.T) {
+	const tenantID = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("X-Tenant-ID")
+		w.Write([]byte(`{}`))
+	}))
+	defer srv.Close()
+
+	c, err := apiclient.NewHTTPClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := account.ContextWithTenant(context.Background(), tenantID)
+	if err = c.CreateUser(ctx, &account.User{}); err != nil {
+		t.Fatal(err)
+	}
+	if got != tenantID {
+		t.Errorf("CreateUser X-Tenant-ID %q, want %q", got, tenantID)
+	}
+}
+
+func TestUserService_protobuf(t *testing.T) {
+	h := api.NewHTTPHandler(api.NewService(inmem.NewClient().User), log.NewNopLogger(), 100)
+	var notProtobuf []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != api.MediaTypeProtobuf {
+			notProtobuf = append(notProtobuf, r.Method+" "+r.URL.Path)
+		}
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	c, err := apiclient.NewHTTPClient(srv.URL, apiclient.WithProtobuf())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := account.ContextWithTenant(context.Background(), "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01")
+	created := account.User{Username: "alice"}
+	if err = c.CreateUser(ctx, &created); err != nil {
+		t.Fatal(err)
+	}
+	err = c.CreateUser(ctx, &account.User{Username: "alice"})
+	if account.ErrorCode(err) != account.EConflict {
+		t.Fatalf("CreateUser error %v, want %s", err, account.EConflict)
+	}
+
+	u, err := c.FindUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID != created.ID {
+		t.Errorf("CreateUser set ID %q, want %q", created.ID, u.ID)
+	}
+	if err = c.ChangeUsername(ctx, u.ID, "bob"); err != nil {
+		t.Fatal(err)
+	}
+	// The former username is redirected to the user resource.
+	got, err := c.FindUserByUsername(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != u.ID || got.Username != "bob" {
+		t.Errorf("FindUserByUsername(alice) = %+v, want user %s named bob", got, u.ID)
+	}
+
+	users, err := c.ListUsers(ctx, account.UserFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].ID != u.ID || users[0].Username != "bob" {
+		t.Errorf("ListUsers() = %+v, want user %s named bob", users, u.ID)
+	}
+
+	if len(notProtobuf) > 0 {
+		t.Errorf("requests without protobuf Accept header: %v", notProtobuf)
+	}
+}
+
+func TestGroupService(t *testing.T) {
+	db := inmem.NewClient()
+	h := api.NewHTTPHandler(
+		api.NewService(db.User),
+		log.NewNopLogger(),
+		100,
+		api.WithGroupService(api.NewGroupService(db.Group)),
+	)
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	c, err := apiclient.NewHTTPGroupClient(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := account.ContextWithTenant(context.Background(), "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01")
+	for _, name := range []string{"sales", "admins", "support"} {
+		if err = c.CreateGroup(ctx, &account.Group{Name: name}); err != nil {
+			t.Fatalf("CreateGroup(%s) failed: %v", name, err)
+		}
+	}
+	err = c.CreateGroup(ctx, &account.Group{Name: "sales"})
+	if account.ErrorCode(err) != account.EConflict {
+		t.Fatalf("CreateGroup error %v, want %s", err, account.EConflict)
+	}
+
+	gg, err := c.ListGroups(ctx, account.GroupFilter{After: "admins", Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gg) != 1 || gg[0].Name != "sales" {
+		t.Fatalf("ListGroups() = %+v, want sales", gg)
+	}
+
+	if err = c.RenameGroup(ctx, gg[0].ID, "marketing"); err != nil {
+		t.Fatal(err)
+	}
+	g, err := c.FindGroupByID(ctx, gg[0].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Name != "marketing" {
+		t.Errorf("FindGroupByID() = %+v, want marketing", g)
+	}
+
+	if err = c.DeleteGroup(ctx, g.ID); err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.FindGroupByID(ctx, g.ID)
+	if account.ErrorCode(err) != account.ENotFound {
+		t.Errorf("FindGroupByID() of deleted group error %v, want %s", err, account.ENotFound)
+	}
+	if gg, err = c.ListGroups(ctx, account.GroupFilter{After: "support"}); err != nil || len(gg) != 0 {
+		t.Errorf("ListGroups() of empty page = %+v, %v, want no groups", gg, err)
+	}
+}
@@ -0,0 +1,41 @@
+package cache
+
+import "time"
+
+// Config configures a UserStorage. Config is set by the ConfigOption values passed to NewUserStorage.
+type Config struct {
+	// size is max number of entries kept in the cache.
+	size int
+	// ttl is how long a found user is cached.
+	ttl time.Duration
+	// negativeTTL is how long a user is remembered as not found.
+	negativeTTL time.Duration
+}
+
+// ConfigOption configures how we set up the UserStorage.
+type ConfigOption func(*Config)
+
+// WithSize sets max number of users kept in the cache, 10000 by default.
+// The least recently used entries are evicted when the cache is full.
+func WithSize(size int) ConfigOption {
+	return func(c *Config) {
+		c.size = size
+	}
+}
+
+// WithTTL sets how long a user is cached, 1 minute by default.
+// The cached user can be stale for that long if it was changed bypassing the cache,
+// e.g., by another instance of the service.
+func WithTTL(ttl time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.ttl = ttl
+	}
+}
+
+// WithNegativeTTL sets how long a user is remembered as not found, 10 seconds by default.
+// Zero duration disables negative caching.
+func WithNegativeTTL(ttl time.Duration) ConfigOption {
+	return func(c *Config) {
+		c.negativeTTL = ttl
+	}
+}
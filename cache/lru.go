@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"container/list"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// key identifies a cached user.
+type key struct {
+	tenantID string
+	userID   string
+}
+
+// String returns the key of the lookup shared by concurrent callers.
+func (k key) String() string {
+	return k.tenantID + "/" + k.userID
+}
+
+// entry is a cached result of FindUserByID: either a user or ENotFound error.
+type entry struct {
+	key       key
+	user      account.User
+	err       error
+	expiresAt time.Time
+}
+
+// lru is a fixed size cache which evicts the least recently used entries.
+// It's not safe for concurrent use.
+type lru struct {
+	size  int
+	ll    *list.List
+	items map[key]*list.Element
+}
+
+func newLRU(size int) *lru {
+	return &lru{
+		size:  size,
+		ll:    list.New(),
+		items: make(map[key]*list.Element),
+	}
+}
+
+// get returns an entry which hasn't expired by now.
+// The expired entry is removed.
+func (c *lru) get(k key, now time.Time) (*entry, bool) {
+	el, ok := c.items[k]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !now.Before(e.expiresAt) {
+		c.removeElement(el)
+		return nil, false
+	}
+	c.ll.MoveToFront(el)
+	return e, true
+}
+
+// add adds or replaces an entry. It reports whether another entry was evicted.
+func (c *lru) add(e *entry) (evicted bool) {
+	if el, ok := c.items[e.key]; ok {
+		el.Value = e
+		c.ll.MoveToFront(el)
+		return false
+	}
+	c.items[e.key] = c.ll.PushFront(e)
+	if c.ll.Len() > c.size {
+		c.removeElement(c.ll.Back())
+		return true
+	}
+	return false
+}
+
+// remove removes an entry if it exists.
+func (c *lru) remove(k key) {
+	if el, ok := c.items[k]; ok {
+		c.removeElement(el)
+	}
+}
+
+func (c *lru) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
+
+func (c *lru) len() int {
+	return c.ll.Len()
+}
@@ -0,0 +1,322 @@
+// Package cache provides a read-through cache of users in front of a UserRepository
+// to spare the database from looking up the same users over and over again.
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// UserStorage caches users found by ID in a LRU cache with TTL.
+// It also remembers for a short time that a user was not found,
+// so repeated lookups of a nonexistent ID don't reach the underlying storage.
+// Concurrent lookups of the same uncached user are merged into one.
+//
+// A user is evicted from the cache when it is created or updated through the UserStorage.
+// Changes made within a transaction evict the user once the transaction ends.
+// Lookups within a transaction or with account.ContextWithReadYourWrites bypass the cache,
+// so the transaction must be started with UserStorage.Transact.
+// The users are loaded with account.ContextWithReadYourWrites, so the cache isn't filled from a lagging replica.
+// Other methods are passed through to the underlying storage.
+//
+// UserStorage is safe for concurrent use.
+type UserStorage struct {
+	next   account.UserRepository
+	config Config
+	now    func() time.Time
+
+	group singleflight.Group
+
+	// mu guards the cache, in-flight lookups and stats.
+	mu      sync.Mutex
+	entries *lru
+	loads   map[key]*load
+	stats   Stats
+}
+
+// load is a lookup of a user in the underlying storage.
+// The stale load's result must not be cached since the user was changed while it was looked up.
+type load struct {
+	stale bool
+}
+
+// Stats shows how efficient the cache is.
+type Stats struct {
+	// Hits is a number of lookups which found a user in the cache.
+	Hits uint64
+	// NegativeHits is a number of lookups which found in the cache that a user doesn't exist.
+	NegativeHits uint64
+	// Misses is a number of lookups which weren't answered by the cache.
+	Misses uint64
+	// Loads is a number of lookups sent to the underlying storage.
+	// It's less than Misses when concurrent lookups of the same user are merged.
+	Loads uint64
+	// Evictions is a number of entries removed to make room for new ones.
+	Evictions uint64
+	// Size is a number of entries in the cache.
+	Size int
+}
+
+// NewUserStorage returns a UserStorage which caches users found in the next repository.
+func NewUserStorage(next account.UserRepository, options ...ConfigOption) *UserStorage {
+	s := UserStorage{
+		next: next,
+		config: Config{
+			size:        10000,
+			ttl:         time.Minute,
+			negativeTTL: 10 * time.Second,
+		},
+		now:   time.Now,
+		loads: make(map[key]*load),
+	}
+	for _, opt := range options {
+		opt(&s.config)
+	}
+	s.entries = newLRU(s.config.size)
+	return &s
+}
+
+// Stats returns the cache statistics collected since the UserStorage was created.
+func (s *UserStorage) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	stats.Size = s.entries.len()
+	return stats
+}
+
+// Transact executes a function within a transaction of the underlying storage.
+// The users changed within the transaction are evicted from the cache when it ends.
+func (s *UserStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) error {
+	if _, ok := s.txFromContext(ctx); ok {
+		return s.next.Transact(ctx, atomic)
+	}
+
+	t := txn{
+		storage: s,
+		keys:    make(map[key]struct{}),
+	}
+	// The users are evicted after the transaction is committed (or rolled back),
+	// otherwise a concurrent lookup could cache them before the changes are visible.
+	defer t.evict()
+
+	return s.next.Transact(ctx, func(ctx context.Context) error {
+		return atomic(context.WithValue(ctx, txKey{}, &t))
+	})
+}
+
+// FindUserByID returns a user by ID from the cache or the underlying storage.
+func (s *UserStorage) FindUserByID(ctx context.Context, id string) (*account.User, error) {
+	tenantID := account.TenantFromContext(ctx)
+	if _, ok := s.txFromContext(ctx); ok || tenantID == "" || account.ReadYourWritesFromContext(ctx) {
+		return s.next.FindUserByID(ctx, id)
+	}
+
+	k := key{tenantID: tenantID, userID: id}
+	s.mu.Lock()
+	if e, ok := s.entries.get(k, s.now()); ok {
+		if e.err != nil {
+			s.stats.NegativeHits++
+			s.mu.Unlock()
+			return nil, e.err
+		}
+		s.stats.Hits++
+		s.mu.Unlock()
+		u := e.user
+		return &u, nil
+	}
+	s.stats.Misses++
+	s.mu.Unlock()
+
+	// The lookup is shared by concurrent callers, so it shouldn't be canceled
+	// when the first caller gives up.
+	ch := s.group.DoChan(k.String(), func() (interface{}, error) {
+		return s.load(context.WithoutCancel(ctx), k)
+	})
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-ch:
+		if r.Err != nil {
+			return nil, r.Err
+		}
+		u := *r.Val.(*account.User)
+		return &u, nil
+	}
+}
+
+// load looks up a user in the underlying storage and caches the result unless the user was changed meanwhile.
+// The lookup requires read-your-writes, otherwise a lagging replica could answer it
+// with the user as it was before the change which evicted it, or not found right after the user was created,
+// and the answer would be cached.
+func (s *UserStorage) load(ctx context.Context, k key) (*account.User, error) {
+	ctx = account.ContextWithReadYourWrites(ctx)
+	ld := load{}
+	s.mu.Lock()
+	s.loads[k] = &ld
+	s.stats.Loads++
+	s.mu.Unlock()
+
+	u, err := s.next.FindUserByID(ctx, k.userID)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.loads[k] == &ld {
+		delete(s.loads, k)
+	}
+	if ld.stale {
+		return u, err
+	}
+
+	e := entry{key: k}
+	switch {
+	case err == nil && s.config.ttl > 0:
+		e.user = *u
+		e.expiresAt = s.now().Add(s.config.ttl)
+	case account.ErrorCode(err) == account.ENotFound && s.config.negativeTTL > 0:
+		e.err = err
+		e.expiresAt = s.now().Add(s.config.negativeTTL)
+	default:
+		return u, err
+	}
+	if s.entries.add(&e) {
+		s.stats.Evictions++
+	}
+	return u, err
+}
+
+// UsernameInUse looks up a user by username in the underlying storage.
+func (s *UserStorage) UsernameInUse(ctx context.Context, username string) (bool, error) {
+	return s.next.UsernameInUse(ctx, username)
+}
+
+// EmailInUse looks up a user by email in the underlying storage.
+func (s *UserStorage) EmailInUse(ctx context.Context, email string) (bool, error) {
+	return s.next.EmailInUse(ctx, email)
+}
+
+// CreateUser creates a new user and evicts it from the cache in case it was remembered as not found.
+func (s *UserStorage) CreateUser(ctx context.Context, user *account.User) error {
+	err := s.next.CreateUser(ctx, user)
+	s.changed(ctx, user.ID)
+	return err
+}
+
+// UpdateUser updates a user and evicts it from the cache.
+func (s *UserStorage) UpdateUser(ctx context.Context, user *account.User) error {
+	err := s.next.UpdateUser(ctx, user)
+	s.changed(ctx, user.ID)
+	return err
+}
+
+// FindUserByUsername returns a user by current username from the underlying storage.
+func (s *UserStorage) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	return s.next.FindUserByUsername(ctx, username)
+}
+
+// CreateUsernameChange adds a username change to the history in the underlying storage.
+func (s *UserStorage) CreateUsernameChange(ctx context.Context, c *account.UsernameChange) error {
+	return s.next.CreateUsernameChange(ctx, c)
+}
+
+// FindUsernameChange returns the latest change where the username was released from the underlying storage.
+func (s *UserStorage) FindUsernameChange(ctx context.Context, username string) (*account.UsernameChange, error) {
+	return s.next.FindUsernameChange(ctx, username)
+}
+
+// ListUsers returns a page of users from the underlying storage.
+func (s *UserStorage) ListUsers(ctx context.Context, filter account.UserFilter) ([]*account.User, error) {
+	return s.next.ListUsers(ctx, filter)
+}
+
+// UserImportStorage is a UserStorage in front of a UserImportRepository.
+// The imported users are evicted from the cache in case they were remembered as not found.
+type UserImportStorage struct {
+	*UserStorage
+	next account.UserImportRepository
+}
+
+// NewUserImportStorage returns a UserImportStorage which caches users found in the next repository.
+func NewUserImportStorage(next account.UserImportRepository, options ...ConfigOption) *UserImportStorage {
+	return &UserImportStorage{
+		UserStorage: NewUserStorage(next, options...),
+		next:        next,
+	}
+}
+
+// ImportUsers creates the users in the underlying storage and evicts them from the cache.
+func (s *UserImportStorage) ImportUsers(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+	err := s.next.ImportUsers(ctx, users, reservedSince)
+	for _, u := range users {
+		s.changed(ctx, u.ID)
+	}
+	return err
+}
+
+// changed evicts a user from the cache right away or when the transaction ends.
+func (s *UserStorage) changed(ctx context.Context, userID string) {
+	k := key{
+		tenantID: account.TenantFromContext(ctx),
+		userID:   userID,
+	}
+	if t, ok := s.txFromContext(ctx); ok {
+		t.add(k)
+		return
+	}
+	s.evict(k)
+}
+
+// evict removes a user from the cache and prevents in-flight lookups from caching it.
+func (s *UserStorage) evict(k key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries.remove(k)
+	if ld, ok := s.loads[k]; ok {
+		ld.stale = true
+		delete(s.loads, k)
+	}
+	// The callers arriving after the eviction shouldn't wait for the stale lookup.
+	s.group.Forget(k.String())
+}
+
+func (s *UserStorage) txFromContext(ctx context.Context) (*txn, bool) {
+	t, ok := ctx.Value(txKey{}).(*txn)
+	if !ok || t.storage != s {
+		return nil, false
+	}
+	return t, true
+}
+
+// txKey is the context key of a transaction in progress.
+type txKey struct{}
+
+// txn keeps track of users changed within a transaction.
+type txn struct {
+	storage *UserStorage
+
+	mu   sync.Mutex
+	keys map[key]struct{}
+}
+
+func (t *txn) add(k key) {
+	t.mu.Lock()
+	t.keys[k] = struct{}{}
+	t.mu.Unlock()
+}
+
+func (t *txn) evict() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k := range t.keys {
+		t.storage.evict(k)
+	}
+}
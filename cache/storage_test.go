@@ -0,0 +1,372 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/inmem"
+	"github.com/marselester/ddd-err/mock"
+	"github.com/marselester/ddd-err/storagetest"
+)
+
+// Ensure UserStorage implements account.UserRepository.
+var _ account.UserRepository = &UserStorage{}
+
+// Ensure UserImportStorage implements account.UserImportRepository.
+var _ account.UserImportRepository = &UserImportStorage{}
+
+const testTenantID = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+
+func TestUserStorage_conformance(t *testing.T) {
+	storagetest.TestUserRepository(t, func(t *testing.T) account.UserRepository {
+		return NewUserStorage(inmem.NewClient().User)
+	})
+}
+
+func TestUserStorage_FindUserByID(t *testing.T) {
+	var calls int
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			calls++
+			if id == "404" {
+				return nil, account.Error{Code: account.ENotFound, Message: "User not found."}
+			}
+			return &account.User{ID: id, Username: "alice"}, nil
+		},
+	}
+	s := NewUserStorage(db, WithTTL(time.Minute), WithNegativeTTL(time.Second))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	for i := 0; i < 3; i++ {
+		u, err := s.FindUserByID(ctx, "123")
+		if err != nil {
+			t.Fatalf("FindUserByID() failed: %v", err)
+		}
+		if u.Username != "alice" {
+			t.Errorf("FindUserByID() got %+v", u)
+		}
+		// The cached user must not be changed by the caller.
+		u.Username = "bob"
+
+		if _, err = s.FindUserByID(ctx, "404"); account.ErrorCode(err) != account.ENotFound {
+			t.Errorf("FindUserByID() expected not found error, got %v", err)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("FindUserByID() called storage %d times, want 2", calls)
+	}
+
+	now = now.Add(2 * time.Second)
+	if _, err := s.FindUserByID(ctx, "404"); account.ErrorCode(err) != account.ENotFound {
+		t.Errorf("FindUserByID() expected not found error, got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("FindUserByID() didn't expire negative entry")
+	}
+
+	want := Stats{Hits: 2, NegativeHits: 2, Misses: 3, Loads: 3, Size: 2}
+	if got := s.Stats(); got != want {
+		t.Errorf("Stats() got %+v, want %+v", got, want)
+	}
+}
+
+func TestUserStorage_FindUserByID_bypass(t *testing.T) {
+	var calls int
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			calls++
+			return &account.User{ID: id}, nil
+		},
+	}
+	s := NewUserStorage(db)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	tt := map[string]func(ctx context.Context) error{
+		"no tenant": func(context.Context) error {
+			_, err := s.FindUserByID(context.Background(), "123")
+			return err
+		},
+		"read your writes": func(ctx context.Context) error {
+			_, err := s.FindUserByID(account.ContextWithReadYourWrites(ctx), "123")
+			return err
+		},
+		"transaction": func(ctx context.Context) error {
+			return s.Transact(ctx, func(ctx context.Context) error {
+				_, err := s.FindUserByID(ctx, "123")
+				return err
+			})
+		},
+	}
+	for name, find := range tt {
+		t.Run(name, func(t *testing.T) {
+			calls = 0
+			for i := 0; i < 2; i++ {
+				if err := find(ctx); err != nil {
+					t.Fatalf("FindUserByID() failed: %v", err)
+				}
+			}
+			if calls != 2 {
+				t.Errorf("FindUserByID() called storage %d times, want 2", calls)
+			}
+		})
+	}
+	if got := s.Stats(); got != (Stats{}) {
+		t.Errorf("Stats() got %+v, want no cache use", got)
+	}
+}
+
+func TestUserStorage_FindUserByID_singleflight(t *testing.T) {
+	const callers = 10
+	var (
+		calls   atomic.Int32
+		release = make(chan struct{})
+	)
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			calls.Add(1)
+			<-release
+			return &account.User{ID: id}, nil
+		},
+	}
+	s := NewUserStorage(db)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.FindUserByID(ctx, "123"); err != nil {
+				t.Errorf("FindUserByID() failed: %v", err)
+			}
+		}()
+	}
+	// Wait until all the callers missed the cache.
+	for s.Stats().Misses != callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("FindUserByID() called storage %d times, want 1", n)
+	}
+	if got := s.Stats(); got.Loads != 1 || got.Size != 1 {
+		t.Errorf("Stats() got %+v", got)
+	}
+}
+
+func TestUserStorage_FindUserByID_canceled(t *testing.T) {
+	release := make(chan struct{})
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			<-release
+			return &account.User{ID: id}, ctx.Err()
+		},
+	}
+	s := NewUserStorage(db)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	canceledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := s.FindUserByID(canceledCtx, "123"); err != context.Canceled {
+		t.Errorf("FindUserByID() expected context canceled, got %v", err)
+	}
+
+	// The lookup keeps running for other callers.
+	close(release)
+	if _, err := s.FindUserByID(ctx, "123"); err != nil {
+		t.Errorf("FindUserByID() failed: %v", err)
+	}
+}
+
+func TestUserStorage_evict(t *testing.T) {
+	db := inmem.NewClient().User
+	s := NewUserStorage(db)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	if _, err := s.FindUserByID(ctx, "123"); account.ErrorCode(err) != account.ENotFound {
+		t.Fatalf("FindUserByID() expected not found error, got %v", err)
+	}
+	u := account.User{ID: "123", Username: "alice", Status: account.StatusActive}
+	if err := s.CreateUser(ctx, &u); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	assertUsername(t, s, ctx, "alice")
+
+	u.Username = "bob"
+	if err := s.UpdateUser(ctx, &u); err != nil {
+		t.Fatalf("UpdateUser() failed: %v", err)
+	}
+	assertUsername(t, s, ctx, "bob")
+
+	err := s.Transact(ctx, func(txCtx context.Context) error {
+		u.Username = "carol"
+		if err := s.UpdateUser(txCtx, &u); err != nil {
+			return err
+		}
+		// The change isn't committed yet, so the cache must still have the old user.
+		assertUsername(t, s, ctx, "bob")
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+	assertUsername(t, s, ctx, "carol")
+
+	// The user was changed bypassing the cache.
+	u.Username = "dave"
+	if err = db.UpdateUser(ctx, &u); err != nil {
+		t.Fatalf("UpdateUser() failed: %v", err)
+	}
+	assertUsername(t, s, ctx, "carol")
+}
+
+func TestUserStorage_lagging_replica(t *testing.T) {
+	// The replica hasn't received the user yet, only the primary has it.
+	var primary, replica *account.User
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			u := replica
+			if account.ReadYourWritesFromContext(ctx) {
+				u = primary
+			}
+			if u == nil {
+				return nil, account.Error{Code: account.ENotFound, Message: "User not found."}
+			}
+			v := *u
+			return &v, nil
+		},
+		CreateUserFn: func(ctx context.Context, u *account.User) error {
+			v := *u
+			primary = &v
+			return nil
+		},
+		UpdateUserFn: func(ctx context.Context, u *account.User) error {
+			v := *u
+			primary = &v
+			return nil
+		},
+	}
+	s := NewUserStorage(db)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	u := account.User{ID: "123", Username: "alice"}
+	if err := s.CreateUser(ctx, &u); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	assertUsername(t, s, ctx, "alice")
+
+	replica = &account.User{ID: "123", Username: "alice"}
+	u.Username = "bob"
+	if err := s.UpdateUser(ctx, &u); err != nil {
+		t.Fatalf("UpdateUser() failed: %v", err)
+	}
+	assertUsername(t, s, ctx, "bob")
+}
+
+func TestUserStorage_evict_in_flight(t *testing.T) {
+	var (
+		username = "alice"
+		loading  = make(chan struct{})
+		release  = make(chan struct{})
+	)
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			u := account.User{ID: id, Username: username}
+			if u.Username == "alice" {
+				close(loading)
+				<-release
+			}
+			return &u, nil
+		},
+		UpdateUserFn: func(ctx context.Context, u *account.User) error {
+			username = u.Username
+			return nil
+		},
+	}
+	s := NewUserStorage(db)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := s.FindUserByID(ctx, "123"); err != nil {
+			t.Errorf("FindUserByID() failed: %v", err)
+		}
+	}()
+
+	// The user is updated while the old version is being loaded.
+	<-loading
+	if err := s.UpdateUser(ctx, &account.User{ID: "123", Username: "bob"}); err != nil {
+		t.Fatalf("UpdateUser() failed: %v", err)
+	}
+	close(release)
+	<-done
+
+	assertUsername(t, s, ctx, "bob")
+}
+
+func TestUserStorage_LRU(t *testing.T) {
+	var calls int
+	db := &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			calls++
+			return &account.User{ID: id}, nil
+		},
+	}
+	s := NewUserStorage(db, WithSize(2))
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	for _, id := range []string{"1", "2", "1", "3", "1", "2"} {
+		if _, err := s.FindUserByID(ctx, id); err != nil {
+			t.Fatalf("FindUserByID() failed: %v", err)
+		}
+	}
+	// User 2 was evicted by 3 as the least recently used, then 3 was evicted by 2.
+	if calls != 4 {
+		t.Errorf("FindUserByID() called storage %d times, want 4", calls)
+	}
+	want := Stats{Hits: 2, Misses: 4, Loads: 4, Evictions: 2, Size: 2}
+	if got := s.Stats(); got != want {
+		t.Errorf("Stats() got %+v, want %+v", got, want)
+	}
+}
+
+func assertUsername(t *testing.T, s *UserStorage, ctx context.Context, username string) {
+	t.Helper()
+
+	u, err := s.FindUserByID(ctx, "123")
+	if err != nil {
+		t.Fatalf("FindUserByID() failed: %v", err)
+	}
+	if u.Username != username {
+		t.Errorf("FindUserByID() got username %q, want %q", u.Username, username)
+	}
+}
+
+func TestUserImportStorage_ImportUsers(t *testing.T) {
+	s := NewUserImportStorage(inmem.NewClient().User)
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	if _, err := s.FindUserByID(ctx, "123"); account.ErrorCode(err) != account.ENotFound {
+		t.Fatalf("FindUserByID() expected not found error, got %v", err)
+	}
+	users := []*account.User{{ID: "123", Username: "alice", Status: account.StatusActive}}
+	if err := s.ImportUsers(ctx, users, time.Now()); err != nil {
+		t.Fatalf("ImportUsers() failed: %v", err)
+	}
+	u, err := s.FindUserByID(ctx, "123")
+	if err != nil {
+		t.Fatalf("FindUserByID() didn't evict the imported user: %v", err)
+	}
+	if u.Username != "alice" {
+		t.Errorf("FindUserByID() got %+v", u)
+	}
+}
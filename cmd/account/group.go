@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// groupCommands are the subcommands of group resource.
+var groupCommands = map[string]command{
+	"create": createGroup,
+	"find":   findGroup,
+	"update": updateGroup,
+	"list":   listGroups,
+	"delete": deleteGroup,
+}
+
+// createGroup creates a group and prints it.
+// The created group is looked up by its ID on the primary database,
+// since a replica might not have the group yet.
+func createGroup(ctx context.Context, a *app, args []string) error {
+	g := account.Group{}
+	fs := newFlagSet(a, "group create", "-name NAME")
+	fs.StringVar(&g.Name, "name", "", "name of the group")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if err := a.groups.CreateGroup(ctx, &g); err != nil {
+		return err
+	}
+	created, err := a.groups.FindGroupByID(account.ContextWithReadYourWrites(ctx), g.ID)
+	if err != nil {
+		return err
+	}
+	return a.printer.printGroups(created)
+}
+
+// findGroup prints a group found by ID.
+func findGroup(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "group find", "ID")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	g, err := a.groups.FindGroupByID(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return a.printer.printGroups(g)
+}
+
+// updateGroup renames a group and prints it.
+// The group is looked up on the primary database to print the new name.
+func updateGroup(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "group update", "-name NAME ID")
+	name := fs.String("name", "", "new name of the group")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if *name == "" {
+		return fmt.Errorf("%w: nothing to update, -name is required", errUsage)
+	}
+
+	id := fs.Arg(0)
+	if err := a.groups.RenameGroup(ctx, id, *name); err != nil {
+		return err
+	}
+	g, err := a.groups.FindGroupByID(account.ContextWithReadYourWrites(ctx), id)
+	if err != nil {
+		return err
+	}
+	return a.printer.printGroups(g)
+}
+
+// listGroups prints a page of groups ordered by name.
+// The next page starts after the name of the last printed group.
+func listGroups(ctx context.Context, a *app, args []string) error {
+	f := account.GroupFilter{}
+	fs := newFlagSet(a, "group list", "[-after NAME] [-limit N]")
+	fs.StringVar(&f.After, "after", "", "group name after which the page starts")
+	fs.IntVar(&f.Limit, "limit", 0, "max number of groups (100 by default)")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	groups, err := a.groups.ListGroups(ctx, f)
+	if err != nil {
+		return err
+	}
+	return a.printer.printGroups(groups...)
+}
+
+// deleteGroup deletes a group by ID.
+// Nothing is printed on success.
+func deleteGroup(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "group delete", "ID")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	return a.groups.DeleteGroup(ctx, fs.Arg(0))
+}
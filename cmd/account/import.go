@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+)
+
+// importUsers creates users in bulk from a CSV or JSONL file and prints them.
+// Either all the users are imported or none of them.
+// The rejected users are logged along with their line numbers in the file.
+func importUsers(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "user import", "[-format csv|jsonl] FILE")
+	format := fs.String("format", "", "file format: csv or jsonl (detected by file extension by default)")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if a.imports == nil {
+		return fmt.Errorf("%w: bulk import is only available over grpc transport", errUsage)
+	}
+
+	filename := fs.Arg(0)
+	if *format == "" {
+		switch strings.ToLower(filepath.Ext(filename)) {
+		case ".csv":
+			*format = "csv"
+		case ".jsonl", ".ndjson":
+			*format = "jsonl"
+		}
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// lines are line numbers of the users in the file.
+	var (
+		users []*account.User
+		lines []int
+	)
+	switch *format {
+	case "csv":
+		users, lines, err = readCSV(f)
+	case "jsonl":
+		users, lines, err = readJSONL(f)
+	default:
+		return fmt.Errorf("%w: unknown file format %q", errUsage, *format)
+	}
+	if err != nil {
+		return fmt.Errorf("could not read users: %w", err)
+	}
+
+	err = a.imports.ImportUsers(ctx, users)
+	var importErr *account.ImportError
+	if errors.As(err, &importErr) {
+		for _, r := range importErr.Rows {
+			var e account.Error
+			errors.As(r.Err, &e)
+			a.logger.Log("msg", "user rejected", "line", lines[r.Row-1], "code", e.Code, "field", e.Field, "err", e.Message)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = a.printer.printUsers(users...); err != nil {
+		return err
+	}
+	a.logger.Log("msg", "users imported", "count", len(users))
+	return nil
+}
+
+// readCSV reads users from CSV with a header which names the columns.
+// It returns the users and their line numbers.
+func readCSV(r io.Reader) ([]*account.User, []int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("csv header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+	if _, ok := columns["username"]; !ok {
+		return nil, nil, fmt.Errorf("csv header has no username column")
+	}
+	field := func(record []string, name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	var (
+		users []*account.User
+		lines []int
+	)
+	for {
+		record, err := cr.Read()
+		if errors.Is(err, io.EOF) {
+			return users, lines, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		line, _ := cr.FieldPos(0)
+		users = append(users, &account.User{
+			Username:    field(record, "username"),
+			Email:       field(record, "email"),
+			DisplayName: field(record, "display_name"),
+		})
+		lines = append(lines, line)
+	}
+}
+
+// readJSONL reads users from JSON objects separated by new lines. Blank lines are skipped.
+// It returns the users and their line numbers.
+func readJSONL(r io.Reader) ([]*account.User, []int, error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1024*1024)
+
+	var (
+		users []*account.User
+		lines []int
+	)
+	for line := 1; s.Scan(); line++ {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+
+		var req api.CreateUserReq
+		if err := json.Unmarshal(s.Bytes(), &req); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		users = append(users, &account.User{
+			Username:    req.Username,
+			Email:       req.Email,
+			DisplayName: req.DisplayName,
+		})
+		lines = append(lines, line)
+	}
+	return users, lines, s.Err()
+}
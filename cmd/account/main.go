@@ -0,0 +1,283 @@
+// Program account manages users and customer groups at API server over gRPC or HTTP.
+//
+// Usage:
+//
+//	account [flags] user|group COMMAND [flags] [ARG]
+//
+// The user commands are:
+//
+//	create -username NAME [-email EMAIL] [-display-name NAME]
+//	find ID
+//	find -username NAME
+//	update -username NAME ID
+//	list [-after NAME] [-limit N]
+//	suspend|reactivate|lock ID
+//	verify-email TOKEN
+//	delete ID
+//	import [-format csv|jsonl] FILE
+//
+// The group commands are:
+//
+//	create -name NAME
+//	find ID
+//	update -name NAME ID
+//	list [-after NAME] [-limit N]
+//	delete ID
+//
+// The users and groups are listed in pages ordered by name,
+// the next page starts after the name of the last printed entity.
+//
+// The users and groups are printed as tab-separated values (-output plain),
+// as aligned columns with a header (-output table), or as JSON object per line (-output json).
+//
+// The exit code tells why a command failed, so scripts can branch on it:
+//
+//	0 success
+//	1 internal error, e.g., the server is unavailable
+//	2 invalid usage
+//	3 not_found
+//	4 conflict or username_reserved
+//	5 invalid input, e.g., invalid_username, invalid_group_name or import_rejected
+//	6 rate_limit or concurrent_update, the command can be retried
+//	7 unauthenticated or feature_disabled
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/go-kit/log"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/apiclient"
+)
+
+// Exit codes of the program.
+const (
+	exitOK = iota
+	exitInternal
+	exitUsage
+	exitNotFound
+	exitConflict
+	exitInvalid
+	exitRetry
+	exitDenied
+)
+
+// exitCodes maps the domain error codes to the exit codes.
+// The codes which aren't listed result in exitInternal.
+var exitCodes = map[string]int{
+	account.ENotFound:                 exitNotFound,
+	account.EConflict:                 exitConflict,
+	account.EUsernameReserved:         exitConflict,
+	account.EInvalidUserID:            exitInvalid,
+	account.EInvalidUsername:          exitInvalid,
+	account.EInvalidGroupID:           exitInvalid,
+	account.EInvalidGroupName:         exitInvalid,
+	account.EInvalidEmail:             exitInvalid,
+	account.EInvalidDisplayName:       exitInvalid,
+	account.EInvalidVerificationToken: exitInvalid,
+	account.EInvalidTenant:            exitInvalid,
+	account.EInvalidTimeRange:         exitInvalid,
+	account.EInvalidLimit:             exitInvalid,
+	account.EInvalidStateTransition:   exitInvalid,
+	account.EImportRejected:           exitInvalid,
+	account.ENotAcceptable:            exitInvalid,
+	account.EUnsupportedMediaType:     exitInvalid,
+	account.EMalformedRequest:         exitInvalid,
+	account.EPayloadTooLarge:          exitInvalid,
+	account.ERateLimit:                exitRetry,
+	account.EConcurrentUpdate:         exitRetry,
+	account.EUnauthenticated:          exitDenied,
+	account.EFeatureDisabled:          exitDenied,
+}
+
+// errUsage indicates that the program was called with invalid arguments.
+var errUsage = errors.New("invalid usage")
+
+// exitCode returns the exit code for the error returned by a command.
+func exitCode(err error) int {
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return exitOK
+	case errors.Is(err, errUsage):
+		return exitUsage
+	}
+
+	code := account.ErrorCode(err)
+	// The server rejects unauthenticated and malformed gRPC requests before they reach the API.
+	if code == "" {
+		switch status.Code(err) {
+		case codes.Unauthenticated:
+			code = account.EUnauthenticated
+		case codes.InvalidArgument:
+			code = account.EMalformedRequest
+		}
+	}
+	if c, ok := exitCodes[code]; ok {
+		return c
+	}
+	return exitInternal
+}
+
+const usage = `Usage: account [flags] user|group COMMAND [flags] [ARG]
+
+User commands:
+  create -username NAME [-email EMAIL] [-display-name NAME]
+  find ID
+  find -username NAME
+  update -username NAME ID
+  list [-after NAME] [-limit N]
+  suspend|reactivate|lock ID
+  verify-email TOKEN
+  delete ID
+  import [-format csv|jsonl] FILE
+
+Group commands:
+  create -name NAME
+  find ID
+  update -name NAME ID
+  list [-after NAME] [-limit N]
+  delete ID
+
+Flags:
+`
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		sigint := make(chan os.Signal, 1)
+		signal.Notify(sigint, os.Interrupt)
+		<-sigint
+
+		cancel()
+	}()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command line and returns the exit code.
+// The results are printed to stdout, and the errors are logged to stderr.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("account", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	var (
+		transport = fs.String("transport", "grpc", "API transport: grpc or http")
+		grpcAddr  = fs.String("grpc", ":8080", "gRPC API address")
+		httpURL   = fs.String("http", "http://localhost:8000", "HTTP API base URL")
+		tenantID  = fs.String("tenant", os.Getenv("ACCOUNT_TENANT"), "tenant ID, ACCOUNT_TENANT env variable by default")
+		actor     = fs.String("actor", "", "ID of the principal who manages users")
+		apiKey    = fs.String("api-key", os.Getenv("ACCOUNT_API_KEY"), "API key, ACCOUNT_API_KEY env variable by default")
+		output    = fs.String("output", "plain", "output format: plain, table or json")
+		timeout   = fs.Duration("timeout", 30*time.Second, "time limit of the command")
+	)
+	if err := fs.Parse(args); err != nil {
+		return exitCode(err)
+	}
+
+	var logger log.Logger
+	{
+		logger = log.NewJSONLogger(log.NewSyncWriter(stderr))
+		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+		logger = log.With(logger, "caller", log.DefaultCaller)
+	}
+
+	p, err := newPrinter(*output, stdout)
+	if err != nil {
+		logger.Log("msg", "invalid output", "err", err)
+		return exitUsage
+	}
+	commands := map[string]map[string]command{
+		"user":  userCommands,
+		"group": groupCommands,
+	}
+	cmd, ok := commands[fs.Arg(0)][fs.Arg(1)]
+	if !ok {
+		fs.Usage()
+		return exitUsage
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, *timeout)
+	defer cancel()
+	if *tenantID != "" {
+		ctx = account.ContextWithTenant(ctx, *tenantID)
+	}
+	if *actor != "" {
+		ctx = account.ContextWithActor(ctx, *actor)
+	}
+
+	a := app{
+		printer: p,
+		stderr:  stderr,
+		logger:  logger,
+	}
+	switch *transport {
+	case "grpc":
+		dialOptions := []grpc.DialOption{grpc.WithInsecure()}
+		if *apiKey != "" {
+			dialOptions = append(dialOptions, apiclient.WithGRPCAPIKey(*apiKey))
+		}
+		conn, err := grpc.DialContext(ctx, *grpcAddr, dialOptions...)
+		if err != nil {
+			logger.Log("msg", "grpc dial", "err", err)
+			return exitInternal
+		}
+		defer conn.Close()
+
+		a.users = apiclient.NewGRPCUserClient(conn)
+		a.groups = apiclient.NewGRPCGroupClient(conn)
+		a.imports = apiclient.NewGRPCUserImportClient(conn)
+	case "http":
+		var clientOptions []apiclient.HTTPClientOption
+		if *apiKey != "" {
+			clientOptions = append(clientOptions, apiclient.WithAPIKey(*apiKey))
+		}
+		if a.users, err = apiclient.NewHTTPClient(*httpURL, clientOptions...); err != nil {
+			logger.Log("msg", "invalid HTTP API URL", "err", err)
+			return exitUsage
+		}
+		if a.groups, err = apiclient.NewHTTPGroupClient(*httpURL, clientOptions...); err != nil {
+			logger.Log("msg", "invalid HTTP API URL", "err", err)
+			return exitUsage
+		}
+	default:
+		logger.Log("msg", "unknown transport", "transport", *transport)
+		return exitUsage
+	}
+
+	name := fs.Arg(0) + " " + fs.Arg(1)
+	err = cmd(ctx, &a, fs.Args()[2:])
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, errUsage):
+		logger.Log("msg", name, "err", err)
+	default:
+		logger.Log("msg", name+" failed", "code", account.ErrorCode(err), "err", err)
+	}
+	return exitCode(err)
+}
+
+// app holds the API clients which the commands use.
+type app struct {
+	users  account.UserService
+	groups account.GroupService
+	// imports is nil when the transport doesn't support bulk import.
+	imports account.UserImportService
+	printer *printer
+	// stderr is where the usage of the commands is printed.
+	stderr io.Writer
+	logger log.Logger
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+	"github.com/marselester/ddd-err/inmem"
+)
+
+const tenantID = "7a0c1f39-8c1e-4b6b-9a57-2d6f1bb3a5d1"
+
+func TestExitCode(t *testing.T) {
+	tt := []struct {
+		err  error
+		want int
+	}{
+		{nil, exitOK},
+		{fmt.Errorf("%w: 1 arguments expected, got 0", errUsage), exitUsage},
+		{account.Error{Code: account.ENotFound}, exitNotFound},
+		{account.Error{Code: account.EUsernameReserved}, exitConflict},
+		{account.Error{Code: account.EInvalidUsername}, exitInvalid},
+		{account.Error{Code: account.ERateLimit}, exitRetry},
+		{status.Error(codes.Unauthenticated, "API key is missing or invalid."), exitDenied},
+		{status.Error(codes.InvalidArgument, "Request is not a valid CreateUserRequest message."), exitInvalid},
+		{account.Error{Code: account.EInternal}, exitInternal},
+		{errors.New("connection refused"), exitInternal},
+	}
+	for _, tc := range tt {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Errorf("exitCode(%v) = %d want %d", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRun_http(t *testing.T) {
+	db := inmem.NewClient()
+	srv := httptest.NewServer(api.NewHTTPHandler(
+		api.NewService(db.User),
+		log.NewNopLogger(),
+		100,
+		api.WithGroupService(api.NewGroupService(db.Group)),
+	))
+	defer srv.Close()
+
+	// account runs the command line and returns its exit code and output.
+	account := func(args ...string) (int, string) {
+		args = append([]string{"-transport", "http", "-http", srv.URL, "-tenant", tenantID, "-output", "json"}, args...)
+		var stdout, stderr bytes.Buffer
+		code := run(context.Background(), args, &stdout, &stderr)
+		return code, stdout.String()
+	}
+
+	code, out := account("user", "create", "-username", "alice", "-email", "alice@example.com")
+	if code != exitOK {
+		t.Fatalf("user create exit code %d want %d", code, exitOK)
+	}
+	id := strings.Split(out, `"`)[3]
+	if !strings.Contains(out, `"username":"alice","status":"active","email":"alice@example.com"`) {
+		t.Errorf("user create printed %s", out)
+	}
+
+	code, out = account("group", "create", "-name", "sales")
+	if code != exitOK {
+		t.Fatalf("group create exit code %d want %d", code, exitOK)
+	}
+	groupID := strings.Split(out, `"`)[3]
+
+	tt := []struct {
+		args []string
+		code int
+		out  string
+	}{
+		{[]string{"user", "create", "-username", "alice"}, exitConflict, ""},
+		{[]string{"user", "list"}, exitOK, `"username":"alice"`},
+		{[]string{"user", "list", "-after", "alice"}, exitOK, ""},
+		{[]string{"user", "create", "-username", ""}, exitInvalid, ""},
+		{[]string{"user", "update", "-username", "alice2", id}, exitOK, `"username":"alice2"`},
+		{[]string{"user", "find", "-username", "alice"}, exitOK, `"username":"alice2"`},
+		{[]string{"user", "suspend", id}, exitOK, ""},
+		{[]string{"user", "find", id}, exitOK, `"status":"suspended"`},
+		{[]string{"user", "delete", id}, exitOK, ""},
+		{[]string{"user", "find", id}, exitOK, `"status":"deleted"`},
+		{[]string{"user", "find", "-username", "bob"}, exitNotFound, ""},
+		{[]string{"user", "find"}, exitUsage, ""},
+		{[]string{"user", "rename", id}, exitUsage, ""},
+		{[]string{"user", "import", "users.csv"}, exitUsage, ""},
+		{[]string{"group", "create", "-name", "sales"}, exitConflict, ""},
+		{[]string{"group", "create", "-name", " sales"}, exitInvalid, ""},
+		{[]string{"group", "update", "-name", "marketing", groupID}, exitOK, `"name":"marketing"`},
+		{[]string{"group", "list", "-limit", "1"}, exitOK, `"name":"marketing"`},
+		{[]string{"group", "find", "sales"}, exitInvalid, ""},
+		{[]string{"group", "delete", groupID}, exitOK, ""},
+		{[]string{"group", "find", groupID}, exitNotFound, ""},
+		{[]string{"group", "rename", groupID}, exitUsage, ""},
+	}
+	for _, tc := range tt {
+		code, out := account(tc.args...)
+		if code != tc.code {
+			t.Errorf("%v exit code %d want %d", tc.args, code, tc.code)
+		}
+		if !strings.Contains(out, tc.out) {
+			t.Errorf("%v printed %q want %q", tc.args, out, tc.out)
+		}
+	}
+}
+
+func TestPrinter(t *testing.T) {
+	u := account.User{ID: "123", Username: "alice", Status: account.StatusActive, EmailVerified: true}
+	tt := map[string]string{
+		outputPlain: "123\talice\tactive\t\t\ttrue\n",
+		outputTable: "ID   USERNAME  STATUS  EMAIL  DISPLAY NAME  EMAIL VERIFIED\n" +
+			"123  alice     active                       true\n",
+		outputJSON: `{"id":"123","username":"alice","status":"active","email":"","display_name":"","email_verified":true}` + "\n",
+	}
+	for format, want := range tt {
+		var b bytes.Buffer
+		p, err := newPrinter(format, &b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = p.printUsers(&u); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != want {
+			t.Errorf("%s printed %q want %q", format, b.String(), want)
+		}
+	}
+}
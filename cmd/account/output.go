@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// Output formats.
+const (
+	outputPlain = "plain"
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// printer prints users and groups to w in the output format.
+type printer struct {
+	format string
+	w      io.Writer
+}
+
+// newPrinter returns a printer of the output format: plain, table or json.
+func newPrinter(format string, w io.Writer) (*printer, error) {
+	switch format {
+	case outputPlain, outputTable, outputJSON:
+		return &printer{format: format, w: w}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+// userJSON is a user printed in json output format.
+type userJSON struct {
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	Status        string `json:"status"`
+	Email         string `json:"email"`
+	DisplayName   string `json:"display_name"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// printUsers prints the users:
+// tab-separated fields per line in plain format,
+// aligned columns with a header in table format,
+// and JSON object per line in json format.
+func (p *printer) printUsers(users ...*account.User) error {
+	switch p.format {
+	case outputJSON:
+		enc := json.NewEncoder(p.w)
+		for _, u := range users {
+			err := enc.Encode(userJSON{
+				ID:            u.ID,
+				Username:      u.Username,
+				Status:        u.Status,
+				Email:         u.Email,
+				DisplayName:   u.DisplayName,
+				EmailVerified: u.EmailVerified,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputTable:
+		tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tUSERNAME\tSTATUS\tEMAIL\tDISPLAY NAME\tEMAIL VERIFIED")
+		for _, u := range users {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%t\n", u.ID, u.Username, u.Status, u.Email, u.DisplayName, u.EmailVerified)
+		}
+		return tw.Flush()
+	default:
+		for _, u := range users {
+			_, err := fmt.Fprintf(p.w, "%s\t%s\t%s\t%s\t%s\t%t\n", u.ID, u.Username, u.Status, u.Email, u.DisplayName, u.EmailVerified)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// groupJSON is a group printed in json output format.
+type groupJSON struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// printGroups prints the groups in the same formats as printUsers.
+func (p *printer) printGroups(groups ...*account.Group) error {
+	switch p.format {
+	case outputJSON:
+		enc := json.NewEncoder(p.w)
+		for _, g := range groups {
+			if err := enc.Encode(groupJSON{ID: g.ID, Name: g.Name}); err != nil {
+				return err
+			}
+		}
+		return nil
+	case outputTable:
+		tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME")
+		for _, g := range groups {
+			fmt.Fprintf(tw, "%s\t%s\n", g.ID, g.Name)
+		}
+		return tw.Flush()
+	default:
+		for _, g := range groups {
+			if _, err := fmt.Fprintf(p.w, "%s\t%s\n", g.ID, g.Name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// command executes a subcommand with its arguments.
+type command func(ctx context.Context, a *app, args []string) error
+
+// userCommands are the subcommands of user resource.
+var userCommands = map[string]command{
+	"create":       createUser,
+	"find":         findUser,
+	"update":       updateUser,
+	"suspend":      changeUserStatus("suspend", account.UserService.SuspendUser),
+	"reactivate":   changeUserStatus("reactivate", account.UserService.ReactivateUser),
+	"lock":         changeUserStatus("lock", account.UserService.LockUser),
+	"verify-email": verifyEmail,
+	"delete":       changeUserStatus("delete", account.UserService.DeleteUser),
+	"import":       importUsers,
+	"list":         listUsers,
+}
+
+// parseArgs parses the flags of a subcommand and checks the number of its positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string, nargs int) error {
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	return checkArgs(fs, nargs)
+}
+
+// parseFlags parses the flags of a subcommand.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return nil
+}
+
+// checkArgs checks the number of positional arguments of a subcommand.
+func checkArgs(fs *flag.FlagSet, nargs int) error {
+	if fs.NArg() != nargs {
+		return fmt.Errorf("%w: %d arguments expected, got %d", errUsage, nargs, fs.NArg())
+	}
+	return nil
+}
+
+// newFlagSet returns a flag set of the subcommand, e.g., "user create",
+// which prints its errors to stderr of the program.
+func newFlagSet(a *app, name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: account [flags] %s %s\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// createUser creates a user and prints it.
+// The created user is looked up by its ID on the primary database,
+// since a replica might not have the user yet.
+func createUser(ctx context.Context, a *app, args []string) error {
+	u := account.User{}
+	fs := newFlagSet(a, "user create", "-username NAME [-email EMAIL] [-display-name NAME]")
+	fs.StringVar(&u.Username, "username", "", "username of the user")
+	fs.StringVar(&u.Email, "email", "", "email of the user")
+	fs.StringVar(&u.DisplayName, "display-name", "", "display name of the user")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	if err := a.users.CreateUser(ctx, &u); err != nil {
+		return err
+	}
+	created, err := a.users.FindUserByID(account.ContextWithReadYourWrites(ctx), u.ID)
+	if err != nil {
+		return err
+	}
+	return a.printer.printUsers(created)
+}
+
+// findUser prints a user found by ID or by current or former username.
+func findUser(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "user find", "ID | -username NAME")
+	username := fs.String("username", "", "current or former username of the user")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	nargs := 1
+	if *username != "" {
+		nargs = 0
+	}
+	if err := checkArgs(fs, nargs); err != nil {
+		return err
+	}
+
+	var (
+		u   *account.User
+		err error
+	)
+	if *username != "" {
+		u, err = a.users.FindUserByUsername(ctx, *username)
+	} else {
+		u, err = a.users.FindUserByID(ctx, fs.Arg(0))
+	}
+	if err != nil {
+		return err
+	}
+	return a.printer.printUsers(u)
+}
+
+// updateUser renames a user and prints it.
+func updateUser(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "user update", "-username NAME ID")
+	username := fs.String("username", "", "new username of the user")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+	if *username == "" {
+		return fmt.Errorf("%w: nothing to update, -username is required", errUsage)
+	}
+
+	id := fs.Arg(0)
+	if err := a.users.ChangeUsername(ctx, id, *username); err != nil {
+		return err
+	}
+	u, err := a.users.FindUserByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return a.printer.printUsers(u)
+}
+
+// changeUserStatus returns a command which changes the status of a user by ID, e.g., suspends the user.
+// Nothing is printed on success.
+func changeUserStatus(name string, change func(s account.UserService, ctx context.Context, id string) error) command {
+	return func(ctx context.Context, a *app, args []string) error {
+		fs := newFlagSet(a, "user "+name, "ID")
+		if err := parseArgs(fs, args, 1); err != nil {
+			return err
+		}
+		return change(a.users, ctx, fs.Arg(0))
+	}
+}
+
+// verifyEmail confirms the email of a user by the token and prints the user.
+// Only the user ID is known after verification.
+func verifyEmail(ctx context.Context, a *app, args []string) error {
+	fs := newFlagSet(a, "user verify-email", "TOKEN")
+	if err := parseArgs(fs, args, 1); err != nil {
+		return err
+	}
+
+	u, err := a.users.VerifyEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return a.printer.printUsers(u)
+}
+
+// listUsers prints a page of users ordered by username.
+// The next page starts after the username of the last printed user.
+func listUsers(ctx context.Context, a *app, args []string) error {
+	f := account.UserFilter{}
+	fs := newFlagSet(a, "user list", "[-after NAME] [-limit N]")
+	fs.StringVar(&f.After, "after", "", "username after which the page starts")
+	fs.IntVar(&f.Limit, "limit", 0, "max number of users (100 by default)")
+	if err := parseArgs(fs, args, 0); err != nil {
+		return err
+	}
+
+	users, err := a.users.ListUsers(ctx, f)
+	if err != nil {
+		return err
+	}
+	return a.printer.printUsers(users...)
+}
@@ -0,0 +1,124 @@
+// Program errcatalog prints the catalog of API error codes:
+// their HTTP status and gRPC code, public message, retryability,
+// and the operations which may return them.
+//
+// Usage:
+//
+//	errcatalog [-format markdown|json|openapi] [-o FILE]
+//
+// The openapi format is a components fragment of OpenAPI 3 document
+// with Error schemas and error responses per HTTP status.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-kit/log"
+
+	"github.com/marselester/ddd-err/api"
+)
+
+func main() {
+	format := flag.String("format", "markdown", "catalog format: markdown, json or openapi")
+	output := flag.String("o", "", "file to write the catalog to instead of stdout")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	exitCode := 1
+	defer func() { os.Exit(exitCode) }()
+
+	var logger log.Logger
+	{
+		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
+		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+		logger = log.With(logger, "caller", log.DefaultCaller)
+	}
+
+	var b bytes.Buffer
+	catalog := api.ErrorCatalog()
+	switch *format {
+	case "markdown":
+		writeMarkdown(&b, catalog)
+	case "json":
+		writeJSON(&b, catalog)
+	case "openapi":
+		writeJSON(&b, map[string]interface{}{"components": api.OpenAPIErrorComponents()})
+	default:
+		logger.Log("msg", "unknown catalog format", "format", *format)
+		return
+	}
+
+	var err error
+	if *output == "" {
+		_, err = b.WriteTo(os.Stdout)
+	} else {
+		err = os.WriteFile(*output, b.Bytes(), 0o644)
+	}
+	if err != nil {
+		logger.Log("msg", "could not write catalog", "err", err)
+		return
+	}
+
+	exitCode = 0
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		panic(err)
+	}
+}
+
+// writeMarkdown writes the catalog as a Markdown table followed by the operations of every code.
+func writeMarkdown(w io.Writer, catalog []api.ErrorInfo) {
+	fmt.Fprint(w, `# API errors
+
+<!-- Code generated by errcatalog. DO NOT EDIT. -->
+
+An API error has a machine-readable code and a human-readable message.
+A validation error also names the request field which failed validation.
+
+`+"```json"+`
+{"error":{"code":"invalid_username","message":"Username is invalid.","field":"username"}}
+`+"```"+`
+
+HTTP API responds with the status of the error code.
+gRPC API sends the error in the error field of a response with OK status,
+except for the requests which are rejected before they reach the service, e.g., unauthenticated ones.
+Those get the gRPC status code listed below, and "-" marks the errors returned only by HTTP API.
+The retryable errors might not occur if the same request is sent later.
+The operations are advisory, i.e., an operation might return an error which isn't listed.
+
+| Code | HTTP status | gRPC code | Retryable | Description |
+| --- | --- | --- | --- | --- |
+`)
+	for _, e := range catalog {
+		retryable := "no"
+		if e.Retryable {
+			retryable = "yes"
+		}
+		grpcCode := e.GRPCCode
+		if grpcCode == "" {
+			grpcCode = "-"
+		}
+		fmt.Fprintf(w, "| `%s` | %d %s | %s | %s | %s |\n", e.Code, e.HTTPStatus, http.StatusText(e.HTTPStatus), grpcCode, retryable, e.Description)
+	}
+
+	for _, e := range catalog {
+		fmt.Fprintf(w, "\n## %s\n\n", e.Code)
+		fmt.Fprintf(w, "Example message: %s\n\n", e.Message)
+		fmt.Fprintf(w, "Operations: %s.\n", strings.Join(e.Operations, ", "))
+	}
+}
@@ -0,0 +1,17 @@
+// Program errlint checks the domain error discipline, see errlint package.
+//
+// Usage:
+//
+//	errlint [flags] PACKAGES
+//	go vet -vettool=$(which errlint) PACKAGES
+package main
+
+import (
+	"golang.org/x/tools/go/analysis/singlechecker"
+
+	"github.com/marselester/ddd-err/errlint"
+)
+
+func main() {
+	singlechecker.Main(errlint.Analyzer)
+}
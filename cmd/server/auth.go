@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// authenticator authenticates API clients by API keys sent as bearer tokens.
+// An authenticated client is scoped to the tenant of its key, and
+// the actor of the key is recorded in the audit trail.
+// The actor the client sent in X-Actor-ID header is recorded only as an unverified claimed actor.
+type authenticator struct {
+	keys map[string]apiKey
+}
+
+// newAuthenticator returns an authenticator of the API keys.
+// It returns nil if there are no keys, i.e., the clients aren't authenticated.
+func newAuthenticator(keys []apiKey) *authenticator {
+	if len(keys) == 0 {
+		return nil
+	}
+	a := authenticator{
+		keys: make(map[string]apiKey, len(keys)),
+	}
+	for _, k := range keys {
+		a.keys[k.Key] = k
+	}
+	return &a
+}
+
+// errUnauthenticated is returned when API key is missing or unknown.
+var errUnauthenticated = account.Error{
+	Code:    account.EUnauthenticated,
+	Message: "API key is missing or invalid.",
+}
+
+// authenticate returns the API key by its bearer token from the authorization header, e.g., "Bearer s3cr3t".
+func (a *authenticator) authenticate(authorization string) (apiKey, bool) {
+	token, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok {
+		return apiKey{}, false
+	}
+	k, ok := a.keys[token]
+	return k, ok
+}
+
+// contextWithPrincipal stores the tenant and the actor of the API key in ctx.
+func contextWithPrincipal(ctx context.Context, k apiKey) context.Context {
+	ctx = account.ContextWithTenant(ctx, k.TenantID)
+	if k.Actor != "" {
+		ctx = account.ContextWithActor(ctx, k.Actor)
+	}
+	return ctx
+}
+
+// httpHandler authenticates HTTP requests before passing them to next handler.
+// The OpenAPI document is public, so its requests aren't authenticated.
+func (a *authenticator) httpHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/openapi.json" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		k, ok := a.authenticate(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(struct {
+				Err account.Error `json:"error"`
+			}{errUnauthenticated})
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(contextWithPrincipal(r.Context(), k)))
+	})
+}
+
+// grpcContext authenticates a gRPC request by its authorization metadata.
+func (a *authenticator) grpcContext(ctx context.Context) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var authorization string
+	if v := md.Get("authorization"); len(v) > 0 {
+		authorization = v[0]
+	}
+	k, ok := a.authenticate(authorization)
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, errUnauthenticated.Message)
+	}
+	return contextWithPrincipal(ctx, k), nil
+}
+
+// unaryInterceptor authenticates unary gRPC requests.
+func (a *authenticator) unaryInterceptor(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := a.grpcContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// streamInterceptor authenticates streaming gRPC requests, e.g., a bulk import.
+func (a *authenticator) streamInterceptor(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.grpcContext(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticatedStream is a server stream with the context of the authenticated client.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+// Context returns the context of the authenticated client.
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
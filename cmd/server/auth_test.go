@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/api"
+)
+
+func TestAuthenticator_httpHandler(t *testing.T) {
+	auth := newAuthenticator([]apiKey{
+		{Key: "s3cr3t", TenantID: "7a0c1f39-8c1e-4b6b-9a57-2d6f1bb3a5d1", Actor: "importer"},
+	})
+	var tenantID, actor string
+	h := auth.httpHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenantID = account.TenantFromContext(r.Context())
+		actor = account.ActorFromContext(r.Context())
+	}))
+
+	tt := map[string]int{
+		"":              http.StatusUnauthorized,
+		"s3cr3t":        http.StatusUnauthorized,
+		"Bearer qwerty": http.StatusUnauthorized,
+		"Bearer s3cr3t": http.StatusOK,
+	}
+	for authorization, want := range tt {
+		r := httptest.NewRequest(http.MethodGet, "/v1/users/123", nil)
+		r.Header.Set("Authorization", authorization)
+		r.Header.Set("X-Actor-ID", "admin")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			t.Errorf("Authorization %q status code: %d, want %d", authorization, w.Code, want)
+		}
+	}
+
+	if tenantID != "7a0c1f39-8c1e-4b6b-9a57-2d6f1bb3a5d1" || actor != "importer" {
+		t.Errorf("authenticated tenant %q actor %q want the principal of the API key", tenantID, actor)
+	}
+}
+
+func TestAuthenticator_grpcContext(t *testing.T) {
+	auth := newAuthenticator([]apiKey{{Key: "s3cr3t", TenantID: "7a0c1f39-8c1e-4b6b-9a57-2d6f1bb3a5d1"}})
+
+	var want string
+	for _, info := range api.ErrorCatalog() {
+		if info.Code == account.EUnauthenticated {
+			want = info.GRPCCode
+		}
+	}
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer qwerty"))
+	if _, err := auth.grpcContext(ctx); status.Code(err).String() != want {
+		t.Errorf("grpcContext() got status %q, want %q from the error catalog", status.Code(err), want)
+	}
+
+	ctx = metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer s3cr3t"))
+	ctx, err := auth.grpcContext(ctx)
+	if err != nil {
+		t.Fatalf("grpcContext() failed: %v", err)
+	}
+	if tenantID := account.TenantFromContext(ctx); tenantID != "7a0c1f39-8c1e-4b6b-9a57-2d6f1bb3a5d1" {
+		t.Errorf("grpcContext() authenticated tenant %q want the tenant of the API key", tenantID)
+	}
+}
+
+func TestAuthenticator_httpHandlerOpenAPI(t *testing.T) {
+	auth := newAuthenticator([]apiKey{{Key: "s3cr3t"}})
+	h := auth.httpHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /openapi.json status code: %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestNewAuthenticator_disabled(t *testing.T) {
+	if auth := newAuthenticator(nil); auth != nil {
+		t.Errorf("newAuthenticator(nil) = %+v want nil", auth)
+	}
+}
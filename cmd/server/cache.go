@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+
+	"github.com/marselester/ddd-err/cache"
+)
+
+// newCache returns a cache of users found by ID in front of the storage db.
+func newCache(cfg cacheConfig, db *storage) *cache.UserImportStorage {
+	return cache.NewUserImportStorage(
+		db.user,
+		cache.WithSize(cfg.Size),
+		cache.WithTTL(time.Duration(cfg.TTL)),
+		cache.WithNegativeTTL(time.Duration(cfg.NegativeTTL)),
+	)
+}
+
+// logCacheStats logs the cache statistics every interval until the context is done.
+func logCacheStats(ctx context.Context, c *cache.UserImportStorage, interval time.Duration, logger log.Logger) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			s := c.Stats()
+			logger.Log(
+				"msg", "cache stats",
+				"hits", s.Hits,
+				"negative_hits", s.NegativeHits,
+				"misses", s.Misses,
+				"loads", s.Loads,
+				"evictions", s.Evictions,
+				"size", s.Size,
+			)
+		}
+	}
+}
@@ -0,0 +1,474 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is a prefix of environment variables which configure the server.
+const envPrefix = "ACCOUNT_"
+
+// redacted replaces secrets when the config is printed.
+const redacted = "REDACTED"
+
+// config is the server configuration.
+// The settings are taken from the following sources in the order of increasing precedence:
+//
+//  1. defaults (see defaultConfig),
+//  2. a YAML, TOML or JSON config file set by -config flag or ACCOUNT_CONFIG env variable,
+//  3. ACCOUNT_* environment variables named after the flags, e.g., ACCOUNT_HTTP_READ_TIMEOUT,
+//  4. flags, e.g., -http-read-timeout.
+//
+// API keys, storage replicas and username policy can be set only in the config file.
+// Rate limits, logging, features and username policy can be reloaded without restarting the server (see reloader).
+type config struct {
+	HTTP      httpConfig      `yaml:"http" json:"http" toml:"http"`
+	GRPC      grpcConfig      `yaml:"grpc" json:"grpc" toml:"grpc"`
+	RateLimit rateLimitConfig `yaml:"rate_limit" json:"rate_limit" toml:"rate_limit"`
+	Storage   storageConfig   `yaml:"storage" json:"storage" toml:"storage"`
+	Cache     cacheConfig     `yaml:"cache" json:"cache" toml:"cache"`
+	Log       logConfig       `yaml:"log" json:"log" toml:"log"`
+	Auth      authConfig      `yaml:"auth" json:"auth" toml:"auth"`
+	Features  featuresConfig  `yaml:"features" json:"features" toml:"features"`
+	Mail      mailConfig      `yaml:"mail" json:"mail" toml:"mail"`
+	// UsernamePolicy restricts which usernames users can claim.
+	UsernamePolicy usernamePolicyConfig `yaml:"username_policy" json:"username_policy" toml:"username_policy"`
+}
+
+// httpConfig configures HTTP API server.
+type httpConfig struct {
+	Addr         string   `yaml:"addr" json:"addr" toml:"addr"`
+	ReadTimeout  duration `yaml:"read_timeout" json:"read_timeout" toml:"read_timeout"`
+	WriteTimeout duration `yaml:"write_timeout" json:"write_timeout" toml:"write_timeout"`
+	IdleTimeout  duration `yaml:"idle_timeout" json:"idle_timeout" toml:"idle_timeout"`
+}
+
+// grpcConfig configures gRPC API server.
+type grpcConfig struct {
+	Addr string `yaml:"addr" json:"addr" toml:"addr"`
+}
+
+// rateLimitConfig limits API requests, HTTP and gRPC servers have separate limits.
+type rateLimitConfig struct {
+	QPS int `yaml:"qps" json:"qps" toml:"qps"`
+}
+
+// storageConfig configures the storage backend.
+type storageConfig struct {
+	// Backend is pg, inmem or mock (emulates storage errors).
+	Backend string `yaml:"backend" json:"backend" toml:"backend"`
+	// DSN is a Postgres connection string.
+	// The settings it doesn't set are taken from PG* environment variables.
+	DSN string `yaml:"dsn" json:"dsn" toml:"dsn"`
+	// Replicas are connection strings of Postgres read replicas which serve reads outside of transactions.
+	// The settings they don't set are taken from DSN except for host and port.
+	Replicas []string `yaml:"replicas" json:"replicas" toml:"replicas"`
+	// ConnectTimeout limits how long it takes to connect to the storage and check its schema at startup.
+	ConnectTimeout duration `yaml:"connect_timeout" json:"connect_timeout" toml:"connect_timeout"`
+}
+
+// cacheConfig configures the cache of users found by ID in front of the storage.
+type cacheConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled" toml:"enabled"`
+	// Size is max number of users kept in the cache.
+	Size int `yaml:"size" json:"size" toml:"size"`
+	// TTL is how long a user is cached.
+	TTL duration `yaml:"ttl" json:"ttl" toml:"ttl"`
+	// NegativeTTL is how long a user is remembered as not found, zero disables it.
+	NegativeTTL duration `yaml:"negative_ttl" json:"negative_ttl" toml:"negative_ttl"`
+	// StatsInterval is how often the cache statistics are logged, zero disables it.
+	StatsInterval duration `yaml:"stats_interval" json:"stats_interval" toml:"stats_interval"`
+}
+
+// logConfig configures the server logs.
+type logConfig struct {
+	// Level is debug, info, warn or error.
+	// Log entries without a level are always written.
+	Level string `yaml:"level" json:"level" toml:"level"`
+	// Format is json or logfmt.
+	Format string `yaml:"format" json:"format" toml:"format"`
+}
+
+// authConfig configures authentication of API clients.
+// When there are no API keys, the clients are not authenticated.
+type authConfig struct {
+	APIKeys []apiKey `yaml:"api_keys" json:"api_keys" toml:"api_keys"`
+}
+
+// apiKey authenticates API client as a principal of the tenant.
+type apiKey struct {
+	Key      string `yaml:"key" json:"key" toml:"key"`
+	TenantID string `yaml:"tenant_id" json:"tenant_id" toml:"tenant_id"`
+	// Actor is an ID of the principal recorded in the audit trail.
+	Actor string `yaml:"actor" json:"actor" toml:"actor"`
+}
+
+// featuresConfig toggles optional API features.
+type featuresConfig struct {
+	// UserImport enables bulk import of users.
+	UserImport bool `yaml:"user_import" json:"user_import" toml:"user_import"`
+	// AuditAPI enables querying the audit trail.
+	AuditAPI bool `yaml:"audit_api" json:"audit_api" toml:"audit_api"`
+}
+
+// Mail senders deliver email verification tokens to users.
+const (
+	mailSenderNone = "none"
+	mailSenderLog  = "log"
+	mailSenderFile = "file"
+)
+
+// mailConfig configures how the emails are sent to users, e.g., to verify their emails.
+type mailConfig struct {
+	// Sender is none (email verification is disabled), log (emails are logged) or
+	// file (emails are saved as .eml files in the dir).
+	Sender string `yaml:"sender" json:"sender" toml:"sender"`
+	// Dir is a directory where file sender saves the emails.
+	Dir string `yaml:"dir" json:"dir" toml:"dir"`
+}
+
+// usernamePolicyConfig restricts usernames.
+type usernamePolicyConfig struct {
+	// Blocked usernames can't be claimed by users, e.g., admin.
+	Blocked []string `yaml:"blocked" json:"blocked" toml:"blocked"`
+}
+
+// defaultConfig returns the configuration used when nothing else is set.
+func defaultConfig() *config {
+	return &config{
+		HTTP: httpConfig{
+			Addr:         ":8000",
+			ReadTimeout:  duration(5 * time.Second),
+			WriteTimeout: duration(10 * time.Second),
+			IdleTimeout:  duration(30 * time.Second),
+		},
+		GRPC: grpcConfig{
+			Addr: ":8080",
+		},
+		RateLimit: rateLimitConfig{
+			QPS: 2,
+		},
+		Storage: storageConfig{
+			Backend:        storageInmem,
+			ConnectTimeout: duration(5 * time.Second),
+		},
+		Cache: cacheConfig{
+			Size:          10000,
+			TTL:           duration(time.Minute),
+			NegativeTTL:   duration(10 * time.Second),
+			StatsInterval: duration(time.Minute),
+		},
+		Log: logConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Features: featuresConfig{
+			UserImport: true,
+			AuditAPI:   true,
+		},
+		Mail: mailConfig{
+			Sender: mailSenderLog,
+		},
+	}
+}
+
+// bindFlags defines the flags which set the config c.
+func bindFlags(fs *flag.FlagSet, c *config) {
+	fs.StringVar(&c.HTTP.Addr, "http", c.HTTP.Addr, "HTTP API address")
+	fs.Var(&c.HTTP.ReadTimeout, "http-read-timeout", "max duration for reading an entire HTTP request")
+	fs.Var(&c.HTTP.WriteTimeout, "http-write-timeout", "max duration before timing out writes of an HTTP response")
+	fs.Var(&c.HTTP.IdleTimeout, "http-idle-timeout", "max amount of time to wait for the next HTTP request when keep-alives are enabled")
+	fs.StringVar(&c.GRPC.Addr, "grpc", c.GRPC.Addr, "gRPC API address")
+	fs.IntVar(&c.RateLimit.QPS, "qps", c.RateLimit.QPS, "API requests limit per second")
+	fs.StringVar(&c.Storage.Backend, "storage", c.Storage.Backend, "storage backend: pg, inmem or mock (emulates storage errors)")
+	fs.StringVar(&c.Storage.DSN, "dsn", c.Storage.DSN, "Postgres connection string, PG* env variables are used for the settings it doesn't set")
+	fs.Var(&c.Storage.ConnectTimeout, "storage-timeout", "how long it may take to connect to the storage and check its schema at startup")
+	fs.BoolVar(&c.Cache.Enabled, "cache", c.Cache.Enabled, "cache users found by ID in front of the storage")
+	fs.IntVar(&c.Cache.Size, "cache-size", c.Cache.Size, "max number of users kept in the cache")
+	fs.Var(&c.Cache.TTL, "cache-ttl", "how long a user is cached")
+	fs.Var(&c.Cache.NegativeTTL, "cache-negative-ttl", "how long a user is remembered as not found, 0s disables it")
+	fs.Var(&c.Cache.StatsInterval, "cache-stats-interval", "how often the cache statistics are logged, 0s disables it")
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "log level: debug, info, warn or error")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "log format: json or logfmt")
+	fs.BoolVar(&c.Features.UserImport, "feature-user-import", c.Features.UserImport, "enable bulk import of users")
+	fs.BoolVar(&c.Features.AuditAPI, "feature-audit-api", c.Features.AuditAPI, "enable querying the audit trail")
+	fs.StringVar(&c.Mail.Sender, "mail-sender", c.Mail.Sender, "how emails are sent to verify users' emails: none (verification is disabled), log or file")
+	fs.StringVar(&c.Mail.Dir, "mail-dir", c.Mail.Dir, "directory where file mail sender saves emails")
+}
+
+// envName returns the name of the environment variable which corresponds to the flag, e.g.,
+// ACCOUNT_HTTP_READ_TIMEOUT for -http-read-timeout.
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// cmdline are the command line settings which aren't part of the config.
+type cmdline struct {
+	// configFile is a path to the config file.
+	configFile string
+	// printConfig tells to print the config and exit.
+	printConfig bool
+	// configWatch is how often the config file is checked for changes, zero disables the checks.
+	configWatch time.Duration
+}
+
+// loadConfig builds the configuration from defaults, the config file, environment variables and
+// command line arguments. It returns flag.ErrHelp if -h flag was passed.
+// The config is validated, so it's ready to use.
+func loadConfig(name string, args []string, lookupEnv func(string) (string, bool)) (cfg *config, cl cmdline, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	cl.configFile, _ = lookupEnv(envPrefix + "CONFIG")
+	fs.StringVar(&cl.configFile, "config", cl.configFile, "path to a YAML, TOML or JSON config file, env "+envPrefix+"CONFIG")
+	fs.BoolVar(&cl.printConfig, "print-config", false, "print the config with redacted secrets and exit")
+	fs.DurationVar(&cl.configWatch, "config-watch", 0, "how often the config file is checked for changes to reload it, e.g., 10s (disabled by default, send SIGHUP to reload)")
+	bindFlags(fs, defaultConfig())
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", name)
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\nEvery flag except -config, -config-watch and -print-config can be set by %s* env variable named after it, e.g., %s.\n", envPrefix, envName("http-read-timeout"))
+		fmt.Fprintln(fs.Output(), "Precedence: flags, env variables, config file, defaults.")
+	}
+	if err = fs.Parse(args); err != nil {
+		return nil, cl, err
+	}
+	if fs.NArg() > 0 {
+		return nil, cl, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg = defaultConfig()
+	if cl.configFile != "" {
+		if err = readConfigFile(cl.configFile, cfg); err != nil {
+			return nil, cl, err
+		}
+	}
+
+	// The flags and env variables are applied to the config by the same flag set,
+	// so they are parsed identically.
+	apply := flag.NewFlagSet(name, flag.ContinueOnError)
+	apply.SetOutput(io.Discard)
+	bindFlags(apply, cfg)
+	var errs []error
+	apply.VisitAll(func(f *flag.Flag) {
+		env := envName(f.Name)
+		v, ok := lookupEnv(env)
+		if !ok {
+			return
+		}
+		if err := apply.Set(f.Name, v); err != nil {
+			errs = append(errs, fmt.Errorf("%s env variable: %w", env, err))
+		}
+	})
+	fs.Visit(func(f *flag.Flag) {
+		if apply.Lookup(f.Name) != nil {
+			apply.Set(f.Name, f.Value.String())
+		}
+	})
+	if len(errs) > 0 {
+		return nil, cl, errors.Join(errs...)
+	}
+
+	if err = cfg.validate(); err != nil {
+		return nil, cl, err
+	}
+	return cfg, cl, nil
+}
+
+// readConfigFile reads the config file into cfg. The format is detected by the file extension.
+// Unknown settings are rejected, so a typo doesn't go unnoticed.
+func readConfigFile(path string, cfg *config) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("config file: %w", err)
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err = dec.Decode(cfg); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case ".json":
+		dec := json.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".toml":
+		var md toml.MetaData
+		md, err = toml.NewDecoder(f).Decode(cfg)
+		if undecoded := md.Undecoded(); err == nil && len(undecoded) > 0 {
+			err = fmt.Errorf("unknown setting %q", undecoded[0].String())
+		}
+	default:
+		return fmt.Errorf("config file %s: unknown format %q, want .yaml, .yml, .toml or .json", path, ext)
+	}
+	if err != nil {
+		return fmt.Errorf("config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// validate checks the settings and reports all the invalid ones.
+func (c *config) validate() error {
+	var errs []error
+	check := func(ok bool, format string, a ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, a...))
+		}
+	}
+
+	check(validAddr(c.HTTP.Addr), "http.addr: %q is not a host:port address", c.HTTP.Addr)
+	check(c.HTTP.ReadTimeout > 0, "http.read_timeout: must be positive, got %s", c.HTTP.ReadTimeout)
+	check(c.HTTP.WriteTimeout > 0, "http.write_timeout: must be positive, got %s", c.HTTP.WriteTimeout)
+	check(c.HTTP.IdleTimeout > 0, "http.idle_timeout: must be positive, got %s", c.HTTP.IdleTimeout)
+	check(validAddr(c.GRPC.Addr), "grpc.addr: %q is not a host:port address", c.GRPC.Addr)
+	check(c.HTTP.Addr != c.GRPC.Addr, "grpc.addr: %q is already used by http.addr", c.GRPC.Addr)
+	check(c.RateLimit.QPS > 0, "rate_limit.qps: must be positive, got %d", c.RateLimit.QPS)
+
+	switch c.Storage.Backend {
+	case storagePostgres, storageInmem, storageMock:
+	default:
+		check(false, "storage.backend: unknown %q, want %s, %s or %s", c.Storage.Backend, storagePostgres, storageInmem, storageMock)
+	}
+	check(len(c.Storage.Replicas) == 0 || c.Storage.Backend == storagePostgres, "storage.replicas: must be used with %s backend", storagePostgres)
+	for i, dsn := range c.Storage.Replicas {
+		check(dsn != "", "storage.replicas[%d]: must not be empty", i)
+	}
+	check(c.Storage.ConnectTimeout > 0, "storage.connect_timeout: must be positive, got %s", c.Storage.ConnectTimeout)
+
+	check(c.Cache.Size > 0, "cache.size: must be positive, got %d", c.Cache.Size)
+	check(c.Cache.TTL > 0, "cache.ttl: must be positive, got %s", c.Cache.TTL)
+	check(c.Cache.NegativeTTL >= 0, "cache.negative_ttl: must not be negative, got %s", c.Cache.NegativeTTL)
+	check(c.Cache.StatsInterval >= 0, "cache.stats_interval: must not be negative, got %s", c.Cache.StatsInterval)
+
+	_, err := level.Parse(c.Log.Level)
+	check(err == nil, "log.level: unknown %q, want debug, info, warn or error", c.Log.Level)
+	check(c.Log.Format == "json" || c.Log.Format == "logfmt", "log.format: unknown %q, want json or logfmt", c.Log.Format)
+
+	keys := make(map[string]bool, len(c.Auth.APIKeys))
+	for i, k := range c.Auth.APIKeys {
+		check(k.Key != "", "auth.api_keys[%d].key: must be set", i)
+		check(!keys[k.Key], "auth.api_keys[%d].key: is repeated", i)
+		keys[k.Key] = true
+		_, err = uuid.Parse(k.TenantID)
+		check(err == nil, "auth.api_keys[%d].tenant_id: %q is not a UUID", i, k.TenantID)
+	}
+	switch c.Mail.Sender {
+	case mailSenderNone, mailSenderLog:
+	case mailSenderFile:
+		check(c.Mail.Dir != "", "mail.dir: must be set for %s sender", mailSenderFile)
+	default:
+		check(false, "mail.sender: unknown %q, want %s, %s or %s", c.Mail.Sender, mailSenderNone, mailSenderLog, mailSenderFile)
+	}
+
+	for i, u := range c.UsernamePolicy.Blocked {
+		check(u != "", "username_policy.blocked[%d]: must not be empty", i)
+	}
+
+	return errors.Join(errs...)
+}
+
+// validAddr reports whether addr is a host:port address the server can listen to.
+func validAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && port != ""
+}
+
+// redacted returns a copy of the config where the secrets are replaced, so it can be printed.
+func (c config) redacted() config {
+	c.Storage.DSN = redactDSN(c.Storage.DSN)
+	replicas := make([]string, len(c.Storage.Replicas))
+	for i, dsn := range c.Storage.Replicas {
+		replicas[i] = redactDSN(dsn)
+	}
+	c.Storage.Replicas = replicas
+	keys := make([]apiKey, len(c.Auth.APIKeys))
+	for i, k := range c.Auth.APIKeys {
+		k.Key = redacted
+		keys[i] = k
+	}
+	c.Auth.APIKeys = keys
+	return c
+}
+
+// dsnPassword matches a password in keyword/value Postgres connection string.
+var dsnPassword = regexp.MustCompile(`password\s*=\s*('(?:[^'\\]|\\.)*'|\S+)`)
+
+// redactDSN replaces a password in Postgres connection string.
+func redactDSN(dsn string) string {
+	if !strings.HasPrefix(dsn, "postgres://") && !strings.HasPrefix(dsn, "postgresql://") {
+		return dsnPassword.ReplaceAllString(dsn, "password="+redacted)
+	}
+
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), redacted)
+	}
+	if q := u.Query(); q.Has("password") {
+		q.Set("password", redacted)
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// newLogger returns a logger configured according to cfg.
+// The timestamp and caller are added by the server logger which wraps it, see log.SwapLogger.
+func newLogger(cfg logConfig, w io.Writer) log.Logger {
+	var logger log.Logger
+	w = log.NewSyncWriter(w)
+	if cfg.Format == "logfmt" {
+		logger = log.NewLogfmtLogger(w)
+	} else {
+		logger = log.NewJSONLogger(w)
+	}
+
+	lvl := level.ParseDefault(cfg.Level, level.InfoValue())
+	return level.NewFilter(logger, level.Allow(lvl))
+}
+
+// duration is time.Duration which is set from a string such as "5s" in the config file and flags.
+type duration time.Duration
+
+// String implements flag.Value.
+func (d duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set implements flag.Value.
+func (d *duration) Set(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = duration(v)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *duration) UnmarshalText(b []byte) error {
+	return d.Set(string(b))
+}
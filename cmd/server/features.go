@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// features are the toggles of optional API features which can be changed while the server is running.
+type features struct {
+	v atomic.Pointer[featuresConfig]
+}
+
+// newFeatures returns the feature toggles set according to cfg.
+func newFeatures(cfg featuresConfig) *features {
+	var f features
+	f.set(cfg)
+	return &f
+}
+
+// set replaces all the toggles at once.
+func (f *features) set(cfg featuresConfig) {
+	f.v.Store(&cfg)
+}
+
+// get returns the current toggles.
+func (f *features) get() featuresConfig {
+	return *f.v.Load()
+}
+
+// toggledUserImportService rejects bulk imports when the feature is disabled.
+type toggledUserImportService struct {
+	features *features
+	next     account.UserImportService
+}
+
+// ImportUsers imports users if the feature is enabled.
+func (s *toggledUserImportService) ImportUsers(ctx context.Context, users []*account.User) error {
+	if !s.features.get().UserImport {
+		return account.Error{
+			Code:    account.EFeatureDisabled,
+			Message: "Bulk import of users is disabled.",
+		}
+	}
+	return s.next.ImportUsers(ctx, users)
+}
+
+// toggledAuditService rejects audit trail queries when the feature is disabled.
+type toggledAuditService struct {
+	features *features
+	next     account.AuditService
+}
+
+// FindAuditEntries returns audit entries if the feature is enabled.
+func (s *toggledAuditService) FindAuditEntries(ctx context.Context, filter account.AuditFilter) ([]*account.AuditEntry, error) {
+	if !s.features.get().AuditAPI {
+		return nil, account.Error{
+			Code:    account.EFeatureDisabled,
+			Message: "Audit trail is disabled.",
+		}
+	}
+	return s.next.FindAuditEntries(ctx, filter)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"github.com/go-kit/log"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/mail"
+)
+
+// newMailSender returns the configured sender of emails to users.
+// Emails aren't delivered, they are logged or saved as files for local development.
+func newMailSender(cfg mailConfig, logger log.Logger) (account.MailSender, error) {
+	if cfg.Sender == mailSenderFile {
+		return mail.NewFileSender(cfg.Dir)
+	}
+	return mail.NewLogSender(logger), nil
+}
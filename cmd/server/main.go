@@ -3,7 +3,7 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
@@ -15,93 +15,191 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/oklog/run"
+	"golang.org/x/time/rate"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
+	"gopkg.in/yaml.v3"
 
 	account "github.com/marselester/ddd-err"
 	"github.com/marselester/ddd-err/api"
-	"github.com/marselester/ddd-err/mock"
+	"github.com/marselester/ddd-err/cache"
 	pb "github.com/marselester/ddd-err/rpc/account"
 )
 
 func main() {
-	apiAddr := flag.String("http", ":8000", "HTTP API address")
-	apiQPS := flag.Int("qps", 2, "API requests limit per second")
-	grpcAddr := flag.String("grpc", ":8080", "gRPC API address")
-	flag.Parse()
-
 	exitCode := 1
 	defer func() { os.Exit(exitCode) }()
 
+	cfg, cl, err := loadConfig(os.Args[0], os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		exitCode = 0
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid config:\n%v\n", err)
+		return
+	}
+	if cl.printConfig {
+		enc := yaml.NewEncoder(os.Stdout)
+		enc.SetIndent(2)
+		if err = enc.Encode(cfg.redacted()); err != nil {
+			fmt.Fprintf(os.Stderr, "could not print config: %v\n", err)
+			return
+		}
+		exitCode = 0
+		return
+	}
+
+	// swapLogger is replaced when the log settings are reloaded.
+	var swapLogger log.SwapLogger
+	swapLogger.Swap(newLogger(cfg.Log, os.Stderr))
 	var logger log.Logger
 	{
-		logger = log.NewJSONLogger(log.NewSyncWriter(os.Stderr))
-		logger = log.With(logger, "ts", log.DefaultTimestampUTC)
+		logger = log.With(&swapLogger, "ts", log.DefaultTimestampUTC)
 		logger = log.With(logger, "caller", log.DefaultCaller)
 	}
 
-	// db helps to emulate storage errors.
-	db := &mock.UserStorage{
-		FindUserByIDFn: func(ctx context.Context, dbtx *sql.Tx, id string) (*account.User, error) {
-			return nil, account.Error{
-				Code:    account.ENotFound,
-				Message: "User not found.",
-				Inner:   sql.ErrNoRows,
-			}
-		},
-		UsernameInUseFn: func(ctx context.Context, username string) bool {
-			return username == "bob"
-		},
-		CreateUserFn: func(ctx context.Context, user *account.User) error {
-			return fmt.Errorf(
-				"UserStorage.CreateUser: %w",
-				fmt.Errorf(
-					"insertUser: %w",
-					fmt.Errorf("db connection failed"),
-				),
-			)
-		},
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// The setup which might fail is done before the storage is opened,
+	// so once the storage is open, it's closed only by its actor in the run group.
+	grpcListener, err := net.Listen("tcp", cfg.GRPC.Addr)
+	if err != nil {
+		logger.Log("msg", "could not listen to gRPC port", "err", err)
+		return
+	}
+	var sender account.MailSender
+	if cfg.Mail.Sender != mailSenderNone {
+		if sender, err = newMailSender(cfg.Mail, log.With(logger, "component", "mail")); err != nil {
+			logger.Log("msg", "could not create mail sender", "sender", cfg.Mail.Sender, "err", err)
+			return
+		}
+	}
+
+	startCtx, startCancel := context.WithTimeout(ctx, time.Duration(cfg.Storage.ConnectTimeout))
+	db, err := openStorage(startCtx, cfg.Storage, log.With(logger, "component", "storage"))
+	startCancel()
+	if err != nil {
+		logger.Log("msg", "could not open storage", "storage", cfg.Storage.Backend, "err", err)
+		return
+	}
+	logger.Log("msg", "storage is open", "storage", cfg.Storage.Backend)
+
+	// users is the storage of user accounts, the users found by ID are cached if the cache is enabled.
+	var (
+		users     account.UserImportRepository = db.user
+		userCache *cache.UserImportStorage
+	)
+	if cfg.Cache.Enabled {
+		userCache = newCache(cfg.Cache, db)
+		users = userCache
+		logger.Log("msg", "user cache is enabled", "size", cfg.Cache.Size, "ttl", cfg.Cache.TTL)
+	}
+
+	// The settings below can be changed without restarting the server, see reloader.
+	usernames := api.NewUsernamePolicy(cfg.UsernamePolicy.Blocked...)
+	toggles := newFeatures(cfg.Features)
+	// Every server has its own rate limiter.
+	httpLimiter := rate.NewLimiter(rate.Limit(cfg.RateLimit.QPS), cfg.RateLimit.QPS)
+	userLimiter := rate.NewLimiter(rate.Limit(cfg.RateLimit.QPS), cfg.RateLimit.QPS)
+	auditLimiter := rate.NewLimiter(rate.Limit(cfg.RateLimit.QPS), cfg.RateLimit.QPS)
+	importLimiter := rate.NewLimiter(rate.Limit(cfg.RateLimit.QPS), cfg.RateLimit.QPS)
+	groupLimiter := rate.NewLimiter(rate.Limit(cfg.RateLimit.QPS), cfg.RateLimit.QPS)
+
+	serviceOpts := []api.ConfigOption{
+		api.WithLogger(logger),
+		api.WithUsernamePolicy(usernames),
+	}
+	// Email verification is disabled if the storage can't keep the tokens or mail sender is none.
+	if db.verification != nil && sender != nil {
+		serviceOpts = append(serviceOpts, api.WithEmailVerification(db.verification, sender))
 	}
 
 	var s account.UserService
 	{
-		s = api.NewService(
-			db,
+		s = api.NewService(users, serviceOpts...)
+		s = api.NewAuditMiddleware(logger, db.audit, s)
+		s = api.NewLoggingMiddleware(logger, s)
+	}
+	var auditService account.AuditService
+	{
+		auditService = api.NewAuditService(db.audit)
+		auditService = &toggledAuditService{features: toggles, next: auditService}
+	}
+	var importService account.UserImportService
+	{
+		importService = api.NewUserImportService(
+			users,
 			api.WithLogger(logger),
+			api.WithUsernamePolicy(usernames),
 		)
-		s = api.NewLoggingMiddleware(logger, s)
+		importService = api.NewUserImportAuditMiddleware(logger, db.audit, importService)
+		importService = &toggledUserImportService{features: toggles, next: importService}
 	}
+	groupService := api.NewGroupService(db.group)
+
+	// auth authenticates API clients if API keys are configured.
+	auth := newAuthenticator(cfg.Auth.APIKeys)
 
 	// REST-style API server for creating new users.
-	apiserver := http.Server{
-		Addr: *apiAddr,
-		Handler: api.NewHTTPHandler(
+	var h http.Handler
+	{
+		h = api.NewHTTPHandler(
 			s,
 			log.With(logger, "component", "HTTP"),
-			*apiQPS,
-		),
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  30 * time.Second,
+			cfg.RateLimit.QPS,
+			api.WithAuditService(auditService),
+			api.WithUserImportService(importService),
+			api.WithGroupService(groupService),
+			api.WithRateLimiter(httpLimiter),
+		)
+		if auth != nil {
+			h = auth.httpHandler(h)
+		}
+	}
+	apiserver := http.Server{
+		Addr:         cfg.HTTP.Addr,
+		Handler:      h,
+		ReadTimeout:  time.Duration(cfg.HTTP.ReadTimeout),
+		WriteTimeout: time.Duration(cfg.HTTP.WriteTimeout),
+		IdleTimeout:  time.Duration(cfg.HTTP.IdleTimeout),
 	}
 
 	// gRPC API server for creating new users.
-	grpcListener, err := net.Listen("tcp", *grpcAddr)
-	if err != nil {
-		logger.Log("msg", "could not listen to gRPC port", "err", err)
-		return
+	var grpcOptions []grpc.ServerOption
+	if auth != nil {
+		grpcOptions = append(grpcOptions,
+			grpc.UnaryInterceptor(auth.unaryInterceptor),
+			grpc.StreamInterceptor(auth.streamInterceptor),
+		)
 	}
-	grpcserver := grpc.NewServer()
-	pb.RegisterUserServiceServer(
+	grpcserver := grpc.NewServer(grpcOptions...)
+	// The services reject malformed requests with InvalidArgument status.
+	api.RegisterGRPCServer(
+		grpcserver,
+		&pb.UserService_ServiceDesc,
+		api.NewGRPCUserServer(s, logger, cfg.RateLimit.QPS, api.WithGRPCRateLimiter(userLimiter)),
+	)
+	api.RegisterGRPCServer(
+		grpcserver,
+		&pb.AuditService_ServiceDesc,
+		api.NewGRPCAuditServer(auditService, logger, cfg.RateLimit.QPS, api.WithGRPCRateLimiter(auditLimiter)),
+	)
+	api.RegisterGRPCServer(
+		grpcserver,
+		&pb.UserImportService_ServiceDesc,
+		api.NewGRPCUserImportServer(importService, logger, cfg.RateLimit.QPS, api.WithGRPCRateLimiter(importLimiter)),
+	)
+	api.RegisterGRPCServer(
 		grpcserver,
-		api.NewGRPCUserServer(s, logger, *apiQPS),
+		&pb.GroupService_ServiceDesc,
+		api.NewGRPCGroupServer(groupService, logger, cfg.RateLimit.QPS, api.WithGRPCRateLimiter(groupLimiter)),
 	)
 	// gRPC reflection provides information about publicly-accessible gRPC services on a server,
 	// and assists clients at runtime to construct RPC requests and responses
 	// without precompiled service information. It is used by grpcurl CLI.
 	reflection.Register(grpcserver)
 
-	ctx, cancel := context.WithCancel(context.Background())
 	var g run.Group
 	{
 		g.Add(func() error {
@@ -114,8 +212,42 @@ func main() {
 		})
 	}
 	{
+		r := reloader{
+			load: func() (*config, error) {
+				cfg, _, err := loadConfig(os.Args[0], os.Args[1:], os.LookupEnv)
+				return cfg, err
+			},
+			logger:     log.With(logger, "component", "config"),
+			current:    cfg,
+			swapLogger: &swapLogger,
+			newLogger: func(c logConfig) log.Logger {
+				return newLogger(c, os.Stderr)
+			},
+			limiters:  []*rate.Limiter{httpLimiter, userLimiter, auditLimiter, importLimiter, groupLimiter},
+			usernames: usernames,
+			features:  toggles,
+		}
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		reloadCtx, reloadCancel := context.WithCancel(ctx)
+		g.Add(func() error {
+			return r.run(reloadCtx, hup, cl.configFile, cl.configWatch)
+		}, func(err error) {
+			signal.Stop(hup)
+			reloadCancel()
+		})
+	}
+	if userCache != nil && cfg.Cache.StatsInterval > 0 {
+		statsCtx, statsCancel := context.WithCancel(ctx)
 		g.Add(func() error {
-			logger.Log("msg", "API server is starting", "addr", *apiAddr)
+			return logCacheStats(statsCtx, userCache, time.Duration(cfg.Cache.StatsInterval), log.With(logger, "component", "cache"))
+		}, func(err error) {
+			statsCancel()
+		})
+	}
+	{
+		g.Add(func() error {
+			logger.Log("msg", "API server is starting", "addr", cfg.HTTP.Addr)
 			return apiserver.ListenAndServe()
 		}, func(err error) {
 			logger.Log("msg", "API server was interrupted", "err", err)
@@ -125,7 +257,7 @@ func main() {
 	}
 	{
 		g.Add(func() error {
-			logger.Log("msg", "gRPC server is starting", "addr", *grpcAddr)
+			logger.Log("msg", "gRPC server is starting", "addr", cfg.GRPC.Addr)
 			return grpcserver.Serve(grpcListener)
 		}, func(err error) {
 			logger.Log("msg", "gRPC server was interrupted", "err", err)
@@ -133,6 +265,20 @@ func main() {
 			logger.Log("msg", "gRPC server shut down")
 		})
 	}
+	{
+		// The storage actor is added last, so it's interrupted after the servers have shut down
+		// (run.Group interrupts the actors in the order they were added),
+		// and the requests in flight can still use the storage.
+		stop := make(chan struct{})
+		g.Add(func() error {
+			<-stop
+			return nil
+		}, func(err error) {
+			close(stop)
+			err = db.close()
+			logger.Log("msg", "storage closed", "err", err)
+		})
+	}
 	err = g.Run()
 	logger.Log("msg", "actors stopped", "err", err)
 
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"golang.org/x/time/rate"
+	"gopkg.in/yaml.v3"
+
+	"github.com/marselester/ddd-err/api"
+)
+
+// reloadable are the config sections which are applied without restarting the server.
+var reloadable = []string{"rate_limit", "log", "features", "username_policy"}
+
+// reloader applies the settings which can be changed without restarting the server:
+// rate limits, logging, features and username policy.
+// A new config is rejected if it's invalid, so the server keeps running with the current one.
+type reloader struct {
+	// load reads and validates the new config.
+	load   func() (*config, error)
+	logger log.Logger
+
+	// mu serializes the reloads.
+	mu      sync.Mutex
+	current *config
+
+	// swapLogger is the server logger which is replaced by newLogger when the log settings change.
+	swapLogger *log.SwapLogger
+	newLogger  func(logConfig) log.Logger
+	// limiters throttle API requests, every server has its own limiter.
+	limiters  []*rate.Limiter
+	usernames *api.UsernamePolicy
+	features  *features
+}
+
+// reload loads the config and applies its reloadable settings.
+// The changes which require restart are logged, but not applied.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.load()
+	if err != nil {
+		r.logger.Log("msg", "config reload rejected, the current config is kept", "err", err)
+		return err
+	}
+
+	var applied, ignored []string
+	for _, c := range diffConfig(r.current, cfg) {
+		if c.reloadable() {
+			applied = append(applied, c.String())
+		} else {
+			ignored = append(ignored, c.String())
+		}
+	}
+
+	next := *r.current
+	next.RateLimit = cfg.RateLimit
+	next.Log = cfg.Log
+	next.Features = cfg.Features
+	next.UsernamePolicy = cfg.UsernamePolicy
+	r.apply(&next)
+	r.current = &next
+
+	if len(ignored) > 0 {
+		r.logger.Log("msg", "config changes require restart", "changes", strings.Join(ignored, "; "))
+	}
+	if len(applied) == 0 {
+		r.logger.Log("msg", "config reloaded without changes")
+		return nil
+	}
+	r.logger.Log("msg", "config reloaded", "changes", strings.Join(applied, "; "))
+	return nil
+}
+
+// apply changes the server components according to the reloadable settings of cfg.
+func (r *reloader) apply(cfg *config) {
+	r.swapLogger.Swap(r.newLogger(cfg.Log))
+	for _, l := range r.limiters {
+		l.SetLimit(rate.Limit(cfg.RateLimit.QPS))
+		l.SetBurst(cfg.RateLimit.QPS)
+	}
+	r.usernames.SetBlocked(cfg.UsernamePolicy.Blocked...)
+	r.features.set(cfg.Features)
+}
+
+// run reloads the config whenever a signal is received on hup (SIGHUP).
+// If the watch interval is set, the config file is also reloaded when its modification time or size change.
+func (r *reloader) run(ctx context.Context, hup <-chan os.Signal, configFile string, watch time.Duration) error {
+	var (
+		tick <-chan time.Time
+		last fileVersion
+	)
+	if watch > 0 && configFile != "" {
+		t := time.NewTicker(watch)
+		defer t.Stop()
+		tick = t.C
+		last = statFile(configFile)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-hup:
+			r.reload()
+		case <-tick:
+			if v := statFile(configFile); v != last {
+				last = v
+				r.reload()
+			}
+		}
+	}
+}
+
+// fileVersion tells whether a file has changed.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// statFile returns the version of the file, or zero version if the file can't be accessed.
+func statFile(path string) fileVersion {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}
+	}
+	return fileVersion{modTime: fi.ModTime(), size: fi.Size()}
+}
+
+// change is a changed config setting.
+type change struct {
+	// key is a setting path, e.g., rate_limit.qps.
+	key string
+	// before and after are redacted setting values.
+	before string
+	after  string
+}
+
+// String returns a description of the change, e.g., "rate_limit.qps: 2 -> 10".
+func (c change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.key, c.before, c.after)
+}
+
+// reloadable reports whether the setting can be applied without restart.
+func (c change) reloadable() bool {
+	for _, section := range reloadable {
+		if strings.HasPrefix(c.key, section+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// diffConfig returns the settings which differ in the configs sorted by their keys.
+// The secrets are redacted, but their changes are still reported.
+func diffConfig(a, b *config) []change {
+	rawA, rawB := flattenConfig(*a), flattenConfig(*b)
+	redactedA, redactedB := flattenConfig(a.redacted()), flattenConfig(b.redacted())
+
+	var cc []change
+	for key, v := range rawB {
+		if rawA[key] != v {
+			cc = append(cc, change{key: key, before: redactedA[key], after: redactedB[key]})
+		}
+	}
+	for key := range rawA {
+		if _, ok := rawB[key]; !ok {
+			cc = append(cc, change{key: key, before: redactedA[key], after: ""})
+		}
+	}
+	sort.Slice(cc, func(i, j int) bool { return cc[i].key < cc[j].key })
+	return cc
+}
+
+// flattenConfig returns the config settings keyed by their paths, e.g., http.addr.
+// The lists are formatted as a single setting, e.g., [admin root].
+func flattenConfig(c config) map[string]string {
+	b, err := yaml.Marshal(c)
+	if err != nil {
+		panic(err)
+	}
+	var tree map[string]interface{}
+	if err = yaml.Unmarshal(b, &tree); err != nil {
+		panic(err)
+	}
+
+	settings := make(map[string]string)
+	var walk func(prefix string, v interface{})
+	walk = func(prefix string, v interface{}) {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			settings[prefix] = fmt.Sprint(v)
+			return
+		}
+		for k, v := range m {
+			walk(prefix+"."+k, v)
+		}
+	}
+	for k, v := range tree {
+		walk(k, v)
+	}
+	return settings
+}
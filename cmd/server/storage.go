@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/inmem"
+	"github.com/marselester/ddd-err/mock"
+	"github.com/marselester/ddd-err/pg"
+)
+
+// Storage backends the server can keep user accounts in.
+const (
+	storagePostgres = "pg"
+	storageInmem    = "inmem"
+	storageMock     = "mock"
+)
+
+// storage is a backend where user accounts, customer groups and the audit trail are kept.
+type storage struct {
+	user  account.UserImportRepository
+	group account.GroupRepository
+	audit account.AuditRepository
+	// verification keeps email verification tokens, it's nil if the backend can't verify emails.
+	verification account.EmailVerificationRepository
+	// close releases the backend's resources, e.g., db connections.
+	close func() error
+}
+
+// openStorage opens the configured storage backend.
+// Postgres is checked to be reachable and have an up-to-date schema,
+// so the server doesn't start serving requests it can't handle.
+func openStorage(ctx context.Context, cfg storageConfig, logger log.Logger) (*storage, error) {
+	switch cfg.Backend {
+	case storagePostgres:
+		db := pg.NewClient(
+			pg.WithDSN(cfg.DSN),
+			pg.WithLogger(logger),
+			pg.WithConnectTimeout(time.Duration(cfg.ConnectTimeout)),
+			pg.WithReplicas(cfg.Replicas...),
+		)
+		if err := db.Open(); err != nil {
+			return nil, fmt.Errorf("postgres is unreachable: %w", err)
+		}
+		if err := db.CheckSchema(ctx); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("postgres schema check failed: %w", err)
+		}
+		return &storage{
+			user:         db.User,
+			group:        db.Group,
+			audit:        db.Audit,
+			verification: db.EmailVerification,
+			close:        db.Close,
+		}, nil
+	case storageInmem:
+		db := inmem.NewClient()
+		return &storage{
+			user:         db.User,
+			group:        db.Group,
+			audit:        db.Audit,
+			verification: db.EmailVerification,
+			close:        func() error { return nil },
+		}, nil
+	case storageMock:
+		// The groups and the audit trail are discarded since the backend only emulates storage errors.
+		return &storage{
+			user:  newFaultyStorage(),
+			group: &mock.GroupStorage{},
+			audit: &mock.AuditStorage{},
+			close: func() error { return nil },
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown storage %q: want %s, %s or %s", cfg.Backend, storagePostgres, storageInmem, storageMock)
+	}
+}
+
+// newFaultyStorage returns a storage that helps to emulate storage errors:
+// users are never found, "bob" username is taken, and users can't be created.
+func newFaultyStorage() *mock.UserStorage {
+	return &mock.UserStorage{
+		FindUserByIDFn: func(ctx context.Context, id string) (*account.User, error) {
+			return nil, account.Error{
+				Code:    account.ENotFound,
+				Message: "User not found.",
+				Inner:   sql.ErrNoRows,
+			}
+		},
+		UsernameInUseFn: func(ctx context.Context, username string) (bool, error) {
+			return username == "bob", nil
+		},
+		CreateUserFn: func(ctx context.Context, user *account.User) error {
+			return fmt.Errorf(
+				"UserStorage.CreateUser: %w",
+				fmt.Errorf(
+					"insertUser: %w",
+					fmt.Errorf("db connection failed"),
+				),
+			)
+		},
+		ImportUsersFn: func(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+			return nil
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package account
+
+import "context"
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	claimedActorKey
+	requestIDKey
+	tenantKey
+	readYourWritesKey
+)
+
+// ContextWithActor returns a copy of ctx that carries an ID of the principal
+// who makes the request, e.g., an authenticated user.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor stored in ctx, if any.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey).(string)
+	return actor
+}
+
+// ContextWithClaimedActor returns a copy of ctx that carries an ID of the principal
+// API client claims to act on behalf of, e.g., sent in X-Actor-ID header.
+// Unlike the actor (see ContextWithActor), the claim is not verified.
+func ContextWithClaimedActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, claimedActorKey, actor)
+}
+
+// ClaimedActorFromContext returns the claimed actor stored in ctx, if any.
+func ClaimedActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(claimedActorKey).(string)
+	return actor
+}
+
+// ContextWithRequestID returns a copy of ctx that carries a request ID
+// to correlate logs and audit entries.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID stored in ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// ContextWithTenant returns a copy of ctx that carries an ID of the tenant (organization)
+// the request is scoped to.
+// Repositories must not return or change records of other tenants.
+func ContextWithTenant(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantKey, tenantID)
+}
+
+// TenantFromContext returns the tenant ID stored in ctx, if any.
+func TenantFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantKey).(string)
+	return tenantID
+}
+
+// ContextWithReadYourWrites returns a copy of ctx that requires reads to observe the writes made before them,
+// e.g., to find a user right after it was created.
+// Storages with asynchronous replicas serve such reads from the primary database.
+func ContextWithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesKey, true)
+}
+
+// ReadYourWritesFromContext reports whether ctx requires reads to observe the preceding writes.
+func ReadYourWritesFromContext(ctx context.Context) bool {
+	ok, _ := ctx.Value(readYourWritesKey).(bool)
+	return ok
+}
@@ -0,0 +1,498 @@
+// Package errlint defines an analyzer which enforces the domain error discipline:
+//
+//   - account.Error is constructed with a registered error code, e.g., account.ENotFound;
+//   - public messages of account.Error have no format verbs such as %s;
+//   - errors are not compared by their Error() strings,
+//     and error codes are compared with the constants instead of string literals;
+//   - exported methods of services (types implementing account.XxxService interfaces)
+//     don't return new errors which aren't domain errors, e.g., errors.New("not found"),
+//     including the ones held by local variables or returned by helper functions of the package.
+//
+// Test files are not checked.
+package errlint
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// accountPath is the import path of the package which defines the domain errors.
+const accountPath = "github.com/marselester/ddd-err"
+
+// Analyzer reports violations of the domain error discipline.
+var Analyzer = &analysis.Analyzer{
+	Name:     "errlint",
+	Doc:      "check that domain errors have registered codes and plain messages, and aren't compared by strings",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// formatVerb matches fmt verbs, e.g., %s, %d, %-10v, %w.
+// The space flag is omitted to allow messages such as "100% sure".
+var formatVerb = regexp.MustCompile(`%[-+#0]*[0-9*]*(\.[0-9*]+)?[vTtbcdoOqxXUeEfFgGspw]`)
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	accPkg := accountPackage(pass.Pkg)
+	if accPkg == nil {
+		return nil, nil
+	}
+	c := checker{
+		pass:     pass,
+		errType:  accPkg.Scope().Lookup("Error"),
+		codes:    errorCodes(accPkg),
+		services: serviceInterfaces(accPkg),
+		funcs:    make(map[*types.Func]*ast.FuncDecl),
+		helpers:  make(map[*types.Func]bool),
+	}
+	if c.errType == nil {
+		return nil, nil
+	}
+	for _, f := range pass.Files {
+		for _, decl := range f.Decls {
+			fd, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			if fn, ok := pass.TypesInfo.Defs[fd.Name].(*types.Func); ok {
+				c.funcs[fn] = fd
+			}
+		}
+	}
+
+	insp := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	nodeFilter := []ast.Node{
+		(*ast.CompositeLit)(nil),
+		(*ast.BinaryExpr)(nil),
+		(*ast.FuncDecl)(nil),
+	}
+	insp.Preorder(nodeFilter, func(n ast.Node) {
+		if strings.HasSuffix(pass.Fset.File(n.Pos()).Name(), "_test.go") {
+			return
+		}
+		switch n := n.(type) {
+		case *ast.CompositeLit:
+			c.checkErrorLiteral(n)
+		case *ast.BinaryExpr:
+			c.checkComparison(n)
+		case *ast.FuncDecl:
+			c.checkServiceMethod(n)
+		}
+	})
+	return nil, nil
+}
+
+// accountPackage returns the account package if pkg is the one or imports it.
+func accountPackage(pkg *types.Package) *types.Package {
+	if pkg.Path() == accountPath {
+		return pkg
+	}
+	for _, p := range pkg.Imports() {
+		if p.Path() == accountPath {
+			return p
+		}
+	}
+	return nil
+}
+
+// errorCodes returns the registered error codes, i.e., the values of E-prefixed string constants.
+func errorCodes(pkg *types.Package) map[string]bool {
+	codes := make(map[string]bool)
+	for _, name := range pkg.Scope().Names() {
+		c, ok := pkg.Scope().Lookup(name).(*types.Const)
+		if !ok || !strings.HasPrefix(name, "E") || c.Val().Kind() != constant.String {
+			continue
+		}
+		codes[constant.StringVal(c.Val())] = true
+	}
+	return codes
+}
+
+// serviceInterfaces returns the interfaces of the account package which are named XxxService.
+func serviceInterfaces(pkg *types.Package) []*types.Interface {
+	var ifaces []*types.Interface
+	for _, name := range pkg.Scope().Names() {
+		tn, ok := pkg.Scope().Lookup(name).(*types.TypeName)
+		if !ok || !strings.HasSuffix(name, "Service") {
+			continue
+		}
+		if iface, ok := tn.Type().Underlying().(*types.Interface); ok {
+			ifaces = append(ifaces, iface)
+		}
+	}
+	return ifaces
+}
+
+// checker holds the facts about the account package needed to check a package.
+type checker struct {
+	pass *analysis.Pass
+	// errType is account.Error type.
+	errType types.Object
+	// codes are the registered error codes.
+	codes    map[string]bool
+	services []*types.Interface
+	// funcs are the function declarations of the package.
+	funcs map[*types.Func]*ast.FuncDecl
+	// helpers tells whether the package's functions can return new plain errors, see helperReturnsPlainError.
+	helpers map[*types.Func]bool
+}
+
+// isDomainError reports whether t is account.Error or a pointer to it.
+func (c *checker) isDomainError(t types.Type) bool {
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && named.Obj() == c.errType
+}
+
+// stringConst returns the value of a constant string expression.
+func (c *checker) stringConst(e ast.Expr) (string, bool) {
+	tv, ok := c.pass.TypesInfo.Types[e]
+	if !ok || tv.Value == nil || tv.Value.Kind() != constant.String {
+		return "", false
+	}
+	return constant.StringVal(tv.Value), true
+}
+
+// checkErrorLiteral reports account.Error literals with unregistered codes or messages with format verbs.
+func (c *checker) checkErrorLiteral(lit *ast.CompositeLit) {
+	if !c.isDomainError(c.pass.TypesInfo.TypeOf(lit)) {
+		return
+	}
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		key, ok := kv.Key.(*ast.Ident)
+		if !ok {
+			continue
+		}
+		v, ok := c.stringConst(kv.Value)
+		if !ok {
+			continue
+		}
+		switch key.Name {
+		case "Code":
+			if !c.codes[v] {
+				c.pass.Reportf(kv.Value.Pos(), "unknown error code %q, use a code constant declared in account package", v)
+			}
+		case "Message":
+			if verb := formatVerb.FindString(strings.ReplaceAll(v, "%%", "")); verb != "" {
+				c.pass.Reportf(kv.Value.Pos(), "public message has format verb %s, use fmt.Sprintf to format it", verb)
+			}
+		}
+	}
+}
+
+// checkComparison reports comparisons of Error() strings, and error codes compared with string literals.
+func (c *checker) checkComparison(be *ast.BinaryExpr) {
+	if be.Op != token.EQL && be.Op != token.NEQ {
+		return
+	}
+	for _, operand := range []ast.Expr{be.X, be.Y} {
+		if c.isErrorString(operand) {
+			c.pass.Reportf(be.Pos(), "error compared by its Error() string, use errors.Is, errors.As or account.ErrorCode")
+			return
+		}
+	}
+
+	pairs := [][2]ast.Expr{{be.X, be.Y}, {be.Y, be.X}}
+	for _, p := range pairs {
+		code, lit := p[0], p[1]
+		bl, ok := unparen(lit).(*ast.BasicLit)
+		if !ok || bl.Kind != token.STRING || bl.Value == `""` || !c.isErrorCode(code) {
+			continue
+		}
+		s, _ := strconv.Unquote(bl.Value)
+		c.pass.Reportf(bl.Pos(), "error code compared with string literal %q, use a code constant declared in account package", s)
+		return
+	}
+}
+
+// isErrorString reports whether e is a call of Error method of error interface, e.g., err.Error().
+func (c *checker) isErrorString(e ast.Expr) bool {
+	call, ok := unparen(e).(*ast.CallExpr)
+	if !ok || len(call.Args) != 0 {
+		return false
+	}
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Error" {
+		return false
+	}
+	t := c.pass.TypesInfo.TypeOf(sel.X)
+	return t != nil && types.Implements(t, errorInterface)
+}
+
+// isErrorCode reports whether e is a Code field of account.Error or a call of account.ErrorCode.
+func (c *checker) isErrorCode(e ast.Expr) bool {
+	switch e := unparen(e).(type) {
+	case *ast.SelectorExpr:
+		return e.Sel.Name == "Code" && c.isDomainError(c.pass.TypesInfo.TypeOf(e.X))
+	case *ast.CallExpr:
+		var id *ast.Ident
+		switch fun := e.Fun.(type) {
+		case *ast.Ident:
+			id = fun
+		case *ast.SelectorExpr:
+			id = fun.Sel
+		default:
+			return false
+		}
+		fn, ok := c.pass.TypesInfo.Uses[id].(*types.Func)
+		return ok && fn.Name() == "ErrorCode" && fn.Pkg() != nil && fn.Pkg().Path() == accountPath
+	}
+	return false
+}
+
+// unparen returns e with any enclosing parentheses stripped.
+func unparen(e ast.Expr) ast.Expr {
+	for {
+		p, ok := e.(*ast.ParenExpr)
+		if !ok {
+			return e
+		}
+		e = p.X
+	}
+}
+
+// errorType is the built-in error type.
+var errorType = types.Universe.Lookup("error").Type()
+
+// errorInterface is the built-in error interface.
+var errorInterface = errorType.Underlying().(*types.Interface)
+
+// checkServiceMethod reports new non-domain errors returned by an exported method of a service.
+// A service is a type which implements one of account.XxxService interfaces.
+// The errors wrapped with %w verb are allowed, since they can be domain errors.
+//
+// Besides errors.New and fmt.Errorf calls, the method must not return local variables
+// which only ever hold such errors, or results of the package's helper functions
+// which can return such errors (see helperReturnsPlainError).
+func (c *checker) checkServiceMethod(fd *ast.FuncDecl) {
+	if fd.Recv == nil || fd.Body == nil || !fd.Name.IsExported() {
+		return
+	}
+	fn, ok := c.pass.TypesInfo.Defs[fd.Name].(*types.Func)
+	if !ok || !c.isServiceMethod(fn) {
+		return
+	}
+
+	for _, r := range c.plainErrorResults(fn, fd.Body) {
+		c.pass.Reportf(r.Pos(), "%s returns an error which is not a domain error, use account.Error", fn.Name())
+	}
+}
+
+// plainErrorResults returns the expressions returned by the function fn with the body
+// which are new plain errors (see isPlainError).
+// Errors returned by closures are checked where the closure results are returned.
+func (c *checker) plainErrorResults(fn *types.Func, body *ast.BlockStmt) []ast.Expr {
+	errIdx := errorResult(fn)
+	if errIdx < 0 {
+		return nil
+	}
+	locals := c.plainLocals(body)
+
+	var plain []ast.Expr
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			return false
+		case *ast.ReturnStmt:
+			// return f() forwards all the results of f.
+			if len(n.Results) == 1 && errIdx > 0 {
+				if c.isPlainError(n.Results[0], errIdx, locals) {
+					plain = append(plain, n.Results[0])
+				}
+				return true
+			}
+			for _, r := range n.Results {
+				if c.isPlainError(r, 0, locals) {
+					plain = append(plain, r)
+				}
+			}
+		}
+		return true
+	})
+	return plain
+}
+
+// errorResult returns the index of the last error result of fn, or -1 if there is none.
+func errorResult(fn *types.Func) int {
+	results := fn.Type().(*types.Signature).Results()
+	for i := results.Len() - 1; i >= 0; i-- {
+		if types.Identical(results.At(i).Type(), errorType) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isPlainError reports whether the idx-th value of e is a new error which can't be a domain error:
+// errors.New call, fmt.Errorf call without %w verb, a local variable which only holds such errors,
+// or a call of a helper function which can return such an error.
+func (c *checker) isPlainError(e ast.Expr, idx int, locals map[types.Object]bool) bool {
+	switch e := unparen(e).(type) {
+	case *ast.Ident:
+		return idx == 0 && locals[c.pass.TypesInfo.Uses[e]]
+	case *ast.CallExpr:
+		if idx == 0 && c.isNewPlainError(e) {
+			return true
+		}
+		fn := c.calledFunc(e)
+		return fn != nil && errorResult(fn) == idx && c.helperReturnsPlainError(fn)
+	}
+	return false
+}
+
+// plainLocals returns the local variables of the function body which are assigned only new plain errors.
+// A variable assigned within a closure or whose address is taken is not tracked,
+// since it can't be told what it holds when it's returned.
+func (c *checker) plainLocals(body *ast.BlockStmt) map[types.Object]bool {
+	plain := make(map[types.Object]bool)
+	// untracked are the variables which might hold other errors.
+	untracked := make(map[types.Object]bool)
+	assign := func(lhs ast.Expr, rhs ast.Expr, idx int, locals map[types.Object]bool) {
+		id, ok := lhs.(*ast.Ident)
+		if !ok || id.Name == "_" {
+			return
+		}
+		obj := c.pass.TypesInfo.ObjectOf(id)
+		if obj == nil || untracked[obj] {
+			return
+		}
+		if rhs == nil || !c.isPlainError(rhs, idx, locals) {
+			untracked[obj] = true
+			delete(plain, obj)
+			return
+		}
+		plain[obj] = true
+	}
+
+	var inClosure int
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncLit:
+			inClosure++
+			ast.Inspect(n.Body, visit)
+			inClosure--
+			return false
+		case *ast.UnaryExpr:
+			if id, ok := n.X.(*ast.Ident); ok && n.Op == token.AND {
+				assign(id, nil, 0, plain)
+			}
+		case *ast.AssignStmt:
+			for i, lhs := range n.Lhs {
+				switch {
+				case inClosure > 0:
+					assign(lhs, nil, 0, plain)
+				case len(n.Lhs) == len(n.Rhs):
+					assign(lhs, n.Rhs[i], 0, plain)
+				case len(n.Rhs) == 1:
+					assign(lhs, n.Rhs[0], i, plain)
+				}
+			}
+		case *ast.ValueSpec:
+			for i, name := range n.Names {
+				switch {
+				case inClosure > 0:
+					assign(name, nil, 0, plain)
+				case len(n.Names) == len(n.Values):
+					assign(name, n.Values[i], 0, plain)
+				case len(n.Values) == 1:
+					assign(name, n.Values[0], i, plain)
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(body, visit)
+	return plain
+}
+
+// calledFunc returns the function or method called by the call expression, if it's statically known.
+func (c *checker) calledFunc(call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	fn, _ := c.pass.TypesInfo.Uses[id].(*types.Func)
+	return fn
+}
+
+// helperReturnsPlainError reports whether a function declared in the package can return a new plain error.
+// Functions of other packages and interface methods are not checked.
+func (c *checker) helperReturnsPlainError(fn *types.Func) bool {
+	if plain, ok := c.helpers[fn]; ok {
+		return plain
+	}
+	fd, ok := c.funcs[fn]
+	if !ok || fd.Body == nil {
+		return false
+	}
+	// A recursive call is assumed to return a domain error until the function is checked.
+	c.helpers[fn] = false
+	plain := len(c.plainErrorResults(fn, fd.Body)) > 0
+	c.helpers[fn] = plain
+	return plain
+}
+
+// isServiceMethod reports whether fn is a method of one of the service interfaces,
+// and its receiver implements that interface.
+func (c *checker) isServiceMethod(fn *types.Func) bool {
+	recv := fn.Type().(*types.Signature).Recv()
+	if recv == nil {
+		return false
+	}
+	t := recv.Type()
+	if _, ok := t.(*types.Pointer); !ok {
+		t = types.NewPointer(t)
+	}
+	for _, iface := range c.services {
+		for i := 0; i < iface.NumMethods(); i++ {
+			if iface.Method(i).Name() == fn.Name() && types.Implements(t, iface) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isNewPlainError reports whether e creates a new error which can't be a domain error:
+// errors.New call or fmt.Errorf call without %w verb.
+func (c *checker) isNewPlainError(e ast.Expr) bool {
+	call, ok := unparen(e).(*ast.CallExpr)
+	if !ok {
+		return false
+	}
+	fn := c.calledFunc(call)
+	if fn == nil || fn.Pkg() == nil {
+		return false
+	}
+	switch fn.Pkg().Path() + "." + fn.Name() {
+	case "errors.New":
+		return true
+	case "fmt.Errorf":
+		if len(call.Args) == 0 {
+			return true
+		}
+		format, ok := c.stringConst(call.Args[0])
+		return ok && !strings.Contains(format, "%w")
+	}
+	return false
+}
@@ -0,0 +1,13 @@
+package errlint_test
+
+import (
+	"testing"
+
+	"golang.org/x/tools/go/analysis/analysistest"
+
+	"github.com/marselester/ddd-err/errlint"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), errlint.Analyzer, "a")
+}
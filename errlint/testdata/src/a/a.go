@@ -0,0 +1,121 @@
+package a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	account "github.com/marselester/ddd-err"
+)
+
+const eGone = "gone"
+
+func codes() []error {
+	return []error{
+		account.Error{Code: account.ENotFound, Message: "User not found."},
+		account.Error{Code: "not_found", Message: "User not found."},
+		account.Error{Code: "notfound", Message: "User not found."}, // want `unknown error code "notfound"`
+		&account.Error{Code: eGone},                                 // want `unknown error code "gone"`
+	}
+}
+
+func messages(id string) []error {
+	return []error{
+		account.Error{Code: account.ENotFound, Message: fmt.Sprintf("User %s not found.", id)},
+		account.Error{Code: account.ENotFound, Message: "User %s not found."},    // want `public message has format verb %s`
+		account.Error{Code: account.ENotFound, Message: "User %-10v not found."}, // want `public message has format verb %-10v`
+		account.Error{Code: account.ENotFound, Message: "100%% sure."},
+		account.Error{Code: account.ENotFound, Message: "100% sure."},
+	}
+}
+
+func comparisons(err error, e account.Error) bool {
+	_ = err.Error() == "not_found: User not found." // want `error compared by its Error\(\) string`
+	_ = "boom" != e.Error()                         // want `error compared by its Error\(\) string`
+	_ = account.ErrorCode(err) == "conflict"        // want `error code compared with string literal "conflict"`
+	_ = e.Code != ("not_found")                     // want `error code compared with string literal "not_found"`
+	_ = e.Code == ""
+	return account.ErrorCode(err) == account.EConflict
+}
+
+type service struct{}
+
+func (s *service) DeleteUser(ctx context.Context, id string) error {
+	if id == "" {
+		return errors.New("user ID is required") // want `DeleteUser returns an error which is not a domain error`
+	}
+	if id == "0" {
+		return fmt.Errorf("user %s is reserved", id) // want `DeleteUser returns an error which is not a domain error`
+	}
+	if err := s.delete(id); err != nil {
+		return fmt.Errorf("user (id %s) not deleted: %w", id, err)
+	}
+	return account.Error{Code: account.EConflict, Message: "User can't be deleted.", Inner: errors.New("db is read-only")}
+}
+
+// delete isn't an exported boundary, so it can return any error.
+func (s *service) delete(id string) error {
+	return errors.New("not implemented")
+}
+
+func (s *service) FindUserByID(ctx context.Context, id string) (*account.User, error) {
+	err := errors.New("user not found")
+	if id == "" {
+		return nil, err // want `FindUserByID returns an error which is not a domain error`
+	}
+	var reserved = fmt.Errorf("user %s is reserved", id)
+	if id == "0" {
+		return nil, reserved // want `FindUserByID returns an error which is not a domain error`
+	}
+	if id == "1" {
+		return nil, s.delete(id) // want `FindUserByID returns an error which is not a domain error`
+	}
+	if id == "2" {
+		return s.find(id) // want `FindUserByID returns an error which is not a domain error`
+	}
+	if _, notFound := s.find(id); notFound != nil {
+		return nil, notFound // want `FindUserByID returns an error which is not a domain error`
+	}
+	if err := validate(id); err != nil {
+		return nil, err
+	}
+	return &account.User{ID: id}, nil
+}
+
+// find returns a plain error through a local variable.
+func (s *service) find(id string) (*account.User, error) {
+	err := fmt.Errorf("user %s not found", id)
+	return nil, err
+}
+
+// validate returns only domain errors.
+func validate(id string) error {
+	err := account.Error{Code: account.ENotFound, Message: "User not found."}
+	if id == "" {
+		return err
+	}
+	return nil
+}
+
+func (s *service) LockUser(ctx context.Context, id string) error {
+	// err might hold a domain error when it's returned.
+	err := errors.New("user is locked")
+	if id == "" {
+		err = account.Error{Code: account.EConflict, Message: "User is locked."}
+	}
+	if id == "0" {
+		return err
+	}
+	// wrapped is assigned in the closure, so it can hold any error.
+	wrapped := errors.New("not locked")
+	func() { wrapped = validate(id) }()
+	if wrapped != nil {
+		return wrapped
+	}
+	return fmt.Errorf("user (id %s) not locked: %w", id, s.delete(id))
+}
+
+// Purge isn't a method of a service interface.
+func (s *service) Purge() error {
+	return errors.New("not implemented")
+}
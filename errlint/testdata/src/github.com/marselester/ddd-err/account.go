@@ -0,0 +1,43 @@
+// Package account is a stub of the domain package for errlint tests.
+package account
+
+import (
+	"context"
+	"errors"
+)
+
+// Application error codes.
+const (
+	EConflict = "conflict"
+	ENotFound = "not_found"
+)
+
+// Error defines a standard application error.
+type Error struct {
+	Code    string
+	Message string
+	Inner   error
+}
+
+func (e Error) Error() string { return e.Code + ": " + e.Message }
+
+// ErrorCode returns the code of the error, if available.
+func ErrorCode(err error) string {
+	var e Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return ""
+}
+
+// UserService represents a service for managing users.
+type UserService interface {
+	DeleteUser(ctx context.Context, id string) error
+	FindUserByID(ctx context.Context, id string) (*User, error)
+	LockUser(ctx context.Context, id string) error
+}
+
+// User represents a user account.
+type User struct {
+	ID string
+}
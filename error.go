@@ -5,7 +5,10 @@ import (
 	"fmt"
 )
 
+//go:generate go run ./cmd/errcatalog -o docs/errors.md
+
 // Application error codes.
+// A new code must be described in the error catalog, see api.ErrorCatalog.
 const (
 	// Action cannot be performed.
 	EConflict = "conflict"
@@ -19,6 +22,42 @@ const (
 	EInvalidUserID = "invalid_user_id"
 	// Username validation failed.
 	EInvalidUsername = "invalid_username"
+	// Group ID validation failed.
+	EInvalidGroupID = "invalid_group_id"
+	// Group name validation failed.
+	EInvalidGroupName = "invalid_group_name"
+	// Time range validation failed.
+	EInvalidTimeRange = "invalid_time_range"
+	// Limit of returned entities is not a number.
+	EInvalidLimit = "invalid_limit"
+	// Entity status does not allow the action.
+	EInvalidStateTransition = "invalid_state_transition"
+	// Email validation failed.
+	EInvalidEmail = "invalid_email"
+	// Display name validation failed.
+	EInvalidDisplayName = "invalid_display_name"
+	// Verification token is unknown, expired or was already used.
+	EInvalidVerificationToken = "invalid_verification_token"
+	// Tenant ID is missing or malformed.
+	EInvalidTenant = "invalid_tenant"
+	// Username was recently released by another user and can't be claimed yet.
+	EUsernameReserved = "username_reserved"
+	// Concurrent requests changed the same data, the request can be retried.
+	EConcurrentUpdate = "concurrent_update"
+	// Bulk import was rejected because some of the users are invalid.
+	EImportRejected = "import_rejected"
+	// API key is missing or unknown.
+	EUnauthenticated = "unauthenticated"
+	// Feature was disabled by the server configuration.
+	EFeatureDisabled = "feature_disabled"
+	// None of the media types accepted by HTTP API client can be sent.
+	ENotAcceptable = "not_acceptable"
+	// Media type of HTTP request body is not supported.
+	EUnsupportedMediaType = "unsupported_media_type"
+	// Request can't be decoded, e.g., its body has unknown fields or it isn't valid UTF-8.
+	EMalformedRequest = "malformed_request"
+	// Request body is larger than API accepts.
+	EPayloadTooLarge = "payload_too_large"
 )
 
 // Error defines a standard application error.
@@ -27,6 +66,8 @@ type Error struct {
 	Code string `json:"code"`
 	// Message is a human-readable message.
 	Message string `json:"message"`
+	// Field is a name of the request field that failed validation, if any.
+	Field string `json:"field,omitempty"`
 	// Inner is a wrapped error that is never shown to API consumers.
 	Inner error `json:"-"`
 }
@@ -0,0 +1,59 @@
+package inmem
+
+import (
+	"context"
+	"sort"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// AuditStorage represents an in-memory append-only storage of account audit entries.
+// The entries appended within a transaction are committed or discarded along with the other changes.
+type AuditStorage struct {
+	client *Client
+}
+
+// AppendAuditEntry adds an entry to the audit trail of the tenant.
+func (s *AuditStorage) AppendAuditEntry(ctx context.Context, e *account.AuditEntry) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	r := auditRecord{tenantID: tenantID, entry: *e}
+	r.entry.Changes = append([]account.Change(nil), e.Changes...)
+	d.entries = append(d.entries, r)
+	return nil
+}
+
+// FindAuditEntries returns audit entries of a tenant's user created within a time range ordered by creation time.
+func (s *AuditStorage) FindAuditEntries(ctx context.Context, f account.AuditFilter) ([]*account.AuditEntry, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	var ee []*account.AuditEntry
+	d.rangeEntries(func(r auditRecord) {
+		if r.tenantID != tenantID || r.entry.TargetID != f.UserID {
+			return
+		}
+		if r.entry.CreatedAt.Before(f.Since) || !r.entry.CreatedAt.Before(f.Until) {
+			return
+		}
+		e := r.entry
+		e.Changes = append([]account.Change(nil), r.entry.Changes...)
+		ee = append(ee, &e)
+	})
+	sort.SliceStable(ee, func(i, j int) bool {
+		return ee[i].CreatedAt.Before(ee[j].CreatedAt)
+	})
+	if len(ee) > f.Limit {
+		ee = ee[:f.Limit]
+	}
+	return ee, nil
+}
@@ -0,0 +1,98 @@
+package inmem
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// Ensure AuditStorage implements account.AuditRepository.
+var _ account.AuditRepository = &AuditStorage{}
+
+func TestAuditStorage(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	now := time.Now().UTC()
+	entries := []*account.AuditEntry{
+		{
+			ID:        "1",
+			CreatedAt: now.Add(-time.Hour),
+			Actor:     "admin",
+			Action:    "CreateUser",
+			TargetID:  "123",
+			Changes:   []account.Change{{Field: "username", After: "Alice"}},
+			RequestID: "req-1",
+			Outcome:   account.AuditSuccess,
+		},
+		{
+			ID:        "2",
+			CreatedAt: now,
+			Actor:     "admin",
+			Action:    "CreateUser",
+			TargetID:  "123",
+			Changes:   []account.Change{{Field: "username", After: "Bob"}},
+			RequestID: "req-2",
+			Outcome:   account.AuditRejected,
+			ErrorCode: account.EConflict,
+		},
+	}
+	for _, e := range entries {
+		if err := c.Audit.AppendAuditEntry(ctx, e); err != nil {
+			t.Fatalf("AppendAuditEntry() failed: %v", err)
+		}
+	}
+
+	filter := account.AuditFilter{
+		UserID: "123",
+		Since:  now.Add(-time.Minute),
+		Until:  now.Add(time.Minute),
+		Limit:  10,
+	}
+	got, err := c.Audit.FindAuditEntries(ctx, filter)
+	if err != nil {
+		t.Fatalf("FindAuditEntries() failed: %v", err)
+	}
+	if len(got) != 1 || !reflect.DeepEqual(got[0], entries[1]) {
+		t.Fatalf("FindAuditEntries() got %+v, want %+v", got, entries[1:])
+	}
+
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	if got, _ = c.Audit.FindAuditEntries(otherCtx, filter); len(got) != 0 {
+		t.Errorf("FindAuditEntries() got %d entries of other tenant, want 0", len(got))
+	}
+}
+
+func TestAuditStorage_rollback(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	now := time.Now()
+
+	errRollback := errors.New("rollback")
+	err := c.Transact(ctx, func(ctx context.Context) error {
+		e := account.AuditEntry{ID: "1", CreatedAt: now, TargetID: "123"}
+		if err := c.Audit.AppendAuditEntry(ctx, &e); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("Transact() = %v, want %v", err, errRollback)
+	}
+
+	got, err := c.Audit.FindAuditEntries(ctx, account.AuditFilter{
+		UserID: "123",
+		Since:  now.Add(-time.Minute),
+		Until:  now.Add(time.Minute),
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("FindAuditEntries() failed: %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("FindAuditEntries() got %d entries of rolled back transaction, want 0", len(got))
+	}
+}
@@ -0,0 +1,142 @@
+// Package inmem provides in-memory UserRepository, GroupRepository, EmailVerificationRepository and
+// AuditRepository implementations to run the service and tests without a database.
+package inmem
+
+import (
+	"context"
+	"sync"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// Client represents a client to the in-memory data store.
+// It's safe for concurrent use.
+type Client struct {
+	User  *UserStorage
+	Group *GroupStorage
+	Audit *AuditStorage
+
+	EmailVerification *EmailVerificationStorage
+
+	// txMu serializes transactions, so they don't overwrite each other's changes.
+	txMu sync.Mutex
+	// mu guards the committed data.
+	mu   sync.RWMutex
+	data *data
+}
+
+// NewClient returns a new in-memory client with no records.
+func NewClient() *Client {
+	c := Client{
+		data: newData(),
+	}
+	c.User = &UserStorage{client: &c}
+	c.Group = &GroupStorage{client: &c}
+	c.Audit = &AuditStorage{client: &c}
+	c.EmailVerification = &EmailVerificationStorage{client: &c}
+	return &c
+}
+
+// Transact executes a function on a layer on top of the data store which holds the changes.
+// If the function is successfully completed, the changes are committed atomically.
+// If there is an error or a panic, the changes are discarded.
+//
+// The transaction is carried by the context passed to the function, so
+// the storages of the client use the layer when called with that context.
+// A nested call works on another layer which is merged into the outer one
+// only if the nested function succeeds, i.e., it acts as a savepoint.
+// Transactions are serialized.
+func (c *Client) Transact(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
+	if t, ok := ctx.Value(txKey{}).(*txn); ok && t.client == c {
+		return t.savepoint(ctx, atomic)
+	}
+
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+
+	t := txn{
+		client: c,
+		data:   c.data.layer(),
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			panic(p)
+		}
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			return
+		}
+
+		c.mu.Lock()
+		err = c.data.commit(t.data)
+		c.mu.Unlock()
+	}()
+
+	err = atomic(context.WithValue(ctx, txKey{}, &t))
+	return err
+}
+
+// txKey is the context key of a transaction in progress.
+type txKey struct{}
+
+// txn is a transaction in progress started by the client.
+type txn struct {
+	client *Client
+	// data is the layer changed within the transaction.
+	// It's used by a single goroutine, so it needs no locking,
+	// but the committed data underneath it does.
+	data *data
+}
+
+// savepoint executes a function on a layer on top of the transaction's one.
+// The layer is merged into the outer one only if the function succeeds.
+func (t *txn) savepoint(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
+	outer := t.data
+	t.data = outer.layer()
+	defer func() {
+		inner := t.data
+		t.data = outer
+		if p := recover(); p != nil {
+			panic(p)
+		}
+		if err == nil {
+			outer.merge(inner)
+		}
+	}()
+
+	err = atomic(ctx)
+	return err
+}
+
+// view returns the data visible to a repository call and a func to release it.
+// Within a transaction it's the transaction's layer and the committed data is locked for reading,
+// since only the layer is written. Otherwise it's the committed data locked for reading or writing.
+func (c *Client) view(ctx context.Context, write bool) (*data, func()) {
+	if t, ok := ctx.Value(txKey{}).(*txn); ok && t.client == c {
+		c.mu.RLock()
+		return t.data, c.mu.RUnlock
+	}
+
+	if write {
+		c.mu.Lock()
+		return c.data, c.mu.Unlock
+	}
+	c.mu.RLock()
+	return c.data, c.mu.RUnlock
+}
+
+// tenantFromContext returns the tenant ID the records must be scoped to.
+// It returns EInvalidTenant error when the context has no tenant.
+func tenantFromContext(ctx context.Context) (string, error) {
+	tenantID := account.TenantFromContext(ctx)
+	if tenantID == "" {
+		return "", account.Error{
+			Code:    account.EInvalidTenant,
+			Message: "Tenant ID is required.",
+		}
+	}
+	return tenantID, nil
+}
@@ -0,0 +1,445 @@
+package inmem
+
+import (
+	"strings"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// data holds records of all tenants.
+//
+// Within a transaction the data is a layer on top of its parent, e.g., the committed data,
+// and it holds only the records the transaction read or wrote, so a write
+// doesn't copy the whole store. The records missing in the layer are looked up in the parent.
+// A savepoint is another layer on top of the transaction's one.
+type data struct {
+	// parent is the data the layer is on top of. It's nil for the committed data.
+	parent *data
+
+	users map[string]userRecord
+	// groups of a layer can hold deleted records to hide the groups of the parent.
+	groups map[string]groupRecord
+	// usernames and emails index user IDs by username and lowercase email within a tenant.
+	// An empty ID in a layer means the key was released within the transaction.
+	usernames map[indexKey]string
+	emails    map[indexKey]string
+	// changes and entries of a layer are the ones added within the transaction.
+	changes []changeRecord
+	entries []auditRecord
+	// verifications are keyed by token hash.
+	verifications map[string]verificationRecord
+
+	// dirtyUsers and dirtyGroups map IDs of records written within a transaction
+	// to the versions the records had before they were written (zero if a record didn't exist).
+	dirtyUsers  map[string]uint64
+	dirtyGroups map[string]uint64
+}
+
+// indexKey is a key of a unique index within a tenant, e.g., a username.
+type indexKey struct {
+	tenantID string
+	value    string
+}
+
+// userRecord is a user of a tenant.
+// The version is incremented on every write, so a transaction can tell
+// whether the record was changed after the transaction read it.
+type userRecord struct {
+	tenantID string
+	user     account.User
+	version  uint64
+}
+
+type groupRecord struct {
+	tenantID string
+	group    account.Group
+	version  uint64
+	deleted  bool
+}
+
+type changeRecord struct {
+	tenantID string
+	change   account.UsernameChange
+}
+
+type verificationRecord struct {
+	tenantID     string
+	verification account.EmailVerification
+}
+
+type auditRecord struct {
+	tenantID string
+	entry    account.AuditEntry
+}
+
+func newData() *data {
+	return &data{
+		users:         make(map[string]userRecord),
+		groups:        make(map[string]groupRecord),
+		usernames:     make(map[indexKey]string),
+		emails:        make(map[indexKey]string),
+		verifications: make(map[string]verificationRecord),
+	}
+}
+
+// layer returns an empty layer on top of the data to be changed within a transaction.
+func (d *data) layer() *data {
+	l := newData()
+	l.parent = d
+	l.dirtyUsers = make(map[string]uint64)
+	l.dirtyGroups = make(map[string]uint64)
+	return l
+}
+
+// merge applies the records of a savepoint's layer to the data the layer is on top of.
+// The versions the records had before the transaction are kept.
+func (d *data) merge(l *data) {
+	for id, r := range l.users {
+		d.users[id] = r
+	}
+	for id, v := range l.dirtyUsers {
+		if _, written := d.dirtyUsers[id]; !written {
+			d.dirtyUsers[id] = v
+		}
+	}
+	for id, r := range l.groups {
+		d.groups[id] = r
+	}
+	for id, v := range l.dirtyGroups {
+		if _, written := d.dirtyGroups[id]; !written {
+			d.dirtyGroups[id] = v
+		}
+	}
+	for k, id := range l.usernames {
+		d.usernames[k] = id
+	}
+	for k, id := range l.emails {
+		d.emails[k] = id
+	}
+	for h, r := range l.verifications {
+		d.verifications[h] = r
+	}
+	d.changes = append(d.changes, l.changes...)
+	d.entries = append(d.entries, l.entries...)
+}
+
+// commit applies the records written within a transaction's layer.
+// It returns EConcurrentUpdate error if any of the records was written outside of the transaction
+// after the transaction read it, e.g., by UserStorage.UpdateUser, so the changes aren't lost.
+// The records are validated against the committed data since it could have been changed
+// outside of the transaction, e.g., by UserStorage.CreateUser.
+// The committed records overwritten by the transaction are skipped,
+// e.g., a username can be released and claimed by another user within the same transaction.
+// Nothing is applied if any of the records conflicts.
+func (d *data) commit(l *data) error {
+	for id, version := range l.dirtyUsers {
+		if d.users[id].version != version {
+			return errConcurrentUpdate
+		}
+	}
+	for id, version := range l.dirtyGroups {
+		if d.groups[id].version != version {
+			return errConcurrentUpdate
+		}
+	}
+	for id := range l.dirtyUsers {
+		if err := d.userConflict(l.users[id], l.dirtyUsers); err != nil {
+			return err
+		}
+	}
+	for id := range l.dirtyGroups {
+		r := l.groups[id]
+		if r.deleted {
+			continue
+		}
+		if err := d.groupConflict(r, l.dirtyGroups); err != nil {
+			return err
+		}
+	}
+
+	// The index entries are removed before new ones are added
+	// since a username can move from one user to another.
+	for id := range l.dirtyUsers {
+		if r, ok := d.users[id]; ok {
+			d.unindexUser(r)
+		}
+	}
+	for id := range l.dirtyUsers {
+		r := l.users[id]
+		d.users[id] = r
+		d.indexUser(r)
+	}
+	for id := range l.dirtyGroups {
+		r := l.groups[id]
+		if r.deleted {
+			delete(d.groups, id)
+			continue
+		}
+		d.groups[id] = r
+	}
+	for h, r := range l.verifications {
+		d.verifications[h] = r
+	}
+	d.changes = append(d.changes, l.changes...)
+	d.entries = append(d.entries, l.entries...)
+	return nil
+}
+
+// errConcurrentUpdate is returned when a transaction can't be committed
+// because its records were changed after it read them.
+var errConcurrentUpdate = account.Error{
+	Code:    account.EConcurrentUpdate,
+	Message: "The data was changed by a concurrent request. Please try again.",
+}
+
+// user returns a user record by ID.
+// A record found in the parent is copied to the layer, so the transaction keeps
+// the version it read, and a concurrent write is detected on commit.
+func (d *data) user(id string) (userRecord, bool) {
+	r, ok := d.users[id]
+	if ok || d.parent == nil {
+		return r, ok
+	}
+	if r, ok = d.parent.user(id); ok {
+		d.users[id] = r
+	}
+	return r, ok
+}
+
+// rangeUsers calls fn for every user record.
+// The records of a layer replace the ones of its parent.
+func (d *data) rangeUsers(fn func(r userRecord)) {
+	seen := make(map[string]struct{})
+	for l := d; l != nil; l = l.parent {
+		for id, r := range l.users {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			if l.parent != nil {
+				seen[id] = struct{}{}
+			}
+			fn(r)
+		}
+	}
+}
+
+// putUser writes a user record after checking that the username and email are unique within the tenant.
+func (d *data) putUser(r userRecord) error {
+	if err := d.userConflict(r, nil); err != nil {
+		return err
+	}
+
+	prev, ok := d.user(r.user.ID)
+	if ok {
+		d.unindexUser(prev)
+	}
+	if d.dirtyUsers != nil {
+		if _, written := d.dirtyUsers[r.user.ID]; !written {
+			d.dirtyUsers[r.user.ID] = prev.version
+		}
+	}
+	r.version = prev.version + 1
+	d.users[r.user.ID] = r
+	d.indexUser(r)
+	return nil
+}
+
+// userConflict returns EConflict error if another user of the tenant has the same username or email.
+// Emails are compared regardless of their case. Users with IDs from skip aren't checked.
+func (d *data) userConflict(r userRecord, skip map[string]uint64) error {
+	taken := func(id string, ok bool) bool {
+		if !ok || id == r.user.ID {
+			return false
+		}
+		_, skipped := skip[id]
+		return !skipped
+	}
+
+	if taken(d.userByUsername(r.tenantID, r.user.Username)) {
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Username is already in use. Please choose a different username.",
+			Field:   "username",
+		}
+	}
+	if r.user.Email != "" && taken(d.userByEmail(r.tenantID, r.user.Email)) {
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Email is already in use. Please choose a different email.",
+			Field:   "email",
+		}
+	}
+	return nil
+}
+
+// userByUsername returns ID of the tenant's user who has the username.
+func (d *data) userByUsername(tenantID, username string) (string, bool) {
+	return d.indexed(usernameIndex, indexKey{tenantID: tenantID, value: username})
+}
+
+// userByEmail returns ID of the tenant's user who has the email regardless of its case.
+func (d *data) userByEmail(tenantID, email string) (string, bool) {
+	return d.indexed(emailIndex, indexKey{tenantID: tenantID, value: strings.ToLower(email)})
+}
+
+func usernameIndex(d *data) map[indexKey]string { return d.usernames }
+func emailIndex(d *data) map[indexKey]string    { return d.emails }
+
+// indexed returns ID of the user indexed by the key in the layer or its parents.
+func (d *data) indexed(index func(*data) map[indexKey]string, k indexKey) (string, bool) {
+	for l := d; l != nil; l = l.parent {
+		if id, ok := index(l)[k]; ok {
+			return id, id != ""
+		}
+	}
+	return "", false
+}
+
+// indexUser adds the username and email of the user to the indexes.
+func (d *data) indexUser(r userRecord) {
+	d.usernames[indexKey{tenantID: r.tenantID, value: r.user.Username}] = r.user.ID
+	if r.user.Email != "" {
+		d.emails[indexKey{tenantID: r.tenantID, value: strings.ToLower(r.user.Email)}] = r.user.ID
+	}
+}
+
+// unindexUser removes the username and email of the user from the indexes
+// unless they already point to another user.
+func (d *data) unindexUser(r userRecord) {
+	d.unindex(usernameIndex, indexKey{tenantID: r.tenantID, value: r.user.Username}, r.user.ID)
+	if r.user.Email != "" {
+		d.unindex(emailIndex, indexKey{tenantID: r.tenantID, value: strings.ToLower(r.user.Email)}, r.user.ID)
+	}
+}
+
+// unindex removes the key pointing to the user from the index.
+// A layer keeps the key with an empty ID to hide the key of its parent.
+func (d *data) unindex(index func(*data) map[indexKey]string, k indexKey, userID string) {
+	if id, _ := d.indexed(index, k); id != userID {
+		return
+	}
+	if d.parent == nil {
+		delete(index(d), k)
+		return
+	}
+	index(d)[k] = ""
+}
+
+// rangeChanges calls fn for every username change in the order they were added.
+func (d *data) rangeChanges(fn func(r changeRecord)) {
+	if d.parent != nil {
+		d.parent.rangeChanges(fn)
+	}
+	for _, r := range d.changes {
+		fn(r)
+	}
+}
+
+// rangeEntries calls fn for every audit entry in the order they were appended.
+func (d *data) rangeEntries(fn func(r auditRecord)) {
+	if d.parent != nil {
+		d.parent.rangeEntries(fn)
+	}
+	for _, r := range d.entries {
+		fn(r)
+	}
+}
+
+// verification returns an email verification record by token hash.
+func (d *data) verification(tokenHash string) (verificationRecord, bool) {
+	for l := d; l != nil; l = l.parent {
+		if r, ok := l.verifications[tokenHash]; ok {
+			return r, true
+		}
+	}
+	return verificationRecord{}, false
+}
+
+// putVerification writes an email verification record.
+func (d *data) putVerification(r verificationRecord) {
+	d.verifications[r.verification.TokenHash] = r
+}
+
+// group returns a group record by ID.
+// Like user, a record found in the parent is copied to the layer.
+func (d *data) group(id string) (groupRecord, bool) {
+	r, ok := d.groups[id]
+	if !ok && d.parent != nil {
+		if r, ok = d.parent.group(id); ok {
+			d.groups[id] = r
+		}
+	}
+	return r, ok && !r.deleted
+}
+
+// rangeGroups calls fn for every group record except the deleted ones.
+// The records of a layer replace the ones of its parent.
+func (d *data) rangeGroups(fn func(r groupRecord)) {
+	seen := make(map[string]struct{})
+	for l := d; l != nil; l = l.parent {
+		for id, r := range l.groups {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			if l.parent != nil {
+				seen[id] = struct{}{}
+			}
+			if !r.deleted {
+				fn(r)
+			}
+		}
+	}
+}
+
+// putGroup writes a group record after checking that the group name is unique within the tenant.
+func (d *data) putGroup(r groupRecord) error {
+	if err := d.groupConflict(r, nil); err != nil {
+		return err
+	}
+
+	prev, _ := d.group(r.group.ID)
+	if d.dirtyGroups != nil {
+		if _, written := d.dirtyGroups[r.group.ID]; !written {
+			d.dirtyGroups[r.group.ID] = prev.version
+		}
+	}
+	r.version = prev.version + 1
+	d.groups[r.group.ID] = r
+	return nil
+}
+
+// deleteGroup removes a group record.
+// A layer keeps the deleted record to hide the group of its parent.
+func (d *data) deleteGroup(id string) {
+	if d.parent == nil {
+		delete(d.groups, id)
+		return
+	}
+	prev, _ := d.group(id)
+	if _, written := d.dirtyGroups[id]; !written {
+		d.dirtyGroups[id] = prev.version
+	}
+	d.groups[id] = groupRecord{deleted: true}
+}
+
+// groupConflict returns EConflict error if another group of the tenant has the same name.
+// Groups with IDs from skip aren't checked.
+func (d *data) groupConflict(r groupRecord, skip map[string]uint64) error {
+	var taken bool
+	d.rangeGroups(func(other groupRecord) {
+		if _, ok := skip[other.group.ID]; ok {
+			return
+		}
+		if other.group.ID != r.group.ID && other.tenantID == r.tenantID && other.group.Name == r.group.Name {
+			taken = true
+		}
+	})
+	if taken {
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Group name is already in use. Please choose a different name.",
+			Field:   "name",
+		}
+	}
+	return nil
+}
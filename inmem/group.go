@@ -0,0 +1,123 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// GroupStorage represents an in-memory storage of customer groups.
+type GroupStorage struct {
+	client *Client
+}
+
+// FindGroupByID returns a group by ID or ENotFound error if group does not exist in the tenant.
+func (s *GroupStorage) FindGroupByID(ctx context.Context, id string) (*account.Group, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	r, ok := d.group(id)
+	if !ok || r.tenantID != tenantID {
+		return nil, errGroupNotFound
+	}
+	g := r.group
+	return &g, nil
+}
+
+// CreateGroup creates a new group in the tenant.
+// It returns EConflict error if the group name is already in use.
+func (s *GroupStorage) CreateGroup(ctx context.Context, g *account.Group) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if _, ok := d.group(g.ID); ok {
+		return fmt.Errorf("GroupStorage.CreateGroup: group (id %s) already exists", g.ID)
+	}
+	if err = d.putGroup(groupRecord{tenantID: tenantID, group: *g}); err != nil {
+		return fmt.Errorf("GroupStorage.CreateGroup: %w", err)
+	}
+	return nil
+}
+
+// UpdateGroup updates group details.
+// It returns ENotFound error if group does not exist in the tenant or
+// EConflict error if the group name is already in use.
+func (s *GroupStorage) UpdateGroup(ctx context.Context, g *account.Group) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if r, ok := d.group(g.ID); !ok || r.tenantID != tenantID {
+		return errGroupNotFound
+	}
+	if err = d.putGroup(groupRecord{tenantID: tenantID, group: *g}); err != nil {
+		return fmt.Errorf("GroupStorage.UpdateGroup: %w", err)
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group by ID or returns ENotFound error if group does not exist in the tenant.
+func (s *GroupStorage) DeleteGroup(ctx context.Context, id string) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if r, ok := d.group(id); !ok || r.tenantID != tenantID {
+		return errGroupNotFound
+	}
+	d.deleteGroup(id)
+	return nil
+}
+
+// ListGroups returns groups of the tenant ordered by name which come after f.After, at most f.Limit of them.
+func (s *GroupStorage) ListGroups(ctx context.Context, f account.GroupFilter) ([]*account.Group, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	var gg []*account.Group
+	d.rangeGroups(func(r groupRecord) {
+		if r.tenantID != tenantID || r.group.Name <= f.After {
+			return
+		}
+		g := r.group
+		gg = append(gg, &g)
+	})
+	sort.Slice(gg, func(i, j int) bool {
+		return gg[i].Name < gg[j].Name
+	})
+	if len(gg) > f.Limit {
+		gg = gg[:f.Limit]
+	}
+	return gg, nil
+}
+
+// Transact relies on Client to implement a Storage interface.
+func (s *GroupStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) error {
+	return s.client.Transact(ctx, atomic)
+}
+
+// errGroupNotFound is returned when a group does not exist in the tenant.
+var errGroupNotFound = account.Error{
+	Code:    account.ENotFound,
+	Message: "Group not found.",
+}
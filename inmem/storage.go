@@ -0,0 +1,244 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// UserStorage represents an in-memory storage of users.
+type UserStorage struct {
+	client *Client
+}
+
+// FindUserByID returns a user by ID or ENotFound error if user does not exist in the tenant.
+func (s *UserStorage) FindUserByID(ctx context.Context, id string) (*account.User, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	r, ok := d.user(id)
+	if !ok || r.tenantID != tenantID {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "User not found.",
+		}
+	}
+	u := r.user
+	return &u, nil
+}
+
+// FindUserByUsername returns a user by current username or ENotFound error if user does not exist in the tenant.
+func (s *UserStorage) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	id, ok := d.userByUsername(tenantID, username)
+	if !ok {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "User not found.",
+		}
+	}
+	r, _ := d.user(id)
+	u := r.user
+	return &u, nil
+}
+
+// UsernameInUse returns true if username is already claimed within the tenant.
+func (s *UserStorage) UsernameInUse(ctx context.Context, username string) (bool, error) {
+	_, err := s.FindUserByUsername(ctx, username)
+	switch {
+	case account.ErrorCode(err) == account.ENotFound:
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return true, nil
+}
+
+// EmailInUse returns true if email is already claimed by another user of the tenant regardless of its case.
+func (s *UserStorage) EmailInUse(ctx context.Context, email string) (bool, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	_, ok := d.userByEmail(tenantID, email)
+	return ok, nil
+}
+
+// CreateUser creates a new user in the tenant.
+// It returns EConflict error if the username or email is already in use.
+func (s *UserStorage) CreateUser(ctx context.Context, u *account.User) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if _, ok := d.user(u.ID); ok {
+		return fmt.Errorf("UserStorage.CreateUser: user (id %s) already exists", u.ID)
+	}
+	if err = d.putUser(userRecord{tenantID: tenantID, user: *u}); err != nil {
+		return fmt.Errorf("UserStorage.CreateUser: %w", err)
+	}
+	return nil
+}
+
+// ImportUsers creates users in the tenant within a transaction.
+// It returns *account.ImportError listing EConflict errors if any username or email is already in use and
+// EUsernameReserved errors if any username was released after reservedSince.
+func (s *UserStorage) ImportUsers(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+	if _, err := tenantFromContext(ctx); err != nil {
+		return err
+	}
+
+	return s.client.Transact(ctx, func(ctx context.Context) error {
+		var importErr account.ImportError
+		for i, u := range users {
+			err := s.CreateUser(ctx, u)
+			if err == nil {
+				err = s.checkUsernameReserved(ctx, u.Username, reservedSince)
+			}
+			if code := account.ErrorCode(err); code == account.EConflict || code == account.EUsernameReserved {
+				importErr.Rows = append(importErr.Rows, account.ImportRowError{Row: i + 1, Err: err})
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if len(importErr.Rows) > 0 {
+			return &importErr
+		}
+		return nil
+	})
+}
+
+// checkUsernameReserved returns EUsernameReserved if the username was released after reservedSince.
+func (s *UserStorage) checkUsernameReserved(ctx context.Context, username string, reservedSince time.Time) error {
+	c, err := s.FindUsernameChange(ctx, username)
+	switch {
+	case account.ErrorCode(err) == account.ENotFound:
+		return nil
+	case err != nil:
+		return err
+	case c.ChangedAt.After(reservedSince):
+		return account.Error{
+			Code:    account.EUsernameReserved,
+			Message: "Username was recently released and is reserved. Please choose a different username.",
+			Field:   "username",
+		}
+	}
+	return nil
+}
+
+// UpdateUser updates user details.
+// It returns ENotFound error if user does not exist in the tenant or
+// EConflict error if the username or email is already in use.
+func (s *UserStorage) UpdateUser(ctx context.Context, u *account.User) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if r, ok := d.user(u.ID); !ok || r.tenantID != tenantID {
+		return account.Error{
+			Code:    account.ENotFound,
+			Message: "User not found.",
+		}
+	}
+	if err = d.putUser(userRecord{tenantID: tenantID, user: *u}); err != nil {
+		return fmt.Errorf("UserStorage.UpdateUser: %w", err)
+	}
+	return nil
+}
+
+// CreateUsernameChange adds a former username of a user to the history.
+func (s *UserStorage) CreateUsernameChange(ctx context.Context, c *account.UsernameChange) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	d.changes = append(d.changes, changeRecord{tenantID: tenantID, change: *c})
+	return nil
+}
+
+// FindUsernameChange returns the latest change where the username was released or
+// ENotFound error if nobody in the tenant had that username before.
+func (s *UserStorage) FindUsernameChange(ctx context.Context, username string) (*account.UsernameChange, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	var latest *account.UsernameChange
+	d.rangeChanges(func(r changeRecord) {
+		if r.tenantID != tenantID || r.change.Username != username {
+			return
+		}
+		if latest == nil || !r.change.ChangedAt.Before(latest.ChangedAt) {
+			c := r.change
+			latest = &c
+		}
+	})
+	if latest == nil {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "Username change not found.",
+		}
+	}
+	return latest, nil
+}
+
+// ListUsers returns users of the tenant ordered by username which come after f.After, at most f.Limit of them.
+func (s *UserStorage) ListUsers(ctx context.Context, f account.UserFilter) ([]*account.User, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	var uu []*account.User
+	d.rangeUsers(func(r userRecord) {
+		if r.tenantID != tenantID || r.user.Username <= f.After {
+			return
+		}
+		u := r.user
+		uu = append(uu, &u)
+	})
+	sort.Slice(uu, func(i, j int) bool {
+		return uu[i].Username < uu[j].Username
+	})
+	if len(uu) > f.Limit {
+		uu = uu[:f.Limit]
+	}
+	return uu, nil
+}
+
+// Transact relies on Client to implement a Storage interface.
+func (s *UserStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) error {
+	return s.client.Transact(ctx, atomic)
+}
@@ -0,0 +1,377 @@
+package inmem
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/storagetest"
+)
+
+// Ensure UserStorage implements account.UserImportRepository.
+var _ account.UserImportRepository = &UserStorage{}
+
+// Ensure GroupStorage implements account.GroupRepository.
+var _ account.GroupRepository = &GroupStorage{}
+
+func TestUserStorage_conformance(t *testing.T) {
+	storagetest.TestUserRepository(t, func(t *testing.T) account.UserRepository {
+		return NewClient().User
+	})
+}
+
+func TestGroupStorage_conformance(t *testing.T) {
+	storagetest.TestGroupRepository(t, func(t *testing.T) account.GroupRepository {
+		return NewClient().Group
+	})
+}
+
+const (
+	testTenantID  = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+	otherTenantID = "5d1c0a57-4d7e-4b4b-9a43-7c2f3f0e6c02"
+)
+
+func TestUserStorage_CreateUser_conflict(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	}
+	if err := c.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	if inUse, _ := c.User.UsernameInUse(ctx, "Alice"); !inUse {
+		t.Errorf("UsernameInUse() got false, want true")
+	}
+	if inUse, _ := c.User.EmailInUse(ctx, "ALICE@example.com"); !inUse {
+		t.Errorf("EmailInUse() got false, want true")
+	}
+
+	tt := []struct {
+		user      account.User
+		wantField string
+	}{
+		{account.User{ID: "456", Username: "Alice", Status: account.StatusActive}, "username"},
+		{account.User{ID: "456", Username: "Bob", Status: account.StatusActive, Email: "Alice@Example.com"}, "email"},
+	}
+	for _, tc := range tt {
+		err := c.User.CreateUser(ctx, &tc.user)
+		var accErr account.Error
+		if !errors.As(err, &accErr) || accErr.Code != account.EConflict || accErr.Field != tc.wantField {
+			t.Errorf("CreateUser(%+v) = %v, want %s conflict", tc.user, err, tc.wantField)
+		}
+	}
+}
+
+func TestUserStorage_tenant(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := c.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	_, err := c.User.FindUserByID(otherCtx, alice.ID)
+	if code := account.ErrorCode(err); code != account.ENotFound {
+		t.Errorf("FindUserByID() got %q error code from other tenant, want not_found", code)
+	}
+	err = c.User.UpdateUser(otherCtx, &alice)
+	if code := account.ErrorCode(err); code != account.ENotFound {
+		t.Errorf("UpdateUser() got %q error code from other tenant, want not_found", code)
+	}
+	bob := account.User{
+		ID:       "456",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err = c.User.CreateUser(otherCtx, &bob); err != nil {
+		t.Errorf("CreateUser() failed to reuse username in other tenant: %v", err)
+	}
+
+	_, err = c.User.FindUserByID(context.Background(), alice.ID)
+	if code := account.ErrorCode(err); code != account.EInvalidTenant {
+		t.Errorf("FindUserByID() got %q error code without tenant, want invalid_tenant", code)
+	}
+}
+
+func TestTransact(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := c.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	err := c.Transact(ctx, func(txCtx context.Context) error {
+		u, err := c.User.FindUserByID(txCtx, alice.ID)
+		if err != nil {
+			return err
+		}
+		u.Username = "Bob"
+		if err = c.User.UpdateUser(txCtx, u); err != nil {
+			return err
+		}
+		if err = c.Group.CreateGroup(txCtx, &account.Group{ID: "1", Name: "admins"}); err != nil {
+			return err
+		}
+
+		// The changes are visible only within the transaction.
+		if u, _ = c.User.FindUserByID(ctx, alice.ID); u.Username != "Alice" {
+			t.Errorf("FindUserByID() got uncommitted username %q", u.Username)
+		}
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("Transact() got %v, want %v", err, errRollback)
+	}
+	if u, _ := c.User.FindUserByID(ctx, alice.ID); u.Username != "Alice" {
+		t.Errorf("Transact() was not rolled back, got username %q", u.Username)
+	}
+	if err = c.Group.CreateGroup(ctx, &account.Group{ID: "2", Name: "admins"}); err != nil {
+		t.Errorf("Transact() was not rolled back, CreateGroup() failed: %v", err)
+	}
+
+	err = c.Transact(ctx, func(txCtx context.Context) error {
+		u, err := c.User.FindUserByID(txCtx, alice.ID)
+		if err != nil {
+			return err
+		}
+		u.Username = "Bob"
+		if err = c.User.UpdateUser(txCtx, u); err != nil {
+			return err
+		}
+		return c.User.CreateUsernameChange(txCtx, &account.UsernameChange{
+			UserID:    alice.ID,
+			Username:  "Alice",
+			ChangedAt: time.Now(),
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+	if u, _ := c.User.FindUserByUsername(ctx, "Bob"); u == nil || u.ID != alice.ID {
+		t.Errorf("Transact() was not committed, got user %+v", u)
+	}
+	if ch, _ := c.User.FindUsernameChange(ctx, "Alice"); ch == nil || ch.UserID != alice.ID {
+		t.Errorf("Transact() was not committed, got username change %+v", ch)
+	}
+}
+
+func TestTransact_commit_conflict(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := c.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	err := c.Transact(ctx, func(txCtx context.Context) error {
+		u, err := c.User.FindUserByID(txCtx, alice.ID)
+		if err != nil {
+			return err
+		}
+		u.Username = "Bob"
+		if err = c.User.UpdateUser(txCtx, u); err != nil {
+			return err
+		}
+		// Bob signs up while the transaction is in progress.
+		return c.User.CreateUser(ctx, &account.User{ID: "456", Username: "Bob"})
+	})
+	if code := account.ErrorCode(err); code != account.EConflict {
+		t.Errorf("Transact() got %v, want conflict", err)
+	}
+	if u, _ := c.User.FindUserByID(ctx, alice.ID); u.Username != "Alice" {
+		t.Errorf("Transact() was not rolled back, got username %q", u.Username)
+	}
+}
+
+func TestTransact_commit_concurrentUpdate(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := c.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	err := c.Transact(ctx, func(txCtx context.Context) error {
+		u, err := c.User.FindUserByID(txCtx, alice.ID)
+		if err != nil {
+			return err
+		}
+		// Alice is suspended while the transaction is in progress.
+		suspended := alice
+		suspended.Status = account.StatusSuspended
+		if err = c.User.UpdateUser(ctx, &suspended); err != nil {
+			return err
+		}
+
+		u.Username = "Bob"
+		return c.User.UpdateUser(txCtx, u)
+	})
+	if code := account.ErrorCode(err); code != account.EConcurrentUpdate {
+		t.Errorf("Transact() got %v, want concurrent update", err)
+	}
+	u, _ := c.User.FindUserByID(ctx, alice.ID)
+	if u.Username != "Alice" || u.Status != account.StatusSuspended {
+		t.Errorf("Transact() overwrote the concurrent update, got %+v", u)
+	}
+	if inUse, _ := c.User.UsernameInUse(ctx, "Bob"); inUse {
+		t.Error("UsernameInUse(Bob) got true after rollback, want false")
+	}
+}
+
+func TestUserStorage_concurrency(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			u := account.User{ID: fmt.Sprint(i), Username: "Alice"}
+			if err := c.User.CreateUser(ctx, &u); err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+			}
+			c.Transact(ctx, func(txCtx context.Context) error {
+				_, err := c.User.FindUserByUsername(txCtx, "Alice")
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("CreateUser() created %d users with the same username, want 1", created)
+	}
+}
+
+func TestTransact_savepoint(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+
+	errRollback := errors.New("rollback")
+	err := c.Transact(ctx, func(txCtx context.Context) error {
+		if err := c.Group.CreateGroup(txCtx, &account.Group{ID: "1", Name: "admins"}); err != nil {
+			return err
+		}
+
+		err := c.Transact(txCtx, func(txCtx context.Context) error {
+			if err := c.Group.CreateGroup(txCtx, &account.Group{ID: "2", Name: "editors"}); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if err != errRollback {
+			t.Errorf("Transact() got %v, want %v", err, errRollback)
+		}
+
+		return c.Transact(txCtx, func(txCtx context.Context) error {
+			return c.Group.CreateGroup(txCtx, &account.Group{ID: "3", Name: "viewers"})
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	tt := []struct {
+		name         string
+		wantConflict bool
+	}{
+		{"admins", true},
+		{"editors", false},
+		{"viewers", true},
+	}
+	for i, tc := range tt {
+		err = c.Group.CreateGroup(ctx, &account.Group{ID: fmt.Sprint(i + 10), Name: tc.name})
+		if got := account.ErrorCode(err) == account.EConflict; got != tc.wantConflict {
+			t.Errorf("CreateGroup(%q) got %v, want conflict %t", tc.name, err, tc.wantConflict)
+		}
+	}
+}
+
+func TestTransact_layer(t *testing.T) {
+	c := NewClient()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	for i := 0; i < 100; i++ {
+		u := account.User{ID: fmt.Sprint(i), Username: fmt.Sprintf("user%d", i)}
+		if err := c.User.CreateUser(ctx, &u); err != nil {
+			t.Fatalf("CreateUser() failed: %v", err)
+		}
+	}
+
+	errRollback := errors.New("rollback")
+	err := c.Transact(ctx, func(txCtx context.Context) error {
+		u, err := c.User.FindUserByUsername(txCtx, "user1")
+		if err != nil {
+			return err
+		}
+		u.Username = "Alice"
+		if err = c.User.UpdateUser(txCtx, u); err != nil {
+			return err
+		}
+
+		err = c.Transact(txCtx, func(txCtx context.Context) error {
+			u.Username = "Bob"
+			if err := c.User.UpdateUser(txCtx, u); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if err != errRollback {
+			t.Errorf("Transact() got %v, want %v", err, errRollback)
+		}
+
+		// Only the records the transaction touched are copied.
+		if n := len(txCtx.Value(txKey{}).(*txn).data.users); n != 1 {
+			t.Errorf("Transact() copied %d users, want 1", n)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	tt := map[string]bool{
+		"user1": false,
+		"Alice": true,
+		"Bob":   false,
+	}
+	for username, want := range tt {
+		if inUse, _ := c.User.UsernameInUse(ctx, username); inUse != want {
+			t.Errorf("UsernameInUse(%s) got %t, want %t", username, inUse, want)
+		}
+	}
+}
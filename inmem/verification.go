@@ -0,0 +1,74 @@
+package inmem
+
+import (
+	"context"
+	"fmt"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// EmailVerificationStorage represents an in-memory storage of email verification tokens.
+type EmailVerificationStorage struct {
+	client *Client
+}
+
+// CreateEmailVerification creates a new verification token in the tenant.
+func (s *EmailVerificationStorage) CreateEmailVerification(ctx context.Context, v *account.EmailVerification) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if _, ok := d.verification(v.TokenHash); ok {
+		return fmt.Errorf("EmailVerificationStorage.CreateEmailVerification: token (hash %s) already exists", v.TokenHash)
+	}
+	d.putVerification(verificationRecord{tenantID: tenantID, verification: *v})
+	return nil
+}
+
+// FindEmailVerification returns a verification by token hash or ENotFound error if it does not exist.
+func (s *EmailVerificationStorage) FindEmailVerification(ctx context.Context, tokenHash string) (*account.EmailVerification, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d, release := s.client.view(ctx, false)
+	defer release()
+
+	r, ok := d.verification(tokenHash)
+	if !ok || r.tenantID != tenantID {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "Email verification not found.",
+		}
+	}
+	v := r.verification
+	return &v, nil
+}
+
+// UpdateEmailVerification updates a verification, e.g., marks it used.
+// It returns ENotFound error if the verification does not exist in the tenant.
+func (s *EmailVerificationStorage) UpdateEmailVerification(ctx context.Context, v *account.EmailVerification) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	d, release := s.client.view(ctx, true)
+	defer release()
+
+	if r, ok := d.verification(v.TokenHash); !ok || r.tenantID != tenantID {
+		return account.Error{
+			Code:    account.ENotFound,
+			Message: "Email verification not found.",
+		}
+	}
+	d.putVerification(verificationRecord{tenantID: tenantID, verification: *v})
+	return nil
+}
+
+// Transact relies on Client to implement a Storage interface.
+func (s *EmailVerificationStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) error {
+	return s.client.Transact(ctx, atomic)
+}
@@ -0,0 +1,67 @@
+// Package mail provides account.MailSender implementations for local development:
+// emails are logged or saved as files instead of being delivered.
+package mail
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/google/uuid"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// LogSender logs emails instead of sending them.
+type LogSender struct {
+	logger log.Logger
+}
+
+// NewLogSender returns a LogSender that writes emails to the logger.
+func NewLogSender(l log.Logger) *LogSender {
+	return &LogSender{logger: l}
+}
+
+// SendMail logs the email.
+func (s *LogSender) SendMail(_ context.Context, m *account.Mail) error {
+	return s.logger.Log(
+		"msg", "email was sent",
+		"to", m.To,
+		"subject", m.Subject,
+		"body", m.Body,
+	)
+}
+
+// FileSender saves emails as .eml files in a directory instead of sending them.
+// The files can be opened by most of email clients.
+type FileSender struct {
+	dir string
+}
+
+// NewFileSender returns a FileSender that keeps emails in the dir.
+// The dir is created if it does not exist.
+func NewFileSender(dir string) (*FileSender, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileSender{dir: dir}, nil
+}
+
+// SendMail saves the email in a file named after the time it was sent.
+func (s *FileSender) SendMail(_ context.Context, m *account.Mail) error {
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s-%s.eml", now.Format("20060102T150405"), uuid.NewString())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	b.WriteString(m.Body)
+
+	return os.WriteFile(filepath.Join(s.dir, name), []byte(b.String()), 0o640)
+}
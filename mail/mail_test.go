@@ -0,0 +1,52 @@
+package mail_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/mail"
+)
+
+// Ensure senders implement account.MailSender.
+var (
+	_ account.MailSender = &mail.LogSender{}
+	_ account.MailSender = &mail.FileSender{}
+)
+
+func TestFileSender(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	s, err := mail.NewFileSender(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := account.Mail{
+		To:      "bob@example.com",
+		Subject: "Verify your email",
+		Body:    "token",
+	}
+	if err = s.SendMail(context.Background(), &m); err != nil {
+		t.Fatalf("SendMail() failed: %v", err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("SendMail() created %d files, want 1", len(files))
+	}
+	b, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: bob@example.com\r\n", "Subject: Verify your email\r\n", "\r\n\r\ntoken"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("SendMail() file %q does not contain %q", b, want)
+		}
+	}
+}
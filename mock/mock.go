@@ -3,15 +3,24 @@ package mock
 
 import (
 	"context"
-	"database/sql"
+	"time"
 
 	account "github.com/marselester/ddd-err"
 )
 
 // UserService is a mock that implements account.UserService.
 type UserService struct {
-	FindUserByIDFn func(ctx context.Context, id string) (*account.User, error)
-	CreateUserFn   func(ctx context.Context, user *account.User) error
+	FindUserByIDFn   func(ctx context.Context, id string) (*account.User, error)
+	CreateUserFn     func(ctx context.Context, user *account.User) error
+	SuspendUserFn    func(ctx context.Context, id string) error
+	ReactivateUserFn func(ctx context.Context, id string) error
+	LockUserFn       func(ctx context.Context, id string) error
+	DeleteUserFn     func(ctx context.Context, id string) error
+	VerifyEmailFn    func(ctx context.Context, token string) (*account.User, error)
+
+	ChangeUsernameFn     func(ctx context.Context, id, username string) error
+	FindUserByUsernameFn func(ctx context.Context, username string) (*account.User, error)
+	ListUsersFn          func(ctx context.Context, filter account.UserFilter) ([]*account.User, error)
 }
 
 // FindUserByID calls FindUserByIDFn for tests to inspect the mock.
@@ -30,44 +39,172 @@ func (s *UserService) CreateUser(ctx context.Context, user *account.User) error
 	return s.CreateUserFn(ctx, user)
 }
 
+// SuspendUser calls SuspendUserFn for tests to inspect the mock.
+func (s *UserService) SuspendUser(ctx context.Context, id string) error {
+	if s.SuspendUserFn == nil {
+		return nil
+	}
+	return s.SuspendUserFn(ctx, id)
+}
+
+// ReactivateUser calls ReactivateUserFn for tests to inspect the mock.
+func (s *UserService) ReactivateUser(ctx context.Context, id string) error {
+	if s.ReactivateUserFn == nil {
+		return nil
+	}
+	return s.ReactivateUserFn(ctx, id)
+}
+
+// LockUser calls LockUserFn for tests to inspect the mock.
+func (s *UserService) LockUser(ctx context.Context, id string) error {
+	if s.LockUserFn == nil {
+		return nil
+	}
+	return s.LockUserFn(ctx, id)
+}
+
+// DeleteUser calls DeleteUserFn for tests to inspect the mock.
+func (s *UserService) DeleteUser(ctx context.Context, id string) error {
+	if s.DeleteUserFn == nil {
+		return nil
+	}
+	return s.DeleteUserFn(ctx, id)
+}
+
+// VerifyEmail calls VerifyEmailFn for tests to inspect the mock.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (*account.User, error) {
+	if s.VerifyEmailFn == nil {
+		return &account.User{}, nil
+	}
+	return s.VerifyEmailFn(ctx, token)
+}
+
+// ChangeUsername calls ChangeUsernameFn for tests to inspect the mock.
+func (s *UserService) ChangeUsername(ctx context.Context, id, username string) error {
+	if s.ChangeUsernameFn == nil {
+		return nil
+	}
+	return s.ChangeUsernameFn(ctx, id, username)
+}
+
+// FindUserByUsername calls FindUserByUsernameFn for tests to inspect the mock.
+func (s *UserService) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	if s.FindUserByUsernameFn == nil {
+		return &account.User{}, nil
+	}
+	return s.FindUserByUsernameFn(ctx, username)
+}
+
+// ListUsers calls ListUsersFn for tests to inspect the mock.
+func (s *UserService) ListUsers(ctx context.Context, filter account.UserFilter) ([]*account.User, error) {
+	if s.ListUsersFn == nil {
+		return nil, nil
+	}
+	return s.ListUsersFn(ctx, filter)
+}
+
+// GroupService is a mock that implements account.GroupService.
+type GroupService struct {
+	FindGroupByIDFn func(ctx context.Context, id string) (*account.Group, error)
+	CreateGroupFn   func(ctx context.Context, group *account.Group) error
+	RenameGroupFn   func(ctx context.Context, id, name string) error
+	ListGroupsFn    func(ctx context.Context, filter account.GroupFilter) ([]*account.Group, error)
+	DeleteGroupFn   func(ctx context.Context, id string) error
+}
+
+// FindGroupByID calls FindGroupByIDFn for tests to inspect the mock.
+func (s *GroupService) FindGroupByID(ctx context.Context, id string) (*account.Group, error) {
+	if s.FindGroupByIDFn == nil {
+		return &account.Group{}, nil
+	}
+	return s.FindGroupByIDFn(ctx, id)
+}
+
+// CreateGroup calls CreateGroupFn for tests to inspect the mock.
+func (s *GroupService) CreateGroup(ctx context.Context, group *account.Group) error {
+	if s.CreateGroupFn == nil {
+		return nil
+	}
+	return s.CreateGroupFn(ctx, group)
+}
+
+// RenameGroup calls RenameGroupFn for tests to inspect the mock.
+func (s *GroupService) RenameGroup(ctx context.Context, id, name string) error {
+	if s.RenameGroupFn == nil {
+		return nil
+	}
+	return s.RenameGroupFn(ctx, id, name)
+}
+
+// ListGroups calls ListGroupsFn for tests to inspect the mock.
+func (s *GroupService) ListGroups(ctx context.Context, filter account.GroupFilter) ([]*account.Group, error) {
+	if s.ListGroupsFn == nil {
+		return nil, nil
+	}
+	return s.ListGroupsFn(ctx, filter)
+}
+
+// DeleteGroup calls DeleteGroupFn for tests to inspect the mock.
+func (s *GroupService) DeleteGroup(ctx context.Context, id string) error {
+	if s.DeleteGroupFn == nil {
+		return nil
+	}
+	return s.DeleteGroupFn(ctx, id)
+}
+
 // Storage is a mock that implements account.Storage.
 type Storage struct {
-	TransactFn func(ctx context.Context, atomic func(*sql.Tx) error) error
+	TransactFn func(ctx context.Context, atomic func(ctx context.Context) error) error
 }
 
 // Transact calls TransactFn for tests to inspect the mock.
-func (s *Storage) Transact(ctx context.Context, atomic func(*sql.Tx) error) (err error) {
+func (s *Storage) Transact(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
 	if s.TransactFn == nil {
-		return atomic(nil)
+		return atomic(ctx)
 	}
 	return s.TransactFn(ctx, atomic)
 }
 
-// UserStorage is a mock that implements account.UserRepository.
+// UserStorage is a mock that implements account.UserImportRepository.
 type UserStorage struct {
 	Storage
-	FindUserByIDFn  func(ctx context.Context, dbtx *sql.Tx, id string) (*account.User, error)
-	UsernameInUseFn func(ctx context.Context, username string) bool
+	FindUserByIDFn  func(ctx context.Context, id string) (*account.User, error)
+	UsernameInUseFn func(ctx context.Context, username string) (bool, error)
+	EmailInUseFn    func(ctx context.Context, email string) (bool, error)
 	CreateUserFn    func(ctx context.Context, user *account.User) error
-	UpdateUserFn    func(ctx context.Context, dbtx *sql.Tx, user *account.User) error
+	UpdateUserFn    func(ctx context.Context, user *account.User) error
+
+	FindUserByUsernameFn   func(ctx context.Context, username string) (*account.User, error)
+	CreateUsernameChangeFn func(ctx context.Context, c *account.UsernameChange) error
+	FindUsernameChangeFn   func(ctx context.Context, username string) (*account.UsernameChange, error)
+	ImportUsersFn          func(ctx context.Context, users []*account.User, reservedSince time.Time) error
+	ListUsersFn            func(ctx context.Context, filter account.UserFilter) ([]*account.User, error)
 }
 
 // FindUserByID calls FindUserByIDFn for tests to inspect the mock.
-func (s *UserStorage) FindUserByID(ctx context.Context, dbtx *sql.Tx, id string) (*account.User, error) {
+func (s *UserStorage) FindUserByID(ctx context.Context, id string) (*account.User, error) {
 	if s.FindUserByIDFn == nil {
 		return &account.User{}, nil
 	}
-	return s.FindUserByIDFn(ctx, dbtx, id)
+	return s.FindUserByIDFn(ctx, id)
 }
 
 // UsernameInUse calls UsernameInUseFn for tests to inspect the mock.
-func (s *UserStorage) UsernameInUse(ctx context.Context, username string) bool {
+func (s *UserStorage) UsernameInUse(ctx context.Context, username string) (bool, error) {
 	if s.UsernameInUseFn == nil {
-		return true
+		return true, nil
 	}
 	return s.UsernameInUseFn(ctx, username)
 }
 
+// EmailInUse calls EmailInUseFn for tests to inspect the mock.
+func (s *UserStorage) EmailInUse(ctx context.Context, email string) (bool, error) {
+	if s.EmailInUseFn == nil {
+		return false, nil
+	}
+	return s.EmailInUseFn(ctx, email)
+}
+
 // CreateUser calls CreateUserFn for tests to inspect the mock.
 func (s *UserStorage) CreateUser(ctx context.Context, user *account.User) error {
 	if s.CreateUserFn == nil {
@@ -77,23 +214,193 @@ func (s *UserStorage) CreateUser(ctx context.Context, user *account.User) error
 }
 
 // UpdateUser calls UpdateUserFn for tests to inspect the mock.
-func (s *UserStorage) UpdateUser(ctx context.Context, dbtx *sql.Tx, user *account.User) error {
+func (s *UserStorage) UpdateUser(ctx context.Context, user *account.User) error {
 	if s.UpdateUserFn == nil {
 		return nil
 	}
-	return s.UpdateUserFn(ctx, dbtx, user)
+	return s.UpdateUserFn(ctx, user)
+}
+
+// FindUserByUsername calls FindUserByUsernameFn for tests to inspect the mock.
+func (s *UserStorage) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	if s.FindUserByUsernameFn == nil {
+		return &account.User{}, nil
+	}
+	return s.FindUserByUsernameFn(ctx, username)
+}
+
+// CreateUsernameChange calls CreateUsernameChangeFn for tests to inspect the mock.
+func (s *UserStorage) CreateUsernameChange(ctx context.Context, c *account.UsernameChange) error {
+	if s.CreateUsernameChangeFn == nil {
+		return nil
+	}
+	return s.CreateUsernameChangeFn(ctx, c)
+}
+
+// FindUsernameChange calls FindUsernameChangeFn for tests to inspect the mock.
+// By default the username was never released.
+func (s *UserStorage) FindUsernameChange(ctx context.Context, username string) (*account.UsernameChange, error) {
+	if s.FindUsernameChangeFn == nil {
+		return nil, account.Error{Code: account.ENotFound, Message: "Username change not found."}
+	}
+	return s.FindUsernameChangeFn(ctx, username)
+}
+
+// ImportUsers calls ImportUsersFn for tests to inspect the mock.
+func (s *UserStorage) ImportUsers(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+	if s.ImportUsersFn == nil {
+		return nil
+	}
+	return s.ImportUsersFn(ctx, users, reservedSince)
+}
+
+// ListUsers calls ListUsersFn for tests to inspect the mock.
+func (s *UserStorage) ListUsers(ctx context.Context, filter account.UserFilter) ([]*account.User, error) {
+	if s.ListUsersFn == nil {
+		return nil, nil
+	}
+	return s.ListUsersFn(ctx, filter)
+}
+
+// EmailVerificationStorage is a mock that implements account.EmailVerificationRepository.
+type EmailVerificationStorage struct {
+	Storage
+	CreateEmailVerificationFn func(ctx context.Context, v *account.EmailVerification) error
+	FindEmailVerificationFn   func(ctx context.Context, tokenHash string) (*account.EmailVerification, error)
+	UpdateEmailVerificationFn func(ctx context.Context, v *account.EmailVerification) error
+}
+
+// CreateEmailVerification calls CreateEmailVerificationFn for tests to inspect the mock.
+func (s *EmailVerificationStorage) CreateEmailVerification(ctx context.Context, v *account.EmailVerification) error {
+	if s.CreateEmailVerificationFn == nil {
+		return nil
+	}
+	return s.CreateEmailVerificationFn(ctx, v)
+}
+
+// FindEmailVerification calls FindEmailVerificationFn for tests to inspect the mock.
+func (s *EmailVerificationStorage) FindEmailVerification(ctx context.Context, tokenHash string) (*account.EmailVerification, error) {
+	if s.FindEmailVerificationFn == nil {
+		return &account.EmailVerification{}, nil
+	}
+	return s.FindEmailVerificationFn(ctx, tokenHash)
+}
+
+// UpdateEmailVerification calls UpdateEmailVerificationFn for tests to inspect the mock.
+func (s *EmailVerificationStorage) UpdateEmailVerification(ctx context.Context, v *account.EmailVerification) error {
+	if s.UpdateEmailVerificationFn == nil {
+		return nil
+	}
+	return s.UpdateEmailVerificationFn(ctx, v)
+}
+
+// MailSender is a mock that implements account.MailSender.
+type MailSender struct {
+	SendMailFn func(ctx context.Context, m *account.Mail) error
+}
+
+// SendMail calls SendMailFn for tests to inspect the mock.
+func (s *MailSender) SendMail(ctx context.Context, m *account.Mail) error {
+	if s.SendMailFn == nil {
+		return nil
+	}
+	return s.SendMailFn(ctx, m)
 }
 
 // GroupStorage is a mock that implements account.GroupRepository.
 type GroupStorage struct {
 	Storage
-	CreateGroupFn func(ctx context.Context, dbtx *sql.Tx, group *account.Group) error
+	FindGroupByIDFn func(ctx context.Context, id string) (*account.Group, error)
+	CreateGroupFn   func(ctx context.Context, group *account.Group) error
+	UpdateGroupFn   func(ctx context.Context, group *account.Group) error
+	DeleteGroupFn   func(ctx context.Context, id string) error
+	ListGroupsFn    func(ctx context.Context, filter account.GroupFilter) ([]*account.Group, error)
+}
+
+// FindGroupByID calls FindGroupByIDFn for tests to inspect the mock.
+func (s *GroupStorage) FindGroupByID(ctx context.Context, id string) (*account.Group, error) {
+	if s.FindGroupByIDFn == nil {
+		return &account.Group{}, nil
+	}
+	return s.FindGroupByIDFn(ctx, id)
 }
 
 // CreateGroup calls CreateGroupFn for tests to inspect the mock.
-func (s *GroupStorage) CreateGroup(ctx context.Context, dbtx *sql.Tx, group *account.Group) error {
+func (s *GroupStorage) CreateGroup(ctx context.Context, group *account.Group) error {
 	if s.CreateGroupFn == nil {
 		return nil
 	}
-	return s.CreateGroupFn(ctx, dbtx, group)
+	return s.CreateGroupFn(ctx, group)
+}
+
+// UpdateGroup calls UpdateGroupFn for tests to inspect the mock.
+func (s *GroupStorage) UpdateGroup(ctx context.Context, group *account.Group) error {
+	if s.UpdateGroupFn == nil {
+		return nil
+	}
+	return s.UpdateGroupFn(ctx, group)
+}
+
+// DeleteGroup calls DeleteGroupFn for tests to inspect the mock.
+func (s *GroupStorage) DeleteGroup(ctx context.Context, id string) error {
+	if s.DeleteGroupFn == nil {
+		return nil
+	}
+	return s.DeleteGroupFn(ctx, id)
+}
+
+// ListGroups calls ListGroupsFn for tests to inspect the mock.
+func (s *GroupStorage) ListGroups(ctx context.Context, filter account.GroupFilter) ([]*account.Group, error) {
+	if s.ListGroupsFn == nil {
+		return nil, nil
+	}
+	return s.ListGroupsFn(ctx, filter)
+}
+
+// UserImportService is a mock that implements account.UserImportService.
+type UserImportService struct {
+	ImportUsersFn func(ctx context.Context, users []*account.User) error
+}
+
+// ImportUsers calls ImportUsersFn for tests to inspect the mock.
+func (s *UserImportService) ImportUsers(ctx context.Context, users []*account.User) error {
+	if s.ImportUsersFn == nil {
+		return nil
+	}
+	return s.ImportUsersFn(ctx, users)
+}
+
+// AuditService is a mock that implements account.AuditService.
+type AuditService struct {
+	FindAuditEntriesFn func(ctx context.Context, filter account.AuditFilter) ([]*account.AuditEntry, error)
+}
+
+// FindAuditEntries calls FindAuditEntriesFn for tests to inspect the mock.
+func (s *AuditService) FindAuditEntries(ctx context.Context, filter account.AuditFilter) ([]*account.AuditEntry, error) {
+	if s.FindAuditEntriesFn == nil {
+		return nil, nil
+	}
+	return s.FindAuditEntriesFn(ctx, filter)
+}
+
+// AuditStorage is a mock that implements account.AuditRepository.
+type AuditStorage struct {
+	AppendAuditEntryFn func(ctx context.Context, entry *account.AuditEntry) error
+	FindAuditEntriesFn func(ctx context.Context, filter account.AuditFilter) ([]*account.AuditEntry, error)
+}
+
+// AppendAuditEntry calls AppendAuditEntryFn for tests to inspect the mock.
+func (s *AuditStorage) AppendAuditEntry(ctx context.Context, entry *account.AuditEntry) error {
+	if s.AppendAuditEntryFn == nil {
+		return nil
+	}
+	return s.AppendAuditEntryFn(ctx, entry)
+}
+
+// FindAuditEntries calls FindAuditEntriesFn for tests to inspect the mock.
+func (s *AuditStorage) FindAuditEntries(ctx context.Context, filter account.AuditFilter) ([]*account.AuditEntry, error) {
+	if s.FindAuditEntriesFn == nil {
+		return nil, nil
+	}
+	return s.FindAuditEntriesFn(ctx, filter)
 }
@@ -2,7 +2,6 @@ package mock
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
 	"strings"
 	"testing"
@@ -12,7 +11,7 @@ import (
 
 // TestStorageTransact is a dummy example of mocking a service, repository and db transaction.
 func TestStorageTransact(t *testing.T) {
-	userRepo := UserStorage{FindUserByIDFn: func(_ context.Context, _ *sql.Tx, _ string) (*account.User, error) {
+	userRepo := UserStorage{FindUserByIDFn: func(_ context.Context, _ string) (*account.User, error) {
 		return nil, account.Error{
 			Code:    account.ENotFound,
 			Message: "User not found.",
@@ -21,8 +20,8 @@ func TestStorageTransact(t *testing.T) {
 	groupRepo := GroupStorage{}
 
 	s := UserService{CreateUserFn: func(ctx context.Context, user *account.User) error {
-		err := userRepo.Transact(ctx, func(tx *sql.Tx) error {
-			_, err := userRepo.FindUserByID(ctx, tx, user.ID)
+		err := userRepo.Transact(ctx, func(ctx context.Context) error {
+			_, err := userRepo.FindUserByID(ctx, user.ID)
 			if account.ErrorCode(err) != account.ENotFound {
 				return account.Error{
 					Code:    "shoe_fell_off",
@@ -38,7 +37,7 @@ func TestStorageTransact(t *testing.T) {
 				ID:   user.ID,
 				Name: strings.ToLower(user.Username),
 			}
-			return groupRepo.CreateGroup(ctx, tx, &group)
+			return groupRepo.CreateGroup(ctx, &group)
 		})
 
 		return err
@@ -53,7 +52,7 @@ func TestStorageTransact(t *testing.T) {
 		t.Errorf("CreateUser() failed: %v", err)
 	}
 
-	userRepo.FindUserByIDFn = func(_ context.Context, _ *sql.Tx, _ string) (*account.User, error) {
+	userRepo.FindUserByIDFn = func(_ context.Context, _ string) (*account.User, error) {
 		return nil, fmt.Errorf("shoe fell off")
 	}
 	err = s.CreateUser(context.Background(), &alice)
@@ -0,0 +1,81 @@
+package pg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// AuditStorage represents a Postgres append-only storage of account audit entries.
+type AuditStorage struct {
+	client *Client
+}
+
+// AppendAuditEntry adds an entry to the audit trail of the tenant.
+func (s *AuditStorage) AppendAuditEntry(ctx context.Context, e *account.AuditEntry) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(e.Changes)
+	if err != nil {
+		return fmt.Errorf("AuditStorage.AppendAuditEntry: %w", err)
+	}
+
+	q, _ := s.client.querier(ctx)
+	_, err = q.Exec(
+		ctx,
+		`INSERT INTO audit_log (id, tenant_id, created_at, actor, claimed_actor, action, target_id, changes, request_id, outcome, error_code)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		e.ID, tenantID, e.CreatedAt, e.Actor, e.ClaimedActor, e.Action, e.TargetID, string(changes), e.RequestID, e.Outcome, e.ErrorCode,
+	)
+	if err != nil {
+		return fmt.Errorf("AuditStorage.AppendAuditEntry: %w", err)
+	}
+	return nil
+}
+
+// FindAuditEntries returns audit entries of a tenant's user created within a time range ordered by creation time.
+func (s *AuditStorage) FindAuditEntries(ctx context.Context, f account.AuditFilter) ([]*account.AuditEntry, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q, _ := s.client.reader(ctx)
+	rows, err := q.Query(
+		ctx,
+		`SELECT id, created_at, actor, claimed_actor, action, target_id, changes, request_id, outcome, error_code
+		FROM audit_log
+		WHERE tenant_id = $1 AND target_id = $2 AND created_at >= $3 AND created_at < $4
+		ORDER BY created_at
+		LIMIT $5`,
+		tenantID, f.UserID, f.Since, f.Until, f.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("AuditStorage.FindAuditEntries: %w", err)
+	}
+	defer rows.Close()
+
+	var ee []*account.AuditEntry
+	for rows.Next() {
+		var (
+			e       account.AuditEntry
+			changes []byte
+		)
+		err = rows.Scan(&e.ID, &e.CreatedAt, &e.Actor, &e.ClaimedActor, &e.Action, &e.TargetID, &changes, &e.RequestID, &e.Outcome, &e.ErrorCode)
+		if err != nil {
+			return nil, fmt.Errorf("AuditStorage.FindAuditEntries: %w", err)
+		}
+		if err = json.Unmarshal(changes, &e.Changes); err != nil {
+			return nil, fmt.Errorf("AuditStorage.FindAuditEntries: %w", err)
+		}
+		ee = append(ee, &e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("AuditStorage.FindAuditEntries: %w", err)
+	}
+	return ee, nil
+}
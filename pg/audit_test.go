@@ -0,0 +1,66 @@
+package pg
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// Ensure AuditStorage implements account.AuditRepository.
+var _ account.AuditRepository = &AuditStorage{}
+
+func TestAuditStorage(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	entries := []*account.AuditEntry{
+		{
+			ID:        "1",
+			CreatedAt: now.Add(-time.Hour),
+			Actor:     "admin",
+			Action:    "CreateUser",
+			TargetID:  "123",
+			Changes:   []account.Change{{Field: "username", After: "Alice"}},
+			RequestID: "req-1",
+			Outcome:   account.AuditSuccess,
+		},
+		{
+			ID:        "2",
+			CreatedAt: now,
+			Actor:     "admin",
+			Action:    "CreateUser",
+			TargetID:  "123",
+			Changes:   []account.Change{{Field: "username", After: "Bob"}},
+			RequestID: "req-2",
+			Outcome:   account.AuditRejected,
+			ErrorCode: account.EConflict,
+		},
+	}
+	for _, e := range entries {
+		if err := c.storageClient.Audit.AppendAuditEntry(ctx, e); err != nil {
+			t.Fatalf("AppendAuditEntry() failed: %v", err)
+		}
+	}
+
+	got, err := c.storageClient.Audit.FindAuditEntries(ctx, account.AuditFilter{
+		UserID: "123",
+		Since:  now.Add(-time.Minute),
+		Until:  now.Add(time.Minute),
+		Limit:  10,
+	})
+	if err != nil {
+		t.Fatalf("FindAuditEntries() failed: %v", err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("FindAuditEntries() got %d entries, want 1", len(got))
+	}
+	got[0].CreatedAt = got[0].CreatedAt.UTC()
+	if !reflect.DeepEqual(got[0], entries[1]) {
+		t.Errorf("FindAuditEntries() got %+v, want %+v", got[0], entries[1])
+	}
+}
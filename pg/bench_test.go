@@ -0,0 +1,105 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackc/pgx/v5/stdlib"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// The benchmarks compare the native pgx pool used by the client with database/sql
+// which the package used to wrap the pool with, e.g.,
+//
+//	$ make docker_run_postgres
+//	$ go test ./pg -run=^$ -bench=. -benchmem
+func BenchmarkFindUserByID(b *testing.B) {
+	c := mustOpenClient(b)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	}
+	if err := c.storageClient.User.CreateUser(ctx, &alice); err != nil {
+		b.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	b.Run("pgxpool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := c.storageClient.User.FindUserByID(ctx, alice.ID); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("database/sql", func(b *testing.B) {
+		db := stdlib.OpenDBFromPool(c.storageClient.pool)
+		defer db.Close()
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var u account.User
+			err := db.QueryRowContext(
+				ctx,
+				`SELECT id, username, status, COALESCE(email, ''), display_name, email_verified FROM account
+				WHERE tenant_id = $1 AND id = $2`,
+				testTenantID, alice.ID,
+			).Scan(&u.ID, &u.Username, &u.Status, &u.Email, &u.DisplayName, &u.EmailVerified)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCreateUser(b *testing.B) {
+	c := mustOpenClient(b)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	// seq numbers the created users, since a benchmark function runs several times with growing b.N
+	// and the users created in the previous runs are kept.
+	var seq atomic.Int64
+
+	b.Run("pgxpool", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			n := seq.Add(1)
+			u := account.User{
+				ID:       fmt.Sprintf("pool-%d", n),
+				Username: fmt.Sprintf("pool-%d", n),
+				Status:   account.StatusActive,
+			}
+			if err := c.storageClient.User.CreateUser(ctx, &u); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("database/sql", func(b *testing.B) {
+		db := stdlib.OpenDBFromPool(c.storageClient.pool)
+		defer db.Close()
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			n := seq.Add(1)
+			_, err := db.ExecContext(
+				ctx,
+				`INSERT INTO account (id, tenant_id, username, status, email, display_name, email_verified)
+				VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
+				fmt.Sprintf("sql-%d", n), testTenantID, fmt.Sprintf("sql-%d", n), account.StatusActive, "", "", false,
+			)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -2,32 +2,57 @@ package pg
 
 import (
 	"context"
-	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/jackc/pgx"
-	// pgx driver registers itself as being available to the database/sql package.
-	pgxstdlib "github.com/jackc/pgx/stdlib"
+	"github.com/go-kit/log"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	account "github.com/marselester/ddd-err"
 )
 
 // Client represents a client to the underlying PostgreSQL data store.
 type Client struct {
-	User *UserStorage
+	User              *UserStorage
+	Group             *GroupStorage
+	Audit             *AuditStorage
+	EmailVerification *EmailVerificationStorage
 
 	config Config
-	db     *sql.DB
+	pool   *pgxpool.Pool
+
+	replicas []*replica
+	// next is a counter to pick replicas in round-robin fashion.
+	next atomic.Uint64
+	// stop signals the replica health checks to stop.
+	stop chan struct{}
+	wg   sync.WaitGroup
 }
 
 // NewClient returns a new Postgres client.
-// By default there are 5 max simultaneous Postgres connections.
+// By default there are 5 max simultaneous Postgres connections unless the DSN sets pool_max_conns,
+// transactions have the database's default isolation level and
+// are retried 3 times after a serialization failure or a deadlock,
+// replicas are health checked every 5 seconds.
+// See Config for how the connection settings are resolved.
 func NewClient(options ...ConfigOption) *Client {
 	c := Client{
 		config: Config{
-			host:           "localhost",
-			port:           5432,
-			maxConnections: 5,
+			txMaxRetries:   3,
+			txRetryBackoff: 10 * time.Millisecond,
+			logger:         log.NewNopLogger(),
+
+			replicaCheckInterval: 5 * time.Second,
 		},
 	}
 	c.User = &UserStorage{client: &c}
+	c.Group = &GroupStorage{client: &c}
+	c.Audit = &AuditStorage{client: &c}
+	c.EmailVerification = &EmailVerificationStorage{client: &c}
 
 	for _, opt := range options {
 		opt(&c.config)
@@ -37,59 +62,175 @@ func NewClient(options ...ConfigOption) *Client {
 
 // Open connects to a PostgreSQL DB.
 func (c *Client) Open() error {
-	connPoolConfig := pgx.ConnPoolConfig{
-		ConnConfig: pgx.ConnConfig{
-			Host:     c.config.host,
-			Port:     c.config.port,
-			Database: c.config.database,
-			User:     c.config.user,
-			Password: c.config.password,
-		},
-		MaxConnections: c.config.maxConnections,
+	poolConfig, err := c.config.poolConfig()
+	if err != nil {
+		return err
 	}
-	pool, err := pgx.NewConnPool(connPoolConfig)
+
+	ctx := context.Background()
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return err
 	}
+	// The pool connects lazily, so the connection is checked right away.
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return err
+	}
+
+	c.pool = pool
 
-	c.db = pgxstdlib.OpenDBFromPool(pool)
+	if err = c.openReplicas(ctx); err != nil {
+		c.Close()
+		return err
+	}
 	return nil
 }
 
-// Close closes PostgreSQL connection.
+// Close closes PostgreSQL connections.
 func (c *Client) Close() error {
-	return c.db.Close()
+	if c.stop != nil {
+		close(c.stop)
+		c.wg.Wait()
+		c.stop = nil
+	}
+	for _, r := range c.replicas {
+		r.pool.Close()
+	}
+	c.replicas = nil
+
+	c.pool.Close()
+	return nil
 }
 
 // Transact executes a function where transaction atomicity on the database is guaranteed.
 // If the function is successfully completed, the changes are committed to the database.
 // If there is an error, the changes are rolled back.
 // The solution is borrowed from https://stackoverflow.com/questions/16184238/database-sql-tx-detecting-commit-or-rollback.
-func (c *Client) Transact(ctx context.Context, atomic func(*sql.Tx) error) (err error) {
-	tx, err := c.db.BeginTx(ctx, nil)
+//
+// The transaction is carried by the context passed to the function, so
+// the storages of the client use it when called with that context.
+// A nested call creates a savepoint which is rolled back if the nested function fails,
+// leaving the outer transaction intact. The options of a nested call are ignored.
+//
+// The transaction is retried from scratch when Postgres reports a serialization failure or a deadlock,
+// so the function should have no side effects besides the database changes.
+// When the retries are exhausted, EConcurrentUpdate error is returned.
+func (c *Client) Transact(ctx context.Context, atomic func(ctx context.Context) error, options ...TxOption) error {
+	if t, ok := ctx.Value(txKey{}).(*txn); ok && t.client == c {
+		return t.savepoint(ctx, atomic)
+	}
+
+	opts := txOptions{
+		isolation:  c.config.txIsolation,
+		maxRetries: c.config.txMaxRetries,
+	}
+	for _, opt := range options {
+		opt(&opts)
+	}
+
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = c.transact(ctx, &opts, atomic); !retryable(err) {
+			return err
+		}
+		if attempt == opts.maxRetries {
+			return account.Error{
+				Code:    account.EConcurrentUpdate,
+				Message: "The data was changed by a concurrent request. Please try again.",
+				Inner:   fmt.Errorf("pg: transaction failed after %d retries: %w", attempt, err),
+			}
+		}
+
+		c.config.logger.Log("msg", "transaction retry", "attempt", attempt+1, "err", err)
+		if err = sleep(ctx, backoff(c.config.txRetryBackoff, attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// transact executes a function within a single transaction attempt.
+func (c *Client) transact(ctx context.Context, opts *txOptions, atomic func(ctx context.Context) error) (err error) {
+	tx, err := c.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:       opts.isolation,
+		AccessMode:     opts.accessMode,
+		DeferrableMode: opts.deferrableMode,
+	})
 	if err != nil {
 		return
 	}
 	defer func() {
 		// Catch panics to ensure a Rollback happens right away.
 		// Under normal circumstances a panic should not occur.
-		// If we did not handle panics, the transaction would be rolled back eventually.
-		// A non-committed transaction gets rolled back by the database when the client disconnects
-		// or when the transaction gets garbage collected.
+		// If we did not handle panics, the connection would be returned to the pool
+		// only after the transaction gets rolled back by the database when the client disconnects.
 		// It's better to resolve the issue as quickly as possible.
 		if p := recover(); p != nil {
-			tx.Rollback()
+			tx.Rollback(ctx)
 			panic(p)
 		}
 		// err is not nil; don't change it.
 		if err != nil {
-			tx.Rollback()
+			tx.Rollback(ctx)
 			return
 		}
 		// err is nil; if Commit returns error, update err.
-		err = tx.Commit()
+		err = tx.Commit(ctx)
+	}()
+
+	t := txn{client: c, tx: tx}
+	err = atomic(context.WithValue(ctx, txKey{}, &t))
+	return err
+}
+
+// txKey is the context key of a transaction in progress.
+type txKey struct{}
+
+// txn is a transaction in progress started by the client.
+type txn struct {
+	client *Client
+	tx     pgx.Tx
+}
+
+// savepoint executes a function within a savepoint of the transaction.
+// The changes made by the function are rolled back if it fails or panics.
+func (t *txn) savepoint(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
+	// A nested pgx transaction is a savepoint on the same connection,
+	// so the queries made with the outer transaction belong to the savepoint.
+	sp, err := t.tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			sp.Rollback(ctx)
+			panic(p)
+		}
+		if err != nil {
+			sp.Rollback(ctx)
+			return
+		}
+		err = sp.Commit(ctx)
 	}()
 
-	err = atomic(tx)
+	err = atomic(ctx)
 	return err
 }
+
+// querier is implemented by both pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// querier returns the transaction carried by the context or the pool otherwise.
+// The second return value reports whether the query runs within a transaction,
+// e.g., to lock the selected rows.
+func (c *Client) querier(ctx context.Context) (querier, bool) {
+	if t, ok := ctx.Value(txKey{}).(*txn); ok && t.client == c {
+		return t.tx, true
+	}
+	return c.pool, false
+}
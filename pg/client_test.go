@@ -1,18 +1,20 @@
 package pg
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"testing"
 
-	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/v5"
 )
 
 // client is a test wrapper for UserStorage implementation.
 type client struct {
 	// connConfig contains Postgres connection settings populated from the env.
-	connConfig pgx.ConnConfig
-	// conn is a connection to a test db to create schema.
-	conn *pgx.Conn
+	connConfig *pgx.ConnConfig
 	// sysConn is a connection to "postgres" db to drop/create a test db.
 	sysConn *pgx.Conn
 
@@ -22,98 +24,108 @@ type client struct {
 // newClient returns configured test client.
 // It parses Postgres connection settings for a test db.
 func newClient() (*client, error) {
-	config, err := parsePgEnv()
+	options, err := parsePgEnv()
 	if err != nil {
 		return nil, err
 	}
 
 	c := client{
-		connConfig: config,
-
-		storageClient: NewClient(
-			WithHost(config.Host),
-			WithPort(config.Port),
-			WithDatabase(config.Database),
-			WithUser(config.User),
-			WithPassword(config.Password),
-		),
+		storageClient: NewClient(options...),
 	}
+	poolConfig, err := c.storageClient.config.poolConfig()
+	if err != nil {
+		return nil, err
+	}
+	c.connConfig = poolConfig.ConnConfig
 	return &c, nil
 }
 
-// open creates the test database and opens a test client.
+// open creates the test database, opens a test client and migrates the schema.
 func (c *client) open() error {
 	var err error
+	ctx := context.Background()
 
-	config := c.connConfig
+	config := c.connConfig.Copy()
 	config.Database = "postgres"
-	if c.sysConn, err = pgx.Connect(config); err != nil {
+	if c.sysConn, err = pgx.ConnectConfig(ctx, config); err != nil {
 		return err
 	}
-	if _, err = c.sysConn.Exec("DROP DATABASE IF EXISTS " + c.connConfig.Database); err != nil {
+	if _, err = c.sysConn.Exec(ctx, "DROP DATABASE IF EXISTS "+c.connConfig.Database); err != nil {
 		return err
 	}
-	if _, err = c.sysConn.Exec("CREATE DATABASE " + c.connConfig.Database); err != nil {
+	if _, err = c.sysConn.Exec(ctx, "CREATE DATABASE "+c.connConfig.Database); err != nil {
 		return err
 	}
 
-	if c.conn, err = pgx.Connect(c.connConfig); err != nil {
-		return err
-	}
-	if _, err = c.conn.Exec(Schema); err != nil {
+	if err = c.storageClient.Open(); err != nil {
 		return err
 	}
-
-	return c.storageClient.Open()
+	return c.storageClient.Migrate(ctx)
 }
 
 // close closes client and drops the test database.
 func (c *client) close() {
+	ctx := context.Background()
 	c.storageClient.Close()
-	c.conn.Close()
 
-	c.sysConn.Exec("DROP DATABASE IF EXISTS " + c.connConfig.Database)
-	c.sysConn.Close()
+	c.sysConn.Exec(ctx, "DROP DATABASE IF EXISTS "+c.connConfig.Database)
+	c.sysConn.Close(ctx)
 }
 
 /*
-parsePgEnv parses the environment into Postgres connection config.
+parsePgEnv parses the environment into Postgres connection options.
 The following variables are supported:
 
 	TEST_PGHOST, localhost by default
-	TEST_PGPORT, 5432 by default
+	TEST_PGPORT
 	TEST_PGDATABASE
 	TEST_PGUSER
 	TEST_PGPASSWORD
 
+The settings which are not set are resolved the same way as in Client, e.g., from PGPORT,
+except the database which is dropped by the tests.
+Its name must be set in TEST_PGDATABASE and start with "test",
+so the tests never drop the database which PGDATABASE points to.
 */
-func parsePgEnv() (pgx.ConnConfig, error) {
-	config := pgx.ConnConfig{
-		Host:     os.Getenv("TEST_PGHOST"),
-		Database: os.Getenv("TEST_PGDATABASE"),
-		User:     os.Getenv("TEST_PGUSER"),
-		Password: os.Getenv("TEST_PGPASSWORD"),
+func parsePgEnv() ([]ConfigOption, error) {
+	db := os.Getenv("TEST_PGDATABASE")
+	if !strings.HasPrefix(db, "test") {
+		return nil, fmt.Errorf("TEST_PGDATABASE %q must start with test", db)
+	}
+	options := []ConfigOption{
+		WithHost("localhost"),
+		WithDatabase(db),
+		WithUser(os.Getenv("TEST_PGUSER")),
+		WithPassword(os.Getenv("TEST_PGPASSWORD")),
+	}
+	if h := os.Getenv("TEST_PGHOST"); h != "" {
+		options = append(options, WithHost(h))
 	}
 
 	if p := os.Getenv("TEST_PGPORT"); p != "" {
 		port, err := strconv.Atoi(p)
 		if err != nil {
-			return config, err
+			return nil, err
 		}
-		config.Port = uint16(port)
+		options = append(options, WithPort(uint16(port)))
 	}
 
-	return config, nil
+	return options, nil
 }
 
-// mustOpenClient creates and opens a test client or panics.
-func mustOpenClient() *client {
+// mustOpenClient creates and opens a test client or fails the test.
+// The test is skipped when TEST_PGDATABASE is not set.
+func mustOpenClient(tb testing.TB) *client {
+	tb.Helper()
+	if os.Getenv("TEST_PGDATABASE") == "" {
+		tb.Skip("TEST_PGDATABASE is not set")
+	}
 	c, err := newClient()
 	if err != nil {
-		panic(err)
+		tb.Fatal(err)
 	}
 	if err := c.open(); err != nil {
-		panic(err)
+		tb.Fatal(err)
 	}
 	return c
 }
@@ -0,0 +1,156 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// GroupStorage represents a Postgres storage of customer groups.
+type GroupStorage struct {
+	client *Client
+}
+
+// FindGroupByID returns a group by ID or ENotFound error if group does not exist in the tenant.
+// Within a transaction the group row is locked until the transaction ends,
+// otherwise the group might be read from a replica (see Client.reader).
+func (s *GroupStorage) FindGroupByID(ctx context.Context, id string) (*account.Group, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := "SELECT id, name FROM customer_group WHERE tenant_id = $1 AND id = $2"
+	q, inTx := s.client.reader(ctx)
+	if inTx {
+		query += " FOR UPDATE"
+	}
+	row := q.QueryRow(ctx, query, tenantID, id)
+
+	g := account.Group{}
+	err = row.Scan(&g.ID, &g.Name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "Group not found.",
+			Inner:   err,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GroupStorage.FindGroupByID: %w", err)
+	}
+	return &g, nil
+}
+
+// CreateGroup creates a new group in the tenant.
+// It returns EConflict error if the group name is already in use.
+func (s *GroupStorage) CreateGroup(ctx context.Context, g *account.Group) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	q, _ := s.client.querier(ctx)
+	_, err = q.Exec(
+		ctx,
+		"INSERT INTO customer_group (id, tenant_id, name) VALUES ($1, $2, $3)",
+		g.ID, tenantID, g.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("GroupStorage.CreateGroup: %w", conflictError(err))
+	}
+	return nil
+}
+
+// UpdateGroup updates group details.
+// It returns ENotFound error if group does not exist in the tenant or
+// EConflict error if the group name is already in use.
+func (s *GroupStorage) UpdateGroup(ctx context.Context, g *account.Group) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	q, _ := s.client.querier(ctx)
+	tag, err := q.Exec(
+		ctx,
+		"UPDATE customer_group SET name=$3 WHERE tenant_id=$1 AND id=$2",
+		tenantID, g.ID, g.Name,
+	)
+	if err != nil {
+		return fmt.Errorf("GroupStorage.UpdateGroup: %w", conflictError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return account.Error{
+			Code:    account.ENotFound,
+			Message: "Group not found.",
+		}
+	}
+	return nil
+}
+
+// DeleteGroup deletes a group by ID or returns ENotFound error if group does not exist in the tenant.
+func (s *GroupStorage) DeleteGroup(ctx context.Context, id string) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	q, _ := s.client.querier(ctx)
+	tag, err := q.Exec(ctx, "DELETE FROM customer_group WHERE tenant_id=$1 AND id=$2", tenantID, id)
+	if err != nil {
+		return fmt.Errorf("GroupStorage.DeleteGroup: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return account.Error{
+			Code:    account.ENotFound,
+			Message: "Group not found.",
+		}
+	}
+	return nil
+}
+
+// ListGroups returns groups of the tenant ordered by name which come after f.After, at most f.Limit of them.
+// The groups might be read from a replica (see Client.reader).
+func (s *GroupStorage) ListGroups(ctx context.Context, f account.GroupFilter) ([]*account.Group, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q, _ := s.client.reader(ctx)
+	rows, err := q.Query(
+		ctx,
+		`SELECT id, name FROM customer_group
+		WHERE tenant_id = $1 AND name > $2
+		ORDER BY name
+		LIMIT $3`,
+		tenantID, f.After, f.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("GroupStorage.ListGroups: %w", err)
+	}
+	defer rows.Close()
+
+	var gg []*account.Group
+	for rows.Next() {
+		g := account.Group{}
+		if err = rows.Scan(&g.ID, &g.Name); err != nil {
+			return nil, fmt.Errorf("GroupStorage.ListGroups: %w", err)
+		}
+		gg = append(gg, &g)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("GroupStorage.ListGroups: %w", err)
+	}
+	return gg, nil
+}
+
+// Transact relies on Client to implement a Storage interface to keep the Postgres client private.
+func (s *GroupStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
+	return s.client.Transact(ctx, atomic)
+}
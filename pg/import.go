@@ -0,0 +1,157 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// ImportUsers creates users in the tenant within a db transaction.
+// The users are copied into a temporary staging table with COPY protocol,
+// so conflicts with existing users and within the import are found by a single query
+// before the users are moved into the account table.
+// It returns *account.ImportError listing EConflict errors if any username or email is already in use and
+// EUsernameReserved errors if any username was released after reservedSince.
+func (s *UserStorage) ImportUsers(ctx context.Context, users []*account.User, reservedSince time.Time) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	return s.client.Transact(ctx, func(ctx context.Context) error {
+		q, _ := s.client.querier(ctx)
+
+		// The staging table is dropped explicitly because
+		// the import might be a part of a longer transaction.
+		_, err := q.Exec(ctx, `CREATE TEMP TABLE account_import (
+			ordinal integer NOT NULL,
+			id varchar(36) NOT NULL,
+			username varchar(40) NOT NULL,
+			status varchar(10) NOT NULL,
+			email varchar(254),
+			display_name varchar(64) NOT NULL,
+			email_verified boolean NOT NULL
+		) ON COMMIT DROP`)
+		if err != nil {
+			return fmt.Errorf("UserStorage.ImportUsers: staging table: %w", err)
+		}
+
+		_, err = q.CopyFrom(
+			ctx,
+			pgx.Identifier{"account_import"},
+			[]string{"ordinal", "id", "username", "status", "email", "display_name", "email_verified"},
+			pgx.CopyFromSlice(len(users), func(i int) ([]any, error) {
+				u := users[i]
+				var email *string
+				if u.Email != "" {
+					email = &u.Email
+				}
+				return []any{i + 1, u.ID, u.Username, u.Status, email, u.DisplayName, u.EmailVerified}, nil
+			}),
+		)
+		if err != nil {
+			return fmt.Errorf("UserStorage.ImportUsers: copy: %w", err)
+		}
+
+		if err = importConflicts(ctx, q, tenantID, reservedSince); err != nil {
+			return err
+		}
+
+		_, err = q.Exec(
+			ctx,
+			`INSERT INTO account (id, tenant_id, username, status, email, display_name, email_verified)
+			SELECT id, $1, username, status, email, display_name, email_verified FROM account_import ORDER BY ordinal`,
+			tenantID,
+		)
+		if err != nil {
+			return fmt.Errorf("UserStorage.ImportUsers: %w", conflictError(err))
+		}
+
+		if _, err = q.Exec(ctx, "DROP TABLE account_import"); err != nil {
+			return fmt.Errorf("UserStorage.ImportUsers: staging table: %w", err)
+		}
+		return nil
+	})
+}
+
+// importConflicts returns *account.ImportError if the staged users have a username or email
+// which is already in use in the tenant or which belongs to a preceding staged user,
+// or a username which was released after reservedSince.
+func importConflicts(ctx context.Context, q querier, tenantID string, reservedSince time.Time) error {
+	const query = `SELECT ordinal, username_taken, username_reserved, email_taken FROM (
+		SELECT ordinal,
+			repeated_username OR EXISTS (
+				SELECT 1 FROM account a WHERE a.tenant_id = $1 AND a.username = i.username
+			) AS username_taken,
+			EXISTS (
+				SELECT 1 FROM username_history h
+				WHERE h.tenant_id = $1 AND h.username = i.username AND h.changed_at > $2
+			) AS username_reserved,
+			email IS NOT NULL AND (repeated_email OR EXISTS (
+				SELECT 1 FROM account a WHERE a.tenant_id = $1 AND lower(a.email) = lower(i.email)
+			)) AS email_taken
+		FROM (
+			SELECT ordinal, username, email,
+				row_number() OVER (PARTITION BY username ORDER BY ordinal) > 1 AS repeated_username,
+				row_number() OVER (PARTITION BY lower(email) ORDER BY ordinal) > 1 AS repeated_email
+			FROM account_import
+		) i
+	) c
+	WHERE username_taken OR username_reserved OR email_taken
+	ORDER BY ordinal`
+
+	rows, err := q.Query(ctx, query, tenantID, reservedSince)
+	if err != nil {
+		return fmt.Errorf("UserStorage.ImportUsers: conflicts: %w", err)
+	}
+	defer rows.Close()
+
+	var importErr account.ImportError
+	for rows.Next() {
+		var (
+			row                                         int
+			usernameTaken, usernameReserved, emailTaken bool
+		)
+		if err = rows.Scan(&row, &usernameTaken, &usernameReserved, &emailTaken); err != nil {
+			return fmt.Errorf("UserStorage.ImportUsers: conflicts: %w", err)
+		}
+
+		var e account.Error
+		switch {
+		case usernameTaken:
+			e = account.Error{
+				Code:    account.EConflict,
+				Message: "Username is already in use. Please choose a different username.",
+				Field:   "username",
+			}
+		case usernameReserved:
+			e = account.Error{
+				Code:    account.EUsernameReserved,
+				Message: "Username was recently released and is reserved. Please choose a different username.",
+				Field:   "username",
+			}
+		default:
+			e = account.Error{
+				Code:    account.EConflict,
+				Message: "Email is already in use. Please choose a different email.",
+				Field:   "email",
+			}
+		}
+		importErr.Rows = append(importErr.Rows, account.ImportRowError{Row: row, Err: e})
+	}
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("UserStorage.ImportUsers: conflicts: %w", err)
+	}
+
+	if len(importErr.Rows) > 0 {
+		return &importErr
+	}
+	return nil
+}
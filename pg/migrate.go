@@ -0,0 +1,320 @@
+package pg
+
+import (
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// migrationFS contains SQL migrations named as VERSION_NAME.up.sql and VERSION_NAME.down.sql,
+// e.g., 0001_create_account.up.sql. Versions start from 1 and have no gaps.
+//
+//go:embed migrations/*.sql
+var migrationFS embed.FS
+
+// migrations are ordered by version, i.e., migrations[i].version == i+1.
+var migrations = mustLoadMigrations(migrationFS, "migrations")
+
+// migrationLockID is a key of the advisory lock which prevents concurrent migrations.
+// It's an arbitrary number which shouldn't clash with other advisory locks in the db.
+const migrationLockID = 7102354918
+
+// ErrSchemaOutdated is returned by CheckSchema when the db schema needs to be migrated.
+var ErrSchemaOutdated = errors.New("pg: db schema is outdated")
+
+// migration is a versioned change of db schema.
+type migration struct {
+	version int
+	name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus describes whether a migration was applied to the db.
+type MigrationStatus struct {
+	Version int
+	Name    string
+	// AppliedAt is zero if the migration wasn't applied.
+	AppliedAt time.Time
+}
+
+// LatestSchemaVersion returns the schema version the package works with.
+func LatestSchemaVersion() int {
+	return len(migrations)
+}
+
+func mustLoadMigrations(fsys fs.FS, dir string) []migration {
+	mm, err := loadMigrations(fsys, dir)
+	if err != nil {
+		panic(err)
+	}
+	return mm
+}
+
+// loadMigrations reads migrations from the dir and checks that every version has up and down SQL.
+func loadMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	files, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*migration)
+	for _, f := range files {
+		base, direction, ok := cutMigrationName(f.Name())
+		if !ok {
+			return nil, fmt.Errorf("pg: invalid migration file name %q", f.Name())
+		}
+		v, name, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(v)
+		if err != nil || version < 1 || name == "" {
+			return nil, fmt.Errorf("pg: invalid migration file name %q", f.Name())
+		}
+
+		b, err := fs.ReadFile(fsys, path.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &migration{version: version, name: name}
+			byVersion[version] = m
+		}
+		if m.name != name {
+			return nil, fmt.Errorf("pg: migration %d has different names %q and %q", version, m.name, name)
+		}
+		if direction == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+
+	mm := make([]migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		mm = append(mm, *m)
+	}
+	sort.Slice(mm, func(i, j int) bool {
+		return mm[i].version < mm[j].version
+	})
+	for i, m := range mm {
+		if m.version != i+1 {
+			return nil, fmt.Errorf("pg: migration %d is missing", i+1)
+		}
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("pg: migration %d must have both up and down SQL", m.version)
+		}
+	}
+	return mm, nil
+}
+
+// cutMigrationName splits a file name such as 0001_create_account.up.sql
+// into 0001_create_account and up.
+func cutMigrationName(filename string) (base, direction string, ok bool) {
+	if base, ok = strings.CutSuffix(filename, ".up.sql"); ok {
+		return base, "up", true
+	}
+	if base, ok = strings.CutSuffix(filename, ".down.sql"); ok {
+		return base, "down", true
+	}
+	return "", "", false
+}
+
+// Migrate applies all the migrations which weren't applied yet.
+func (c *Client) Migrate(ctx context.Context) error {
+	return c.MigrateTo(ctx, LatestSchemaVersion())
+}
+
+// MigrateDown reverts the last applied migration.
+func (c *Client) MigrateDown(ctx context.Context) error {
+	return c.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if current == 0 {
+			return nil
+		}
+		return migrateTo(ctx, conn, current, current-1, c.config.legacyTenantID)
+	})
+}
+
+// MigrateTo applies or reverts migrations to get the schema of the given version.
+// Version 0 means that all the migrations are reverted.
+// Every migration runs in its own transaction.
+// Concurrent migrations are serialized by a Postgres advisory lock.
+func (c *Client) MigrateTo(ctx context.Context, version int) error {
+	if version < 0 || version > LatestSchemaVersion() {
+		return fmt.Errorf("pg: unknown schema version %d", version)
+	}
+
+	return c.withMigrationLock(ctx, func(conn *pgxpool.Conn) error {
+		current, err := schemaVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+		return migrateTo(ctx, conn, current, version, c.config.legacyTenantID)
+	})
+}
+
+// SchemaVersion returns the version of the last applied migration or 0 if there is none.
+func (c *Client) SchemaVersion(ctx context.Context) (int, error) {
+	var exists bool
+	err := c.pool.QueryRow(ctx, "SELECT to_regclass('schema_migration') IS NOT NULL").Scan(&exists)
+	if err != nil {
+		return 0, fmt.Errorf("pg: schema version: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+
+	var version int
+	err = c.pool.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migration").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("pg: schema version: %w", err)
+	}
+	return version, nil
+}
+
+// CheckSchema returns ErrSchemaOutdated error if the db schema is older than LatestSchemaVersion.
+// A newer schema is accepted, e.g., when the migrations were applied by a newer release during a rolling deploy.
+func (c *Client) CheckSchema(ctx context.Context) error {
+	version, err := c.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < LatestSchemaVersion() {
+		return fmt.Errorf("%w: version %d, want %d", ErrSchemaOutdated, version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// MigrationStatus returns all the known migrations ordered by version
+// and tells which of them were applied.
+func (c *Client) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	ss := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		ss[i] = MigrationStatus{
+			Version: m.version,
+			Name:    m.name,
+		}
+	}
+
+	version, err := c.SchemaVersion(ctx)
+	if err != nil || version == 0 {
+		return ss, err
+	}
+
+	rows, err := c.pool.Query(ctx, "SELECT version, applied_at FROM schema_migration")
+	if err != nil {
+		return nil, fmt.Errorf("pg: migration status: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			v         int
+			appliedAt time.Time
+		)
+		if err = rows.Scan(&v, &appliedAt); err != nil {
+			return nil, fmt.Errorf("pg: migration status: %w", err)
+		}
+		if v >= 1 && v <= len(ss) {
+			ss[v-1].AppliedAt = appliedAt
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("pg: migration status: %w", err)
+	}
+	return ss, nil
+}
+
+// withMigrationLock executes a function on a connection holding the migration advisory lock.
+// The tracking table is created under the lock if it doesn't exist.
+func (c *Client) withMigrationLock(ctx context.Context, fn func(conn *pgxpool.Conn) error) error {
+	conn, err := c.pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("pg: migrate: %w", err)
+	}
+	defer conn.Release()
+
+	if _, err = conn.Exec(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("pg: migrate: lock: %w", err)
+	}
+	defer func() {
+		// The lock is released when the connection is closed
+		// in case the context is already canceled.
+		if _, err := conn.Exec(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID); err != nil {
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	_, err = conn.Exec(ctx, `CREATE TABLE IF NOT EXISTS schema_migration (
+		version integer PRIMARY KEY,
+		name varchar(255) NOT NULL,
+		applied_at timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return fmt.Errorf("pg: migrate: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// schemaVersion returns the version of the last applied migration.
+// It must be called after the tracking table was created.
+func schemaVersion(ctx context.Context, conn *pgxpool.Conn) (int, error) {
+	var version int
+	err := conn.QueryRow(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migration").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("pg: migrate: %w", err)
+	}
+	if version > LatestSchemaVersion() {
+		return 0, fmt.Errorf("pg: migrate: db schema version %d is newer than %d", version, LatestSchemaVersion())
+	}
+	return version, nil
+}
+
+// migrateTo applies or reverts migrations one by one from the current to the target version.
+// The legacy tenant ID is available to the migrations as account.legacy_tenant_id setting.
+func migrateTo(ctx context.Context, conn *pgxpool.Conn, current, target int, legacyTenantID string) error {
+	for v := current + 1; v <= target; v++ {
+		m := migrations[v-1]
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, "SELECT set_config('account.legacy_tenant_id', $1, true)", legacyTenantID); err != nil {
+				return err
+			}
+			if _, err := tx.Exec(ctx, m.up); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "INSERT INTO schema_migration (version, name) VALUES ($1, $2)", m.version, m.name)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("pg: migrate up to %d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	for v := current; v > target; v-- {
+		m := migrations[v-1]
+		err := pgx.BeginFunc(ctx, conn, func(tx pgx.Tx) error {
+			if _, err := tx.Exec(ctx, m.down); err != nil {
+				return err
+			}
+			_, err := tx.Exec(ctx, "DELETE FROM schema_migration WHERE version = $1", m.version)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("pg: migrate down from %d_%s: %w", m.version, m.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	account "github.com/marselester/ddd-err"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	if len(migrations) == 0 {
+		t.Fatal("no embedded migrations")
+	}
+
+	file := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	tt := map[string]struct {
+		fsys    fstest.MapFS
+		wantErr bool
+	}{
+		"valid": {
+			fsys: fstest.MapFS{
+				"m/0001_a.up.sql":   file,
+				"m/0001_a.down.sql": file,
+				"m/0002_b.up.sql":   file,
+				"m/0002_b.down.sql": file,
+			},
+		},
+		"missing down": {
+			fsys: fstest.MapFS{
+				"m/0001_a.up.sql": file,
+			},
+			wantErr: true,
+		},
+		"gap": {
+			fsys: fstest.MapFS{
+				"m/0001_a.up.sql":   file,
+				"m/0001_a.down.sql": file,
+				"m/0003_c.up.sql":   file,
+				"m/0003_c.down.sql": file,
+			},
+			wantErr: true,
+		},
+		"name mismatch": {
+			fsys: fstest.MapFS{
+				"m/0001_a.up.sql":   file,
+				"m/0001_b.down.sql": file,
+			},
+			wantErr: true,
+		},
+		"invalid name": {
+			fsys: fstest.MapFS{
+				"m/init.sql": file,
+			},
+			wantErr: true,
+		},
+	}
+	for name, tc := range tt {
+		t.Run(name, func(t *testing.T) {
+			mm, err := loadMigrations(tc.fsys, "m")
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("loadMigrations() expected error, got %d migrations", len(mm))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadMigrations() failed: %v", err)
+			}
+			if len(mm) != 2 || mm[0].name != "a" || mm[1].name != "b" {
+				t.Errorf("loadMigrations() got %+v", mm)
+			}
+		})
+	}
+}
+
+func TestClient_Migrate(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := context.Background()
+	if err := c.storageClient.CheckSchema(ctx); err != nil {
+		t.Fatalf("CheckSchema() failed after migration: %v", err)
+	}
+
+	if err := c.storageClient.MigrateDown(ctx); err != nil {
+		t.Fatalf("MigrateDown() failed: %v", err)
+	}
+	if err := c.storageClient.CheckSchema(ctx); !errors.Is(err, ErrSchemaOutdated) {
+		t.Errorf("CheckSchema() got %v, want %v", err, ErrSchemaOutdated)
+	}
+
+	if err := c.storageClient.MigrateTo(ctx, 0); err != nil {
+		t.Fatalf("MigrateTo(0) failed: %v", err)
+	}
+	var exists bool
+	c.storageClient.pool.QueryRow(ctx, "SELECT to_regclass('account') IS NOT NULL").Scan(&exists)
+	if exists {
+		t.Errorf("MigrateTo(0) didn't drop account table")
+	}
+	ss, err := c.storageClient.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus() failed: %v", err)
+	}
+	for _, s := range ss {
+		if !s.AppliedAt.IsZero() {
+			t.Errorf("MigrationStatus() got %d_%s applied at %v", s.Version, s.Name, s.AppliedAt)
+		}
+	}
+
+	// Concurrent runners wait for each other and apply every migration once.
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.storageClient.Migrate(ctx); err != nil {
+				t.Errorf("Migrate() failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	version, err := c.storageClient.SchemaVersion(ctx)
+	if err != nil {
+		t.Fatalf("SchemaVersion() failed: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("SchemaVersion() = %d, want %d", version, LatestSchemaVersion())
+	}
+	if ss, err = c.storageClient.MigrationStatus(ctx); err != nil {
+		t.Fatalf("MigrationStatus() failed: %v", err)
+	}
+	for _, s := range ss {
+		if s.AppliedAt.IsZero() {
+			t.Errorf("MigrationStatus() got %d_%s not applied", s.Version, s.Name)
+		}
+	}
+
+	if err = c.storageClient.MigrateTo(ctx, LatestSchemaVersion()+1); err == nil {
+		t.Errorf("MigrateTo() expected error for unknown version")
+	}
+}
+
+func TestClient_Migrate_legacy(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := context.Background()
+	if err := c.storageClient.MigrateTo(ctx, 0); err != nil {
+		t.Fatalf("MigrateTo(0) failed: %v", err)
+	}
+	// The db was created by the former pg.Schema.
+	_, err := c.storageClient.pool.Exec(ctx, `
+CREATE TABLE account (
+    id varchar(27),
+    username varchar(40) NOT NULL,
+    PRIMARY KEY(id),
+    UNIQUE(username)
+);
+INSERT INTO account (id, username) VALUES ('123', 'alice');`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = c.storageClient.Migrate(ctx); err == nil {
+		t.Fatalf("Migrate() expected error without legacy tenant")
+	}
+	if v, _ := c.storageClient.SchemaVersion(ctx); v != 1 {
+		t.Errorf("SchemaVersion() = %d, want 1", v)
+	}
+
+	c.storageClient.config.legacyTenantID = testTenantID
+	if err = c.storageClient.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate() failed: %v", err)
+	}
+	u, err := c.storageClient.User.FindUserByID(account.ContextWithTenant(ctx, testTenantID), "123")
+	if err != nil {
+		t.Fatalf("FindUserByID() failed: %v", err)
+	}
+	if u.Username != "alice" || u.Status != account.StatusActive {
+		t.Errorf("FindUserByID() got %+v", u)
+	}
+}
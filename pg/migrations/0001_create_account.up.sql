@@ -1,11 +1,7 @@
-package pg
-
-// Schema is db schema which must be created before working with UserService.
-const Schema = `
+-- The table is the former pg.Schema, so the db created by it is adopted as is.
 CREATE TABLE IF NOT EXISTS account (
     id varchar(27),
     username varchar(40) NOT NULL,
     PRIMARY KEY(id),
     UNIQUE(username)
 );
-`
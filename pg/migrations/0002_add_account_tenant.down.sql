@@ -0,0 +1,11 @@
+DROP INDEX account_email_key;
+ALTER TABLE account DROP CONSTRAINT account_tenant_id_username_key;
+-- The usernames must be unique across the tenants to restore the constraint.
+ALTER TABLE account ADD CONSTRAINT account_username_key UNIQUE (username);
+-- The id is kept varchar(36) because the IDs issued since then don't fit into varchar(27).
+ALTER TABLE account
+    DROP COLUMN tenant_id,
+    DROP COLUMN status,
+    DROP COLUMN email,
+    DROP COLUMN display_name,
+    DROP COLUMN email_verified;
@@ -0,0 +1,23 @@
+ALTER TABLE account
+    ALTER COLUMN id TYPE varchar(36),
+    ADD COLUMN tenant_id varchar(36),
+    ADD COLUMN status varchar(10) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'suspended', 'locked', 'deleted')),
+    ADD COLUMN email varchar(254),
+    ADD COLUMN display_name varchar(64) NOT NULL DEFAULT '',
+    ADD COLUMN email_verified boolean NOT NULL DEFAULT false;
+
+-- The users created before tenants were introduced are assigned to the tenant
+-- set by pg.WithLegacyTenantID, e.g., migrate -legacy-tenant flag.
+UPDATE account SET tenant_id = NULLIF(current_setting('account.legacy_tenant_id', true), '');
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM account WHERE tenant_id IS NULL) THEN
+        RAISE EXCEPTION 'account has users without a tenant, set the legacy tenant ID to assign them';
+    END IF;
+END $$;
+ALTER TABLE account ALTER COLUMN tenant_id SET NOT NULL;
+
+ALTER TABLE account DROP CONSTRAINT account_username_key;
+ALTER TABLE account ADD CONSTRAINT account_tenant_id_username_key UNIQUE (tenant_id, username);
+-- Emails are unique within a tenant regardless of their case.
+CREATE UNIQUE INDEX account_email_key ON account (tenant_id, lower(email));
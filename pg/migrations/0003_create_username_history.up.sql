@@ -0,0 +1,8 @@
+-- Former usernames are kept to find users who renamed and to reserve released usernames.
+CREATE TABLE username_history (
+    tenant_id varchar(36) NOT NULL,
+    user_id varchar(36) NOT NULL REFERENCES account (id),
+    username varchar(40) NOT NULL,
+    changed_at timestamptz NOT NULL
+);
+CREATE INDEX username_history_tenant_id_username_idx ON username_history (tenant_id, username, changed_at);
@@ -0,0 +1,9 @@
+CREATE TABLE email_verification (
+    token_hash varchar(64),
+    tenant_id varchar(36) NOT NULL,
+    user_id varchar(36) NOT NULL REFERENCES account (id),
+    email varchar(254) NOT NULL,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz,
+    PRIMARY KEY(token_hash)
+);
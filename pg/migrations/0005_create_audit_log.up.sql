@@ -0,0 +1,17 @@
+CREATE TABLE audit_log (
+    id varchar(36),
+    tenant_id varchar(36) NOT NULL,
+    created_at timestamptz NOT NULL,
+    actor varchar(64) NOT NULL,
+    action varchar(40) NOT NULL,
+    target_id varchar(36) NOT NULL,
+    changes jsonb NOT NULL,
+    request_id varchar(64) NOT NULL,
+    outcome varchar(10) NOT NULL,
+    error_code varchar(40) NOT NULL,
+    PRIMARY KEY(id)
+);
+CREATE INDEX audit_log_tenant_id_target_id_created_at_idx ON audit_log (tenant_id, target_id, created_at);
+-- The audit trail is append-only.
+CREATE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
@@ -0,0 +1,2 @@
+-- The actor sent by API client is not verified, so it's kept apart from the authenticated actor.
+ALTER TABLE audit_log ADD COLUMN claimed_actor varchar(64) NOT NULL DEFAULT '';
@@ -0,0 +1,8 @@
+-- The table isn't named "group" since it's a reserved word.
+CREATE TABLE customer_group (
+    id varchar(36),
+    tenant_id varchar(36) NOT NULL,
+    name varchar(64) NOT NULL,
+    PRIMARY KEY(id),
+    UNIQUE(tenant_id, name)
+);
@@ -0,0 +1,210 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// replica is a read replica of the primary database.
+type replica struct {
+	pool *pgxpool.Pool
+	// addr identifies the replica in logs.
+	addr    string
+	healthy atomic.Bool
+}
+
+// openReplicas creates the replica pools and starts their health checks.
+// A replica which is unavailable at the moment doesn't fail the client,
+// it starts serving reads once it passes a health check.
+func (c *Client) openReplicas(ctx context.Context) error {
+	if len(c.config.replicas) == 0 {
+		return nil
+	}
+
+	for _, dsn := range c.config.replicas {
+		poolConfig, err := c.config.replicaPoolConfig(dsn)
+		if err != nil {
+			return fmt.Errorf("pg: replica: %w", err)
+		}
+		pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+		if err != nil {
+			return fmt.Errorf("pg: replica: %w", err)
+		}
+
+		r := replica{
+			pool: pool,
+			addr: fmt.Sprintf("%s:%d", poolConfig.ConnConfig.Host, poolConfig.ConnConfig.Port),
+		}
+		c.replicas = append(c.replicas, &r)
+		// The replica is assumed healthy, so the first check logs only when it's down.
+		r.healthy.Store(true)
+		c.checkReplica(ctx, &r)
+	}
+
+	c.stop = make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.checkReplicas()
+	}()
+	return nil
+}
+
+// checkReplicas periodically checks health of the replicas until the client is closed.
+func (c *Client) checkReplicas() {
+	t := time.NewTicker(c.config.replicaCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-t.C:
+			for _, r := range c.replicas {
+				c.checkReplica(context.Background(), r)
+			}
+		}
+	}
+}
+
+// checkReplica pings the replica and marks it healthy if the ping succeeded.
+func (c *Client) checkReplica(ctx context.Context, r *replica) {
+	ctx, cancel := context.WithTimeout(ctx, c.config.replicaCheckInterval)
+	defer cancel()
+
+	err := r.pool.Ping(ctx)
+	healthy := err == nil
+	if r.healthy.Swap(healthy) != healthy {
+		if healthy {
+			c.config.logger.Log("msg", "replica is up", "addr", r.addr)
+		} else {
+			c.config.logger.Log("msg", "replica is down", "addr", r.addr, "err", err)
+		}
+	}
+}
+
+// reader returns a querier for a read-only query.
+// Reads within a transaction use the transaction, and reads which must observe the preceding writes
+// (see account.ContextWithReadYourWrites) use the primary,
+// otherwise the healthy replicas are picked in turn.
+// The primary is used when there are no healthy replicas, or when the picked replica can't be reached.
+// The second return value reports whether the query runs within a transaction.
+func (c *Client) reader(ctx context.Context) (rowQuerier, bool) {
+	q, inTx := c.querier(ctx)
+	if inTx || len(c.replicas) == 0 || account.ReadYourWritesFromContext(ctx) {
+		return q, inTx
+	}
+
+	n := uint64(len(c.replicas))
+	start := c.next.Add(1)
+	for i := uint64(0); i < n; i++ {
+		if r := c.replicas[(start+i)%n]; r.healthy.Load() {
+			return &replicaQuerier{client: c, replica: r}, false
+		}
+	}
+	return c.pool, false
+}
+
+// rowQuerier is a read-only subset of querier.
+type rowQuerier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// replicaQuerier runs queries on a replica and retries them on the primary
+// when the replica can't be reached (see isConnectionError).
+// The replica is marked unhealthy until it passes a health check.
+type replicaQuerier struct {
+	client  *Client
+	replica *replica
+}
+
+// Query runs the query on the replica, or on the primary if the replica connection failed.
+// The errors which occur while reading the rows are not retried.
+func (q *replicaQuerier) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	rows, err := q.replica.pool.Query(ctx, sql, args...)
+	if err == nil || !q.fallback(ctx, err) {
+		return rows, err
+	}
+	return q.client.pool.Query(ctx, sql, args...)
+}
+
+// QueryRow runs the query on the replica when the row is scanned,
+// or on the primary if the replica connection failed.
+func (q *replicaQuerier) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return &replicaRow{
+		querier: q,
+		ctx:     ctx,
+		sql:     sql,
+		args:    args,
+	}
+}
+
+// fallback reports whether a query which failed on the replica with err should be retried on the primary.
+// The replica is marked unhealthy if so.
+// A query is not retried when its context is done, because it would fail on the primary as well.
+func (q *replicaQuerier) fallback(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || !isConnectionError(err) {
+		return false
+	}
+	if q.replica.healthy.Swap(false) {
+		q.client.config.logger.Log("msg", "replica is down", "addr", q.replica.addr, "err", err)
+	}
+	return true
+}
+
+// replicaRow is a pgx.Row which is fetched from the primary if the replica connection failed.
+type replicaRow struct {
+	querier *replicaQuerier
+	ctx     context.Context
+	sql     string
+	args    []any
+}
+
+// Scan reads the row into dest.
+func (r *replicaRow) Scan(dest ...any) error {
+	err := r.querier.replica.pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+	if err == nil || !r.querier.fallback(r.ctx, err) {
+		return err
+	}
+	return r.querier.client.pool.QueryRow(r.ctx, r.sql, r.args...).Scan(dest...)
+}
+
+// Postgres error codes of a server which is unavailable,
+// see https://www.postgresql.org/docs/current/errcodes-appendix.html.
+const (
+	pgClassConnectionException = "08"
+	pgAdminShutdown            = "57P01"
+	pgCrashShutdown            = "57P02"
+	pgCannotConnectNow         = "57P03"
+)
+
+// isConnectionError reports whether err means the server couldn't be reached or
+// dropped the connection, e.g., it's down or shutting down.
+// Such a read-only query is safe to retry on another server.
+func isConnectionError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return strings.HasPrefix(pgErr.Code, pgClassConnectionException) ||
+			pgErr.Code == pgAdminShutdown ||
+			pgErr.Code == pgCrashShutdown ||
+			pgErr.Code == pgCannotConnectNow
+	}
+
+	var (
+		connectErr *pgconn.ConnectError
+		netErr     net.Error
+	)
+	return pgconn.SafeToRetry(err) || errors.As(err, &connectErr) || errors.As(err, &netErr)
+}
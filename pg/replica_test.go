@@ -0,0 +1,179 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	account "github.com/marselester/ddd-err"
+)
+
+func TestConfig_replicaPoolConfig(t *testing.T) {
+	c := NewClient(
+		WithHost("primary"),
+		WithPort(5433),
+		WithUser("alice"),
+		WithPassword("swordfish"),
+		WithDatabase("account"),
+		WithReplicas("host=replica1", "postgres://replica2:5434"),
+	)
+
+	tt := []struct {
+		dsn  string
+		host string
+		port uint16
+	}{
+		{"host=replica1", "replica1", 5432},
+		{"postgres://replica2:5434", "replica2", 5434},
+	}
+	for _, tc := range tt {
+		got, err := c.config.replicaPoolConfig(tc.dsn)
+		if err != nil {
+			t.Fatalf("replicaPoolConfig(%q) failed: %v", tc.dsn, err)
+		}
+		cc := got.ConnConfig
+		if cc.Host != tc.host || cc.Port != tc.port {
+			t.Errorf("replicaPoolConfig(%q) got %s:%d, want %s:%d", tc.dsn, cc.Host, cc.Port, tc.host, tc.port)
+		}
+		if cc.User != "alice" || cc.Password != "swordfish" || cc.Database != "account" {
+			t.Errorf("replicaPoolConfig(%q) didn't inherit the settings: %s/%s", tc.dsn, cc.User, cc.Database)
+		}
+	}
+}
+
+func TestClient_reader(t *testing.T) {
+	// The pools connect lazily, so no database is needed.
+	newPool := func(host string) *pgxpool.Pool {
+		cfg, err := NewClient(WithHost(host)).config.poolConfig()
+		if err != nil {
+			t.Fatalf("poolConfig() failed: %v", err)
+		}
+		pool, err := pgxpool.NewWithConfig(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("NewWithConfig() failed: %v", err)
+		}
+		t.Cleanup(pool.Close)
+		return pool
+	}
+
+	c := NewClient()
+	c.pool = newPool("primary")
+	for i := 0; i < 3; i++ {
+		r := replica{pool: newPool(fmt.Sprintf("replica%d", i))}
+		r.healthy.Store(i != 1)
+		c.replicas = append(c.replicas, &r)
+	}
+
+	ctx := context.Background()
+	got := make(map[*pgxpool.Pool]int)
+	for i := 0; i < 10; i++ {
+		q, inTx := c.reader(ctx)
+		if inTx {
+			t.Fatalf("reader() reported a transaction")
+		}
+		rq, ok := q.(*replicaQuerier)
+		if !ok {
+			t.Fatalf("reader() didn't pick a replica")
+		}
+		got[rq.replica.pool]++
+	}
+	if len(got) != 2 || got[c.replicas[0].pool] == 0 || got[c.replicas[2].pool] == 0 {
+		t.Errorf("reader() didn't spread reads across healthy replicas: %v", got)
+	}
+
+	if q, _ := c.reader(account.ContextWithReadYourWrites(ctx)); q != c.pool {
+		t.Errorf("reader() didn't pick primary to read your writes")
+	}
+
+	for _, r := range c.replicas {
+		r.healthy.Store(false)
+	}
+	if q, _ := c.reader(ctx); q != c.pool {
+		t.Errorf("reader() didn't fall back to primary")
+	}
+}
+
+func TestIsConnectionError(t *testing.T) {
+	tt := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{fmt.Errorf("boom"), false},
+		{pgx.ErrNoRows, false},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{fmt.Errorf("UserStorage.FindUserByID: %w", &pgconn.PgError{Code: pgAdminShutdown}), true},
+		{&pgconn.PgError{Code: pgCannotConnectNow}, true},
+		{&net.OpError{Op: "dial", Err: fmt.Errorf("connection refused")}, true},
+	}
+	for _, tc := range tt {
+		if got := isConnectionError(tc.err); got != tc.want {
+			t.Errorf("isConnectionError(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestClient_replicas(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	// The test database acts as a replica of itself.
+	cc := c.connConfig
+	replica := NewClient(
+		WithHost(cc.Host),
+		WithPort(cc.Port),
+		WithDatabase(cc.Database),
+		WithUser(cc.User),
+		WithPassword(cc.Password),
+		WithReplicas(fmt.Sprintf("host=%s port=%d", cc.Host, cc.Port), "host=replica.invalid"),
+	)
+	if err := replica.Open(); err != nil {
+		t.Fatalf("Open() failed: %v", err)
+	}
+	defer replica.Close()
+
+	if !replica.replicas[0].healthy.Load() || replica.replicas[1].healthy.Load() {
+		t.Errorf("Open() got replicas health %t and %t, want true and false",
+			replica.replicas[0].healthy.Load(), replica.replicas[1].healthy.Load())
+	}
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := replica.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := replica.User.FindUserByID(ctx, alice.ID); err != nil {
+			t.Errorf("FindUserByID() failed: %v", err)
+		}
+	}
+
+	// The replica went down after the last health check, so the reads are retried on the primary.
+	down, err := pgxpool.New(context.Background(), "host=127.0.0.1 port=1 connect_timeout=1")
+	if err != nil {
+		t.Fatalf("pgxpool.New() failed: %v", err)
+	}
+	defer down.Close()
+	replica.replicas[0].pool, replica.replicas[1].pool = down, down
+	replica.replicas[1].healthy.Store(true)
+	if _, err := replica.User.FindUserByID(ctx, alice.ID); err != nil {
+		t.Errorf("FindUserByID() failed: %v", err)
+	}
+	if _, err := replica.Audit.FindAuditEntries(ctx, account.AuditFilter{UserID: alice.ID, Until: time.Now(), Limit: 1}); err != nil {
+		t.Errorf("FindAuditEntries() failed: %v", err)
+	}
+	if replica.replicas[0].healthy.Load() || replica.replicas[1].healthy.Load() {
+		t.Errorf("unreachable replicas weren't marked unhealthy")
+	}
+}
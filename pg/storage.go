@@ -3,12 +3,11 @@ package pg
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 
-	// pgx driver registers itself as being available to the database/sql package.
-	_ "github.com/jackc/pgx/stdlib"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 
 	account "github.com/marselester/ddd-err"
 )
@@ -18,19 +17,26 @@ type UserStorage struct {
 	client *Client
 }
 
-// FindUserByID returns a user by ID or ENotFound error if user does not exist.
-// Note, dbtx is optional.
-func (s *UserStorage) FindUserByID(ctx context.Context, dbtx *sql.Tx, id string) (*account.User, error) {
-	var row *sql.Row
-	if dbtx == nil {
-		row = s.client.db.QueryRowContext(ctx, "SELECT id, username FROM account WHERE id = $1", id)
-	} else {
-		row = dbtx.QueryRowContext(ctx, "SELECT id, username FROM account WHERE id = $1", id)
+// FindUserByID returns a user by ID or ENotFound error if user does not exist in the tenant.
+// Within a transaction the user row is locked until the transaction ends,
+// otherwise the user might be read from a replica (see Client.reader).
+func (s *UserStorage) FindUserByID(ctx context.Context, id string) (*account.User, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, username, status, COALESCE(email, ''), display_name, email_verified FROM account
+	WHERE tenant_id = $1 AND id = $2`
+	q, inTx := s.client.reader(ctx)
+	if inTx {
+		query += " FOR UPDATE"
 	}
+	row := q.QueryRow(ctx, query, tenantID, id)
 
 	u := account.User{}
-	err := row.Scan(&u.ID, &u.Username)
-	if errors.Is(err, sql.ErrNoRows) {
+	err = row.Scan(&u.ID, &u.Username, &u.Status, &u.Email, &u.DisplayName, &u.EmailVerified)
+	if errors.Is(err, pgx.ErrNoRows) {
 		return nil, account.Error{
 			Code:    account.ENotFound,
 			Message: "User not found.",
@@ -40,30 +46,257 @@ func (s *UserStorage) FindUserByID(ctx context.Context, dbtx *sql.Tx, id string)
 	return &u, err
 }
 
-// UsernameInUse returns true if username is already claimed.
-func (s *UserStorage) UsernameInUse(ctx context.Context, username string) bool {
-	return true
+// FindUserByUsername returns a user by current username or ENotFound error if user does not exist in the tenant.
+func (s *UserStorage) FindUserByUsername(ctx context.Context, username string) (*account.User, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const query = `SELECT id, username, status, COALESCE(email, ''), display_name, email_verified FROM account
+	WHERE tenant_id = $1 AND username = $2`
+	q, _ := s.client.reader(ctx)
+	row := q.QueryRow(ctx, query, tenantID, username)
+
+	u := account.User{}
+	err = row.Scan(&u.ID, &u.Username, &u.Status, &u.Email, &u.DisplayName, &u.EmailVerified)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "User not found.",
+			Inner:   err,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UserStorage.FindUserByUsername: %w", err)
+	}
+	return &u, nil
+}
+
+// UsernameInUse returns true if username is already claimed within the tenant.
+// The primary is queried, since a lagging replica could report a just claimed username as available.
+func (s *UserStorage) UsernameInUse(ctx context.Context, username string) (bool, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var inUse bool
+	q, _ := s.client.querier(ctx)
+	err = q.QueryRow(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM account WHERE tenant_id = $1 AND username = $2)",
+		tenantID, username,
+	).Scan(&inUse)
+	if err != nil {
+		return false, fmt.Errorf("UserStorage.UsernameInUse: %w", err)
+	}
+	return inUse, nil
+}
+
+// EmailInUse returns true if email is already claimed by another user of the tenant regardless of its case.
+// The primary is queried, since a lagging replica could report a just claimed email as available.
+func (s *UserStorage) EmailInUse(ctx context.Context, email string) (bool, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var inUse bool
+	q, _ := s.client.querier(ctx)
+	err = q.QueryRow(
+		ctx,
+		"SELECT EXISTS(SELECT 1 FROM account WHERE tenant_id = $1 AND lower(email) = lower($2))",
+		tenantID, email,
+	).Scan(&inUse)
+	if err != nil {
+		return false, fmt.Errorf("UserStorage.EmailInUse: %w", err)
+	}
+	return inUse, nil
 }
 
-// CreateUser creates a new user in the storage.
+// CreateUser creates a new user in the tenant.
+// It returns EConflict error if the username or email is already in use.
 func (s *UserStorage) CreateUser(ctx context.Context, u *account.User) error {
-	_, err := s.client.db.ExecContext(ctx, "INSERT INTO account (id, username) VALUES ($1, $2)", u.ID, u.Username)
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	q, _ := s.client.querier(ctx)
+	_, err = q.Exec(
+		ctx,
+		`INSERT INTO account (id, tenant_id, username, status, email, display_name, email_verified)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6, $7)`,
+		u.ID, tenantID, u.Username, u.Status, u.Email, u.DisplayName, u.EmailVerified,
+	)
 	if err != nil {
-		return fmt.Errorf("UserStorage.CreateUser: %w", err)
+		return fmt.Errorf("UserStorage.CreateUser: %w", conflictError(err))
 	}
 	return nil
 }
 
 // UpdateUser updates user details within a db transaction.
-func (s *UserStorage) UpdateUser(ctx context.Context, dbtx *sql.Tx, u *account.User) error {
-	_, err := dbtx.ExecContext(ctx, "UPDATE account SET username=$2 WHERE id=$1", u.ID, u.Username)
+// It returns ENotFound error if user does not exist in the tenant or
+// EConflict error if the username or email is already in use.
+func (s *UserStorage) UpdateUser(ctx context.Context, u *account.User) error {
+	tenantID, err := tenantFromContext(ctx)
 	if err != nil {
-		return fmt.Errorf("UserStorage.UpdateUser: %w", err)
+		return err
+	}
+
+	q, _ := s.client.querier(ctx)
+	tag, err := q.Exec(
+		ctx,
+		`UPDATE account SET username=$3, status=$4, email=NULLIF($5, ''), display_name=$6, email_verified=$7
+		WHERE tenant_id=$1 AND id=$2`,
+		tenantID, u.ID, u.Username, u.Status, u.Email, u.DisplayName, u.EmailVerified,
+	)
+	if err != nil {
+		return fmt.Errorf("UserStorage.UpdateUser: %w", conflictError(err))
+	}
+	if tag.RowsAffected() == 0 {
+		return account.Error{
+			Code:    account.ENotFound,
+			Message: "User not found.",
+		}
 	}
 	return nil
 }
 
+// CreateUsernameChange adds a former username of a user to the history.
+func (s *UserStorage) CreateUsernameChange(ctx context.Context, c *account.UsernameChange) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	const query = `INSERT INTO username_history (tenant_id, user_id, username, changed_at) VALUES ($1, $2, $3, $4)`
+	q, _ := s.client.querier(ctx)
+	_, err = q.Exec(ctx, query, tenantID, c.UserID, c.Username, c.ChangedAt)
+	if err != nil {
+		return fmt.Errorf("UserStorage.CreateUsernameChange: %w", err)
+	}
+	return nil
+}
+
+// FindUsernameChange returns the latest change where the username was released or
+// ENotFound error if nobody in the tenant had that username before.
+// The primary is queried, so a just released username is not handed over to someone else
+// before its reservation period ends.
+func (s *UserStorage) FindUsernameChange(ctx context.Context, username string) (*account.UsernameChange, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	const query = `SELECT user_id, username, changed_at FROM username_history
+	WHERE tenant_id = $1 AND username = $2
+	ORDER BY changed_at DESC
+	LIMIT 1`
+	q, _ := s.client.querier(ctx)
+	row := q.QueryRow(ctx, query, tenantID, username)
+
+	var c account.UsernameChange
+	err = row.Scan(&c.UserID, &c.Username, &c.ChangedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "Username change not found.",
+			Inner:   err,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("UserStorage.FindUsernameChange: %w", err)
+	}
+	return &c, nil
+}
+
+// ListUsers returns users of the tenant ordered by username which come after f.After, at most f.Limit of them.
+// The users might be read from a replica (see Client.reader).
+func (s *UserStorage) ListUsers(ctx context.Context, f account.UserFilter) ([]*account.User, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	q, _ := s.client.reader(ctx)
+	rows, err := q.Query(
+		ctx,
+		`SELECT id, username, status, COALESCE(email, ''), display_name, email_verified FROM account
+		WHERE tenant_id = $1 AND username > $2
+		ORDER BY username
+		LIMIT $3`,
+		tenantID, f.After, f.Limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("UserStorage.ListUsers: %w", err)
+	}
+	defer rows.Close()
+
+	var uu []*account.User
+	for rows.Next() {
+		u := account.User{}
+		if err = rows.Scan(&u.ID, &u.Username, &u.Status, &u.Email, &u.DisplayName, &u.EmailVerified); err != nil {
+			return nil, fmt.Errorf("UserStorage.ListUsers: %w", err)
+		}
+		uu = append(uu, &u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("UserStorage.ListUsers: %w", err)
+	}
+	return uu, nil
+}
+
 // Transact relies on Client to implement a Storage interface to keep the Postgres client private.
-func (s *UserStorage) Transact(ctx context.Context, atomic func(*sql.Tx) error) (err error) {
+func (s *UserStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
 	return s.client.Transact(ctx, atomic)
 }
+
+// tenantFromContext returns the tenant ID the queries must be scoped to.
+// It returns EInvalidTenant error when the context has no tenant.
+func tenantFromContext(ctx context.Context) (string, error) {
+	tenantID := account.TenantFromContext(ctx)
+	if tenantID == "" {
+		return "", account.Error{
+			Code:    account.EInvalidTenant,
+			Message: "Tenant ID is required.",
+		}
+	}
+	return tenantID, nil
+}
+
+// conflictError converts unique constraint violations of account and customer_group tables into EConflict domain error.
+// Other errors are returned as is.
+// This guards against concurrent sign-ups that passed UsernameInUse and EmailInUse checks.
+func conflictError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		return err
+	}
+
+	switch pgErr.ConstraintName {
+	case "account_tenant_id_username_key":
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Username is already in use. Please choose a different username.",
+			Field:   "username",
+			Inner:   err,
+		}
+	case "account_email_key":
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Email is already in use. Please choose a different email.",
+			Field:   "email",
+			Inner:   err,
+		}
+	case "customer_group_tenant_id_name_key":
+		return account.Error{
+			Code:    account.EConflict,
+			Message: "Group name is already in use. Please choose a different name.",
+			Field:   "name",
+			Inner:   err,
+		}
+	}
+	return err
+}
@@ -2,40 +2,67 @@ package pg
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"testing"
+	"time"
 
 	account "github.com/marselester/ddd-err"
+	"github.com/marselester/ddd-err/storagetest"
 )
 
-// Ensure UserStorage implements account.UserRepository.
-var _ account.UserRepository = &UserStorage{}
+// Ensure UserStorage implements account.UserImportRepository.
+var _ account.UserImportRepository = &UserStorage{}
+
+func TestUserStorage_conformance(t *testing.T) {
+	storagetest.TestUserRepository(t, func(t *testing.T) account.UserRepository {
+		c := mustOpenClient(t)
+		t.Cleanup(c.close)
+		return c.storageClient.User
+	})
+}
+
+// Ensure GroupStorage implements account.GroupRepository.
+var _ account.GroupRepository = &GroupStorage{}
+
+func TestGroupStorage_conformance(t *testing.T) {
+	storagetest.TestGroupRepository(t, func(t *testing.T) account.GroupRepository {
+		c := mustOpenClient(t)
+		t.Cleanup(c.close)
+		return c.storageClient.Group
+	})
+}
+
+const (
+	testTenantID  = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+	otherTenantID = "5d1c0a57-4d7e-4b4b-9a43-7c2f3f0e6c02"
+)
 
 func TestTransact(t *testing.T) {
-	c := mustOpenClient()
+	c := mustOpenClient(t)
 	defer c.close()
 
-	ctx := context.Background()
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
 	alice := account.User{
 		ID:       "123",
 		Username: "Alice",
+		Status:   account.StatusActive,
 	}
 	c.storageClient.User.CreateUser(ctx, &alice)
 
-	err := c.storageClient.Transact(ctx, func(tx *sql.Tx) error {
-		acc, err := c.storageClient.User.FindUserByID(ctx, tx, alice.ID)
+	err := c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		acc, err := c.storageClient.User.FindUserByID(ctx, alice.ID)
 		if err != nil {
 			return err
 		}
 
 		acc.Username = "Bob"
-		return c.storageClient.User.UpdateUser(ctx, tx, acc)
+		return c.storageClient.User.UpdateUser(ctx, acc)
 	})
 	if err != nil {
 		t.Errorf("Transact() failed: %v", err)
 	}
 
-	bob, err := c.storageClient.User.FindUserByID(ctx, nil, alice.ID)
+	bob, err := c.storageClient.User.FindUserByID(ctx, alice.ID)
 	if err != nil {
 		t.Errorf("Transact() user not found by ID %q: %v", alice.ID, err)
 	}
@@ -43,3 +70,172 @@ func TestTransact(t *testing.T) {
 		t.Errorf("Transact() got username %q, want Bob", bob.Username)
 	}
 }
+
+func TestTransact_savepoint(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	c.storageClient.User.CreateUser(ctx, &alice)
+
+	errRollback := errors.New("rollback")
+	err := c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		err := c.storageClient.Transact(ctx, func(ctx context.Context) error {
+			acc, err := c.storageClient.User.FindUserByID(ctx, alice.ID)
+			if err != nil {
+				return err
+			}
+
+			acc.Username = "Bob"
+			if err = c.storageClient.User.UpdateUser(ctx, acc); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if err != errRollback {
+			t.Errorf("Transact() got %v, want %v", err, errRollback)
+		}
+
+		acc, err := c.storageClient.User.FindUserByID(ctx, alice.ID)
+		if err != nil {
+			return err
+		}
+		acc.DisplayName = "Alice Liddell"
+		return c.storageClient.User.UpdateUser(ctx, acc)
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	acc, err := c.storageClient.User.FindUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("Transact() user not found by ID %q: %v", alice.ID, err)
+	}
+	if acc.Username != "Alice" || acc.DisplayName != "Alice Liddell" {
+		t.Errorf("Transact() got username %q and display name %q, want Alice and Alice Liddell", acc.Username, acc.DisplayName)
+	}
+}
+
+func TestUserStorage_CreateUser_conflict(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	}
+	if err := c.storageClient.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+	if inUse, _ := c.storageClient.User.EmailInUse(ctx, "ALICE@example.com"); !inUse {
+		t.Errorf("EmailInUse() got false, want true")
+	}
+
+	tt := []struct {
+		user      account.User
+		wantField string
+	}{
+		{account.User{ID: "456", Username: "Alice", Status: account.StatusActive}, "username"},
+		{account.User{ID: "456", Username: "Bob", Status: account.StatusActive, Email: "Alice@Example.com"}, "email"},
+	}
+	for _, tc := range tt {
+		err := c.storageClient.User.CreateUser(ctx, &tc.user)
+		var accErr account.Error
+		if !errors.As(err, &accErr) || accErr.Code != account.EConflict || accErr.Field != tc.wantField {
+			t.Errorf("CreateUser(%+v) = %v, want %s conflict", tc.user, err, tc.wantField)
+		}
+	}
+}
+
+func TestUserStorage_tenant(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := c.storageClient.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	_, err := c.storageClient.User.FindUserByID(otherCtx, alice.ID)
+	if code := account.ErrorCode(err); code != account.ENotFound {
+		t.Errorf("FindUserByID() got %q error code from other tenant, want not_found", code)
+	}
+
+	if inUse, _ := c.storageClient.User.UsernameInUse(otherCtx, alice.Username); inUse {
+		t.Errorf("UsernameInUse() got true in other tenant, want false")
+	}
+	bob := account.User{
+		ID:       "456",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err = c.storageClient.User.CreateUser(otherCtx, &bob); err != nil {
+		t.Errorf("CreateUser() failed to reuse username in other tenant: %v", err)
+	}
+
+	_, err = c.storageClient.User.FindUserByID(context.Background(), alice.ID)
+	if code := account.ErrorCode(err); code != account.EInvalidTenant {
+		t.Errorf("FindUserByID() got %q error code without tenant, want invalid_tenant", code)
+	}
+}
+
+func TestUserStorage_UsernameChange(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+	}
+	if err := c.storageClient.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	_, err := c.storageClient.User.FindUsernameChange(ctx, "Alice")
+	if code := account.ErrorCode(err); code != account.ENotFound {
+		t.Errorf("FindUsernameChange() got %q error code, want not_found", code)
+	}
+
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	changes := []account.UsernameChange{
+		{UserID: alice.ID, Username: "Alice", ChangedAt: now.Add(-time.Hour)},
+		{UserID: alice.ID, Username: "Alice", ChangedAt: now},
+	}
+	for i := range changes {
+		if err = c.storageClient.User.CreateUsernameChange(ctx, &changes[i]); err != nil {
+			t.Fatalf("CreateUsernameChange() failed: %v", err)
+		}
+	}
+
+	got, err := c.storageClient.User.FindUsernameChange(ctx, "Alice")
+	if err != nil {
+		t.Fatalf("FindUsernameChange() failed: %v", err)
+	}
+	if !got.ChangedAt.Equal(now) {
+		t.Errorf("FindUsernameChange() got change at %v, want the latest %v", got.ChangedAt, now)
+	}
+
+	u, err := c.storageClient.User.FindUserByUsername(ctx, "Alice")
+	if err != nil {
+		t.Fatalf("FindUserByUsername() failed: %v", err)
+	}
+	if u.ID != alice.ID {
+		t.Errorf("FindUserByUsername() got user %q, want %q", u.ID, alice.ID)
+	}
+}
@@ -0,0 +1,92 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// txOptions configure a transaction started by Client.Transact.
+type txOptions struct {
+	isolation      pgx.TxIsoLevel
+	accessMode     pgx.TxAccessMode
+	deferrableMode pgx.TxDeferrableMode
+	maxRetries     int
+}
+
+// TxOption configures a transaction started by Client.Transact.
+type TxOption func(*txOptions)
+
+// TxIsolation sets the isolation level of a transaction, e.g., pgx.Serializable or pgx.RepeatableRead.
+func TxIsolation(level pgx.TxIsoLevel) TxOption {
+	return func(o *txOptions) {
+		o.isolation = level
+	}
+}
+
+// TxReadOnly makes a transaction read-only.
+func TxReadOnly() TxOption {
+	return func(o *txOptions) {
+		o.accessMode = pgx.ReadOnly
+	}
+}
+
+// TxDeferrable makes a transaction deferrable.
+// It has effect only with serializable read-only transactions:
+// they might wait to start, but then they never fail with a serialization failure.
+func TxDeferrable() TxOption {
+	return func(o *txOptions) {
+		o.deferrableMode = pgx.Deferrable
+	}
+}
+
+// TxMaxRetries sets how many times a transaction is retried after a serialization failure or a deadlock.
+// Zero disables retries.
+func TxMaxRetries(max int) TxOption {
+	return func(o *txOptions) {
+		o.maxRetries = max
+	}
+}
+
+// Postgres error codes of transactions that can succeed if retried.
+const (
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+)
+
+// retryable reports whether err is a serialization failure or a deadlock.
+func retryable(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == pgSerializationFailure || pgErr.Code == pgDeadlockDetected
+}
+
+// backoff returns a delay before the retry attempt (counting from zero).
+// The delay doubles with every attempt, and a random jitter up to the delay itself
+// is added, so the concurrent transactions don't collide again.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := base << uint(attempt)
+	return d + time.Duration(rand.Int63n(int64(d)))
+}
+
+// sleep pauses for d or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,107 @@
+package pg
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	account "github.com/marselester/ddd-err"
+)
+
+func TestRetryable(t *testing.T) {
+	tt := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{fmt.Errorf("boom"), false},
+		{&pgconn.PgError{Code: "23505"}, false},
+		{&pgconn.PgError{Code: pgSerializationFailure}, true},
+		{fmt.Errorf("UserStorage.UpdateUser: %w", &pgconn.PgError{Code: pgDeadlockDetected}), true},
+	}
+	for _, tc := range tt {
+		if got := retryable(tc.err); got != tc.want {
+			t.Errorf("retryable(%v) = %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	for attempt := 0; attempt < 5; attempt++ {
+		min := base << uint(attempt)
+		if got := backoff(base, attempt); got < min || got >= 2*min {
+			t.Errorf("backoff(%v, %d) = %v, want in [%v, %v)", base, attempt, got, min, 2*min)
+		}
+	}
+}
+
+func TestTransact_retry(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	var attempts int
+	err := c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		attempts++
+		if attempts < 3 {
+			return &pgconn.PgError{Code: pgSerializationFailure}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Transact() made %d attempts, want 3", attempts)
+	}
+
+	attempts = 0
+	err = c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		attempts++
+		return &pgconn.PgError{Code: pgDeadlockDetected}
+	}, TxMaxRetries(1))
+	if code := account.ErrorCode(err); code != account.EConcurrentUpdate {
+		t.Errorf("Transact() got %v, want concurrent_update", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Transact() made %d attempts, want 2", attempts)
+	}
+}
+
+func TestTransact_options(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	err := c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		q, _ := c.storageClient.querier(ctx)
+
+		var isolation, readOnly, deferrable string
+		err := q.QueryRow(
+			ctx,
+			"SELECT current_setting('transaction_isolation'), current_setting('transaction_read_only'), current_setting('transaction_deferrable')",
+		).Scan(&isolation, &readOnly, &deferrable)
+		if err != nil {
+			return err
+		}
+		if isolation != "serializable" || readOnly != "on" || deferrable != "on" {
+			t.Errorf("Transact() got isolation %q, read-only %q, deferrable %q", isolation, readOnly, deferrable)
+		}
+		return nil
+	}, TxIsolation(pgx.Serializable), TxReadOnly(), TxDeferrable())
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	err = c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		return c.storageClient.User.CreateUser(ctx, &account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+	}, TxReadOnly())
+	if err == nil {
+		t.Errorf("Transact() created a user in read-only transaction")
+	}
+}
@@ -0,0 +1,99 @@
+package pg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// EmailVerificationStorage represents a Postgres storage of email verification tokens.
+type EmailVerificationStorage struct {
+	client *Client
+}
+
+// CreateEmailVerification creates a new verification token in the tenant.
+func (s *EmailVerificationStorage) CreateEmailVerification(ctx context.Context, v *account.EmailVerification) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	const query = `INSERT INTO email_verification (token_hash, tenant_id, user_id, email, expires_at) VALUES ($1, $2, $3, $4, $5)`
+	q, _ := s.client.querier(ctx)
+	_, err = q.Exec(ctx, query, v.TokenHash, tenantID, v.UserID, v.Email, v.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("EmailVerificationStorage.CreateEmailVerification: %w", err)
+	}
+	return nil
+}
+
+// FindEmailVerification returns a verification by token hash or ENotFound error if it does not exist.
+// Within a transaction the verification row is locked until the transaction ends,
+// so a token can't be used twice concurrently.
+func (s *EmailVerificationStorage) FindEmailVerification(ctx context.Context, tokenHash string) (*account.EmailVerification, error) {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT token_hash, user_id, email, expires_at, used_at FROM email_verification
+	WHERE tenant_id = $1 AND token_hash = $2`
+	q, inTx := s.client.reader(ctx)
+	if inTx {
+		query += " FOR UPDATE"
+	}
+	row := q.QueryRow(ctx, query, tenantID, tokenHash)
+
+	var (
+		v      account.EmailVerification
+		usedAt *time.Time
+	)
+	err = row.Scan(&v.TokenHash, &v.UserID, &v.Email, &v.ExpiresAt, &usedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, account.Error{
+			Code:    account.ENotFound,
+			Message: "Email verification not found.",
+			Inner:   err,
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("EmailVerificationStorage.FindEmailVerification: %w", err)
+	}
+	if usedAt != nil {
+		v.UsedAt = *usedAt
+	}
+	return &v, nil
+}
+
+// UpdateEmailVerification marks a verification used within a db transaction.
+func (s *EmailVerificationStorage) UpdateEmailVerification(ctx context.Context, v *account.EmailVerification) error {
+	tenantID, err := tenantFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var usedAt *time.Time
+	if !v.UsedAt.IsZero() {
+		usedAt = &v.UsedAt
+	}
+	q, _ := s.client.querier(ctx)
+	_, err = q.Exec(
+		ctx,
+		"UPDATE email_verification SET used_at=$3 WHERE tenant_id=$1 AND token_hash=$2",
+		tenantID, v.TokenHash, usedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("EmailVerificationStorage.UpdateEmailVerification: %w", err)
+	}
+	return nil
+}
+
+// Transact relies on Client to implement a Storage interface to keep the Postgres client private.
+func (s *EmailVerificationStorage) Transact(ctx context.Context, atomic func(ctx context.Context) error) (err error) {
+	return s.client.Transact(ctx, atomic)
+}
@@ -0,0 +1,63 @@
+package pg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+)
+
+// Ensure EmailVerificationStorage implements account.EmailVerificationRepository.
+var _ account.EmailVerificationRepository = &EmailVerificationStorage{}
+
+func TestEmailVerificationStorage(t *testing.T) {
+	c := mustOpenClient(t)
+	defer c.close()
+
+	ctx := account.ContextWithTenant(context.Background(), testTenantID)
+	alice := account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	}
+	if err := c.storageClient.User.CreateUser(ctx, &alice); err != nil {
+		t.Fatalf("CreateUser() failed: %v", err)
+	}
+
+	v := account.EmailVerification{
+		TokenHash: "abc",
+		UserID:    alice.ID,
+		Email:     alice.Email,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	if err := c.storageClient.EmailVerification.CreateEmailVerification(ctx, &v); err != nil {
+		t.Fatalf("CreateEmailVerification() failed: %v", err)
+	}
+
+	err := c.storageClient.Transact(ctx, func(ctx context.Context) error {
+		got, err := c.storageClient.EmailVerification.FindEmailVerification(ctx, v.TokenHash)
+		if err != nil {
+			return err
+		}
+		got.UsedAt = time.Now()
+		return c.storageClient.EmailVerification.UpdateEmailVerification(ctx, got)
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	got, err := c.storageClient.EmailVerification.FindEmailVerification(ctx, v.TokenHash)
+	if err != nil {
+		t.Fatalf("FindEmailVerification() failed: %v", err)
+	}
+	if got.UsedAt.IsZero() {
+		t.Errorf("FindEmailVerification() token was not used")
+	}
+
+	_, err = c.storageClient.EmailVerification.FindEmailVerification(ctx, "xyz")
+	if code := account.ErrorCode(err); code != account.ENotFound {
+		t.Errorf("FindEmailVerification() got %q error code, want not_found", code)
+	}
+}
@@ -0,0 +1,74 @@
+package account
+
+import "fmt"
+
+// User account statuses.
+const (
+	// The user can use the system.
+	StatusActive = "active"
+	// The user was temporarily banned, e.g., by support team.
+	StatusSuspended = "suspended"
+	// The account was locked after abuse, e.g., too many failed sign-in attempts.
+	StatusLocked = "locked"
+	// The user has left the system for good, the account can't be restored.
+	StatusDeleted = "deleted"
+)
+
+// Events that change user account status.
+const (
+	EventSuspend    = "suspend"
+	EventReactivate = "reactivate"
+	EventLock       = "lock"
+	EventDelete     = "delete"
+)
+
+// transition describes statuses where an event is allowed and the status it leads to.
+type transition struct {
+	from []string
+	to   string
+}
+
+// transitions is a state machine of user account status.
+var transitions = map[string]transition{
+	EventSuspend: {
+		from: []string{StatusActive},
+		to:   StatusSuspended,
+	},
+	EventReactivate: {
+		from: []string{StatusSuspended, StatusLocked},
+		to:   StatusActive,
+	},
+	EventLock: {
+		from: []string{StatusActive, StatusSuspended},
+		to:   StatusLocked,
+	},
+	EventDelete: {
+		from: []string{StatusActive, StatusSuspended, StatusLocked},
+		to:   StatusDeleted,
+	},
+}
+
+// Active reports whether the user is allowed to use the system.
+func (u *User) Active() bool {
+	return u.Status == StatusActive
+}
+
+// Transition changes the user status according to the event.
+// It returns EInvalidStateTransition if the event is not allowed in the current status.
+func (u *User) Transition(event string) error {
+	t, ok := transitions[event]
+	if !ok {
+		return fmt.Errorf("unknown user status event %q", event)
+	}
+
+	for _, status := range t.from {
+		if u.Status == status {
+			u.Status = t.to
+			return nil
+		}
+	}
+	return Error{
+		Code:    EInvalidStateTransition,
+		Message: fmt.Sprintf("Cannot %s a user account that is %s.", event, u.Status),
+	}
+}
@@ -0,0 +1,38 @@
+package account
+
+import (
+	"testing"
+)
+
+func TestUserTransition(t *testing.T) {
+	tt := []struct {
+		status   string
+		event    string
+		want     string
+		wantCode string
+	}{
+		{StatusActive, EventSuspend, StatusSuspended, ""},
+		{StatusActive, EventLock, StatusLocked, ""},
+		{StatusActive, EventDelete, StatusDeleted, ""},
+		{StatusActive, EventReactivate, StatusActive, EInvalidStateTransition},
+		{StatusSuspended, EventReactivate, StatusActive, ""},
+		{StatusSuspended, EventLock, StatusLocked, ""},
+		{StatusSuspended, EventSuspend, StatusSuspended, EInvalidStateTransition},
+		{StatusLocked, EventReactivate, StatusActive, ""},
+		{StatusLocked, EventSuspend, StatusLocked, EInvalidStateTransition},
+		{StatusLocked, EventDelete, StatusDeleted, ""},
+		{StatusDeleted, EventReactivate, StatusDeleted, EInvalidStateTransition},
+		{StatusDeleted, EventDelete, StatusDeleted, EInvalidStateTransition},
+	}
+
+	for _, tc := range tt {
+		u := User{Status: tc.status}
+		err := u.Transition(tc.event)
+		if code := ErrorCode(err); code != tc.wantCode {
+			t.Errorf("%s %s user: got %q error code, want %q", tc.event, tc.status, code, tc.wantCode)
+		}
+		if u.Status != tc.want {
+			t.Errorf("%s %s user: got %q status, want %q", tc.event, tc.status, u.Status, tc.want)
+		}
+	}
+}
@@ -0,0 +1,916 @@
+// Package storagetest provides a conformance test suite for account repositories.
+// It verifies the behaviour the service relies on, so any implementation
+// (Postgres, in-memory, third-party) can be checked the same way, e.g.,
+//
+//	func TestUserStorage(t *testing.T) {
+//		storagetest.TestUserRepository(t, func(t *testing.T) account.UserRepository {
+//			return inmem.NewClient().User
+//		})
+//	}
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	account "github.com/marselester/ddd-err"
+)
+
+const (
+	testTenantID  = "0b8e5ba0-2b4b-4b79-8a3a-4a1b3e8e1b01"
+	otherTenantID = "5d1c0a57-4d7e-4b4b-9a43-7c2f3f0e6c02"
+)
+
+// UserRepositoryFactory returns an empty user repository for a single test.
+// The factory should release the repository's resources with t.Cleanup.
+type UserRepositoryFactory func(t *testing.T) account.UserRepository
+
+// GroupRepositoryFactory returns an empty group repository for a single test.
+// The factory should release the repository's resources with t.Cleanup.
+type GroupRepositoryFactory func(t *testing.T) account.GroupRepository
+
+// TestUserRepository runs the conformance tests against user repositories returned by newRepo.
+// The bulk import tests are skipped unless the repositories implement account.UserImportRepository.
+func TestUserRepository(t *testing.T, newRepo UserRepositoryFactory) {
+	tt := []struct {
+		name string
+		test func(t *testing.T, repo account.UserRepository)
+	}{
+		{"CreateUser", testCreateUser},
+		{"CreateUser_conflict", testCreateUserConflict},
+		{"FindUser_not_found", testFindUserNotFound},
+		{"UpdateUser", testUpdateUser},
+		{"UpdateUser_conflict", testUpdateUserConflict},
+		{"UsernameChange", testUsernameChange},
+		{"ListUsers", testListUsers},
+		{"tenant", testUserTenant},
+		{"Transact_commit", testTransactCommit},
+		{"Transact_rollback", testTransactRollback},
+		{"Transact_panic", testTransactPanic},
+		{"Transact_savepoint", testTransactSavepoint},
+		{"concurrent_CreateUser", testConcurrentCreateUser},
+		{"concurrent_Transact", testConcurrentTransact},
+		{"ImportUsers", testImportUsers},
+		{"ImportUsers_conflict", testImportUsersConflict},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newRepo(t))
+		})
+	}
+}
+
+// TestGroupRepository runs the conformance tests against group repositories returned by newRepo.
+func TestGroupRepository(t *testing.T, newRepo GroupRepositoryFactory) {
+	tt := []struct {
+		name string
+		test func(t *testing.T, repo account.GroupRepository)
+	}{
+		{"CreateGroup", testCreateGroup},
+		{"CreateGroup_conflict", testCreateGroupConflict},
+		{"UpdateGroup", testUpdateGroup},
+		{"DeleteGroup", testDeleteGroup},
+		{"ListGroups", testListGroups},
+		{"tenant", testGroupTenant},
+		{"Transact_rollback", testGroupTransactRollback},
+		{"Transact_DeleteGroup", testGroupTransactDelete},
+		{"concurrent_CreateGroup", testConcurrentCreateGroup},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.test(t, newRepo(t))
+		})
+	}
+}
+
+func tenantContext() context.Context {
+	return account.ContextWithTenant(context.Background(), testTenantID)
+}
+
+// mustCreateUser creates a user or stops the test.
+func mustCreateUser(t *testing.T, repo account.UserRepository, ctx context.Context, u account.User) *account.User {
+	t.Helper()
+	if err := repo.CreateUser(ctx, &u); err != nil {
+		t.Fatalf("CreateUser(%+v) failed: %v", u, err)
+	}
+	return &u
+}
+
+// mustUsernameInUse tells whether the username is in use or stops the test.
+func mustUsernameInUse(t *testing.T, repo account.UserRepository, ctx context.Context, username string) bool {
+	t.Helper()
+	inUse, err := repo.UsernameInUse(ctx, username)
+	if err != nil {
+		t.Fatalf("UsernameInUse(%q) failed: %v", username, err)
+	}
+	return inUse
+}
+
+// mustEmailInUse tells whether the email is in use or stops the test.
+func mustEmailInUse(t *testing.T, repo account.UserRepository, ctx context.Context, email string) bool {
+	t.Helper()
+	inUse, err := repo.EmailInUse(ctx, email)
+	if err != nil {
+		t.Fatalf("EmailInUse(%q) failed: %v", email, err)
+	}
+	return inUse
+}
+
+// assertUser checks that a user is found by ID and equals to want.
+func assertUser(t *testing.T, repo account.UserRepository, ctx context.Context, want *account.User) {
+	t.Helper()
+	got, err := repo.FindUserByID(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("FindUserByID(%q) failed: %v", want.ID, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindUserByID(%q) = %+v, want %+v", want.ID, got, want)
+	}
+}
+
+// assertError checks that err is a domain error with the given code and field.
+func assertError(t *testing.T, err error, code, field string) {
+	t.Helper()
+	var accErr account.Error
+	if !errors.As(err, &accErr) || accErr.Code != code || accErr.Field != field {
+		t.Errorf("got %v error, want %q error code with %q field", err, code, field)
+	}
+}
+
+func testCreateUser(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{
+		ID:            "123",
+		Username:      "Alice",
+		Status:        account.StatusActive,
+		Email:         "alice@example.com",
+		DisplayName:   "Alice Liddell",
+		EmailVerified: true,
+	})
+	assertUser(t, repo, ctx, alice)
+
+	got, err := repo.FindUserByUsername(ctx, "Alice")
+	if err != nil {
+		t.Fatalf("FindUserByUsername() failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, alice) {
+		t.Errorf("FindUserByUsername() = %+v, want %+v", got, alice)
+	}
+
+	if !mustUsernameInUse(t, repo, ctx, "Alice") {
+		t.Errorf("UsernameInUse(Alice) got false, want true")
+	}
+	if mustUsernameInUse(t, repo, ctx, "Bob") {
+		t.Errorf("UsernameInUse(Bob) got true, want false")
+	}
+	if !mustEmailInUse(t, repo, ctx, "ALICE@example.com") {
+		t.Errorf("EmailInUse(ALICE@example.com) got false, want true")
+	}
+	if mustEmailInUse(t, repo, ctx, "bob@example.com") {
+		t.Errorf("EmailInUse(bob@example.com) got true, want false")
+	}
+}
+
+func testCreateUserConflict(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	mustCreateUser(t, repo, ctx, account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	})
+
+	tt := []struct {
+		user      account.User
+		wantField string
+	}{
+		{account.User{ID: "456", Username: "Alice", Status: account.StatusActive}, "username"},
+		{account.User{ID: "456", Username: "Bob", Status: account.StatusActive, Email: "Alice@Example.com"}, "email"},
+	}
+	for _, tc := range tt {
+		err := repo.CreateUser(ctx, &tc.user)
+		assertError(t, err, account.EConflict, tc.wantField)
+	}
+
+	// Users without email don't conflict with each other.
+	mustCreateUser(t, repo, ctx, account.User{ID: "789", Username: "Bob", Status: account.StatusActive})
+	mustCreateUser(t, repo, ctx, account.User{ID: "790", Username: "Carol", Status: account.StatusActive})
+}
+
+func testFindUserNotFound(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+
+	_, err := repo.FindUserByID(ctx, "123")
+	assertError(t, err, account.ENotFound, "")
+
+	_, err = repo.FindUserByUsername(ctx, "Alice")
+	assertError(t, err, account.ENotFound, "")
+
+	_, err = repo.FindUsernameChange(ctx, "Alice")
+	assertError(t, err, account.ENotFound, "")
+}
+
+func testUpdateUser(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	})
+
+	alice.Username = "Bob"
+	alice.Status = account.StatusSuspended
+	alice.Email = "bob@example.com"
+	alice.DisplayName = "Bob"
+	alice.EmailVerified = true
+	if err := repo.UpdateUser(ctx, alice); err != nil {
+		t.Fatalf("UpdateUser() failed: %v", err)
+	}
+	assertUser(t, repo, ctx, alice)
+
+	if mustUsernameInUse(t, repo, ctx, "Alice") {
+		t.Errorf("UsernameInUse(Alice) got true after rename, want false")
+	}
+	if mustEmailInUse(t, repo, ctx, "alice@example.com") {
+		t.Errorf("EmailInUse(alice@example.com) got true after email change, want false")
+	}
+
+	err := repo.UpdateUser(ctx, &account.User{ID: "456", Username: "Carol", Status: account.StatusActive})
+	assertError(t, err, account.ENotFound, "")
+}
+
+func testUpdateUserConflict(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	mustCreateUser(t, repo, ctx, account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	})
+	bob := mustCreateUser(t, repo, ctx, account.User{
+		ID:       "456",
+		Username: "Bob",
+		Status:   account.StatusActive,
+		Email:    "bob@example.com",
+	})
+
+	u := *bob
+	u.Username = "Alice"
+	err := repo.UpdateUser(ctx, &u)
+	assertError(t, err, account.EConflict, "username")
+
+	u = *bob
+	u.Email = "ALICE@example.com"
+	err = repo.UpdateUser(ctx, &u)
+	assertError(t, err, account.EConflict, "email")
+
+	assertUser(t, repo, ctx, bob)
+}
+
+func testUsernameChange(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+	bob := mustCreateUser(t, repo, ctx, account.User{ID: "456", Username: "Bob", Status: account.StatusActive})
+
+	// Timestamps are truncated since storages might have lower precision.
+	changedAt := time.Now().UTC().Truncate(time.Second)
+	changes := []account.UsernameChange{
+		{UserID: alice.ID, Username: "Carol", ChangedAt: changedAt.Add(-time.Hour)},
+		{UserID: bob.ID, Username: "Carol", ChangedAt: changedAt},
+		{UserID: alice.ID, Username: "Dave", ChangedAt: changedAt.Add(time.Hour)},
+	}
+	for i := range changes {
+		if err := repo.CreateUsernameChange(ctx, &changes[i]); err != nil {
+			t.Fatalf("CreateUsernameChange(%+v) failed: %v", changes[i], err)
+		}
+	}
+
+	got, err := repo.FindUsernameChange(ctx, "Carol")
+	if err != nil {
+		t.Fatalf("FindUsernameChange() failed: %v", err)
+	}
+	if got.UserID != bob.ID || got.Username != "Carol" || !got.ChangedAt.Equal(changedAt) {
+		t.Errorf("FindUsernameChange() = %+v, want %+v", got, changes[1])
+	}
+}
+
+func testUserTenant(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	alice := mustCreateUser(t, repo, ctx, account.User{
+		ID:       "123",
+		Username: "Alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	})
+
+	_, err := repo.FindUserByID(otherCtx, alice.ID)
+	assertError(t, err, account.ENotFound, "")
+	_, err = repo.FindUserByUsername(otherCtx, alice.Username)
+	assertError(t, err, account.ENotFound, "")
+	err = repo.UpdateUser(otherCtx, &account.User{ID: alice.ID, Username: "Mallory", Status: account.StatusActive})
+	assertError(t, err, account.ENotFound, "")
+	assertUser(t, repo, ctx, alice)
+
+	if mustUsernameInUse(t, repo, otherCtx, alice.Username) {
+		t.Errorf("UsernameInUse() got true in other tenant, want false")
+	}
+	if mustEmailInUse(t, repo, otherCtx, alice.Email) {
+		t.Errorf("EmailInUse() got true in other tenant, want false")
+	}
+	mustCreateUser(t, repo, otherCtx, account.User{
+		ID:       "456",
+		Username: alice.Username,
+		Status:   account.StatusActive,
+		Email:    alice.Email,
+	})
+
+	noTenantCtx := context.Background()
+	_, err = repo.FindUserByID(noTenantCtx, alice.ID)
+	assertError(t, err, account.EInvalidTenant, "")
+	_, err = repo.FindUserByUsername(noTenantCtx, alice.Username)
+	assertError(t, err, account.EInvalidTenant, "")
+	err = repo.CreateUser(noTenantCtx, &account.User{ID: "789", Username: "Bob", Status: account.StatusActive})
+	assertError(t, err, account.EInvalidTenant, "")
+	err = repo.UpdateUser(noTenantCtx, alice)
+	assertError(t, err, account.EInvalidTenant, "")
+	_, err = repo.FindUsernameChange(noTenantCtx, alice.Username)
+	assertError(t, err, account.EInvalidTenant, "")
+}
+
+func testTransactCommit(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+
+	var bob account.User
+	err := repo.Transact(ctx, func(ctx context.Context) error {
+		u, err := repo.FindUserByID(ctx, alice.ID)
+		if err != nil {
+			return err
+		}
+		u.Username = "Bob"
+		if err = repo.UpdateUser(ctx, u); err != nil {
+			return err
+		}
+		bob = account.User{ID: "456", Username: "Alice", Status: account.StatusActive}
+		return repo.CreateUser(ctx, &bob)
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	alice.Username = "Bob"
+	assertUser(t, repo, ctx, alice)
+	assertUser(t, repo, ctx, &bob)
+}
+
+func testTransactRollback(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+
+	errRollback := errors.New("rollback")
+	err := repo.Transact(ctx, func(ctx context.Context) error {
+		u := *alice
+		u.Username = "Bob"
+		if err := repo.UpdateUser(ctx, &u); err != nil {
+			return err
+		}
+		if err := repo.CreateUser(ctx, &account.User{ID: "456", Username: "Carol", Status: account.StatusActive}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Transact() got %v, want %v", err, errRollback)
+	}
+
+	assertUser(t, repo, ctx, alice)
+	_, err = repo.FindUserByID(ctx, "456")
+	assertError(t, err, account.ENotFound, "")
+}
+
+func testTransactPanic(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+
+	p := func() (p interface{}) {
+		defer func() {
+			p = recover()
+		}()
+		repo.Transact(ctx, func(ctx context.Context) error {
+			u := *alice
+			u.Username = "Bob"
+			if err := repo.UpdateUser(ctx, &u); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+		return nil
+	}()
+	if p != "boom" {
+		t.Fatalf("Transact() got %v panic, want boom", p)
+	}
+
+	assertUser(t, repo, ctx, alice)
+	// The storage must remain usable after the panic.
+	mustCreateUser(t, repo, ctx, account.User{ID: "456", Username: "Bob", Status: account.StatusActive})
+}
+
+func testTransactSavepoint(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+
+	errRollback := errors.New("rollback")
+	err := repo.Transact(ctx, func(ctx context.Context) error {
+		err := repo.Transact(ctx, func(ctx context.Context) error {
+			u := *alice
+			u.Username = "Bob"
+			if err := repo.UpdateUser(ctx, &u); err != nil {
+				return err
+			}
+			return errRollback
+		})
+		if !errors.Is(err, errRollback) {
+			t.Errorf("Transact() got %v, want %v", err, errRollback)
+		}
+
+		return repo.Transact(ctx, func(ctx context.Context) error {
+			u := *alice
+			u.DisplayName = "Alice Liddell"
+			return repo.UpdateUser(ctx, &u)
+		})
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+
+	alice.DisplayName = "Alice Liddell"
+	assertUser(t, repo, ctx, alice)
+}
+
+func testConcurrentCreateUser(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+
+	const n = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			u := account.User{ID: fmt.Sprint(i), Username: "Alice", Status: account.StatusActive}
+			err := repo.CreateUser(ctx, &u)
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+				return
+			}
+			if code := account.ErrorCode(err); code != account.EConflict {
+				t.Errorf("CreateUser() got %v, want conflict", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("CreateUser() created %d users with the same username, want 1", created)
+	}
+}
+
+func testConcurrentTransact(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	alice := mustCreateUser(t, repo, ctx, account.User{ID: "123", Username: "Alice", Status: account.StatusActive})
+
+	// Every transaction appends a character to the display name,
+	// so a lost update shows up as a shorter name.
+	const n = 10
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := repo.Transact(ctx, func(ctx context.Context) error {
+				u, err := repo.FindUserByID(ctx, alice.ID)
+				if err != nil {
+					return err
+				}
+				u.DisplayName += "a"
+				return repo.UpdateUser(ctx, u)
+			})
+			if err != nil {
+				t.Errorf("Transact() failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	u, err := repo.FindUserByID(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("FindUserByID() failed: %v", err)
+	}
+	if len(u.DisplayName) != n {
+		t.Errorf("Transact() lost updates, got display name %q, want %d characters", u.DisplayName, n)
+	}
+}
+
+// importRepo returns the repository as account.UserImportRepository or skips the test.
+func importRepo(t *testing.T, repo account.UserRepository) account.UserImportRepository {
+	t.Helper()
+	r, ok := repo.(account.UserImportRepository)
+	if !ok {
+		t.Skip("repository doesn't support bulk import")
+	}
+	return r
+}
+
+func testImportUsers(t *testing.T, repo account.UserRepository) {
+	r := importRepo(t, repo)
+	ctx := tenantContext()
+
+	users := make([]*account.User, 100)
+	for i := range users {
+		users[i] = &account.User{
+			ID:       fmt.Sprintf("import-%d", i),
+			Username: fmt.Sprintf("user%d", i),
+			Status:   account.StatusActive,
+		}
+	}
+	users[0].Email = "alice@example.com"
+	users[0].DisplayName = "Alice"
+	users[0].EmailVerified = true
+
+	if err := r.ImportUsers(ctx, users, time.Now()); err != nil {
+		t.Fatalf("ImportUsers() failed: %v", err)
+	}
+	for _, u := range users {
+		assertUser(t, repo, ctx, u)
+	}
+
+	if err := r.ImportUsers(ctx, nil, time.Now()); err != nil {
+		t.Errorf("ImportUsers(nil) failed: %v", err)
+	}
+
+	err := r.ImportUsers(context.Background(), users, time.Now())
+	assertError(t, err, account.EInvalidTenant, "")
+}
+
+func testImportUsersConflict(t *testing.T, repo account.UserRepository) {
+	r := importRepo(t, repo)
+	ctx := tenantContext()
+	mustCreateUser(t, repo, ctx, account.User{
+		ID:       "123",
+		Username: "alice",
+		Status:   account.StatusActive,
+		Email:    "alice@example.com",
+	})
+	// Alice has released frank username recently, and grace a long time ago.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	changes := []account.UsernameChange{
+		{UserID: "123", Username: "frank", ChangedAt: now.Add(-time.Hour)},
+		{UserID: "123", Username: "grace", ChangedAt: now.Add(-48 * time.Hour)},
+	}
+	for i := range changes {
+		if err := repo.CreateUsernameChange(ctx, &changes[i]); err != nil {
+			t.Fatalf("CreateUsernameChange(%+v) failed: %v", changes[i], err)
+		}
+	}
+
+	users := []*account.User{
+		{ID: "1", Username: "bob", Status: account.StatusActive, Email: "bob@example.com"},
+		{ID: "2", Username: "alice", Status: account.StatusActive},
+		{ID: "3", Username: "carol", Status: account.StatusActive, Email: "ALICE@example.com"},
+		{ID: "4", Username: "bob", Status: account.StatusActive},
+		{ID: "5", Username: "dave", Status: account.StatusActive, Email: "Bob@example.com"},
+		{ID: "6", Username: "eve", Status: account.StatusActive},
+		{ID: "7", Username: "frank", Status: account.StatusActive},
+		{ID: "8", Username: "grace", Status: account.StatusActive},
+	}
+	err := r.ImportUsers(ctx, users, now.Add(-24*time.Hour))
+
+	var importErr *account.ImportError
+	if !errors.As(err, &importErr) {
+		t.Fatalf("ImportUsers() expected import error, got %v", err)
+	}
+	want := []struct {
+		row   int
+		code  string
+		field string
+	}{
+		{2, account.EConflict, "username"},
+		{3, account.EConflict, "email"},
+		{4, account.EConflict, "username"},
+		{5, account.EConflict, "email"},
+		{7, account.EUsernameReserved, "username"},
+	}
+	if len(importErr.Rows) != len(want) {
+		t.Fatalf("ImportUsers() got %d rejected rows, want %d: %v", len(importErr.Rows), len(want), importErr)
+	}
+	for i, w := range want {
+		got := importErr.Rows[i]
+		if got.Row != w.row {
+			t.Errorf("ImportUsers() got rejected row %d, want %d", got.Row, w.row)
+		}
+		assertError(t, got.Err, w.code, w.field)
+	}
+
+	// Nothing is imported if any user is rejected.
+	for _, u := range []*account.User{users[0], users[5]} {
+		if _, err = repo.FindUserByID(ctx, u.ID); account.ErrorCode(err) != account.ENotFound {
+			t.Errorf("FindUserByID(%q) expected not found error, got %v", u.ID, err)
+		}
+	}
+}
+
+func testListUsers(t *testing.T, repo account.UserRepository) {
+	ctx := tenantContext()
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	for i, username := range []string{"dave", "bob", "carol", "alice"} {
+		mustCreateUser(t, repo, ctx, account.User{ID: fmt.Sprint(i + 1), Username: username, Status: account.StatusActive})
+	}
+	mustCreateUser(t, repo, otherCtx, account.User{ID: "5", Username: "bill", Status: account.StatusActive})
+
+	tt := []struct {
+		filter account.UserFilter
+		want   []string
+	}{
+		{account.UserFilter{Limit: 10}, []string{"alice", "bob", "carol", "dave"}},
+		{account.UserFilter{Limit: 2}, []string{"alice", "bob"}},
+		{account.UserFilter{After: "bob", Limit: 2}, []string{"carol", "dave"}},
+		{account.UserFilter{After: "dave", Limit: 2}, nil},
+	}
+	for _, tc := range tt {
+		uu, err := repo.ListUsers(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("ListUsers(%+v) failed: %v", tc.filter, err)
+		}
+		var got []string
+		for _, u := range uu {
+			got = append(got, u.Username)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ListUsers(%+v) = %q, want %q", tc.filter, got, tc.want)
+		}
+	}
+
+	_, err := repo.ListUsers(context.Background(), account.UserFilter{Limit: 10})
+	assertError(t, err, account.EInvalidTenant, "")
+}
+
+// assertGroup checks that a group is found by ID and equals to want.
+func assertGroup(t *testing.T, repo account.GroupRepository, ctx context.Context, want *account.Group) {
+	t.Helper()
+	got, err := repo.FindGroupByID(ctx, want.ID)
+	if err != nil {
+		t.Fatalf("FindGroupByID(%q) failed: %v", want.ID, err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindGroupByID(%q) = %+v, want %+v", want.ID, got, want)
+	}
+}
+
+func testCreateGroup(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	admins := account.Group{ID: "1", Name: "admins"}
+	if err := repo.CreateGroup(ctx, &admins); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+	assertGroup(t, repo, ctx, &admins)
+
+	_, err := repo.FindGroupByID(ctx, "2")
+	assertError(t, err, account.ENotFound, "")
+}
+
+func testCreateGroupConflict(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	if err := repo.CreateGroup(ctx, &account.Group{ID: "1", Name: "admins"}); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+
+	err := repo.CreateGroup(ctx, &account.Group{ID: "2", Name: "admins"})
+	assertError(t, err, account.EConflict, "name")
+
+	if err = repo.CreateGroup(ctx, &account.Group{ID: "2", Name: "editors"}); err != nil {
+		t.Errorf("CreateGroup() failed: %v", err)
+	}
+}
+
+func testUpdateGroup(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	admins := account.Group{ID: "1", Name: "admins"}
+	if err := repo.CreateGroup(ctx, &admins); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+	if err := repo.CreateGroup(ctx, &account.Group{ID: "2", Name: "editors"}); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+
+	admins.Name = "owners"
+	if err := repo.UpdateGroup(ctx, &admins); err != nil {
+		t.Fatalf("UpdateGroup() failed: %v", err)
+	}
+	assertGroup(t, repo, ctx, &admins)
+
+	err := repo.UpdateGroup(ctx, &account.Group{ID: "1", Name: "editors"})
+	assertError(t, err, account.EConflict, "name")
+	err = repo.UpdateGroup(ctx, &account.Group{ID: "3", Name: "viewers"})
+	assertError(t, err, account.ENotFound, "")
+
+	// The former name is released.
+	if err = repo.CreateGroup(ctx, &account.Group{ID: "3", Name: "admins"}); err != nil {
+		t.Errorf("CreateGroup() failed: %v", err)
+	}
+}
+
+func testDeleteGroup(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	if err := repo.CreateGroup(ctx, &account.Group{ID: "1", Name: "admins"}); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+
+	if err := repo.DeleteGroup(ctx, "1"); err != nil {
+		t.Fatalf("DeleteGroup() failed: %v", err)
+	}
+	_, err := repo.FindGroupByID(ctx, "1")
+	assertError(t, err, account.ENotFound, "")
+	err = repo.DeleteGroup(ctx, "1")
+	assertError(t, err, account.ENotFound, "")
+
+	// The name is released.
+	if err = repo.CreateGroup(ctx, &account.Group{ID: "2", Name: "admins"}); err != nil {
+		t.Errorf("CreateGroup() failed: %v", err)
+	}
+}
+
+func testListGroups(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	for i, name := range []string{"viewers", "admins", "editors"} {
+		if err := repo.CreateGroup(ctx, &account.Group{ID: fmt.Sprint(i + 1), Name: name}); err != nil {
+			t.Fatalf("CreateGroup() failed: %v", err)
+		}
+	}
+	if err := repo.CreateGroup(otherCtx, &account.Group{ID: "4", Name: "authors"}); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+
+	tt := []struct {
+		filter account.GroupFilter
+		want   []string
+	}{
+		{account.GroupFilter{Limit: 10}, []string{"admins", "editors", "viewers"}},
+		{account.GroupFilter{Limit: 2}, []string{"admins", "editors"}},
+		{account.GroupFilter{After: "editors", Limit: 2}, []string{"viewers"}},
+		{account.GroupFilter{After: "viewers", Limit: 2}, nil},
+	}
+	for _, tc := range tt {
+		gg, err := repo.ListGroups(ctx, tc.filter)
+		if err != nil {
+			t.Fatalf("ListGroups(%+v) failed: %v", tc.filter, err)
+		}
+		var got []string
+		for _, g := range gg {
+			got = append(got, g.Name)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("ListGroups(%+v) = %q, want %q", tc.filter, got, tc.want)
+		}
+	}
+}
+
+func testGroupTenant(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	otherCtx := account.ContextWithTenant(context.Background(), otherTenantID)
+	if err := repo.CreateGroup(ctx, &account.Group{ID: "1", Name: "admins"}); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+	if err := repo.CreateGroup(otherCtx, &account.Group{ID: "2", Name: "admins"}); err != nil {
+		t.Errorf("CreateGroup() failed to reuse name in other tenant: %v", err)
+	}
+
+	_, err := repo.FindGroupByID(otherCtx, "1")
+	assertError(t, err, account.ENotFound, "")
+	err = repo.UpdateGroup(otherCtx, &account.Group{ID: "1", Name: "editors"})
+	assertError(t, err, account.ENotFound, "")
+	err = repo.DeleteGroup(otherCtx, "1")
+	assertError(t, err, account.ENotFound, "")
+
+	err = repo.CreateGroup(context.Background(), &account.Group{ID: "3", Name: "editors"})
+	assertError(t, err, account.EInvalidTenant, "")
+}
+
+func testGroupTransactRollback(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+
+	errRollback := errors.New("rollback")
+	err := repo.Transact(ctx, func(ctx context.Context) error {
+		if err := repo.CreateGroup(ctx, &account.Group{ID: "1", Name: "admins"}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Transact() got %v, want %v", err, errRollback)
+	}
+
+	p := func() (p interface{}) {
+		defer func() {
+			p = recover()
+		}()
+		repo.Transact(ctx, func(ctx context.Context) error {
+			if err := repo.CreateGroup(ctx, &account.Group{ID: "2", Name: "admins"}); err != nil {
+				return err
+			}
+			panic("boom")
+		})
+		return nil
+	}()
+	if p != "boom" {
+		t.Fatalf("Transact() got %v panic, want boom", p)
+	}
+
+	// The name is free since both transactions were rolled back.
+	if err = repo.CreateGroup(ctx, &account.Group{ID: "3", Name: "admins"}); err != nil {
+		t.Errorf("CreateGroup() failed: %v", err)
+	}
+}
+
+func testGroupTransactDelete(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+	admins := account.Group{ID: "1", Name: "admins"}
+	if err := repo.CreateGroup(ctx, &admins); err != nil {
+		t.Fatalf("CreateGroup() failed: %v", err)
+	}
+
+	errRollback := errors.New("rollback")
+	err := repo.Transact(ctx, func(ctx context.Context) error {
+		if err := repo.DeleteGroup(ctx, admins.ID); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("Transact() got %v, want %v", err, errRollback)
+	}
+	assertGroup(t, repo, ctx, &admins)
+
+	// The name of the deleted group can be claimed within the same transaction.
+	err = repo.Transact(ctx, func(ctx context.Context) error {
+		if err := repo.DeleteGroup(ctx, admins.ID); err != nil {
+			return err
+		}
+		return repo.CreateGroup(ctx, &account.Group{ID: "2", Name: "admins"})
+	})
+	if err != nil {
+		t.Fatalf("Transact() failed: %v", err)
+	}
+	_, err = repo.FindGroupByID(ctx, admins.ID)
+	assertError(t, err, account.ENotFound, "")
+	assertGroup(t, repo, ctx, &account.Group{ID: "2", Name: "admins"})
+}
+
+func testConcurrentCreateGroup(t *testing.T, repo account.GroupRepository) {
+	ctx := tenantContext()
+
+	const n = 20
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created int
+	)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			err := repo.Transact(ctx, func(ctx context.Context) error {
+				return repo.CreateGroup(ctx, &account.Group{ID: fmt.Sprint(i), Name: "admins"})
+			})
+			if err == nil {
+				mu.Lock()
+				created++
+				mu.Unlock()
+				return
+			}
+			if code := account.ErrorCode(err); code != account.EConflict {
+				t.Errorf("CreateGroup() got %v, want conflict", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if created != 1 {
+		t.Errorf("CreateGroup() created %d groups with the same name, want 1", created)
+	}
+}